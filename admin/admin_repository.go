@@ -0,0 +1,241 @@
+package admin
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"userland/appcontext"
+	"userland/scim"
+
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	MANAGED_USER_COLUMNS = "id, fullname, email, COALESCE(location, '') AS location, COALESCE(bio, '') AS bio, COALESCE(web, '') AS web, " +
+		"COALESCE(verified, false) AS verified, password_reset_required, " +
+		"EXISTS (SELECT 1 FROM webauthn_credential WHERE webauthn_credential.user_id=\"user\".id) AS tfa_enabled, " +
+		"ARRAY (SELECT role.name FROM user_role JOIN role ON role.id=user_role.role_id WHERE user_role.user_id=\"user\".id ORDER BY role.name) AS roles, " +
+		"CASE WHEN suspended_until IS NULL OR suspended_until > now() THEN COALESCE(suspension, '') ELSE '' END AS suspension, " +
+		"COALESCE(suspension_reason, '') AS suspension_reason, suspended_until, created_at"
+
+	SELECT_MANAGED_USERS_QUERY       = "SELECT " + MANAGED_USER_COLUMNS + " FROM \"user\"%s ORDER BY id LIMIT $%d OFFSET $%d"
+	COUNT_MANAGED_USERS_QUERY        = "SELECT count(*) FROM \"user\"%s"
+	SELECT_MANAGED_USER_BY_ID_QUERY  = "SELECT " + MANAGED_USER_COLUMNS + " FROM \"user\" WHERE id=$1 AND tenant_id=$2"
+	CREATE_MANAGED_USER_QUERY        = "INSERT INTO \"user\" (tenant_id, fullname, email, password, verified) VALUES ($1, $2, $3, $4, $5) RETURNING id"
+	UPDATE_MANAGED_USER_QUERY        = "UPDATE \"user\" SET fullname=$1, email=$2, location=NULLIF($3, ''), bio=NULLIF($4, ''), web=NULLIF($5, ''), profile_version=profile_version+1, token_version=token_version + CASE WHEN email<>$2 THEN 1 ELSE 0 END WHERE id=$6 AND tenant_id=$7"
+	VERIFY_MANAGED_USER_QUERY        = "UPDATE \"user\" SET verified=true, verification_token=NULL WHERE id=$1 AND tenant_id=$2"
+	UPDATE_MANAGED_RESET_TOKEN_QUERY = "UPDATE \"user\" SET reset_password_token=$1 WHERE id=$2 AND tenant_id=$3"
+	REVOKE_MANAGED_SESSIONS_QUERY    = "UPDATE \"user\" SET token_version=token_version+1 WHERE id=$1 AND tenant_id=$2"
+	DELETE_MANAGED_CREDENTIALS_QUERY = "DELETE FROM webauthn_credential WHERE user_id=$1 AND EXISTS (SELECT 1 FROM \"user\" WHERE id=$1 AND tenant_id=$2)"
+	DELETE_MANAGED_USER_QUERY        = "DELETE FROM \"user\" WHERE id=$1 AND tenant_id=$2 RETURNING COALESCE(picture_key, '')"
+	SUSPEND_MANAGED_USER_QUERY       = "UPDATE \"user\" SET suspension=$1, suspension_reason=$2, suspended_by=$3, suspended_at=now(), suspended_until=$4 WHERE id=$5 AND tenant_id=$6"
+	CREATE_REGISTRATION_INVITE_QUERY = "INSERT INTO registration_invitation (tenant_id, code, email, created_by, expires_at) VALUES ($1, $2, NULLIF(lower($3), ''), $4, now() + $5 * interval '1 day') RETURNING id, code, COALESCE(email, '') AS email, created_at, expires_at"
+	UNSUSPEND_MANAGED_USER_QUERY     = "UPDATE \"user\" SET suspension=NULL, suspension_reason=NULL, suspended_by=NULL, suspended_at=NULL, suspended_until=NULL WHERE id=$1 AND tenant_id=$2"
+	CREATE_SCIM_TOKEN_QUERY          = "INSERT INTO scim_token (tenant_id, token_hash, description, created_by) VALUES ($1, $2, $3, $4) RETURNING id, description, created_at"
+	DELETE_SCIM_TOKEN_QUERY          = "DELETE FROM scim_token WHERE tenant_id=$1 AND id=$2"
+
+	SECURE_TOKEN_BYTES = 16
+)
+
+type adminRepositoryInterface interface {
+	getUsers(tenantId int, filter userFilter) ([]ManagedUser, int, error)
+	getUser(tenantId int, id int) (*ManagedUser, error)
+	createUser(tenantId int, req createUserRequest) (*ManagedUser, error)
+	updateUser(tenantId int, id int, req updateUserRequest) error
+	verifyUser(tenantId int, id int) error
+	createPasswordResetToken(tenantId int, id int) (string, error)
+	revokeSessions(tenantId int, id int) error
+	disableTFA(tenantId int, id int) error
+	deleteUser(tenantId int, id int) (string, error)
+	suspendUser(tenantId int, id int, adminId int, req suspendUserRequest) error
+	unsuspendUser(tenantId int, id int) error
+	createRegistrationInvitation(tenantId int, createdBy int, req registrationInvitationRequest) (*RegistrationInvitation, error)
+	createScimToken(tenantId int, createdBy int, req scimTokenRequest) (*ScimToken, error)
+	deleteScimToken(tenantId int, id int) error
+}
+
+type adminRepository struct {
+	db *sqlx.DB
+}
+
+func GetAdminRepository() *adminRepository {
+	repo := adminRepository{appcontext.GetDB()}
+	return &repo
+}
+
+func (repo *adminRepository) getUsers(tenantId int, filter userFilter) ([]ManagedUser, int, error) {
+	where, args := filter.conditions(tenantId)
+
+	var total int
+	err := repo.db.Get(&total, fmt.Sprintf(COUNT_MANAGED_USERS_QUERY, where), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	users := []ManagedUser{}
+	query := fmt.Sprintf(SELECT_MANAGED_USERS_QUERY, where, len(args)+1, len(args)+2)
+	err = repo.db.Select(&users, query, append(args, filter.PerPage, filter.offset())...)
+	return users, total, err
+}
+
+func (repo *adminRepository) getUser(tenantId int, id int) (*ManagedUser, error) {
+	var user ManagedUser
+	err := repo.db.Get(&user, SELECT_MANAGED_USER_BY_ID_QUERY, id, tenantId)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (repo *adminRepository) createUser(tenantId int, req createUserRequest) (*ManagedUser, error) {
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.MinCost)
+	if err != nil {
+		return nil, err
+	}
+
+	var id int
+	err = repo.db.Get(&id, CREATE_MANAGED_USER_QUERY, tenantId, req.Fullname, req.Email, string(passwordHash), req.Verified)
+	if err != nil {
+		return nil, err
+	}
+	return repo.getUser(tenantId, id)
+}
+
+// updateUser overwrites the account's profile. Changing the email address
+// revokes the user's sessions, just like when users change it themselves.
+func (repo *adminRepository) updateUser(tenantId int, id int, req updateUserRequest) error {
+	return repo.execForUser(UPDATE_MANAGED_USER_QUERY, req.Fullname, req.Email, req.Location, req.Bio, req.Web, id, tenantId)
+}
+
+func (repo *adminRepository) verifyUser(tenantId int, id int) error {
+	return repo.execForUser(VERIFY_MANAGED_USER_QUERY, id, tenantId)
+}
+
+func (repo *adminRepository) createPasswordResetToken(tenantId int, id int) (string, error) {
+	token, err := generateSecureToken()
+	if err != nil {
+		return "", err
+	}
+	return token, repo.execForUser(UPDATE_MANAGED_RESET_TOKEN_QUERY, token, id, tenantId)
+}
+
+func (repo *adminRepository) revokeSessions(tenantId int, id int) error {
+	return repo.execForUser(REVOKE_MANAGED_SESSIONS_QUERY, id, tenantId)
+}
+
+func (repo *adminRepository) disableTFA(tenantId int, id int) error {
+	_, err := repo.getUser(tenantId, id)
+	if err != nil {
+		return err
+	}
+	_, err = repo.db.Exec(DELETE_MANAGED_CREDENTIALS_QUERY, id, tenantId)
+	return err
+}
+
+// deleteUser removes the account right away, returning the key of its picture
+// so that the blob can go too, or an empty one when it had none.
+func (repo *adminRepository) deleteUser(tenantId int, id int) (string, error) {
+	var pictureKey string
+	err := repo.db.Get(&pictureKey, DELETE_MANAGED_USER_QUERY, id, tenantId)
+	return pictureKey, err
+}
+
+// suspendUser locks the account until the request's expiry, or for good when
+// it has none. A lapsed suspension stays on the row but no longer applies.
+func (repo *adminRepository) suspendUser(tenantId int, id int, adminId int, req suspendUserRequest) error {
+	return repo.execForUser(SUSPEND_MANAGED_USER_QUERY, req.kind(), req.Reason, adminId, req.Until, id, tenantId)
+}
+
+func (repo *adminRepository) unsuspendUser(tenantId int, id int) error {
+	return repo.execForUser(UNSUSPEND_MANAGED_USER_QUERY, id, tenantId)
+}
+
+func (repo *adminRepository) createRegistrationInvitation(tenantId int, createdBy int, req registrationInvitationRequest) (*RegistrationInvitation, error) {
+	code, err := generateSecureToken()
+	if err != nil {
+		return nil, err
+	}
+
+	var invitation RegistrationInvitation
+	err = repo.db.Get(&invitation, CREATE_REGISTRATION_INVITE_QUERY, tenantId, code, req.Email, createdBy, req.ExpiresInDays)
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// createScimToken issues a SCIM token for the tenant. Only its hash is kept.
+func (repo *adminRepository) createScimToken(tenantId int, createdBy int, req scimTokenRequest) (*ScimToken, error) {
+	token, err := generateSecureToken()
+	if err != nil {
+		return nil, err
+	}
+
+	var scimToken ScimToken
+	err = repo.db.Get(&scimToken, CREATE_SCIM_TOKEN_QUERY, tenantId, scim.HashToken(token), req.Description, createdBy)
+	if err != nil {
+		return nil, err
+	}
+	scimToken.Token = token
+	return &scimToken, nil
+}
+
+func (repo *adminRepository) deleteScimToken(tenantId int, id int) error {
+	return repo.execForUser(DELETE_SCIM_TOKEN_QUERY, tenantId, id)
+}
+
+// execForUser runs a statement on a single user, returning sql.ErrNoRows
+// when the user doesn't exist in the admin's tenant.
+func (repo *adminRepository) execForUser(query string, args ...interface{}) error {
+	stmt, err := repo.db.Preparex(query)
+	if err != nil {
+		return err
+	}
+	result, err := stmt.Exec(args...)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err == nil && affected == 0 {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// conditions builds the WHERE clause of the user list and its arguments,
+// which only ever lists users of the admin's tenant.
+func (filter userFilter) conditions(tenantId int) (string, []interface{}) {
+	conditions := []string{"tenant_id=$1"}
+	args := []interface{}{tenantId}
+
+	if filter.Query != "" {
+		args = append(args, "%"+appcontext.EscapeLikePattern(filter.Query)+"%")
+		conditions = append(conditions, fmt.Sprintf("(email ILIKE $%d OR fullname ILIKE $%d)", len(args), len(args)))
+	}
+	if filter.Verified != nil {
+		args = append(args, *filter.Verified)
+		conditions = append(conditions, fmt.Sprintf("COALESCE(verified, false)=$%d", len(args)))
+	}
+	if filter.Suspended != nil {
+		conditions = append(conditions, fmt.Sprintf("(suspension IS NOT NULL AND (suspended_until IS NULL OR suspended_until > now()))=$%d", len(args)+1))
+		args = append(args, *filter.Suspended)
+	}
+	if filter.Role != "" {
+		args = append(args, filter.Role)
+		conditions = append(conditions, fmt.Sprintf("EXISTS (SELECT 1 FROM user_role JOIN role ON role.id=user_role.role_id WHERE user_role.user_id=\"user\".id AND role.name=$%d)", len(args)))
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func generateSecureToken() (string, error) {
+	token := make([]byte, SECURE_TOKEN_BYTES)
+	_, err := rand.Read(token)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
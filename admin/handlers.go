@@ -0,0 +1,512 @@
+package admin
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"userland/audit"
+	"userland/auth"
+	"userland/config"
+	ulanderrors "userland/errors"
+	"userland/mailer"
+	"userland/profile"
+	"userland/request"
+	"userland/response"
+	"userland/storage"
+	"userland/tenancy"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	ACTION_USER_LIST            = "admin.user.list"
+	ACTION_USER_VIEW            = "admin.user.view"
+	ACTION_USER_CREATE          = "admin.user.create"
+	ACTION_USER_UPDATE          = "admin.user.update"
+	ACTION_USER_VERIFY          = "admin.user.verify"
+	ACTION_USER_PASSWORD_RESET  = "admin.user.password_reset"
+	ACTION_USER_SESSIONS_REVOKE = "admin.user.sessions_revoke"
+	ACTION_USER_TFA_DISABLE     = "admin.user.tfa_disable"
+	ACTION_USER_DELETE          = "admin.user.delete"
+	ACTION_USER_SUSPEND         = "admin.user.suspend"
+	ACTION_USER_UNSUSPEND       = "admin.user.unsuspend"
+
+	ACTION_REGISTRATION_INVITATION_CREATE = "admin.registration_invitation.create"
+	ACTION_SCIM_TOKEN_CREATE              = "admin.scim_token.create"
+	ACTION_SCIM_TOKEN_REVOKE              = "admin.scim_token.revoke"
+)
+
+type AdminHandler struct {
+	AdminRepo   adminRepositoryInterface
+	AuditLogger audit.Logger
+	Mailer      mailer.Mailer
+	Store       storage.BlobStore
+}
+
+func (handler AdminHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseUserFilter(r.URL.Query())
+	if err != nil {
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrAdminUserFilterInvalid)
+		return
+	}
+
+	if !handler.record(w, r, ACTION_USER_LIST, 0, map[string]interface{}{"query": r.URL.RawQuery}) {
+		return
+	}
+
+	users, total, err := handler.AdminRepo.getUsers(tenancy.FromRequest(r).Id, filter)
+	if err != nil {
+		log.Warn(err)
+		response.RespondInternalError(w, ulanderrors.ErrAdminQueryExec)
+		return
+	}
+
+	log.Info("Admin get users successful")
+	response.RespondSuccessWithBody(w, userListResponse{
+		Users:   users,
+		Page:    filter.Page,
+		PerPage: filter.PerPage,
+		Total:   total,
+	})
+}
+
+func (handler AdminHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := userIdFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if !handler.record(w, r, ACTION_USER_VIEW, id, nil) {
+		return
+	}
+
+	user, err := handler.AdminRepo.getUser(tenancy.FromRequest(r).Id, id)
+	if err != nil {
+		respondAdminError(w, err)
+		return
+	}
+
+	log.Info("Admin get user successful")
+	response.RespondSuccessWithBody(w, user)
+}
+
+func (handler AdminHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	var createReq createUserRequest
+	err := request.ParseJSON(r.Body, &createReq)
+	if err != nil {
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrParseBody)
+		return
+	}
+
+	if !createReq.isValid() {
+		log.Info("Created user data is invalid")
+		response.RespondBadRequest(w, ulanderrors.ErrAdminUserDataInvalid)
+		return
+	}
+
+	if !handler.record(w, r, ACTION_USER_CREATE, 0, map[string]interface{}{
+		"email":    createReq.Email,
+		"verified": createReq.Verified,
+	}) {
+		return
+	}
+
+	user, err := handler.AdminRepo.createUser(tenancy.FromRequest(r).Id, createReq)
+	if err != nil {
+		log.Warn(err)
+		response.RespondBadRequest(w, ulanderrors.ErrAdminQueryExec)
+		return
+	}
+
+	log.Info("Admin create user successful")
+	response.RespondSuccessWithBody(w, user)
+}
+
+func (handler AdminHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := userIdFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var updateReq updateUserRequest
+	err := request.ParseJSON(r.Body, &updateReq)
+	if err != nil {
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrParseBody)
+		return
+	}
+
+	if !updateReq.isValid() {
+		log.Info("Updated user data is invalid")
+		response.RespondBadRequest(w, ulanderrors.ErrAdminUserDataInvalid)
+		return
+	}
+
+	if !handler.record(w, r, ACTION_USER_UPDATE, id, map[string]interface{}{
+		"fullname": updateReq.Fullname,
+		"email":    updateReq.Email,
+		"location": updateReq.Location,
+		"bio":      updateReq.Bio,
+		"web":      updateReq.Web,
+	}) {
+		return
+	}
+
+	err = handler.AdminRepo.updateUser(tenancy.FromRequest(r).Id, id, updateReq)
+	if err != nil {
+		respondAdminError(w, err)
+		return
+	}
+
+	log.Info("Admin update user successful")
+	response.RespondSuccess(w)
+}
+
+func (handler AdminHandler) VerifyUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := userIdFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if !handler.record(w, r, ACTION_USER_VERIFY, id, nil) {
+		return
+	}
+
+	err := handler.AdminRepo.verifyUser(tenancy.FromRequest(r).Id, id)
+	if err != nil {
+		respondAdminError(w, err)
+		return
+	}
+
+	log.Info("Admin verify user successful")
+	response.RespondSuccess(w)
+}
+
+// ResetUserPassword emails the user a link to choose a new password. The
+// current password keeps working until the link is used.
+func (handler AdminHandler) ResetUserPassword(w http.ResponseWriter, r *http.Request) {
+	id, ok := userIdFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := handler.AdminRepo.getUser(tenancy.FromRequest(r).Id, id)
+	if err != nil {
+		respondAdminError(w, err)
+		return
+	}
+
+	if !handler.record(w, r, ACTION_USER_PASSWORD_RESET, id, nil) {
+		return
+	}
+
+	token, err := handler.AdminRepo.createPasswordResetToken(tenancy.FromRequest(r).Id, id)
+	if err != nil {
+		respondAdminError(w, err)
+		return
+	}
+
+	err = tenancy.FromRequest(r).Templates().Send(handler.Mailer, user.Email, mailer.PASSWORD_RESET_TEMPLATE, map[string]interface{}{
+		"Fullname": user.Fullname,
+		"Link":     fmt.Sprintf("%s/password/reset?token=%s", config.GetAppURL(), url.QueryEscape(token)),
+	})
+	if err != nil {
+		log.Warn(err)
+		response.RespondInternalError(w, ulanderrors.ErrAdminSendEmail)
+		return
+	}
+
+	log.Info("Admin reset user password successful")
+	response.RespondSuccess(w)
+}
+
+func (handler AdminHandler) RevokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	id, ok := userIdFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if !handler.record(w, r, ACTION_USER_SESSIONS_REVOKE, id, nil) {
+		return
+	}
+
+	err := handler.AdminRepo.revokeSessions(tenancy.FromRequest(r).Id, id)
+	if err != nil {
+		respondAdminError(w, err)
+		return
+	}
+
+	log.Info("Admin revoke user sessions successful")
+	response.RespondSuccess(w)
+}
+
+// DisableUserTFA removes every passkey of the user, for users who lost their
+// authenticators and can't pass the second factor anymore.
+func (handler AdminHandler) DisableUserTFA(w http.ResponseWriter, r *http.Request) {
+	id, ok := userIdFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if !handler.record(w, r, ACTION_USER_TFA_DISABLE, id, nil) {
+		return
+	}
+
+	err := handler.AdminRepo.disableTFA(tenancy.FromRequest(r).Id, id)
+	if err != nil {
+		respondAdminError(w, err)
+		return
+	}
+
+	log.Info("Admin disable user TFA successful")
+	response.RespondSuccess(w)
+}
+
+func (handler AdminHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	admin := r.Context().Value("user").(*auth.User)
+
+	id, ok := userIdFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if id == admin.Id {
+		log.Info("Admin tried to delete their own account")
+		response.RespondBadRequest(w, ulanderrors.ErrAdminCannotDeleteSelf)
+		return
+	}
+
+	user, err := handler.AdminRepo.getUser(tenancy.FromRequest(r).Id, id)
+	if err != nil {
+		respondAdminError(w, err)
+		return
+	}
+
+	if !handler.record(w, r, ACTION_USER_DELETE, id, map[string]interface{}{"email": user.Email}) {
+		return
+	}
+
+	pictureKey, err := handler.AdminRepo.deleteUser(tenancy.FromRequest(r).Id, id)
+	if err != nil {
+		respondAdminError(w, err)
+		return
+	}
+
+	if pictureKey != "" {
+		err = profile.DeletePicture(handler.Store, pictureKey)
+		if err != nil {
+			log.Warn(err)
+		}
+	}
+
+	log.Info("Admin delete user successful")
+	response.RespondSuccess(w)
+}
+
+// SuspendUser locks the user out of logging in and out of every session they
+// have, until the suspension expires or is lifted.
+func (handler AdminHandler) SuspendUser(w http.ResponseWriter, r *http.Request) {
+	admin := r.Context().Value("user").(*auth.User)
+
+	id, ok := userIdFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var suspendReq suspendUserRequest
+	err := request.ParseJSON(r.Body, &suspendReq)
+	if err != nil {
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrParseBody)
+		return
+	}
+
+	if !suspendReq.isValid() {
+		log.Info("Suspension data is invalid")
+		response.RespondBadRequest(w, ulanderrors.ErrAdminSuspensionInvalid)
+		return
+	}
+
+	if id == admin.Id {
+		log.Info("Admin tried to suspend their own account")
+		response.RespondBadRequest(w, ulanderrors.ErrAdminCannotSuspendSelf)
+		return
+	}
+
+	if !handler.record(w, r, ACTION_USER_SUSPEND, id, map[string]interface{}{
+		"suspension": suspendReq.kind(),
+		"reason":     suspendReq.Reason,
+		"until":      suspendReq.Until,
+	}) {
+		return
+	}
+
+	err = handler.AdminRepo.suspendUser(tenancy.FromRequest(r).Id, id, admin.Id, suspendReq)
+	if err != nil {
+		respondAdminError(w, err)
+		return
+	}
+
+	log.Info("Admin suspend user successful")
+	response.RespondSuccess(w)
+}
+
+func (handler AdminHandler) UnsuspendUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := userIdFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if !handler.record(w, r, ACTION_USER_UNSUSPEND, id, nil) {
+		return
+	}
+
+	err := handler.AdminRepo.unsuspendUser(tenancy.FromRequest(r).Id, id)
+	if err != nil {
+		respondAdminError(w, err)
+		return
+	}
+
+	log.Info("Admin unsuspend user successful")
+	response.RespondSuccess(w)
+}
+
+// CreateRegistrationInvitation issues an invitation code for the invite-only
+// registration mode, bound to the given email address if there is one.
+func (handler AdminHandler) CreateRegistrationInvitation(w http.ResponseWriter, r *http.Request) {
+	admin := r.Context().Value("user").(*auth.User)
+
+	invitationReq := registrationInvitationRequest{ExpiresInDays: DEFAULT_INVITATION_EXPIRATION_DAYS}
+	err := request.ParseJSON(r.Body, &invitationReq)
+	if err != nil {
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrParseBody)
+		return
+	}
+
+	if !invitationReq.isValid() {
+		log.Info("Registration invitation data is invalid")
+		response.RespondBadRequest(w, ulanderrors.ErrAdminUserDataInvalid)
+		return
+	}
+
+	if !handler.record(w, r, ACTION_REGISTRATION_INVITATION_CREATE, 0, map[string]interface{}{
+		"email":           invitationReq.Email,
+		"expires_in_days": invitationReq.ExpiresInDays,
+	}) {
+		return
+	}
+
+	invitation, err := handler.AdminRepo.createRegistrationInvitation(tenancy.FromRequest(r).Id, admin.Id, invitationReq)
+	if err != nil {
+		log.Warn(err)
+		response.RespondInternalError(w, ulanderrors.ErrAdminQueryExec)
+		return
+	}
+
+	log.Info("Admin create registration invitation successful")
+	response.RespondSuccessWithBody(w, invitation)
+}
+
+// CreateScimToken issues a bearer token for the tenant's identity provider to
+// provision users through SCIM. It's only shown this once.
+func (handler AdminHandler) CreateScimToken(w http.ResponseWriter, r *http.Request) {
+	admin := r.Context().Value("user").(*auth.User)
+
+	var tokenReq scimTokenRequest
+	err := request.ParseJSON(r.Body, &tokenReq)
+	if err != nil {
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrParseBody)
+		return
+	}
+
+	if !tokenReq.isValid() {
+		log.Info("SCIM token data is invalid")
+		response.RespondBadRequest(w, ulanderrors.ErrAdminUserDataInvalid)
+		return
+	}
+
+	if !handler.record(w, r, ACTION_SCIM_TOKEN_CREATE, 0, map[string]interface{}{
+		"description": tokenReq.Description,
+	}) {
+		return
+	}
+
+	token, err := handler.AdminRepo.createScimToken(tenancy.FromRequest(r).Id, admin.Id, tokenReq)
+	if err != nil {
+		log.Warn(err)
+		response.RespondInternalError(w, ulanderrors.ErrAdminQueryExec)
+		return
+	}
+
+	log.Info("Admin create SCIM token successful")
+	response.RespondSuccessWithBody(w, token)
+}
+
+func (handler AdminHandler) RevokeScimToken(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrAdminScimTokenNotFound)
+		return
+	}
+
+	if !handler.record(w, r, ACTION_SCIM_TOKEN_REVOKE, 0, map[string]interface{}{"token_id": id}) {
+		return
+	}
+
+	err = handler.AdminRepo.deleteScimToken(tenancy.FromRequest(r).Id, id)
+	if err == sql.ErrNoRows {
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrAdminScimTokenNotFound)
+		return
+	}
+	if err != nil {
+		log.Warn(err)
+		response.RespondInternalError(w, ulanderrors.ErrAdminQueryExec)
+		return
+	}
+
+	log.Info("Admin revoke SCIM token successful")
+	response.RespondSuccess(w)
+}
+
+// record writes the action of the admin behind the request to the audit log
+// before it's carried out, and tells whether it did. Actions that can't be
+// audited are refused, so that none goes unrecorded.
+func (handler AdminHandler) record(w http.ResponseWriter, r *http.Request, action string, userId int, details map[string]interface{}) bool {
+	admin := r.Context().Value("user").(*auth.User)
+	err := handler.AuditLogger.Record(audit.NewEvent(r, admin.Id, action, userId, details))
+	if err != nil {
+		log.Warn(err)
+		response.RespondInternalError(w, ulanderrors.ErrAdminAudit)
+		return false
+	}
+	return true
+}
+
+func userIdFromPath(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		log.Info(err)
+		response.RespondNotFound(w, ulanderrors.ErrAdminUserNotFound)
+		return 0, false
+	}
+	return id, true
+}
+
+func respondAdminError(w http.ResponseWriter, err error) {
+	if err == sql.ErrNoRows {
+		log.Info(err)
+		response.RespondNotFound(w, ulanderrors.ErrAdminUserNotFound)
+		return
+	}
+	log.Warn(err)
+	response.RespondInternalError(w, ulanderrors.ErrAdminQueryExec)
+}
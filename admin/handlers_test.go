@@ -0,0 +1,363 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"userland/audit"
+	"userland/auth"
+	"userland/mailer"
+	"userland/storage"
+	"userland/tenancy"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	handler AdminHandler
+	router  *mux.Router
+
+	ctrl            *gomock.Controller
+	mockRepo        *MockadminRepositoryInterface
+	mockAuditLogger *audit.MockLogger
+	mockMailer      *mailer.MockMailer
+	mockStore       *storage.MockBlobStore
+
+	adminUser = auth.User{
+		Id:       1,
+		Fullname: "admin",
+		Email:    "admin@example.com",
+	}
+
+	managedUser = ManagedUser{
+		Id:       2,
+		Fullname: "user",
+		Email:    "user@example.com",
+		Roles:    []string{},
+	}
+)
+
+func testAdminHandlerInit(t *testing.T) {
+	ctrl = gomock.NewController(t)
+	mockRepo = NewMockadminRepositoryInterface(ctrl)
+	mockAuditLogger = audit.NewMockLogger(ctrl)
+	mockMailer = mailer.NewMockMailer(ctrl)
+	mockStore = storage.NewMockBlobStore(ctrl)
+
+	handler = AdminHandler{
+		AdminRepo:   mockRepo,
+		AuditLogger: mockAuditLogger,
+		Mailer:      mockMailer,
+		Store:       mockStore,
+	}
+
+	router = mux.NewRouter()
+	router.HandleFunc("/admin/users", withAdmin(handler.GetUsers)).Methods(http.MethodGet)
+	router.HandleFunc("/admin/users", withAdmin(handler.CreateUser)).Methods(http.MethodPost)
+	router.HandleFunc("/admin/users/{id}", withAdmin(handler.GetUser)).Methods(http.MethodGet)
+	router.HandleFunc("/admin/users/{id}", withAdmin(handler.UpdateUser)).Methods(http.MethodPut)
+	router.HandleFunc("/admin/users/{id}", withAdmin(handler.DeleteUser)).Methods(http.MethodDelete)
+	router.HandleFunc("/admin/users/{id}/verify", withAdmin(handler.VerifyUser)).Methods(http.MethodPost)
+	router.HandleFunc("/admin/users/{id}/password/reset", withAdmin(handler.ResetUserPassword)).Methods(http.MethodPost)
+	router.HandleFunc("/admin/users/{id}/sessions/revoke", withAdmin(handler.RevokeUserSessions)).Methods(http.MethodPost)
+	router.HandleFunc("/admin/users/{id}/tfa/disable", withAdmin(handler.DisableUserTFA)).Methods(http.MethodPost)
+	router.HandleFunc("/admin/users/{id}/suspend", withAdmin(handler.SuspendUser)).Methods(http.MethodPost)
+	router.HandleFunc("/admin/users/{id}/unsuspend", withAdmin(handler.UnsuspendUser)).Methods(http.MethodPost)
+	router.HandleFunc("/admin/registration/invitations", withAdmin(handler.CreateRegistrationInvitation)).Methods(http.MethodPost)
+	router.HandleFunc("/admin/scim/tokens", withAdmin(handler.CreateScimToken)).Methods(http.MethodPost)
+	router.HandleFunc("/admin/scim/tokens/{id}", withAdmin(handler.RevokeScimToken)).Methods(http.MethodDelete)
+}
+
+func testAdminHandlerEnd() {
+	ctrl.Finish()
+}
+
+func withAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), "user", &adminUser)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+func serve(t *testing.T, method string, url string, body interface{}) *httptest.ResponseRecorder {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		reqBody, err = json.Marshal(body)
+		require.Nil(t, err)
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(reqBody))
+	require.Nil(t, err)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	return res
+}
+
+// expectAudit expects the admin's action on the user to be written to the
+// audit log, which happens before the action whether or not it succeeds.
+func expectAudit(t *testing.T, action string, userId int) *gomock.Call {
+	return mockAuditLogger.EXPECT().Record(gomock.Any()).DoAndReturn(func(event audit.Event) error {
+		assert.Equal(t, adminUser.Id, event.ActorId)
+		assert.Equal(t, action, event.Action)
+		assert.Equal(t, userId, event.UserId)
+		return nil
+	})
+}
+
+func TestGetUsers(t *testing.T) {
+	testAdminHandlerInit(t)
+
+	verified := true
+	expectedFilter := userFilter{Query: "user", Verified: &verified, Role: "admin", Page: 2, PerPage: 10}
+	mockRepo.EXPECT().getUsers(tenancy.DEFAULT_TENANT_ID, expectedFilter).Return([]ManagedUser{managedUser}, 11, nil)
+	expectAudit(t, ACTION_USER_LIST, 0)
+
+	res := serve(t, http.MethodGet, "/admin/users?q=user&verified=true&role=admin&page=2&per_page=10", nil)
+	assert.Equal(t, http.StatusOK, res.Code)
+	var list userListResponse
+	require.Nil(t, json.Unmarshal(res.Body.Bytes(), &list))
+	assert.Equal(t, 11, list.Total)
+	assert.Equal(t, 2, list.Page)
+	assert.Len(t, list.Users, 1)
+	assert.NotContains(t, res.Body.String(), "password\"", "User list should not expose passwords")
+
+	assert.Equal(t, http.StatusBadRequest, serve(t, http.MethodGet, "/admin/users?verified=maybe", nil).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(t, http.MethodGet, "/admin/users?per_page=1000", nil).Code)
+
+	testAdminHandlerEnd()
+}
+
+func TestGetUser(t *testing.T) {
+	testAdminHandlerInit(t)
+
+	mockRepo.EXPECT().getUser(tenancy.DEFAULT_TENANT_ID, managedUser.Id).Return(&managedUser, nil)
+	mockRepo.EXPECT().getUser(tenancy.DEFAULT_TENANT_ID, 3).Return(nil, sql.ErrNoRows)
+	expectAudit(t, ACTION_USER_VIEW, managedUser.Id)
+	expectAudit(t, ACTION_USER_VIEW, 3)
+
+	assert.Equal(t, http.StatusOK, serve(t, http.MethodGet, "/admin/users/2", nil).Code)
+	assert.Equal(t, http.StatusNotFound, serve(t, http.MethodGet, "/admin/users/3", nil).Code)
+	assert.Equal(t, http.StatusNotFound, serve(t, http.MethodGet, "/admin/users/abc", nil).Code)
+
+	testAdminHandlerEnd()
+}
+
+func TestGetUserFromAnotherTenant(t *testing.T) {
+	testAdminHandlerInit(t)
+
+	otherTenant := tenancy.Tenant{Id: 2, Slug: "other"}
+	mockRepo.EXPECT().getUser(otherTenant.Id, managedUser.Id).Return(nil, sql.ErrNoRows).Times(2)
+	expectAudit(t, ACTION_USER_VIEW, managedUser.Id)
+
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		req, err := http.NewRequest(method, "/admin/users/2", nil)
+		require.Nil(t, err)
+		req = req.WithContext(context.WithValue(req.Context(), "tenant", &otherTenant))
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		assert.Equal(t, http.StatusNotFound, res.Code)
+	}
+
+	testAdminHandlerEnd()
+}
+
+func TestCreateUser(t *testing.T) {
+	testAdminHandlerInit(t)
+
+	createReq := createUserRequest{Fullname: "user", Email: "user@example.com", Password: "password", Verified: true}
+	mockRepo.EXPECT().createUser(tenancy.DEFAULT_TENANT_ID, createReq).Return(&managedUser, nil)
+	expectAudit(t, ACTION_USER_CREATE, 0)
+	assert.Equal(t, http.StatusOK, serve(t, http.MethodPost, "/admin/users", createReq).Code)
+
+	createReq.Password = "pass"
+	assert.Equal(t, http.StatusBadRequest, serve(t, http.MethodPost, "/admin/users", createReq).Code)
+
+	testAdminHandlerEnd()
+}
+
+func TestUpdateUser(t *testing.T) {
+	testAdminHandlerInit(t)
+
+	updateReq := updateUserRequest{Fullname: "user", Email: "changed@example.com", Bio: "my bio"}
+	mockRepo.EXPECT().updateUser(tenancy.DEFAULT_TENANT_ID, managedUser.Id, updateReq).Return(nil)
+	expectAudit(t, ACTION_USER_UPDATE, managedUser.Id)
+	assert.Equal(t, http.StatusOK, serve(t, http.MethodPut, "/admin/users/2", updateReq).Code)
+
+	updateReq.Email = "invalidemail"
+	assert.Equal(t, http.StatusBadRequest, serve(t, http.MethodPut, "/admin/users/2", updateReq).Code)
+
+	testAdminHandlerEnd()
+}
+
+func TestUserActions(t *testing.T) {
+	testAdminHandlerInit(t)
+
+	mockRepo.EXPECT().verifyUser(tenancy.DEFAULT_TENANT_ID, managedUser.Id).Return(nil)
+	expectAudit(t, ACTION_USER_VERIFY, managedUser.Id)
+	assert.Equal(t, http.StatusOK, serve(t, http.MethodPost, "/admin/users/2/verify", nil).Code)
+
+	mockRepo.EXPECT().revokeSessions(tenancy.DEFAULT_TENANT_ID, managedUser.Id).Return(nil)
+	expectAudit(t, ACTION_USER_SESSIONS_REVOKE, managedUser.Id)
+	assert.Equal(t, http.StatusOK, serve(t, http.MethodPost, "/admin/users/2/sessions/revoke", nil).Code)
+
+	mockRepo.EXPECT().disableTFA(tenancy.DEFAULT_TENANT_ID, managedUser.Id).Return(nil)
+	expectAudit(t, ACTION_USER_TFA_DISABLE, managedUser.Id)
+	assert.Equal(t, http.StatusOK, serve(t, http.MethodPost, "/admin/users/2/tfa/disable", nil).Code)
+
+	mockRepo.EXPECT().revokeSessions(tenancy.DEFAULT_TENANT_ID, 3).Return(sql.ErrNoRows)
+	expectAudit(t, ACTION_USER_SESSIONS_REVOKE, 3)
+	assert.Equal(t, http.StatusNotFound, serve(t, http.MethodPost, "/admin/users/3/sessions/revoke", nil).Code)
+
+	mockRepo.EXPECT().verifyUser(tenancy.DEFAULT_TENANT_ID, 3).Return(errors.New(""))
+	expectAudit(t, ACTION_USER_VERIFY, 3)
+	assert.Equal(t, http.StatusInternalServerError, serve(t, http.MethodPost, "/admin/users/3/verify", nil).Code)
+
+	testAdminHandlerEnd()
+}
+
+func TestResetUserPassword(t *testing.T) {
+	testAdminHandlerInit(t)
+
+	gomock.InOrder(
+		mockRepo.EXPECT().getUser(tenancy.DEFAULT_TENANT_ID, managedUser.Id).Return(&managedUser, nil),
+		expectAudit(t, ACTION_USER_PASSWORD_RESET, managedUser.Id),
+		mockRepo.EXPECT().createPasswordResetToken(tenancy.DEFAULT_TENANT_ID, managedUser.Id).Return("resettoken", nil),
+		mockMailer.EXPECT().Send(managedUser.Email, gomock.Any(), gomock.Any()).DoAndReturn(func(recipient string, subject string, body string) error {
+			assert.Contains(t, body, "/password/reset?token=resettoken")
+			return nil
+		}),
+	)
+	assert.Equal(t, http.StatusOK, serve(t, http.MethodPost, "/admin/users/2/password/reset", nil).Code)
+
+	gomock.InOrder(
+		mockRepo.EXPECT().getUser(tenancy.DEFAULT_TENANT_ID, managedUser.Id).Return(&managedUser, nil),
+		expectAudit(t, ACTION_USER_PASSWORD_RESET, managedUser.Id),
+		mockRepo.EXPECT().createPasswordResetToken(tenancy.DEFAULT_TENANT_ID, managedUser.Id).Return("resettoken", nil),
+		mockMailer.EXPECT().Send(managedUser.Email, gomock.Any(), gomock.Any()).Return(errors.New("")),
+	)
+	assert.Equal(t, http.StatusInternalServerError, serve(t, http.MethodPost, "/admin/users/2/password/reset", nil).Code)
+
+	testAdminHandlerEnd()
+}
+
+func TestDeleteUser(t *testing.T) {
+	testAdminHandlerInit(t)
+
+	gomock.InOrder(
+		mockRepo.EXPECT().getUser(tenancy.DEFAULT_TENANT_ID, managedUser.Id).Return(&managedUser, nil),
+		expectAudit(t, ACTION_USER_DELETE, managedUser.Id),
+		mockRepo.EXPECT().deleteUser(tenancy.DEFAULT_TENANT_ID, managedUser.Id).Return("", nil),
+	)
+	assert.Equal(t, http.StatusOK, serve(t, http.MethodDelete, "/admin/users/2", nil).Code)
+
+	gomock.InOrder(
+		mockRepo.EXPECT().getUser(tenancy.DEFAULT_TENANT_ID, managedUser.Id).Return(&managedUser, nil),
+		expectAudit(t, ACTION_USER_DELETE, managedUser.Id),
+		mockRepo.EXPECT().deleteUser(tenancy.DEFAULT_TENANT_ID, managedUser.Id).Return("pictures/1/2/hash", nil),
+		mockStore.EXPECT().Delete("pictures/1/2/hash").Return(nil),
+		mockStore.EXPECT().Delete("pictures/1/2/hash-64").Return(nil),
+		mockStore.EXPECT().Delete("pictures/1/2/hash-256").Return(nil),
+		mockStore.EXPECT().Delete("pictures/1/2/hash-512").Return(nil),
+	)
+	assert.Equal(t, http.StatusOK, serve(t, http.MethodDelete, "/admin/users/2", nil).Code)
+
+	assert.Equal(t, http.StatusBadRequest, serve(t, http.MethodDelete, "/admin/users/1", nil).Code, "Admins should not delete themselves")
+
+	testAdminHandlerEnd()
+}
+
+func TestSuspendUser(t *testing.T) {
+	testAdminHandlerInit(t)
+
+	until := time.Now().Add(time.Hour).Truncate(time.Second)
+	suspendReq := suspendUserRequest{Reason: "spam", Until: &until}
+	mockRepo.EXPECT().suspendUser(tenancy.DEFAULT_TENANT_ID, managedUser.Id, adminUser.Id, gomock.Any()).DoAndReturn(func(tenantId int, id int, adminId int, req suspendUserRequest) error {
+		assert.Equal(t, auth.SUSPENSION_SUSPENDED, req.kind())
+		assert.True(t, until.Equal(*req.Until))
+		return nil
+	})
+	expectAudit(t, ACTION_USER_SUSPEND, managedUser.Id)
+	assert.Equal(t, http.StatusOK, serve(t, http.MethodPost, "/admin/users/2/suspend", suspendReq).Code)
+
+	banReq := suspendUserRequest{Reason: "fraud", Ban: true}
+	mockRepo.EXPECT().suspendUser(tenancy.DEFAULT_TENANT_ID, managedUser.Id, adminUser.Id, banReq).Return(nil)
+	expectAudit(t, ACTION_USER_SUSPEND, managedUser.Id)
+	assert.Equal(t, http.StatusOK, serve(t, http.MethodPost, "/admin/users/2/suspend", banReq).Code)
+
+	past := time.Now().Add(-time.Hour)
+	assert.Equal(t, http.StatusBadRequest, serve(t, http.MethodPost, "/admin/users/2/suspend", suspendUserRequest{Reason: "spam", Until: &past}).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(t, http.MethodPost, "/admin/users/2/suspend", suspendUserRequest{}).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(t, http.MethodPost, "/admin/users/1/suspend", banReq).Code, "Admins should not suspend themselves")
+
+	mockRepo.EXPECT().unsuspendUser(tenancy.DEFAULT_TENANT_ID, managedUser.Id).Return(nil)
+	expectAudit(t, ACTION_USER_UNSUSPEND, managedUser.Id)
+	assert.Equal(t, http.StatusOK, serve(t, http.MethodPost, "/admin/users/2/unsuspend", nil).Code)
+
+	mockRepo.EXPECT().unsuspendUser(tenancy.DEFAULT_TENANT_ID, 3).Return(sql.ErrNoRows)
+	expectAudit(t, ACTION_USER_UNSUSPEND, 3)
+	assert.Equal(t, http.StatusNotFound, serve(t, http.MethodPost, "/admin/users/3/unsuspend", nil).Code)
+
+	testAdminHandlerEnd()
+}
+
+func TestCreateRegistrationInvitation(t *testing.T) {
+	testAdminHandlerInit(t)
+	invitation := RegistrationInvitation{Id: 1, Code: "invitationcode", Email: "invited@example.com"}
+
+	expectedReq := registrationInvitationRequest{Email: "invited@example.com", ExpiresInDays: DEFAULT_INVITATION_EXPIRATION_DAYS}
+	mockRepo.EXPECT().createRegistrationInvitation(tenancy.DEFAULT_TENANT_ID, adminUser.Id, expectedReq).Return(&invitation, nil)
+	expectAudit(t, ACTION_REGISTRATION_INVITATION_CREATE, 0)
+	res := serve(t, http.MethodPost, "/admin/registration/invitations", map[string]string{"email": "invited@example.com"})
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Body.String(), "invitationcode")
+
+	assert.Equal(t, http.StatusBadRequest, serve(t, http.MethodPost, "/admin/registration/invitations", registrationInvitationRequest{ExpiresInDays: 365}).Code)
+
+	testAdminHandlerEnd()
+}
+
+func TestScimTokens(t *testing.T) {
+	testAdminHandlerInit(t)
+	token := ScimToken{Id: 1, Token: "scimtoken", Description: "Okta"}
+
+	mockRepo.EXPECT().createScimToken(tenancy.DEFAULT_TENANT_ID, adminUser.Id, scimTokenRequest{Description: "Okta"}).Return(&token, nil)
+	expectAudit(t, ACTION_SCIM_TOKEN_CREATE, 0)
+	res := serve(t, http.MethodPost, "/admin/scim/tokens", scimTokenRequest{Description: "Okta"})
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Body.String(), "scimtoken")
+
+	assert.Equal(t, http.StatusBadRequest, serve(t, http.MethodPost, "/admin/scim/tokens", scimTokenRequest{}).Code)
+
+	mockRepo.EXPECT().deleteScimToken(tenancy.DEFAULT_TENANT_ID, 1).Return(nil)
+	mockRepo.EXPECT().deleteScimToken(tenancy.DEFAULT_TENANT_ID, 2).Return(sql.ErrNoRows)
+	expectAudit(t, ACTION_SCIM_TOKEN_REVOKE, 0).Times(2)
+	assert.Equal(t, http.StatusOK, serve(t, http.MethodDelete, "/admin/scim/tokens/1", nil).Code)
+	res = serve(t, http.MethodDelete, "/admin/scim/tokens/2", nil)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assert.Contains(t, res.Body.String(), "1409")
+
+	testAdminHandlerEnd()
+}
+
+func TestUnauditedActionRefused(t *testing.T) {
+	testAdminHandlerInit(t)
+
+	mockAuditLogger.EXPECT().Record(gomock.Any()).Return(errors.New("connection refused")).Times(2)
+	res := serve(t, http.MethodPost, "/admin/users/2/sessions/revoke", nil)
+	assert.Equal(t, http.StatusInternalServerError, res.Code, "Actions that can't be audited should not be carried out")
+	assert.Contains(t, res.Body.String(), "1410")
+
+	res = serve(t, http.MethodGet, "/admin/users", nil)
+	assert.Equal(t, http.StatusInternalServerError, res.Code)
+
+	testAdminHandlerEnd()
+}
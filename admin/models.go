@@ -0,0 +1,194 @@
+package admin
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"userland/auth"
+
+	"github.com/lib/pq"
+)
+
+const (
+	DEFAULT_USERS_PER_PAGE = 20
+	MAX_USERS_PER_PAGE     = 100
+
+	DEFAULT_INVITATION_EXPIRATION_DAYS = 7
+	MAX_INVITATION_EXPIRATION_DAYS     = 90
+)
+
+// ManagedUser is the view of an account admins work with. It never carries
+// the password hash or any of the user's tokens.
+type ManagedUser struct {
+	Id                    int            `json:"id"`
+	Fullname              string         `json:"fullname"`
+	Email                 string         `json:"email"`
+	Location              string         `json:"location"`
+	Bio                   string         `json:"bio"`
+	Web                   string         `json:"web"`
+	Verified              bool           `json:"verified"`
+	PasswordResetRequired bool           `json:"password_reset_required" db:"password_reset_required"`
+	TFAEnabled            bool           `json:"tfa_enabled" db:"tfa_enabled"`
+	Roles                 pq.StringArray `json:"roles"`
+	Suspension            string         `json:"suspension"`
+	SuspensionReason      string         `json:"suspension_reason" db:"suspension_reason"`
+	SuspendedUntil        *time.Time     `json:"suspended_until" db:"suspended_until"`
+	CreatedAt             time.Time      `json:"created_at" db:"created_at"`
+}
+
+type userFilter struct {
+	Query     string
+	Verified  *bool
+	Suspended *bool
+	Role      string
+	Page      int
+	PerPage   int
+}
+
+func parseUserFilter(values url.Values) (userFilter, error) {
+	filter := userFilter{
+		Query:   strings.TrimSpace(values.Get("q")),
+		Role:    values.Get("role"),
+		Page:    1,
+		PerPage: DEFAULT_USERS_PER_PAGE,
+	}
+
+	if verified := values.Get("verified"); verified != "" {
+		value, err := strconv.ParseBool(verified)
+		if err != nil {
+			return filter, err
+		}
+		filter.Verified = &value
+	}
+
+	if suspended := values.Get("suspended"); suspended != "" {
+		value, err := strconv.ParseBool(suspended)
+		if err != nil {
+			return filter, err
+		}
+		filter.Suspended = &value
+	}
+
+	if page := values.Get("page"); page != "" {
+		value, err := strconv.Atoi(page)
+		if err != nil || value < 1 {
+			return filter, errors.New("Page has to be a positive number")
+		}
+		filter.Page = value
+	}
+
+	if perPage := values.Get("per_page"); perPage != "" {
+		value, err := strconv.Atoi(perPage)
+		if err != nil || value < 1 || value > MAX_USERS_PER_PAGE {
+			return filter, errors.New("Page size is out of range")
+		}
+		filter.PerPage = value
+	}
+
+	return filter, nil
+}
+
+func (filter userFilter) offset() int {
+	return (filter.Page - 1) * filter.PerPage
+}
+
+type userListResponse struct {
+	Users   []ManagedUser `json:"users"`
+	Page    int           `json:"page"`
+	PerPage int           `json:"per_page"`
+	Total   int           `json:"total"`
+}
+
+type createUserRequest struct {
+	Fullname string `json:"fullname"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Verified bool   `json:"verified"`
+}
+
+func (req createUserRequest) isValid() bool {
+	return hasValidFullname(req.Fullname) && hasValidEmail(req.Email) &&
+		len(req.Password) >= 6 && len(req.Password) <= 128
+}
+
+type updateUserRequest struct {
+	Fullname string `json:"fullname"`
+	Email    string `json:"email"`
+	Location string `json:"location"`
+	Bio      string `json:"bio"`
+	Web      string `json:"web"`
+}
+
+func (req updateUserRequest) isValid() bool {
+	return hasValidFullname(req.Fullname) && hasValidEmail(req.Email) &&
+		len(req.Location) <= 128 && len(req.Bio) <= 255 && len(req.Web) <= 128
+}
+
+// suspendUserRequest suspends the user until the given time, or indefinitely
+// when it's missing. Bans are meant to be permanent but may expire as well.
+type suspendUserRequest struct {
+	Reason string     `json:"reason"`
+	Until  *time.Time `json:"until"`
+	Ban    bool       `json:"ban"`
+}
+
+func (req suspendUserRequest) isValid() bool {
+	return req.Reason != "" && len(req.Reason) <= 255 &&
+		(req.Until == nil || req.Until.After(time.Now()))
+}
+
+func (req suspendUserRequest) kind() string {
+	if req.Ban {
+		return auth.SUSPENSION_BANNED
+	}
+	return auth.SUSPENSION_SUSPENDED
+}
+
+// RegistrationInvitation is a single-use code letting someone sign up while
+// registration is invite-only. It may be bound to an email address.
+type RegistrationInvitation struct {
+	Id        int       `json:"id"`
+	Code      string    `json:"code"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+}
+
+type registrationInvitationRequest struct {
+	Email         string `json:"email"`
+	ExpiresInDays int    `json:"expires_in_days"`
+}
+
+func (req registrationInvitationRequest) isValid() bool {
+	return (req.Email == "" || hasValidEmail(req.Email)) &&
+		req.ExpiresInDays > 0 && req.ExpiresInDays <= MAX_INVITATION_EXPIRATION_DAYS
+}
+
+// ScimToken lets an identity provider provision the tenant's users through
+// SCIM. The token itself is only returned when it's created.
+type ScimToken struct {
+	Id          int       `json:"id"`
+	Token       string    `json:"token,omitempty" db:"-"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+type scimTokenRequest struct {
+	Description string `json:"description"`
+}
+
+func (req scimTokenRequest) isValid() bool {
+	return req.Description != "" && len(req.Description) <= 128
+}
+
+func hasValidFullname(fullname string) bool {
+	return fullname != "" && len(fullname) <= 128
+}
+
+func hasValidEmail(email string) bool {
+	emailFormatValid := regexp.MustCompile(`^[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,4}$`).MatchString(email)
+	return len(email) <= 128 && emailFormatValid
+}
@@ -0,0 +1,79 @@
+package admin
+
+import (
+	"net/url"
+	"testing"
+	"time"
+	"userland/auth"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUserFilter(t *testing.T) {
+	filter, err := parseUserFilter(url.Values{})
+	require.Nil(t, err)
+	assert.Equal(t, userFilter{Page: 1, PerPage: DEFAULT_USERS_PER_PAGE}, filter)
+	assert.Equal(t, 0, filter.offset())
+
+	filter, err = parseUserFilter(url.Values{"q": {" user "}, "verified": {"false"}, "page": {"3"}, "per_page": {"50"}})
+	require.Nil(t, err)
+	assert.Equal(t, "user", filter.Query)
+	require.NotNil(t, filter.Verified)
+	assert.False(t, *filter.Verified)
+	assert.Equal(t, 100, filter.offset())
+
+	filter, err = parseUserFilter(url.Values{"suspended": {"true"}})
+	require.Nil(t, err)
+	require.NotNil(t, filter.Suspended)
+	assert.True(t, *filter.Suspended)
+
+	_, err = parseUserFilter(url.Values{"page": {"0"}})
+	assert.NotNil(t, err)
+	_, err = parseUserFilter(url.Values{"per_page": {"abc"}})
+	assert.NotNil(t, err)
+}
+
+func TestUserFilterConditions(t *testing.T) {
+	where, args := userFilter{}.conditions(1)
+	assert.Equal(t, " WHERE tenant_id=$1", where, "Users of other tenants should never be listed")
+	assert.Equal(t, []interface{}{1}, args)
+
+	verified := true
+	where, args = userFilter{Query: "50%_off", Verified: &verified, Role: "admin"}.conditions(1)
+	assert.Equal(t, " WHERE tenant_id=$1 AND (email ILIKE $2 OR fullname ILIKE $2) AND COALESCE(verified, false)=$3 AND EXISTS (SELECT 1 FROM user_role JOIN role ON role.id=user_role.role_id WHERE user_role.user_id=\"user\".id AND role.name=$4)", where)
+	assert.Equal(t, []interface{}{1, `%50\%\_off%`, true, "admin"}, args)
+}
+
+func TestUserRequestValidity(t *testing.T) {
+	createReq := createUserRequest{Fullname: "user", Email: "user@example.com", Password: "password"}
+	assert.True(t, createReq.isValid())
+	createReq.Email = "user@example/com"
+	assert.False(t, createReq.isValid())
+
+	updateReq := updateUserRequest{Fullname: "user", Email: "user@example.com"}
+	assert.True(t, updateReq.isValid())
+	updateReq.Fullname = ""
+	assert.False(t, updateReq.isValid())
+}
+
+func TestSuspendUserRequestValidity(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+
+	assert.True(t, suspendUserRequest{Reason: "spam"}.isValid())
+	assert.True(t, suspendUserRequest{Reason: "spam", Until: &future}.isValid())
+	assert.False(t, suspendUserRequest{Reason: "spam", Until: &past}.isValid(), "Suspension should not expire in the past")
+	assert.False(t, suspendUserRequest{}.isValid(), "Suspension should have a reason")
+
+	assert.Equal(t, auth.SUSPENSION_SUSPENDED, suspendUserRequest{}.kind())
+	assert.Equal(t, auth.SUSPENSION_BANNED, suspendUserRequest{Ban: true}.kind())
+}
+
+func TestRegistrationInvitationRequestValidity(t *testing.T) {
+	assert.True(t, registrationInvitationRequest{ExpiresInDays: 7}.isValid(), "Invitation doesn't have to be bound to an email")
+	assert.True(t, registrationInvitationRequest{Email: "user@example.com", ExpiresInDays: 7}.isValid())
+	assert.False(t, registrationInvitationRequest{Email: "user", ExpiresInDays: 7}.isValid())
+	assert.False(t, registrationInvitationRequest{ExpiresInDays: 0}.isValid())
+	assert.False(t, registrationInvitationRequest{ExpiresInDays: MAX_INVITATION_EXPIRATION_DAYS + 1}.isValid())
+}
@@ -0,0 +1,9 @@
+package appcontext
+
+import "strings"
+
+// EscapeLikePattern escapes the wildcards of LIKE and ILIKE, and the
+// backslash escaping them, so that user input only ever matches itself.
+func EscapeLikePattern(pattern string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(pattern)
+}
@@ -0,0 +1,14 @@
+package appcontext
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEscapeLikePattern(t *testing.T) {
+	assert.Equal(t, "jane", EscapeLikePattern("jane"))
+	assert.Equal(t, `50\%\_off`, EscapeLikePattern("50%_off"))
+	assert.Equal(t, `c:\\users\\jane`, EscapeLikePattern(`c:\users\jane`), "Backslash should be escaped once")
+	assert.Equal(t, `\\\%`, EscapeLikePattern(`\%`))
+}
@@ -0,0 +1,66 @@
+package audit
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+	"userland/appcontext"
+	"userland/request"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/types"
+)
+
+const (
+	INSERT_AUDIT_EVENT_QUERY = "INSERT INTO audit_event (actor_id, user_id, action, ip_address, details) VALUES (NULLIF($1, 0), NULLIF($2, 0), $3, $4, $5)"
+)
+
+// Event records who did what to which user. The actor and user ids are
+// zero when there is none, e.g. for actions taken by the system itself.
+// Rows aren't tied to the user table so the trail outlives deleted accounts.
+type Event struct {
+	Id        int            `json:"id"`
+	ActorId   int            `json:"actor_id" db:"actor_id"`
+	UserId    int            `json:"user_id" db:"user_id"`
+	Action    string         `json:"action"`
+	IPAddress string         `json:"ip_address" db:"ip_address"`
+	Details   types.JSONText `json:"details"`
+	CreatedAt time.Time      `json:"created_at" db:"created_at"`
+}
+
+func NewEvent(r *http.Request, actorId int, action string, userId int, details map[string]interface{}) Event {
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	encodedDetails, _ := json.Marshal(details)
+
+	return Event{
+		ActorId:   actorId,
+		UserId:    userId,
+		Action:    action,
+		IPAddress: request.ClientIP(r),
+		Details:   types.JSONText(encodedDetails),
+	}
+}
+
+type Logger interface {
+	Record(event Event) error
+}
+
+type auditLogger struct {
+	db *sqlx.DB
+}
+
+func GetLogger() *auditLogger {
+	logger := auditLogger{appcontext.GetDB()}
+	return &logger
+}
+
+func (logger *auditLogger) Record(event Event) error {
+	stmt, err := logger.db.Preparex(INSERT_AUDIT_EVENT_QUERY)
+	if err != nil {
+		return err
+	}
+	_, err = stmt.Exec(event.ActorId, event.UserId, event.Action, event.IPAddress, event.Details)
+	return err
+}
@@ -0,0 +1,23 @@
+package audit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewEvent(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/users/2/verify", nil)
+
+	event := NewEvent(req, 1, "admin.user.verify", 2, map[string]interface{}{"email": "user@example.com"})
+	assert.Equal(t, 1, event.ActorId)
+	assert.Equal(t, 2, event.UserId)
+	assert.Equal(t, "admin.user.verify", event.Action)
+	assert.Equal(t, "192.0.2.1", event.IPAddress)
+	assert.JSONEq(t, `{"email": "user@example.com"}`, event.Details.String())
+
+	event = NewEvent(req, 1, "admin.user.list", 0, nil)
+	assert.JSONEq(t, `{}`, event.Details.String(), "Event without details should store an empty object")
+}
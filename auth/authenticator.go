@@ -0,0 +1,197 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"userland/config"
+	"userland/ldap"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	errCredentialsRejected = errors.New("Credentials were rejected")
+)
+
+// Authenticator checks passwords against one backend. Either method returns
+// errCredentialsRejected when the backend doesn't know the user or the
+// password doesn't match.
+type Authenticator interface {
+	// authenticate checks the password of the user logging in with the email
+	// address and returns the user.
+	authenticate(tenantId int, email string, password string) (*User, error)
+	// verify checks the password of a user who's already logged in.
+	verify(user *User, password string) error
+}
+
+// GetAuthenticator builds the chain of configured authenticators. Unknown
+// ones are logged and left out.
+func GetAuthenticator(userRepo userRepositoryInterface) Authenticator {
+	chain := AuthenticatorChain{}
+	for _, name := range config.GetAuthenticators() {
+		switch name {
+		case config.AUTHENTICATOR_LOCAL:
+			chain = append(chain, LocalAuthenticator{UserRepo: userRepo})
+		case config.AUTHENTICATOR_LDAP:
+			chain = append(chain, LDAPAuthenticator{
+				URL:               config.GetLDAPURL(),
+				BindDN:            config.GetLDAPBindDN(),
+				BindPassword:      config.GetLDAPBindPassword(),
+				BaseDN:            config.GetLDAPBaseDN(),
+				UserFilter:        config.GetLDAPUserFilter(),
+				FullnameAttribute: config.GetLDAPFullnameAttribute(),
+				EmailAttribute:    config.GetLDAPEmailAttribute(),
+				Timeout:           config.GetLDAPTimeout(),
+				UserRepo:          userRepo,
+			})
+		default:
+			log.Warn(fmt.Sprintf("Unknown authenticator %q is ignored", name))
+		}
+	}
+	return chain
+}
+
+// AuthenticatorChain tries its authenticators in order until one of them
+// accepts the password. A failing backend is logged and skipped, so that
+// users of the other backends can still log in.
+type AuthenticatorChain []Authenticator
+
+func (chain AuthenticatorChain) authenticate(tenantId int, email string, password string) (*User, error) {
+	err := errCredentialsRejected
+	for _, authenticator := range chain {
+		var user *User
+		user, err = authenticator.authenticate(tenantId, email, password)
+		if err == nil {
+			return user, nil
+		}
+		if err != errCredentialsRejected {
+			log.Warn(err)
+		}
+	}
+	return nil, err
+}
+
+func (chain AuthenticatorChain) verify(user *User, password string) error {
+	err := errCredentialsRejected
+	for _, authenticator := range chain {
+		err = authenticator.verify(user, password)
+		if err == nil {
+			return nil
+		}
+		if err != errCredentialsRejected {
+			log.Warn(err)
+		}
+	}
+	return err
+}
+
+// LocalAuthenticator checks passwords against the bcrypt hashes of the user
+// table.
+type LocalAuthenticator struct {
+	UserRepo userRepositoryInterface
+}
+
+func (authenticator LocalAuthenticator) authenticate(tenantId int, email string, password string) (*User, error) {
+	err := authenticator.UserRepo.loginUser(tenantId, email, password)
+	if err == sql.ErrNoRows || err == bcrypt.ErrMismatchedHashAndPassword {
+		return nil, errCredentialsRejected
+	}
+	if err != nil {
+		return nil, err
+	}
+	return authenticator.UserRepo.getUserByEmail(tenantId, email)
+}
+
+func (authenticator LocalAuthenticator) verify(user *User, password string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return errCredentialsRejected
+	}
+	return err
+}
+
+// LDAPAuthenticator checks passwords by binding to a directory as the user,
+// found by the email address through the user filter. Users logging in for
+// the first time are created on the fly from the directory's attributes, and
+// their name is kept in sync on later logins. Accounts the directory didn't
+// create are never taken over, even when the email address matches.
+type LDAPAuthenticator struct {
+	URL               string
+	BindDN            string
+	BindPassword      string
+	BaseDN            string
+	UserFilter        string
+	FullnameAttribute string
+	EmailAttribute    string
+	Timeout           time.Duration
+	UserRepo          userRepositoryInterface
+}
+
+func (authenticator LDAPAuthenticator) authenticate(tenantId int, email string, password string) (*User, error) {
+	entry, err := authenticator.bind(email, password)
+	if err != nil {
+		return nil, err
+	}
+
+	directoryEmail := strings.ToLower(strings.TrimSpace(entry.Attribute(authenticator.EmailAttribute)))
+	if !emailFormat.MatchString(directoryEmail) {
+		directoryEmail = strings.ToLower(email)
+	}
+	fullname := strings.TrimSpace(entry.Attribute(authenticator.FullnameAttribute))
+	if fullname == "" || len(fullname) > 128 {
+		fullname = directoryEmail
+	}
+
+	user, err := authenticator.UserRepo.upsertDirectoryUser(tenantId, entry.DN, fullname, directoryEmail)
+	if err == sql.ErrNoRows {
+		log.Info("Directory entry matches an account it didn't create: " + entry.DN)
+		return nil, errCredentialsRejected
+	}
+	return user, err
+}
+
+func (authenticator LDAPAuthenticator) verify(user *User, password string) error {
+	_, err := authenticator.bind(user.Email, password)
+	return err
+}
+
+// bind finds the user's entry and binds to it with the password, returning
+// the entry once the password is accepted.
+func (authenticator LDAPAuthenticator) bind(email string, password string) (*ldap.Entry, error) {
+	if password == "" {
+		return nil, errCredentialsRejected
+	}
+
+	conn, err := ldap.Dial(authenticator.URL, authenticator.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	err = conn.Bind(authenticator.BindDN, authenticator.BindPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := fmt.Sprintf(authenticator.UserFilter, ldap.EscapeFilter(email))
+	entries, err := conn.Search(authenticator.BaseDN, filter, []string{authenticator.FullnameAttribute, authenticator.EmailAttribute})
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) != 1 {
+		return nil, errCredentialsRejected
+	}
+
+	err = conn.Bind(entries[0].DN, password)
+	if ldap.IsInvalidCredentials(err) {
+		return nil, errCredentialsRejected
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entries[0], nil
+}
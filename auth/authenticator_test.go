@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"userland/ldap/ldaptest"
+	"userland/tenancy"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	SAMPLE_LDAP_SERVICE_DN       = "cn=userland,ou=services,dc=example,dc=com"
+	SAMPLE_LDAP_SERVICE_PASSWORD = "servicepassword"
+	SAMPLE_LDAP_USER_DN          = "uid=jane,ou=people,dc=example,dc=com"
+	SAMPLE_LDAP_USER_PASSWORD    = "janepassword"
+)
+
+var (
+	directoryUser = User{Id: 42, Fullname: "Jane Doe", Email: "jane@example.com", Verified: true, TenantId: tenancy.DEFAULT_TENANT_ID, DirectoryDN: sql.NullString{String: SAMPLE_LDAP_USER_DN, Valid: true}}
+)
+
+func newDirectory() *ldaptest.Server {
+	return ldaptest.NewServer(
+		ldaptest.Entry{DN: SAMPLE_LDAP_SERVICE_DN, Password: SAMPLE_LDAP_SERVICE_PASSWORD},
+		ldaptest.Entry{
+			DN:         SAMPLE_LDAP_USER_DN,
+			Password:   SAMPLE_LDAP_USER_PASSWORD,
+			Attributes: map[string][]string{"uid": {"jane"}, "cn": {"Jane Doe"}, "mail": {"Jane@Example.com"}},
+		},
+	)
+}
+
+func newLDAPAuthenticator(directory *ldaptest.Server) LDAPAuthenticator {
+	return LDAPAuthenticator{
+		URL:               directory.URL,
+		BindDN:            SAMPLE_LDAP_SERVICE_DN,
+		BindPassword:      SAMPLE_LDAP_SERVICE_PASSWORD,
+		BaseDN:            "dc=example,dc=com",
+		UserFilter:        "(|(uid=%[1]s)(mail=%[1]s))",
+		FullnameAttribute: "cn",
+		EmailAttribute:    "mail",
+		Timeout:           5 * time.Second,
+		UserRepo:          mockRepo,
+	}
+}
+
+func TestLDAPAuthenticator(t *testing.T) {
+	testAuthHandlerInit(t)
+	directory := newDirectory()
+	defer directory.Close()
+	authenticator := newLDAPAuthenticator(directory)
+
+	mockRepo.EXPECT().upsertDirectoryUser(tenancy.DEFAULT_TENANT_ID, SAMPLE_LDAP_USER_DN, "Jane Doe", "jane@example.com").Return(&directoryUser, nil)
+	user, err := authenticator.authenticate(tenancy.DEFAULT_TENANT_ID, "jane", SAMPLE_LDAP_USER_PASSWORD)
+	require.Nil(t, err, "User should be created from the directory's attributes")
+	assert.Equal(t, directoryUser.Id, user.Id)
+	assert.Equal(t, []string{SAMPLE_LDAP_SERVICE_DN, SAMPLE_LDAP_USER_DN}, directory.Binds())
+
+	_, err = authenticator.authenticate(tenancy.DEFAULT_TENANT_ID, "jane", "wrongpassword")
+	assert.Equal(t, errCredentialsRejected, err)
+	_, err = authenticator.authenticate(tenancy.DEFAULT_TENANT_ID, "john@example.com", SAMPLE_LDAP_USER_PASSWORD)
+	assert.Equal(t, errCredentialsRejected, err, "User missing from the directory should be rejected")
+	_, err = authenticator.authenticate(tenancy.DEFAULT_TENANT_ID, "*", SAMPLE_LDAP_USER_PASSWORD)
+	assert.Equal(t, errCredentialsRejected, err, "Login should be escaped in the filter")
+	_, err = authenticator.authenticate(tenancy.DEFAULT_TENANT_ID, "jane", "")
+	assert.Equal(t, errCredentialsRejected, err)
+
+	mockRepo.EXPECT().upsertDirectoryUser(tenancy.DEFAULT_TENANT_ID, SAMPLE_LDAP_USER_DN, "Jane Doe", "jane@example.com").Return(nil, sql.ErrNoRows)
+	_, err = authenticator.authenticate(tenancy.DEFAULT_TENANT_ID, "jane", SAMPLE_LDAP_USER_PASSWORD)
+	assert.Equal(t, errCredentialsRejected, err, "Local account with the directory's email address shouldn't be taken over")
+
+	assert.Nil(t, authenticator.verify(&directoryUser, SAMPLE_LDAP_USER_PASSWORD))
+	assert.Equal(t, errCredentialsRejected, authenticator.verify(&directoryUser, "wrongpassword"))
+
+	authenticator.BindPassword = "wrongpassword"
+	_, err = authenticator.authenticate(tenancy.DEFAULT_TENANT_ID, "jane", SAMPLE_LDAP_USER_PASSWORD)
+	assert.NotNil(t, err)
+	assert.NotEqual(t, errCredentialsRejected, err, "Misconfigured service account should be told apart from bad credentials")
+
+	testAuthHandlerEnd()
+}
+
+func TestLocalAuthenticator(t *testing.T) {
+	testAuthHandlerInit(t)
+	authenticator := LocalAuthenticator{UserRepo: mockRepo}
+
+	gomock.InOrder(
+		mockRepo.EXPECT().loginUser(tenancy.DEFAULT_TENANT_ID, "local@example.com", "localpassword").Return(nil),
+		mockRepo.EXPECT().getUserByEmail(tenancy.DEFAULT_TENANT_ID, "local@example.com").Return(&directoryUser, nil),
+		mockRepo.EXPECT().loginUser(tenancy.DEFAULT_TENANT_ID, "local@example.com", "wrongpassword").Return(bcrypt.ErrMismatchedHashAndPassword),
+		mockRepo.EXPECT().loginUser(tenancy.DEFAULT_TENANT_ID, "nobody@example.com", "localpassword").Return(sql.ErrNoRows),
+		mockRepo.EXPECT().loginUser(tenancy.DEFAULT_TENANT_ID, "local@example.com", "localpassword").Return(errors.New("")),
+	)
+	_, err := authenticator.authenticate(tenancy.DEFAULT_TENANT_ID, "local@example.com", "localpassword")
+	assert.Nil(t, err)
+	_, err = authenticator.authenticate(tenancy.DEFAULT_TENANT_ID, "local@example.com", "wrongpassword")
+	assert.Equal(t, errCredentialsRejected, err)
+	_, err = authenticator.authenticate(tenancy.DEFAULT_TENANT_ID, "nobody@example.com", "localpassword")
+	assert.Equal(t, errCredentialsRejected, err, "Unknown user should be rejected like a wrong password")
+	_, err = authenticator.authenticate(tenancy.DEFAULT_TENANT_ID, "local@example.com", "localpassword")
+	assert.NotEqual(t, errCredentialsRejected, err, "Failing database should be told apart from bad credentials")
+
+	testAuthHandlerEnd()
+}
+
+func TestAuthenticatorChain(t *testing.T) {
+	testAuthHandlerInit(t)
+	directory := newDirectory()
+	defer directory.Close()
+	unreachable := newLDAPAuthenticator(directory)
+	unreachable.URL = "ldap://127.0.0.1:1"
+	chain := AuthenticatorChain{unreachable, LocalAuthenticator{UserRepo: mockRepo}, newLDAPAuthenticator(directory)}
+
+	gomock.InOrder(
+		mockRepo.EXPECT().loginUser(tenancy.DEFAULT_TENANT_ID, "jane@example.com", SAMPLE_LDAP_USER_PASSWORD).Return(bcrypt.ErrMismatchedHashAndPassword),
+		mockRepo.EXPECT().upsertDirectoryUser(tenancy.DEFAULT_TENANT_ID, SAMPLE_LDAP_USER_DN, "Jane Doe", "jane@example.com").Return(&directoryUser, nil),
+		mockRepo.EXPECT().loginUser(tenancy.DEFAULT_TENANT_ID, "jane@example.com", "wrongpassword").Return(bcrypt.ErrMismatchedHashAndPassword),
+	)
+	user, err := chain.authenticate(tenancy.DEFAULT_TENANT_ID, "jane@example.com", SAMPLE_LDAP_USER_PASSWORD)
+	require.Nil(t, err, "Failing backend should be skipped")
+	assert.Equal(t, directoryUser.Id, user.Id)
+
+	_, err = chain.authenticate(tenancy.DEFAULT_TENANT_ID, "jane@example.com", "wrongpassword")
+	assert.Equal(t, errCredentialsRejected, err)
+
+	passwordHash, _ := bcrypt.GenerateFromPassword([]byte("localpassword"), bcrypt.MinCost)
+	localUser := User{Email: "local@example.com", Password: string(passwordHash)}
+	assert.Nil(t, chain.verify(&localUser, "localpassword"))
+	assert.Nil(t, chain.verify(&directoryUser, SAMPLE_LDAP_USER_PASSWORD))
+	assert.Equal(t, errCredentialsRejected, chain.verify(&localUser, "wrongpassword"))
+
+	testAuthHandlerEnd()
+}
+
+func TestLoginWithLDAP(t *testing.T) {
+	testAuthHandlerInit(t)
+	directory := newDirectory()
+	defer directory.Close()
+
+	ldapHandler := handler
+	ldapHandler.Authenticator = AuthenticatorChain{LocalAuthenticator{UserRepo: mockRepo}, newLDAPAuthenticator(directory)}
+
+	gomock.InOrder(
+		mockRepo.EXPECT().loginUser(tenancy.DEFAULT_TENANT_ID, "jane", SAMPLE_LDAP_USER_PASSWORD).Return(sql.ErrNoRows),
+		mockRepo.EXPECT().upsertDirectoryUser(tenancy.DEFAULT_TENANT_ID, SAMPLE_LDAP_USER_DN, "Jane Doe", "jane@example.com").Return(&directoryUser, nil),
+		mockWebAuthnRepo.EXPECT().getCredentialsByUserId(tenancy.DEFAULT_TENANT_ID, directoryUser.Id).Return([]WebAuthnCredential{}, nil),
+		expectKnownDevice(&directoryUser),
+	)
+
+	body, err := json.Marshal(map[string]string{"email": "jane", "password": SAMPLE_LDAP_USER_PASSWORD})
+	require.Nil(t, err)
+	req, err := http.NewRequest(http.MethodPost, "/auth/login", bytes.NewReader(body))
+	require.Nil(t, err)
+	res := httptest.NewRecorder()
+	ldapHandler.Login(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	testAuthHandlerEnd()
+}
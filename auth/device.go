@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+	"userland/config"
+	"userland/mailer"
+	"userland/request"
+	"userland/tenancy"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	USER_AGENT_MAX_LENGTH = 255
+
+	// DEVICE_REPORT_TOKEN_LIFETIME_DAYS is how long the link of a new device
+	// notification can be used to report the device.
+	DEVICE_REPORT_TOKEN_LIFETIME_DAYS = 7
+)
+
+func deviceFingerprint(userAgent string, ipAddress string) string {
+	hash := sha256.Sum256([]byte(userAgent + "|" + ipAddress))
+	return hex.EncodeToString(hash[:])
+}
+
+func clientUserAgent(r *http.Request) string {
+	userAgent := r.UserAgent()
+	if len(userAgent) > USER_AGENT_MAX_LENGTH {
+		return userAgent[:USER_AGENT_MAX_LENGTH]
+	}
+	return userAgent
+}
+
+// recognizeDevice records the device a user is signing in from. Signing in from
+// a device that isn't known yet sends a notification with a link to revoke
+// every session, unless it is the first device the user has ever used.
+// Failures are only logged so they never block a login.
+func (handler AuthHandler) recognizeDevice(r *http.Request, user *User) {
+	userAgent, ipAddress := clientUserAgent(r), request.ClientIP(r)
+	fingerprint := deviceFingerprint(userAgent, ipAddress)
+
+	devices, err := handler.DeviceRepo.getUserDevices(user)
+	if err != nil {
+		log.Warn(err)
+		return
+	}
+
+	for _, device := range devices {
+		if device.Fingerprint == fingerprint {
+			err = handler.DeviceRepo.updateDeviceLastSeen(tenancy.FromRequest(r).Id, device, ipAddress)
+			if err != nil {
+				log.Warn(err)
+			}
+			return
+		}
+	}
+
+	reportToken, err := generateToken()
+	if err != nil {
+		log.Warn(err)
+		return
+	}
+	device := UserDevice{
+		Fingerprint: fingerprint,
+		UserAgent:   userAgent,
+		IPAddress:   ipAddress,
+		ReportToken: reportToken,
+	}
+	err = handler.DeviceRepo.createUserDevice(user, device)
+	if err != nil {
+		log.Warn(err)
+		return
+	}
+
+	if len(devices) == 0 {
+		return
+	}
+
+	log.Info("User signed in from a new device")
+	err = tenancy.FromRequest(r).Templates().Send(handler.Mailer, user.Email, mailer.NEW_DEVICE_TEMPLATE, map[string]interface{}{
+		"Fullname":  user.Fullname,
+		"UserAgent": userAgent,
+		"IPAddress": ipAddress,
+		"Time":      time.Now().UTC().Format(time.RFC1123),
+		"Link":      fmt.Sprintf("%s/devices/report?token=%s", config.GetAppURL(), url.QueryEscape(device.ReportToken)),
+	})
+	if err != nil {
+		log.Warn(err)
+	}
+}
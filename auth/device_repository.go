@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"userland/appcontext"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	SELECT_USER_DEVICES_BY_USER_ID_QUERY     = "SELECT * FROM user_device WHERE user_id=$1 AND EXISTS (SELECT 1 FROM \"user\" WHERE id=$1 AND tenant_id=$2)"
+	CREATE_USER_DEVICE_QUERY                 = "INSERT INTO user_device (user_id, fingerprint, user_agent, ip_address, report_token, report_token_expires_at) SELECT $1, $2, $3, $4, $5, now() + $6 * interval '1 day' WHERE EXISTS (SELECT 1 FROM \"user\" WHERE id=$1 AND tenant_id=$7)"
+	UPDATE_USER_DEVICE_LAST_SEEN_QUERY       = "UPDATE user_device SET last_seen_at=now(), ip_address=$1 WHERE id=$2 AND EXISTS (SELECT 1 FROM \"user\" WHERE id=user_device.user_id AND tenant_id=$3)"
+	DELETE_USER_DEVICE_BY_REPORT_TOKEN_QUERY = "DELETE FROM user_device USING \"user\" WHERE \"user\".id=user_device.user_id AND user_device.report_token=$1 AND \"user\".tenant_id=$2 AND user_device.report_token_expires_at > now() RETURNING user_device.user_id"
+	LOCK_OUT_USER_QUERY                      = "UPDATE \"user\" SET token_version=token_version+1, password_reset_required=true, reset_password_token=$1 WHERE id=$2 AND tenant_id=$3 RETURNING *"
+)
+
+type deviceRepositoryInterface interface {
+	getUserDevices(user *User) ([]UserDevice, error)
+	createUserDevice(user *User, device UserDevice) error
+	updateDeviceLastSeen(tenantId int, device UserDevice, ipAddress string) error
+	reportDevice(tenantId int, reportToken string) (*User, error)
+}
+
+type deviceRepository struct {
+	db *sqlx.DB
+}
+
+func GetDeviceRepository() *deviceRepository {
+	repo := deviceRepository{appcontext.GetDB()}
+	return &repo
+}
+
+func (repo *deviceRepository) getUserDevices(user *User) ([]UserDevice, error) {
+	devices := []UserDevice{}
+	err := repo.db.Select(&devices, SELECT_USER_DEVICES_BY_USER_ID_QUERY, user.Id, user.TenantId)
+	return devices, err
+}
+
+func (repo *deviceRepository) createUserDevice(user *User, device UserDevice) error {
+	stmt, err := repo.db.Preparex(CREATE_USER_DEVICE_QUERY)
+	if err != nil {
+		return err
+	}
+	_, err = stmt.Exec(user.Id, device.Fingerprint, device.UserAgent, device.IPAddress, device.ReportToken, DEVICE_REPORT_TOKEN_LIFETIME_DAYS, user.TenantId)
+	return err
+}
+
+func (repo *deviceRepository) updateDeviceLastSeen(tenantId int, device UserDevice, ipAddress string) error {
+	stmt, err := repo.db.Preparex(UPDATE_USER_DEVICE_LAST_SEEN_QUERY)
+	if err != nil {
+		return err
+	}
+	_, err = stmt.Exec(ipAddress, device.Id, tenantId)
+	return err
+}
+
+// reportDevice forgets a device its owner didn't recognize, revokes every
+// session of the owner and requires a password reset before the next login.
+// Expired report tokens and those of other tenants match no device. The
+// returned user carries the new reset password token.
+func (repo *deviceRepository) reportDevice(tenantId int, reportToken string) (*User, error) {
+	tx, err := repo.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var userId int
+	err = tx.Get(&userId, DELETE_USER_DEVICE_BY_REPORT_TOKEN_QUERY, reportToken, tenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	resetToken, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+	var user User
+	err = tx.Get(&user, LOCK_OUT_USER_QUERY, resetToken, userId, tenantId)
+	if err != nil {
+		return nil, err
+	}
+	return &user, tx.Commit()
+}
@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"userland/tenancy"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	SAMPLE_USER_AGENT = "Mozilla/5.0 (X11; Linux x86_64)"
+	SAMPLE_IP_ADDRESS = "192.0.2.1"
+)
+
+var (
+	deviceUser = User{
+		Id:       1,
+		Fullname: "user",
+		Email:    "user@example.com",
+		Verified: true,
+	}
+)
+
+func newDeviceRequest() *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", nil)
+	req.Header.Set("User-Agent", SAMPLE_USER_AGENT)
+	return req
+}
+
+func TestDeviceFingerprint(t *testing.T) {
+	fingerprint := deviceFingerprint(SAMPLE_USER_AGENT, SAMPLE_IP_ADDRESS)
+	assert.Len(t, fingerprint, 64)
+	assert.Equal(t, fingerprint, deviceFingerprint(SAMPLE_USER_AGENT, SAMPLE_IP_ADDRESS))
+	assert.NotEqual(t, fingerprint, deviceFingerprint(SAMPLE_USER_AGENT, "198.51.100.1"), "Fingerprint should change with the IP address")
+	assert.NotEqual(t, fingerprint, deviceFingerprint("curl/7.64.0", SAMPLE_IP_ADDRESS), "Fingerprint should change with the user agent")
+}
+
+func TestRecognizeKnownDevice(t *testing.T) {
+	testAuthHandlerInit(t)
+
+	knownDevice := UserDevice{Id: 1, Fingerprint: deviceFingerprint(SAMPLE_USER_AGENT, SAMPLE_IP_ADDRESS)}
+	gomock.InOrder(
+		mockDeviceRepo.EXPECT().getUserDevices(&deviceUser).Return([]UserDevice{knownDevice}, nil),
+		mockDeviceRepo.EXPECT().updateDeviceLastSeen(tenancy.DEFAULT_TENANT_ID, knownDevice, SAMPLE_IP_ADDRESS).Return(nil),
+	)
+	handler.recognizeDevice(newDeviceRequest(), &deviceUser)
+
+	testAuthHandlerEnd()
+}
+
+func TestRecognizeFirstDevice(t *testing.T) {
+	testAuthHandlerInit(t)
+
+	gomock.InOrder(
+		mockDeviceRepo.EXPECT().getUserDevices(&deviceUser).Return([]UserDevice{}, nil),
+		mockDeviceRepo.EXPECT().createUserDevice(&deviceUser, gomock.Any()).Return(nil),
+	)
+	mockMailer.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+	handler.recognizeDevice(newDeviceRequest(), &deviceUser)
+
+	testAuthHandlerEnd()
+}
+
+func TestRecognizeNewDevice(t *testing.T) {
+	testAuthHandlerInit(t)
+
+	otherDevice := UserDevice{Id: 1, Fingerprint: deviceFingerprint("curl/7.64.0", SAMPLE_IP_ADDRESS)}
+	gomock.InOrder(
+		mockDeviceRepo.EXPECT().getUserDevices(&deviceUser).Return([]UserDevice{otherDevice}, nil),
+		mockDeviceRepo.EXPECT().createUserDevice(&deviceUser, gomock.Any()).DoAndReturn(func(user *User, device UserDevice) error {
+			assert.Equal(t, deviceFingerprint(SAMPLE_USER_AGENT, SAMPLE_IP_ADDRESS), device.Fingerprint)
+			assert.Equal(t, SAMPLE_USER_AGENT, device.UserAgent)
+			assert.Equal(t, SAMPLE_IP_ADDRESS, device.IPAddress)
+			assert.Len(t, device.ReportToken, TOKEN_LENGTH)
+			return nil
+		}),
+		mockMailer.EXPECT().Send(deviceUser.Email, gomock.Any(), gomock.Any()).Return(nil),
+	)
+	handler.recognizeDevice(newDeviceRequest(), &deviceUser)
+
+	testAuthHandlerEnd()
+}
+
+func TestRecognizeDeviceFailureDoesntBlockLogin(t *testing.T) {
+	testAuthHandlerInit(t)
+
+	gomock.InOrder(
+		mockWebAuthnRepo.EXPECT().getCredentialsByUserId(tenancy.DEFAULT_TENANT_ID, deviceUser.Id).Return([]WebAuthnCredential{}, nil),
+		mockDeviceRepo.EXPECT().getUserDevices(&deviceUser).Return(nil, errors.New("")),
+	)
+	res := httptest.NewRecorder()
+	handler.completeFirstFactor(res, newDeviceRequest(), &deviceUser)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.NotNil(t, findCookie(res.Result().Cookies(), "token"))
+
+	testAuthHandlerEnd()
+}
+
+func TestLoginRequiresPasswordReset(t *testing.T) {
+	testAuthHandlerInit(t)
+
+	lockedOutUser := User{
+		Email:                 "user@example.com",
+		Password:              "password",
+		Verified:              true,
+		PasswordResetRequired: true,
+	}
+	gomock.InOrder(
+		mockRepo.EXPECT().loginUser(tenancy.DEFAULT_TENANT_ID, lockedOutUser.Email, lockedOutUser.Password).Return(nil),
+		mockRepo.EXPECT().getUserByEmail(tenancy.DEFAULT_TENANT_ID, lockedOutUser.Email).Return(&lockedOutUser, nil),
+	)
+	testLoginUser(t, lockedOutUser, http.StatusUnauthorized)
+
+	testAuthHandlerEnd()
+}
+
+func TestReportDevice(t *testing.T) {
+	testAuthHandlerInit(t)
+
+	gomock.InOrder(
+		mockDeviceRepo.EXPECT().reportDevice(tenancy.DEFAULT_TENANT_ID, SAMPLE_VALID_VERIFICATION_TOKEN).Return(&deviceUser, nil),
+		mockMailer.EXPECT().Send(deviceUser.Email, gomock.Any(), gomock.Any()).Return(nil),
+		mockDeviceRepo.EXPECT().reportDevice(tenancy.DEFAULT_TENANT_ID, SAMPLE_INVALID_VERIFICATION_TOKEN).Return(nil, errors.New("")),
+	)
+
+	assert.Equal(t, http.StatusOK, serveWithCookies(t, http.MethodPost, "/auth/device/report", deviceReportRequest{Token: SAMPLE_VALID_VERIFICATION_TOKEN}, nil).Code)
+	assert.Equal(t, http.StatusBadRequest, serveWithCookies(t, http.MethodPost, "/auth/device/report", deviceReportRequest{Token: SAMPLE_INVALID_VERIFICATION_TOKEN}, nil).Code)
+	assert.Equal(t, http.StatusBadRequest, serveWithCookies(t, http.MethodPost, "/auth/device/report", deviceReportRequest{}, nil).Code)
+
+	testAuthHandlerEnd()
+}
+
+func TestReportDeviceOfAnotherTenant(t *testing.T) {
+	testAuthHandlerInit(t)
+	otherTenant := tenancy.Tenant{Id: 2, Slug: "other"}
+
+	mockDeviceRepo.EXPECT().reportDevice(otherTenant.Id, SAMPLE_VALID_VERIFICATION_TOKEN).Return(nil, sql.ErrNoRows)
+	reqBody, err := json.Marshal(deviceReportRequest{Token: SAMPLE_VALID_VERIFICATION_TOKEN})
+	require.Nil(t, err)
+	req, err := http.NewRequest(http.MethodPost, "/auth/device/report", bytes.NewReader(reqBody))
+	require.Nil(t, err)
+	req = req.WithContext(context.WithValue(req.Context(), "tenant", &otherTenant))
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+
+	testAuthHandlerEnd()
+}
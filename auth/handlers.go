@@ -1,20 +1,66 @@
 package auth
 
 import (
+	"database/sql"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
+	"userland/audit"
+	"userland/config"
 	ulanderrors "userland/errors"
+	"userland/mailer"
+	"userland/ratelimit"
 	"userland/request"
 	"userland/response"
+	"userland/tenancy"
+	"userland/webauthn"
 
 	log "github.com/sirupsen/logrus"
 )
 
 var err error
 
+const (
+	EMAIL_LOGIN_TOKEN_LIFETIME_MINUTES = 15
+	EMAIL_LOGIN_REQUEST_LIMIT          = 3
+	EMAIL_LOGIN_ATTEMPT_LIMIT          = 5
+	EMAIL_LOGIN_LIMIT_PERIOD           = 15 * time.Minute
+
+	TFA_SESSION_LIFETIME = 5 * time.Minute
+
+	REAUTH_ATTEMPT_LIMIT = 5
+	REAUTH_LIMIT_PERIOD  = 15 * time.Minute
+
+	USERNAME_AVAILABILITY_LIMIT        = 30
+	USERNAME_AVAILABILITY_LIMIT_PERIOD = time.Minute
+)
+
 type AuthHandler struct {
-	UserRepo userRepositoryInterface
+	UserRepo                 userRepositoryInterface
+	WebAuthnRepo             webauthnRepositoryInterface
+	DeviceRepo               deviceRepositoryInterface
+	RoleRepo                 roleRepositoryInterface
+	RelyingParty             webauthn.RelyingParty
+	Mailer                   mailer.Mailer
+	AuditLogger              audit.Logger
+	EmailLoginRequestLimiter *ratelimit.Limiter
+	EmailLoginAttemptLimiter *ratelimit.Limiter
+	ReauthAttemptLimiter     *ratelimit.Limiter
+	UsernameCheckLimiter     *ratelimit.Limiter
+	RegistrationPolicy       RegistrationPolicy
+	Authenticator            Authenticator
+}
+
+// authenticator returns the configured authenticator, checking the local
+// password hashes when there's none.
+func (handler AuthHandler) authenticator() Authenticator {
+	if handler.Authenticator == nil {
+		return LocalAuthenticator{UserRepo: handler.UserRepo}
+	}
+	return handler.Authenticator
 }
 
 func (handler AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
@@ -45,8 +91,25 @@ func (handler AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = handler.UserRepo.createNewUser(userRegistrationData)
+	tenant := tenancy.FromRequest(r)
+	policy := handler.RegistrationPolicy.forTenant(tenant)
+	if refusal, refused := policy.refusal(userRegistrationData); refused {
+		log.Info("User registration is refused by the registration policy")
+		response.RespondForbidden(w, refusal)
+		return
+	}
+
+	if policy.requiresInvitation() {
+		err = handler.UserRepo.createInvitedUser(tenant.Id, userRegistrationData)
+	} else {
+		err = handler.UserRepo.createNewUser(tenant.Id, userRegistrationData)
+	}
 
+	if err == errRegistrationInvitationInvalid {
+		log.Info(err)
+		response.RespondForbidden(w, ulanderrors.ErrRegistrationInvitationInvalid)
+		return
+	}
 	if err != nil {
 		log.Warn(err)
 		response.RespondBadRequest(w, ulanderrors.ErrRegistrationQueryExec)
@@ -73,7 +136,7 @@ func (handler AuthHandler) Verify(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = handler.UserRepo.verifyUser(verifReq.Recipient, verifReq.VerificationToken)
+	err = handler.UserRepo.verifyUser(tenancy.FromRequest(r).Id, verifReq.Recipient, verifReq.VerificationToken)
 
 	if err != nil {
 		log.Warn(err)
@@ -101,7 +164,7 @@ func (handler AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = handler.UserRepo.loginUser(loginUser.Email, loginUser.Password)
+	user, err := handler.authenticator().authenticate(tenancy.FromRequest(r).Id, loginUser.Email, loginUser.Password)
 
 	if err != nil {
 		log.Warn(err)
@@ -109,26 +172,189 @@ func (handler AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, _ := handler.UserRepo.getUserByEmail(loginUser.Email)
 	if !user.Verified {
 		log.Info("User hasn't been verified by the system")
 		response.RespondUnauthorized(w, ulanderrors.ErrLoginUnverified)
 		return
 	}
 
+	handler.completeFirstFactor(w, r, user)
+}
+
+func (handler AuthHandler) LoginWithEmail(w http.ResponseWriter, r *http.Request) {
+	var loginReq emailLoginRequest
+	err = request.ParseJSON(r.Body, &loginReq)
+
+	if err != nil {
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrParseBody)
+		return
+	}
+
+	if !loginReq.isValid() {
+		log.Info("Email login request is incomplete")
+		response.RespondBadRequest(w, ulanderrors.ErrEmailLoginIncomplete)
+		return
+	}
+
+	if !handler.EmailLoginRequestLimiter.Allow(strings.ToLower(loginReq.Email)) {
+		log.Info("Email login requests for the address exceeded the limit")
+		response.RespondTooManyRequests(w, ulanderrors.ErrEmailLoginRateLimited)
+		return
+	}
+
+	// Addresses without an account, or whose account can't log in, get the
+	// same answer as the others, so that the endpoint doesn't tell them apart.
+	tenant := tenancy.FromRequest(r)
+	user, err := handler.UserRepo.getUserByEmail(tenant.Id, loginReq.Email)
+	if err == sql.ErrNoRows {
+		log.Info("Email login requested for an unknown address")
+		response.RespondSuccess(w)
+		return
+	}
+	if err != nil {
+		log.Warn(err)
+		response.RespondBadRequest(w, ulanderrors.ErrEmailLoginQueryExec)
+		return
+	}
+
+	if _, locked := user.lockout(); !user.Verified || locked {
+		log.Info("Email login requested for a user who can't log in")
+		response.RespondSuccess(w)
+		return
+	}
+
+	token, code, err := handler.UserRepo.createLoginToken(user)
+	if err != nil {
+		log.Warn(err)
+		response.RespondBadRequest(w, ulanderrors.ErrEmailLoginQueryExec)
+		return
+	}
+
+	err = tenant.Templates().Send(handler.Mailer, user.Email, mailer.EMAIL_LOGIN_TEMPLATE, map[string]interface{}{
+		"Fullname":         user.Fullname,
+		"Link":             fmt.Sprintf("%s/login/email?token=%s", config.GetAppURL(), url.QueryEscape(token)),
+		"Code":             code,
+		"ExpiresInMinutes": EMAIL_LOGIN_TOKEN_LIFETIME_MINUTES,
+	})
+	if err != nil {
+		log.Warn(err)
+		response.RespondInternalError(w, ulanderrors.ErrEmailLoginSendEmail)
+		return
+	}
+
+	log.Info("Email login request successful")
+	response.RespondSuccess(w)
+}
+
+func (handler AuthHandler) ExchangeEmailLogin(w http.ResponseWriter, r *http.Request) {
+	var exchangeReq emailLoginExchangeRequest
+	err = request.ParseJSON(r.Body, &exchangeReq)
+
+	if err != nil {
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrParseBody)
+		return
+	}
+
+	if !exchangeReq.isValid() {
+		log.Info("Email login exchange request is incomplete")
+		response.RespondBadRequest(w, ulanderrors.ErrEmailLoginTokenIncomplete)
+		return
+	}
+
+	tenantId := tenancy.FromRequest(r).Id
+	var user *User
+	if exchangeReq.usesCode() {
+		if !handler.EmailLoginAttemptLimiter.Allow(strings.ToLower(exchangeReq.Email)) {
+			log.Info("Email login code attempts for the address exceeded the limit")
+			response.RespondTooManyRequests(w, ulanderrors.ErrEmailLoginRateLimited)
+			return
+		}
+		user, err = handler.UserRepo.consumeLoginCode(tenantId, exchangeReq.Email, exchangeReq.Code)
+	} else {
+		user, err = handler.UserRepo.consumeLoginToken(tenantId, exchangeReq.Token)
+	}
+
+	if err != nil {
+		log.Warn(err)
+		response.RespondUnauthorized(w, ulanderrors.ErrEmailLoginTokenInvalid)
+		return
+	}
+
+	if !user.Verified {
+		log.Info("User hasn't been verified by the system")
+		response.RespondUnauthorized(w, ulanderrors.ErrLoginUnverified)
+		return
+	}
+
+	handler.completeFirstFactor(w, r, user)
+}
+
+// completeFirstFactor starts the session unless the user has registered
+// passkeys, in which case one of them has to be asserted as a second factor
+// through the WebAuthn login ceremony before the session is issued.
+func (handler AuthHandler) completeFirstFactor(w http.ResponseWriter, r *http.Request, user *User) {
+	if refuseSuspendedUser(w, user) || refusePasswordResetRequired(w, user) {
+		return
+	}
+
+	credentials, err := handler.WebAuthnRepo.getCredentialsByUserId(tenancy.FromRequest(r).Id, user.Id)
+	if err != nil {
+		log.Warn(err)
+		response.RespondInternalError(w, ulanderrors.ErrWebAuthnQueryExec)
+		return
+	}
+
+	if len(credentials) == 0 {
+		handler.startSession(w, r, user)
+		return
+	}
+
+	expirationTime := time.Now().Add(TFA_SESSION_LIFETIME)
+	token, err := generateCeremonyJWT(tenancy.FromRequest(r), CEREMONY_TFA, user.Id, nil, expirationTime)
+	if err != nil {
+		log.Info(err)
+		response.RespondInternalError(w, ulanderrors.ErrLoginJWT)
+		return
+	}
+	setCeremonyCookie(w, TFA_SESSION_COOKIE, token, expirationTime)
+
+	log.Info("Login requires second factor")
+	response.RespondSuccessWithBody(w, map[string]bool{"require_tfa": true})
+}
+
+// refusePasswordResetRequired keeps users who reported a device they didn't
+// recognize from logging in any way until they reset their password, and
+// tells whether it did so.
+func refusePasswordResetRequired(w http.ResponseWriter, user *User) bool {
+	if user.PasswordResetRequired {
+		log.Info("User has to reset their password before logging in")
+		response.RespondUnauthorized(w, ulanderrors.ErrLoginPasswordResetRequired)
+	}
+	return user.PasswordResetRequired
+}
+
+// startSession issues the session cookie for a user who has passed the login checks.
+func (handler AuthHandler) startSession(w http.ResponseWriter, r *http.Request, user *User) {
+	handler.recognizeDevice(r, user)
+
+	roles, err := handler.RoleRepo.getUserRoles(tenancy.FromRequest(r).Id, user.Id)
+	if err != nil {
+		log.Warn(err)
+		response.RespondInternalError(w, ulanderrors.ErrRoleQueryExec)
+		return
+	}
+
 	expirationTime := time.Now().Add(HOURS_IN_DAY * time.Hour)
-	token, err := generateJWT(*user, expirationTime)
+	token, err := generateJWT(tenancy.FromRequest(r), *user, roles, expirationTime)
 	if err != nil {
 		log.Info(err)
 		response.RespondInternalError(w, ulanderrors.ErrLoginJWT)
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:    "token",
-		Value:   token,
-		Expires: expirationTime,
-	})
+	setSessionCookie(w, token, expirationTime)
 
 	log.Info("Login successful")
 	response.RespondSuccessWithBody(w, map[string]bool{"require_tfa": false})
@@ -150,7 +376,7 @@ func (handler AuthHandler) ForgetPassword(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err = handler.UserRepo.forgetPassword(user.Email)
+	err = handler.UserRepo.forgetPassword(tenancy.FromRequest(r).Id, user.Email)
 
 	if err != nil {
 		log.Warn(err)
@@ -190,7 +416,7 @@ func (handler AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = handler.UserRepo.resetPassword(req.Token, req.Password)
+	err = handler.UserRepo.resetPassword(tenancy.FromRequest(r).Id, req.Token, req.Password)
 
 	if err != nil {
 		log.Warn(err)
@@ -201,3 +427,99 @@ func (handler AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request)
 	log.Info("Reset password successful")
 	response.RespondSuccess(w)
 }
+
+// RestoreAccount cancels the deletion of an account during its grace period.
+// Its sessions stay revoked, so the user logs in again afterwards.
+func (handler AuthHandler) RestoreAccount(w http.ResponseWriter, r *http.Request) {
+	var restoreReq restoreAccountRequest
+	err = request.ParseJSON(r.Body, &restoreReq)
+
+	if err != nil {
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrParseBody)
+		return
+	}
+
+	if restoreReq.Token == "" {
+		log.Info("Restore account token is empty")
+		response.RespondBadRequest(w, ulanderrors.ErrAccountRestoreIncomplete)
+		return
+	}
+
+	_, err = handler.UserRepo.restoreUser(tenancy.FromRequest(r).Id, restoreReq.Token)
+	if err != nil {
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrAccountRestoreQueryExec)
+		return
+	}
+
+	log.Info("Restore account successful")
+	response.RespondSuccess(w)
+}
+
+func (handler AuthHandler) ReportDevice(w http.ResponseWriter, r *http.Request) {
+	var reportReq deviceReportRequest
+	err = request.ParseJSON(r.Body, &reportReq)
+
+	if err != nil {
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrParseBody)
+		return
+	}
+
+	if reportReq.Token == "" {
+		log.Info("Device report token is empty")
+		response.RespondBadRequest(w, ulanderrors.ErrDeviceReportIncomplete)
+		return
+	}
+
+	user, err := handler.DeviceRepo.reportDevice(tenancy.FromRequest(r).Id, reportReq.Token)
+	if err != nil {
+		log.Warn(err)
+		response.RespondBadRequest(w, ulanderrors.ErrDeviceReportQueryExec)
+		return
+	}
+
+	err = tenancy.FromRequest(r).Templates().Send(handler.Mailer, user.Email, mailer.PASSWORD_RESET_REQUIRED_TEMPLATE, map[string]interface{}{
+		"Fullname": user.Fullname,
+		"Link":     fmt.Sprintf("%s/password/reset?token=%s", config.GetAppURL(), url.QueryEscape(user.ResetPasswordToken.String)),
+	})
+	if err != nil {
+		log.Warn(err)
+	}
+
+	log.Info("Device report successful, all sessions revoked")
+	response.RespondSuccess(w)
+}
+
+// CheckUsernameAvailability tells whether a username can be taken. It is
+// limited by client address, so that it can't be used to list usernames.
+func (handler AuthHandler) CheckUsernameAvailability(w http.ResponseWriter, r *http.Request) {
+	if !handler.UsernameCheckLimiter.Allow(request.ClientIP(r)) {
+		log.Info("Username checks from the address exceeded the limit")
+		response.RespondTooManyRequests(w, ulanderrors.ErrUsernameAvailabilityRateLimited)
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	refusal, refused := UsernameRefusal(name)
+	if refused && refusal.Code == ulanderrors.USERNAME_INVALID {
+		log.Info("Username to check is invalid")
+		response.RespondBadRequest(w, refusal)
+		return
+	}
+
+	availability := usernameAvailability{Name: name}
+	if !refused {
+		taken, err := handler.UserRepo.isUsernameTaken(tenancy.FromRequest(r).Id, name)
+		if err != nil {
+			log.Warn(err)
+			response.RespondInternalError(w, ulanderrors.ErrUsernameQueryExec)
+			return
+		}
+		availability.Available = !taken
+	}
+
+	log.Info("Check username availability successful")
+	response.RespondSuccessWithBody(w, availability)
+}
@@ -2,11 +2,22 @@ package auth
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
+	"userland/audit"
+	"userland/config"
+	ulanderrors "userland/errors"
+	"userland/mailer"
+	"userland/ratelimit"
+	"userland/tenancy"
+	"userland/webauthn"
 
 	"github.com/golang/mock/gomock"
 	"github.com/gorilla/mux"
@@ -18,8 +29,13 @@ var (
 	handler AuthHandler
 	router  *mux.Router
 
-	ctrl     *gomock.Controller
-	mockRepo *MockuserRepositoryInterface
+	ctrl             *gomock.Controller
+	mockRepo         *MockuserRepositoryInterface
+	mockWebAuthnRepo *MockwebauthnRepositoryInterface
+	mockDeviceRepo   *MockdeviceRepositoryInterface
+	mockRoleRepo     *MockroleRepositoryInterface
+	mockMailer       *mailer.MockMailer
+	mockAuditLogger  *audit.MockLogger
 
 	validNewUser          userRegistration
 	invalidNewUser        userRegistration
@@ -43,18 +59,73 @@ var (
 	invalidResetPassReq        resetPasswordRequest
 	invalidPassResetPassReq    resetPasswordRequest
 	unmatchingPassResetPassReq resetPasswordRequest
+
+	validEmailLoginReq      emailLoginRequest
+	incompleteEmailLoginReq emailLoginRequest
+	unverifiedEmailLoginReq emailLoginRequest
+
+	validTokenExchangeReq  emailLoginExchangeRequest
+	validCodeExchangeReq   emailLoginExchangeRequest
+	invalidCodeExchangeReq emailLoginExchangeRequest
+	incompleteExchangeReq  emailLoginExchangeRequest
+	unverifiedExchangeReq  emailLoginExchangeRequest
+
+	sessionRoles map[int][]string
+
+	// defaultTenant is the tenant of requests which didn't go through the
+	// tenant middleware, like every request in these tests.
+	defaultTenant = tenancy.Tenant{Id: tenancy.DEFAULT_TENANT_ID}
 )
 
 const (
 	SAMPLE_VALID_VERIFICATION_TOKEN   = "abcdabcdabcdabcdabcdabcdabcd"
 	SAMPLE_INVALID_VERIFICATION_TOKEN = "invalidtokeninvalidtokeninvalidt"
+	SAMPLE_LOGIN_CODE                 = "123456"
+	SAMPLE_RP_ID                      = "localhost"
+	SAMPLE_RP_ORIGIN                  = "http://localhost:3000"
 )
 
 func testAuthHandlerInit(t *testing.T) {
 	ctrl = gomock.NewController(t)
 	mockRepo = NewMockuserRepositoryInterface(ctrl)
+	mockWebAuthnRepo = NewMockwebauthnRepositoryInterface(ctrl)
+	mockDeviceRepo = NewMockdeviceRepositoryInterface(ctrl)
+	mockRoleRepo = NewMockroleRepositoryInterface(ctrl)
+	mockMailer = mailer.NewMockMailer(ctrl)
+	mockAuditLogger = audit.NewMockLogger(ctrl)
+
+	handler = AuthHandler{
+		UserRepo:                 mockRepo,
+		WebAuthnRepo:             mockWebAuthnRepo,
+		DeviceRepo:               mockDeviceRepo,
+		RoleRepo:                 mockRoleRepo,
+		RelyingParty:             webauthn.RelyingParty{ID: SAMPLE_RP_ID, Name: "Userland", Origin: SAMPLE_RP_ORIGIN},
+		Mailer:                   mockMailer,
+		AuditLogger:              mockAuditLogger,
+		EmailLoginRequestLimiter: ratelimit.NewLimiter(EMAIL_LOGIN_REQUEST_LIMIT, EMAIL_LOGIN_LIMIT_PERIOD),
+		EmailLoginAttemptLimiter: ratelimit.NewLimiter(EMAIL_LOGIN_ATTEMPT_LIMIT, EMAIL_LOGIN_LIMIT_PERIOD),
+		ReauthAttemptLimiter:     ratelimit.NewLimiter(REAUTH_ATTEMPT_LIMIT, REAUTH_LIMIT_PERIOD),
+		UsernameCheckLimiter:     ratelimit.NewLimiter(USERNAME_AVAILABILITY_LIMIT, USERNAME_AVAILABILITY_LIMIT_PERIOD),
+	}
 
-	handler = AuthHandler{UserRepo: mockRepo}
+	// Every session embeds the user's roles; tests about roles set their own.
+	mockRoleRepo.EXPECT().getUserRoles(tenancy.DEFAULT_TENANT_ID, gomock.Any()).DoAndReturn(func(tenantId int, userId int) ([]string, error) {
+		return sessionRoles[userId], nil
+	}).AnyTimes()
+
+	// WebAuthn challenges are kept as the repository would, each one usable once.
+	challenges := map[string]bool{}
+	mockWebAuthnRepo.EXPECT().createChallenge(tenancy.DEFAULT_TENANT_ID, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(tenantId int, ceremony string, challenge []byte, expiresAt time.Time) error {
+		challenges[ceremony+hashChallenge(challenge)] = true
+		return nil
+	}).AnyTimes()
+	mockWebAuthnRepo.EXPECT().consumeChallenge(tenancy.DEFAULT_TENANT_ID, gomock.Any(), gomock.Any()).DoAndReturn(func(tenantId int, ceremony string, challenge []byte) error {
+		if !challenges[ceremony+hashChallenge(challenge)] {
+			return sql.ErrNoRows
+		}
+		delete(challenges, ceremony+hashChallenge(challenge))
+		return nil
+	}).AnyTimes()
 
 	router = mux.NewRouter()
 	router.HandleFunc("/auth/register", handler.Register).Methods(http.MethodPost)
@@ -62,12 +133,34 @@ func testAuthHandlerInit(t *testing.T) {
 	router.HandleFunc("/auth/verification", handler.Verify).Methods(http.MethodPost)
 	router.HandleFunc("/auth/password/forgot", handler.ForgetPassword).Methods(http.MethodPost)
 	router.HandleFunc("/auth/password/reset", handler.ResetPassword).Methods(http.MethodPost)
+	router.HandleFunc("/auth/account/restore", handler.RestoreAccount).Methods(http.MethodPost)
+	router.HandleFunc("/auth/username/available", handler.CheckUsernameAvailability).Methods(http.MethodGet)
+	router.HandleFunc("/auth/device/report", handler.ReportDevice).Methods(http.MethodPost)
+	router.HandleFunc("/auth/login/email", handler.LoginWithEmail).Methods(http.MethodPost)
+	router.HandleFunc("/auth/login/email/verify", handler.ExchangeEmailLogin).Methods(http.MethodPost)
+	router.HandleFunc("/auth/webauthn/login/begin", handler.BeginWebAuthnLogin).Methods(http.MethodPost)
+	router.HandleFunc("/auth/webauthn/login/finish", handler.FinishWebAuthnLogin).Methods(http.MethodPost)
+	router.HandleFunc("/me/webauthn", withUser(handler.GetWebAuthnCredentials)).Methods(http.MethodGet)
+	router.HandleFunc("/me/webauthn/register/begin", withUser(handler.BeginWebAuthnRegistration)).Methods(http.MethodPost)
+	router.HandleFunc("/me/webauthn/register/finish", withUser(handler.FinishWebAuthnRegistration)).Methods(http.MethodPost)
+	router.HandleFunc("/me/reauth", withSession(handler.Reauthenticate)).Methods(http.MethodPost)
+	router.HandleFunc("/me/reauth/webauthn", withUser(handler.BeginReauthentication)).Methods(http.MethodPost)
+	router.HandleFunc("/me/webauthn/{id}", withUser(handler.DeleteWebAuthnCredential)).Methods(http.MethodDelete)
 }
 
 func testAuthHandlerEnd() {
 	ctrl.Finish()
 }
 
+// expectKnownDevice expects a login from the device test requests are made
+// from, which the user has already signed in from before.
+func expectKnownDevice(user *User) *gomock.Call {
+	knownDevice := UserDevice{Id: 1, Fingerprint: deviceFingerprint("", "")}
+	return mockDeviceRepo.EXPECT().getUserDevices(user).Return([]UserDevice{knownDevice}, nil).Do(func(user *User) {
+		mockDeviceRepo.EXPECT().updateDeviceLastSeen(tenancy.DEFAULT_TENANT_ID, knownDevice, "").Return(nil)
+	})
+}
+
 func TestRegister(t *testing.T) {
 	testAuthHandlerInit(t)
 	initSuiteAndRepoForRegistration()
@@ -81,6 +174,75 @@ func TestRegister(t *testing.T) {
 	testAuthHandlerEnd()
 }
 
+func TestRegisterWithPolicy(t *testing.T) {
+	testAuthHandlerInit(t)
+	newUser := userRegistration{
+		Fullname:        "user",
+		Email:           "user@example.com",
+		Password:        "password",
+		PasswordConfirm: "password",
+	}
+	closedPolicy := RegistrationPolicy{Mode: config.REGISTRATION_MODE_CLOSED}
+	invitePolicy := RegistrationPolicy{Mode: config.REGISTRATION_MODE_INVITE_ONLY}
+
+	testRegisterUserWithPolicy(t, closedPolicy, newUser, http.StatusForbidden)
+	testRegisterUserWithPolicy(t, invitePolicy, newUser, http.StatusForbidden)
+
+	invitedUser := newUser
+	invitedUser.InvitationCode = "invitationcode"
+	mockRepo.EXPECT().createInvitedUser(tenancy.DEFAULT_TENANT_ID, invitedUser).Return(nil)
+	testRegisterUserWithPolicy(t, invitePolicy, invitedUser, http.StatusOK)
+
+	invitedUser.InvitationCode = "usedcode"
+	mockRepo.EXPECT().createInvitedUser(tenancy.DEFAULT_TENANT_ID, invitedUser).Return(errRegistrationInvitationInvalid)
+	testRegisterUserWithPolicy(t, invitePolicy, invitedUser, http.StatusForbidden)
+
+	testAuthHandlerEnd()
+}
+
+func TestRegisterWithinTenant(t *testing.T) {
+	testAuthHandlerInit(t)
+	brandTenant := &tenancy.Tenant{Id: 2, RegistrationMode: sql.NullString{String: config.REGISTRATION_MODE_INVITE_ONLY, Valid: true}}
+	newUser := userRegistration{
+		Fullname:        "user",
+		Email:           "user@example.com",
+		Password:        "password",
+		PasswordConfirm: "password",
+	}
+	newRequest := func(registration userRegistration) *http.Request {
+		body, err := json.Marshal(registration)
+		require.Nil(t, err)
+		req, _ := http.NewRequest(http.MethodPost, "/auth/register", bytes.NewReader(body))
+		return req.WithContext(context.WithValue(req.Context(), "tenant", brandTenant))
+	}
+
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, newRequest(newUser))
+	assert.Equal(t, http.StatusForbidden, res.Code, "Tenant's own registration mode should apply")
+
+	invitedUser := newUser
+	invitedUser.InvitationCode = "invitationcode"
+	mockRepo.EXPECT().createInvitedUser(brandTenant.Id, invitedUser).Return(nil)
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, newRequest(invitedUser))
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	testAuthHandlerEnd()
+}
+
+func testRegisterUserWithPolicy(t *testing.T, policy RegistrationPolicy, newUser userRegistration, expectedStatusCode int) {
+	policyHandler := handler
+	policyHandler.RegistrationPolicy = policy
+
+	userRegistrationData, err := json.Marshal(newUser)
+	require.Nil(t, err)
+	req, err := http.NewRequest(http.MethodPost, "/auth/register", bytes.NewReader(userRegistrationData))
+	require.Nil(t, err)
+	res := httptest.NewRecorder()
+	policyHandler.Register(res, req)
+	assert.Equal(t, expectedStatusCode, res.Code)
+}
+
 func initSuiteAndRepoForRegistration() {
 	validNewUser = userRegistration{
 		Fullname:        "user",
@@ -116,8 +278,8 @@ func initSuiteAndRepoForRegistration() {
 	}
 
 	gomock.InOrder(
-		mockRepo.EXPECT().createNewUser(validNewUser).Return(nil),
-		mockRepo.EXPECT().createNewUser(validNewUserFailQuery).Return(errors.New("")),
+		mockRepo.EXPECT().createNewUser(tenancy.DEFAULT_TENANT_ID, validNewUser).Return(nil),
+		mockRepo.EXPECT().createNewUser(tenancy.DEFAULT_TENANT_ID, validNewUserFailQuery).Return(errors.New("")),
 	)
 }
 
@@ -168,9 +330,9 @@ func initSuiteAndRepoForVerify() {
 	}
 
 	gomock.InOrder(
-		mockRepo.EXPECT().verifyUser(validVerifReq.Recipient, validVerifReq.VerificationToken).Return(nil),
-		mockRepo.EXPECT().verifyUser(invalidTokenVerifReq.Recipient, invalidTokenVerifReq.VerificationToken).Return(errors.New("")),
-		mockRepo.EXPECT().verifyUser(userlessVerifReq.Recipient, userlessVerifReq.VerificationToken).Return(errors.New("")),
+		mockRepo.EXPECT().verifyUser(tenancy.DEFAULT_TENANT_ID, validVerifReq.Recipient, validVerifReq.VerificationToken).Return(nil),
+		mockRepo.EXPECT().verifyUser(tenancy.DEFAULT_TENANT_ID, invalidTokenVerifReq.Recipient, invalidTokenVerifReq.VerificationToken).Return(errors.New("")),
+		mockRepo.EXPECT().verifyUser(tenancy.DEFAULT_TENANT_ID, userlessVerifReq.Recipient, userlessVerifReq.VerificationToken).Return(errors.New("")),
 	)
 }
 
@@ -195,6 +357,36 @@ func TestLogin(t *testing.T) {
 	testAuthHandlerEnd()
 }
 
+func TestLoginSuspendedUser(t *testing.T) {
+	testAuthHandlerInit(t)
+
+	bannedUser := User{
+		Email:      "banned@example.com",
+		Password:   "password",
+		Verified:   true,
+		Suspension: sql.NullString{String: SUSPENSION_BANNED, Valid: true},
+	}
+	gomock.InOrder(
+		mockRepo.EXPECT().loginUser(tenancy.DEFAULT_TENANT_ID, bannedUser.Email, bannedUser.Password).Return(nil),
+		mockRepo.EXPECT().getUserByEmail(tenancy.DEFAULT_TENANT_ID, bannedUser.Email).Return(&bannedUser, nil),
+	)
+	testLoginUser(t, bannedUser, http.StatusForbidden)
+
+	deletedUser := User{
+		Email:     "deleted@example.com",
+		Password:  "password",
+		Verified:  true,
+		DeletedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	}
+	gomock.InOrder(
+		mockRepo.EXPECT().loginUser(tenancy.DEFAULT_TENANT_ID, deletedUser.Email, deletedUser.Password).Return(nil),
+		mockRepo.EXPECT().getUserByEmail(tenancy.DEFAULT_TENANT_ID, deletedUser.Email).Return(&deletedUser, nil),
+	)
+	testLoginUser(t, deletedUser, http.StatusForbidden)
+
+	testAuthHandlerEnd()
+}
+
 func initSuiteAndRepoForLogin() {
 	loginnableUser = User{
 		Email:    "user@example.com",
@@ -213,10 +405,12 @@ func initSuiteAndRepoForLogin() {
 	}
 
 	gomock.InOrder(
-		mockRepo.EXPECT().loginUser(loginnableUser.Email, loginnableUser.Password).Return(nil),
-		mockRepo.EXPECT().getUserByEmail(loginnableUser.Email).Return(&loginnableUser, nil),
-		mockRepo.EXPECT().loginUser(unverifiedUser.Email, unverifiedUser.Password).Return(nil),
-		mockRepo.EXPECT().getUserByEmail(unverifiedUser.Email).Return(&unverifiedUser, nil),
+		mockRepo.EXPECT().loginUser(tenancy.DEFAULT_TENANT_ID, loginnableUser.Email, loginnableUser.Password).Return(nil),
+		mockRepo.EXPECT().getUserByEmail(tenancy.DEFAULT_TENANT_ID, loginnableUser.Email).Return(&loginnableUser, nil),
+		mockWebAuthnRepo.EXPECT().getCredentialsByUserId(tenancy.DEFAULT_TENANT_ID, loginnableUser.Id).Return([]WebAuthnCredential{}, nil),
+		expectKnownDevice(&loginnableUser),
+		mockRepo.EXPECT().loginUser(tenancy.DEFAULT_TENANT_ID, unverifiedUser.Email, unverifiedUser.Password).Return(nil),
+		mockRepo.EXPECT().getUserByEmail(tenancy.DEFAULT_TENANT_ID, unverifiedUser.Email).Return(&unverifiedUser, nil),
 	)
 }
 
@@ -247,7 +441,7 @@ func initSuiteAndRepoForForgetPassword() {
 
 	userWithoutEmail = User{}
 
-	mockRepo.EXPECT().forgetPassword(userWithEmail.Email).Return(nil)
+	mockRepo.EXPECT().forgetPassword(tenancy.DEFAULT_TENANT_ID, userWithEmail.Email).Return(nil)
 }
 
 func testForgetPassword(t *testing.T, user User, expectedStatusCode int) {
@@ -297,7 +491,7 @@ func initSuiteAndRepoForResetPassword() {
 	}
 
 	gomock.InOrder(
-		mockRepo.EXPECT().resetPassword(validResetPassReq.Token, validResetPassReq.Password).Return(nil),
+		mockRepo.EXPECT().resetPassword(tenancy.DEFAULT_TENANT_ID, validResetPassReq.Token, validResetPassReq.Password).Return(nil),
 	)
 }
 
@@ -310,3 +504,190 @@ func testResetUserPassword(t *testing.T, resetPassReq resetPasswordRequest, expe
 	router.ServeHTTP(res, req)
 	assert.Equal(t, expectedStatusCode, res.Code)
 }
+
+func TestRestoreAccount(t *testing.T) {
+	testAuthHandlerInit(t)
+
+	gomock.InOrder(
+		mockRepo.EXPECT().restoreUser(tenancy.DEFAULT_TENANT_ID, "restoretoken").Return(&User{Id: 1}, nil),
+		mockRepo.EXPECT().restoreUser(tenancy.DEFAULT_TENANT_ID, "expiredtoken").Return(nil, sql.ErrNoRows),
+	)
+	testRestoreUserAccount(t, restoreAccountRequest{Token: "restoretoken"}, http.StatusOK)
+	testRestoreUserAccount(t, restoreAccountRequest{Token: "expiredtoken"}, http.StatusBadRequest)
+	testRestoreUserAccount(t, restoreAccountRequest{}, http.StatusBadRequest)
+
+	testAuthHandlerEnd()
+}
+
+func testRestoreUserAccount(t *testing.T, restoreReq restoreAccountRequest, expectedStatusCode int) {
+	restoreReqData, err := json.Marshal(restoreReq)
+	require.Nil(t, err)
+	req, err := http.NewRequest(http.MethodPost, "/auth/account/restore", bytes.NewReader(restoreReqData))
+	require.Nil(t, err)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(t, expectedStatusCode, res.Code)
+}
+
+func TestCheckUsernameAvailability(t *testing.T) {
+	testAuthHandlerInit(t)
+	mockRepo.EXPECT().isUsernameTaken(tenancy.DEFAULT_TENANT_ID, "free_name").Return(false, nil)
+	mockRepo.EXPECT().isUsernameTaken(tenancy.DEFAULT_TENANT_ID, "Taken").Return(true, nil)
+	mockRepo.EXPECT().isUsernameTaken(tenancy.DEFAULT_TENANT_ID, "broken").Return(false, errors.New("connection refused"))
+
+	res := testCheckUsername(t, "free_name", http.StatusOK)
+	assert.JSONEq(t, `{"name":"free_name","available":true}`, res.Body.String())
+	res = testCheckUsername(t, "Taken", http.StatusOK)
+	assert.JSONEq(t, `{"name":"Taken","available":false}`, res.Body.String())
+	res = testCheckUsername(t, "Admin", http.StatusOK)
+	assert.JSONEq(t, `{"name":"Admin","available":false}`, res.Body.String(), "Reserved usernames should be unavailable")
+	testCheckUsername(t, "no", http.StatusBadRequest)
+	testCheckUsername(t, "12345", http.StatusBadRequest)
+	testCheckUsername(t, "broken", http.StatusInternalServerError)
+
+	testAuthHandlerEnd()
+}
+
+func TestCheckUsernameAvailabilityRateLimited(t *testing.T) {
+	testAuthHandlerInit(t)
+
+	for i := 0; i < USERNAME_AVAILABILITY_LIMIT; i++ {
+		testCheckUsername(t, "x", http.StatusBadRequest)
+	}
+	testCheckUsername(t, "x", http.StatusTooManyRequests)
+
+	testAuthHandlerEnd()
+}
+
+func testCheckUsername(t *testing.T, name string, expectedStatusCode int) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(http.MethodGet, "/auth/username/available?name="+url.QueryEscape(name), nil)
+	require.Nil(t, err)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(t, expectedStatusCode, res.Code)
+	return res
+}
+
+func TestLoginWithEmail(t *testing.T) {
+	testAuthHandlerInit(t)
+	initSuiteAndRepoForLoginWithEmail()
+
+	testRequestEmailLogin(t, validEmailLoginReq, http.StatusOK)
+	testRequestEmailLogin(t, incompleteEmailLoginReq, http.StatusBadRequest)
+	testRequestEmailLogin(t, unverifiedEmailLoginReq, http.StatusOK)
+	testRequestEmailLogin(t, emailLoginRequest{Email: "nobody@example.com"}, http.StatusOK)
+	testRequestEmailLogin(t, emailLoginRequest{Email: "banned@example.com"}, http.StatusOK)
+	testRequestEmailLogin(t, validEmailLoginReq, http.StatusOK)
+	testRequestEmailLogin(t, validEmailLoginReq, http.StatusOK)
+	testRequestEmailLogin(t, validEmailLoginReq, http.StatusTooManyRequests)
+
+	testAuthHandlerEnd()
+}
+
+func initSuiteAndRepoForLoginWithEmail() {
+	loginnableUser = User{
+		Id:       1,
+		Email:    "user@example.com",
+		Verified: true,
+	}
+
+	unverifiedUser = User{
+		Id:       2,
+		Email:    "anotheruser@example.com",
+		Verified: false,
+	}
+
+	validEmailLoginReq = emailLoginRequest{Email: loginnableUser.Email}
+	incompleteEmailLoginReq = emailLoginRequest{}
+	unverifiedEmailLoginReq = emailLoginRequest{Email: unverifiedUser.Email}
+
+	mockRepo.EXPECT().getUserByEmail(tenancy.DEFAULT_TENANT_ID, loginnableUser.Email).Return(&loginnableUser, nil).Times(EMAIL_LOGIN_REQUEST_LIMIT)
+	mockRepo.EXPECT().createLoginToken(&loginnableUser).Return(SAMPLE_VALID_VERIFICATION_TOKEN, SAMPLE_LOGIN_CODE, nil).Times(EMAIL_LOGIN_REQUEST_LIMIT)
+	mockRepo.EXPECT().getUserByEmail(tenancy.DEFAULT_TENANT_ID, unverifiedUser.Email).Return(&unverifiedUser, nil)
+	mockRepo.EXPECT().getUserByEmail(tenancy.DEFAULT_TENANT_ID, "nobody@example.com").Return(nil, sql.ErrNoRows)
+	mockRepo.EXPECT().getUserByEmail(tenancy.DEFAULT_TENANT_ID, "banned@example.com").Return(&User{Id: 3, Email: "banned@example.com", Verified: true, Suspension: sql.NullString{String: SUSPENSION_BANNED, Valid: true}}, nil)
+	mockMailer.EXPECT().Send(loginnableUser.Email, gomock.Any(), gomock.Any()).Return(nil).Times(EMAIL_LOGIN_REQUEST_LIMIT)
+}
+
+func testRequestEmailLogin(t *testing.T, loginReq emailLoginRequest, expectedStatusCode int) {
+	loginReqData, err := json.Marshal(loginReq)
+	require.Nil(t, err)
+	req, err := http.NewRequest(http.MethodPost, "/auth/login/email", bytes.NewReader(loginReqData))
+	require.Nil(t, err)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(t, expectedStatusCode, res.Code)
+}
+
+func TestExchangeEmailLogin(t *testing.T) {
+	testAuthHandlerInit(t)
+	initSuiteAndRepoForExchangeEmailLogin()
+
+	testExchangeEmailLogin(t, validTokenExchangeReq, http.StatusOK)
+	testExchangeEmailLogin(t, validCodeExchangeReq, http.StatusOK)
+	testExchangeEmailLogin(t, invalidCodeExchangeReq, http.StatusUnauthorized)
+	testExchangeEmailLogin(t, incompleteExchangeReq, http.StatusBadRequest)
+	testExchangeEmailLogin(t, unverifiedExchangeReq, http.StatusUnauthorized)
+
+	testAuthHandlerEnd()
+}
+
+func initSuiteAndRepoForExchangeEmailLogin() {
+	loginnableUser = User{
+		Id:       1,
+		Email:    "user@example.com",
+		Verified: true,
+	}
+
+	unverifiedUser = User{
+		Id:       2,
+		Email:    "anotheruser@example.com",
+		Verified: false,
+	}
+
+	validTokenExchangeReq = emailLoginExchangeRequest{Token: SAMPLE_VALID_VERIFICATION_TOKEN}
+	validCodeExchangeReq = emailLoginExchangeRequest{Email: loginnableUser.Email, Code: SAMPLE_LOGIN_CODE}
+	invalidCodeExchangeReq = emailLoginExchangeRequest{Email: loginnableUser.Email, Code: "000000"}
+	incompleteExchangeReq = emailLoginExchangeRequest{Email: loginnableUser.Email}
+	unverifiedExchangeReq = emailLoginExchangeRequest{Token: SAMPLE_INVALID_VERIFICATION_TOKEN}
+
+	gomock.InOrder(
+		mockRepo.EXPECT().consumeLoginToken(tenancy.DEFAULT_TENANT_ID, validTokenExchangeReq.Token).Return(&loginnableUser, nil),
+		mockWebAuthnRepo.EXPECT().getCredentialsByUserId(tenancy.DEFAULT_TENANT_ID, loginnableUser.Id).Return([]WebAuthnCredential{}, nil),
+		expectKnownDevice(&loginnableUser),
+		mockRepo.EXPECT().consumeLoginCode(tenancy.DEFAULT_TENANT_ID, validCodeExchangeReq.Email, validCodeExchangeReq.Code).Return(&loginnableUser, nil),
+		mockWebAuthnRepo.EXPECT().getCredentialsByUserId(tenancy.DEFAULT_TENANT_ID, loginnableUser.Id).Return([]WebAuthnCredential{}, nil),
+		expectKnownDevice(&loginnableUser),
+		mockRepo.EXPECT().consumeLoginCode(tenancy.DEFAULT_TENANT_ID, invalidCodeExchangeReq.Email, invalidCodeExchangeReq.Code).Return(nil, errors.New("")),
+		mockRepo.EXPECT().consumeLoginToken(tenancy.DEFAULT_TENANT_ID, unverifiedExchangeReq.Token).Return(&unverifiedUser, nil),
+	)
+}
+
+func TestExchangeEmailLoginPasswordResetRequired(t *testing.T) {
+	testAuthHandlerInit(t)
+	user := User{Id: 1, Email: "user@example.com", Verified: true, PasswordResetRequired: true}
+	mockRepo.EXPECT().consumeLoginToken(tenancy.DEFAULT_TENANT_ID, SAMPLE_VALID_VERIFICATION_TOKEN).Return(&user, nil)
+
+	exchangeReqData, err := json.Marshal(emailLoginExchangeRequest{Token: SAMPLE_VALID_VERIFICATION_TOKEN})
+	require.Nil(t, err)
+	req, err := http.NewRequest(http.MethodPost, "/auth/login/email/verify", bytes.NewReader(exchangeReqData))
+	require.Nil(t, err)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+	var resErr ulanderrors.UserlandError
+	require.Nil(t, json.Unmarshal(res.Body.Bytes(), &resErr))
+	assert.Equal(t, ulanderrors.ErrLoginPasswordResetRequired, resErr)
+
+	testAuthHandlerEnd()
+}
+
+func testExchangeEmailLogin(t *testing.T, exchangeReq emailLoginExchangeRequest, expectedStatusCode int) {
+	exchangeReqData, err := json.Marshal(exchangeReq)
+	require.Nil(t, err)
+	req, err := http.NewRequest(http.MethodPost, "/auth/login/email/verify", bytes.NewReader(exchangeReqData))
+	require.Nil(t, err)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(t, expectedStatusCode, res.Code)
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"userland/audit"
+	"userland/config"
+	ulanderrors "userland/errors"
+	"userland/response"
+	"userland/tenancy"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	ACTION_IMPERSONATION_START = "admin.user.impersonate"
+)
+
+// ImpersonateUser replaces the admin's session with a short-lived one of the
+// given user, so support sees the API exactly as the user does. The admin has
+// to log in again once done.
+func (handler AuthHandler) ImpersonateUser(w http.ResponseWriter, r *http.Request) {
+	admin := r.Context().Value("user").(*User)
+
+	userId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || userId == admin.Id {
+		log.Info("Impersonated user id is invalid")
+		response.RespondBadRequest(w, ulanderrors.ErrImpersonationTargetInvalid)
+		return
+	}
+
+	tenant := tenancy.FromRequest(r)
+	user, err := handler.UserRepo.getUserById(tenant.Id, userId)
+	if err != nil {
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrImpersonationTargetInvalid)
+		return
+	}
+
+	if user.activeSuspension() != "" {
+		log.Info("Suspended user can't be impersonated")
+		response.RespondBadRequest(w, ulanderrors.ErrImpersonationTargetInvalid)
+		return
+	}
+
+	expirationTime := time.Now().Add(config.GetImpersonationDuration())
+	token, err := generateImpersonationJWT(tenant, *user, admin.Id, expirationTime)
+	if err != nil {
+		log.Info(err)
+		response.RespondInternalError(w, ulanderrors.ErrLoginJWT)
+		return
+	}
+
+	err = handler.AuditLogger.Record(audit.NewEvent(r, admin.Id, ACTION_IMPERSONATION_START, user.Id, map[string]interface{}{
+		"expires_at": expirationTime,
+	}))
+	if err != nil {
+		log.Warn(err)
+		response.RespondInternalError(w, ulanderrors.ErrImpersonationAudit)
+		return
+	}
+
+	setSessionCookie(w, token, expirationTime)
+
+	log.Info("Impersonation started")
+	response.RespondSuccessWithBody(w, map[string]interface{}{
+		"user_id":    user.Id,
+		"expires_at": expirationTime,
+	})
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"testing"
+	"userland/audit"
+	"userland/tenancy"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImpersonateUser(t *testing.T) {
+	testAuthHandlerInit(t)
+	router.HandleFunc("/admin/users/{id}/impersonate", withUser(handler.ImpersonateUser)).Methods(http.MethodPost)
+	impersonatedUser := User{Id: 2, Email: "impersonated@example.com", TokenVersion: 3}
+
+	gomock.InOrder(
+		mockRepo.EXPECT().getUserById(tenancy.DEFAULT_TENANT_ID, impersonatedUser.Id).Return(&impersonatedUser, nil),
+		mockAuditLogger.EXPECT().Record(gomock.Any()).DoAndReturn(func(event audit.Event) error {
+			assert.Equal(t, webauthnUser.Id, event.ActorId)
+			assert.Equal(t, ACTION_IMPERSONATION_START, event.Action)
+			assert.Equal(t, impersonatedUser.Id, event.UserId)
+			return nil
+		}),
+	)
+	res := serveWithCookies(t, http.MethodPost, "/admin/users/2/impersonate", nil, nil)
+	require.Equal(t, http.StatusOK, res.Code)
+	claims := parseSessionCookie(t, res.Result().Cookies())
+	assert.Equal(t, impersonatedUser.Id, claims.UserId)
+	assert.Equal(t, impersonatedUser.TokenVersion, claims.TokenVersion)
+	require.True(t, claims.impersonated())
+	assert.Equal(t, webauthnUser.Id, claims.Act.UserId)
+	assert.Empty(t, claims.Roles, "Impersonated session should not carry roles")
+	assert.Zero(t, claims.AuthTime, "Impersonated session should not count as recently authenticated")
+
+	assert.Equal(t, http.StatusBadRequest, serveWithCookies(t, http.MethodPost, "/admin/users/1/impersonate", nil, nil).Code, "Admins should not impersonate themselves")
+
+	mockRepo.EXPECT().getUserById(tenancy.DEFAULT_TENANT_ID, 3).Return(nil, sql.ErrNoRows)
+	assert.Equal(t, http.StatusBadRequest, serveWithCookies(t, http.MethodPost, "/admin/users/3/impersonate", nil, nil).Code)
+
+	bannedUser := impersonatedUser
+	bannedUser.Suspension = sql.NullString{String: SUSPENSION_BANNED, Valid: true}
+	mockRepo.EXPECT().getUserById(tenancy.DEFAULT_TENANT_ID, impersonatedUser.Id).Return(&bannedUser, nil)
+	assert.Equal(t, http.StatusBadRequest, serveWithCookies(t, http.MethodPost, "/admin/users/2/impersonate", nil, nil).Code)
+
+	gomock.InOrder(
+		mockRepo.EXPECT().getUserById(tenancy.DEFAULT_TENANT_ID, impersonatedUser.Id).Return(&impersonatedUser, nil),
+		mockAuditLogger.EXPECT().Record(gomock.Any()).Return(errors.New("")),
+	)
+	res = serveWithCookies(t, http.MethodPost, "/admin/users/2/impersonate", nil, nil)
+	assert.Equal(t, http.StatusInternalServerError, res.Code)
+	assert.Nil(t, findCookie(res.Result().Cookies(), SESSION_COOKIE), "Impersonation should not start unless it was recorded")
+
+	testAuthHandlerEnd()
+}
@@ -3,21 +3,29 @@ package auth
 import (
 	"context"
 	"net/http"
+	"userland/audit"
 	"userland/config"
 	ulanderrors "userland/errors"
 	"userland/response"
+	"userland/tenancy"
 
 	"github.com/dgrijalva/jwt-go"
 	log "github.com/sirupsen/logrus"
 )
 
+const (
+	ACTION_IMPERSONATED_REQUEST = "impersonation.request"
+)
+
 type AuthMiddleware struct {
-	UserRepo userRepositoryInterface
+	UserRepo    userRepositoryInterface
+	RoleRepo    roleRepositoryInterface
+	AuditLogger audit.Logger
 }
 
 func (middleware AuthMiddleware) WithVerifyJWT(next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie("token")
+		cookie, err := r.Cookie(SESSION_COOKIE)
 
 		if err != nil {
 			log.Info(err)
@@ -31,9 +39,10 @@ func (middleware AuthMiddleware) WithVerifyJWT(next http.HandlerFunc) http.Handl
 
 		tokenString := cookie.Value
 		claims := &Claims{}
+		tenant := tenancy.FromRequest(r)
 
 		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(config.GetJWTKey()), nil
+			return tenant.SigningKey(), nil
 		})
 
 		if err != nil {
@@ -63,15 +72,197 @@ func (middleware AuthMiddleware) WithVerifyJWT(next http.HandlerFunc) http.Handl
 			return
 		}
 
-		user, err := middleware.UserRepo.getUserById(claims.UserId)
+		if !claims.VerifyAudience(SESSION_TOKEN_AUDIENCE, true) {
+			log.Info("Token isn't a session")
+			response.RespondUnauthorized(w, ulanderrors.ErrTokenInvalidContent)
+			return
+		}
+
+		if claims.TenantId != tenant.Id {
+			log.Info("Token was issued for another tenant")
+			response.RespondUnauthorized(w, ulanderrors.ErrTokenTenantMismatch)
+			return
+		}
+
+		user, err := middleware.UserRepo.getUserById(tenant.Id, claims.UserId)
 		if err != nil {
 			log.Warn(err)
 			response.RespondBadRequest(w, ulanderrors.ErrTokenUserIdDoesNotExist)
 			return
 		}
 
+		if claims.TokenVersion != user.TokenVersion {
+			log.Info("Token has been revoked")
+			response.RespondUnauthorized(w, ulanderrors.ErrTokenRevoked)
+			return
+		}
+
+		if refuseSuspendedUser(w, user) {
+			return
+		}
+
+		if claims.impersonated() {
+			err = middleware.AuditLogger.Record(audit.NewEvent(r, claims.Act.UserId, ACTION_IMPERSONATED_REQUEST, user.Id, map[string]interface{}{
+				"method": r.Method,
+				"path":   r.URL.Path,
+			}))
+			if err != nil {
+				log.Warn(err)
+				response.RespondInternalError(w, ulanderrors.ErrImpersonationAudit)
+				return
+			}
+		}
+
 		log.Info("Authentication successful")
 		ctx := context.WithValue(r.Context(), "user", user)
+		ctx = context.WithValue(ctx, "claims", claims)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// WithOptionalJWT lets anonymous requests through to routes whose answer
+// depends on who asks, leaving the user out of the context. Sessions that
+// WithVerifyJWT refuses count as anonymous too, so that public content
+// doesn't break for clients holding an expired session.
+func (middleware AuthMiddleware) WithOptionalJWT(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := r.Cookie(SESSION_COOKIE); err == http.ErrNoCookie {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		authenticated := false
+		middleware.WithVerifyJWT(func(_ http.ResponseWriter, r *http.Request) {
+			authenticated = true
+			next.ServeHTTP(w, r)
+		}).ServeHTTP(discardedResponse{header: http.Header{}}, r)
+
+		if !authenticated {
+			log.Info("Session is refused, going on anonymously")
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// discardedResponse swallows the refusal WithVerifyJWT writes, for
+// WithOptionalJWT.
+type discardedResponse struct {
+	header http.Header
+}
+
+func (res discardedResponse) Header() http.Header {
+	return res.header
+}
+
+func (res discardedResponse) Write(content []byte) (int, error) {
+	return len(content), nil
+}
+
+func (res discardedResponse) WriteHeader(statusCode int) {}
+
+// WithoutImpersonation guards routes which change how the user logs in. On top
+// of WithVerifyJWT, the session must belong to the user themselves rather than
+// to an admin impersonating them.
+func (middleware AuthMiddleware) WithoutImpersonation(next http.HandlerFunc) http.HandlerFunc {
+	return middleware.WithVerifyJWT(func(w http.ResponseWriter, r *http.Request) {
+		claims := r.Context().Value("claims").(*Claims)
+		if claims.impersonated() {
+			log.Info("Action is not allowed while impersonating")
+			response.RespondForbidden(w, ulanderrors.ErrImpersonationForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WithRecentAuth guards sensitive routes. On top of WithoutImpersonation, the
+// session must have been authenticated within the re-authentication window,
+// otherwise the client has to go through POST /api/me/reauth first.
+func (middleware AuthMiddleware) WithRecentAuth(next http.HandlerFunc) http.HandlerFunc {
+	return middleware.WithoutImpersonation(func(w http.ResponseWriter, r *http.Request) {
+		claims := r.Context().Value("claims").(*Claims)
+		if !claims.authenticatedWithin(config.GetReauthWindow()) {
+			log.Info("Session has to be re-authenticated")
+			response.RespondForbidden(w, ulanderrors.ErrReauthRequired)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequirePermission returns a wrapper for handlers behind WithVerifyJWT which
+// only lets through users whose roles grant every given permission. The roles
+// are taken from the session, unless they changed since it was issued, in
+// which case they are read again and the session is reissued with them.
+// Impersonated sessions are never granted any permission.
+func (middleware AuthMiddleware) RequirePermission(permissions ...string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, userOk := r.Context().Value("user").(*User)
+			claims, claimsOk := r.Context().Value("claims").(*Claims)
+			if !userOk || !claimsOk {
+				log.Info("Permission check requires an authenticated session")
+				response.RespondUnauthorized(w, ulanderrors.ErrTokenNotProvided)
+				return
+			}
+
+			if claims.impersonated() {
+				log.Info("Impersonated session can't use permissions")
+				response.RespondForbidden(w, ulanderrors.ErrImpersonationForbidden)
+				return
+			}
+
+			if claims.RolesVersion != user.RolesVersion {
+				roles, err := middleware.RoleRepo.getUserRoles(tenancy.FromRequest(r).Id, user.Id)
+				if err != nil {
+					log.Warn(err)
+					response.RespondInternalError(w, ulanderrors.ErrRoleQueryExec)
+					return
+				}
+
+				refreshedClaims := *claims
+				refreshedClaims.Roles = roles
+				refreshedClaims.RolesVersion = user.RolesVersion
+				err = reissueSession(w, r, refreshedClaims)
+				if err != nil {
+					log.Warn(err)
+				}
+
+				claims = &refreshedClaims
+				r = r.WithContext(context.WithValue(r.Context(), "claims", claims))
+			}
+
+			granted := []string{}
+			if len(claims.Roles) > 0 {
+				rolePermissions, err := middleware.RoleRepo.getRolesPermissions(claims.Roles)
+				if err != nil {
+					log.Warn(err)
+					response.RespondInternalError(w, ulanderrors.ErrRoleQueryExec)
+					return
+				}
+				granted = rolePermissions
+			}
+
+			if !hasPermissions(granted, permissions) {
+				log.Info("User lacks the permission for the action")
+				response.RespondForbidden(w, ulanderrors.ErrPermissionDenied)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func hasPermissions(granted []string, required []string) bool {
+	grantedSet := make(map[string]bool, len(granted))
+	for _, permission := range granted {
+		grantedSet[permission] = true
+	}
+	for _, permission := range required {
+		if !grantedSet[permission] {
+			return false
+		}
+	}
+	return true
+}
@@ -1,11 +1,16 @@
 package auth
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
+	"userland/audit"
+	"userland/tenancy"
 
+	"github.com/dgrijalva/jwt-go"
 	"github.com/golang/mock/gomock"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
@@ -28,16 +33,29 @@ var (
 	cookielessReq    *http.Request
 	temperedTokenReq *http.Request
 	expiredTokenReq  *http.Request
+	revokedTokenReq  *http.Request
 )
 
 func testAuthMiddlewareInit(t *testing.T) {
 	ctrl = gomock.NewController(t)
 	mockRepo = NewMockuserRepositoryInterface(ctrl)
+	mockRoleRepo = NewMockroleRepositoryInterface(ctrl)
+	mockAuditLogger = audit.NewMockLogger(ctrl)
 
-	middleware = AuthMiddleware{UserRepo: mockRepo}
+	middleware = AuthMiddleware{UserRepo: mockRepo, RoleRepo: mockRoleRepo, AuditLogger: mockAuditLogger}
 
 	router = mux.NewRouter()
 	router.HandleFunc("/with/auth", middleware.WithVerifyJWT(nextHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/with/optional/auth", middleware.WithOptionalJWT(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value("user").(*User); !ok {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})).Methods(http.MethodGet)
+	router.HandleFunc("/without/impersonation", middleware.WithoutImpersonation(nextHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/with/recent/auth", middleware.WithRecentAuth(nextHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/with/permission", middleware.WithVerifyJWT(middleware.RequirePermission(PERMISSION_USERS_READ)(nextHandler))).Methods(http.MethodGet)
 }
 
 func testAuthMiddlewareEnd() {
@@ -52,13 +70,85 @@ func TestWithVerifyJWT(t *testing.T) {
 	testJWTVerificationRequest(t, cookielessReq, http.StatusUnauthorized)
 	testJWTVerificationRequest(t, temperedTokenReq, http.StatusUnauthorized)
 	testJWTVerificationRequest(t, expiredTokenReq, http.StatusUnauthorized)
+	testJWTVerificationRequest(t, revokedTokenReq, http.StatusUnauthorized)
+
+	testAuthMiddlewareEnd()
+}
+
+func TestWithVerifyJWTRefusesSuspendedUser(t *testing.T) {
+	testAuthMiddlewareInit(t)
+
+	token, err := generateJWT(defaultTenant, authenticatedUser, nil, time.Now().Add(time.Hour))
+	require.Nil(t, err)
+	newRequest := func() *http.Request {
+		req, _ := http.NewRequest(http.MethodGet, "/with/auth", nil)
+		req.AddCookie(&http.Cookie{Name: SESSION_COOKIE, Value: token})
+		return req
+	}
+
+	suspendedUser := authenticatedUser
+	suspendedUser.Suspension = sql.NullString{String: SUSPENSION_SUSPENDED, Valid: true}
+	suspendedUser.SuspendedUntil = sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true}
+	mockRepo.EXPECT().getUserById(tenancy.DEFAULT_TENANT_ID, authenticatedUser.Id).Return(&suspendedUser, nil)
+	testJWTVerificationRequest(t, newRequest(), http.StatusForbidden)
+
+	expiredUser := suspendedUser
+	expiredUser.SuspendedUntil = sql.NullTime{Time: time.Now().Add(-time.Minute), Valid: true}
+	mockRepo.EXPECT().getUserById(tenancy.DEFAULT_TENANT_ID, authenticatedUser.Id).Return(&expiredUser, nil)
+	testJWTVerificationRequest(t, newRequest(), http.StatusOK)
+
+	deactivatedUser := authenticatedUser
+	deactivatedUser.DeactivatedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	mockRepo.EXPECT().getUserById(tenancy.DEFAULT_TENANT_ID, authenticatedUser.Id).Return(&deactivatedUser, nil)
+	testJWTVerificationRequest(t, newRequest(), http.StatusForbidden)
+
+	testAuthMiddlewareEnd()
+}
+
+func TestWithVerifyJWTRefusesOtherTenant(t *testing.T) {
+	testAuthMiddlewareInit(t)
+	expirationTime := time.Now().Add(time.Hour)
+
+	sharedKeyTenant := tenancy.Tenant{Id: 2}
+	token, err := generateJWT(sharedKeyTenant, authenticatedUser, nil, expirationTime)
+	require.Nil(t, err)
+	req, _ := http.NewRequest(http.MethodGet, "/with/auth", nil)
+	req.AddCookie(&http.Cookie{Name: SESSION_COOKIE, Value: token})
+	testJWTVerificationRequest(t, req, http.StatusUnauthorized)
+
+	ownKeyTenant := tenancy.Tenant{Id: 3, JWTKey: sql.NullString{String: "brand_key", Valid: true}}
+	token, err = generateJWT(ownKeyTenant, authenticatedUser, nil, expirationTime)
+	require.Nil(t, err)
+	req, _ = http.NewRequest(http.MethodGet, "/with/auth", nil)
+	req.AddCookie(&http.Cookie{Name: SESSION_COOKIE, Value: token})
+	testJWTVerificationRequest(t, req, http.StatusUnauthorized)
+
+	testAuthMiddlewareEnd()
+}
+
+func TestWithVerifyJWTRefusesCeremonyToken(t *testing.T) {
+	testAuthMiddlewareInit(t)
+	expirationTime := time.Now().Add(time.Hour)
+
+	for _, ceremony := range []string{CEREMONY_WEBAUTHN_LOGIN, CEREMONY_TFA} {
+		token, err := generateCeremonyJWT(defaultTenant, ceremony, authenticatedUser.Id, nil, expirationTime)
+		require.Nil(t, err)
+		req, _ := http.NewRequest(http.MethodGet, "/with/auth", nil)
+		req.AddCookie(&http.Cookie{Name: SESSION_COOKIE, Value: token})
+		testJWTVerificationRequest(t, req, http.StatusUnauthorized)
+	}
+
+	sessionToken, err := generateJWT(defaultTenant, authenticatedUser, nil, expirationTime)
+	require.Nil(t, err)
+	_, err = parseCeremonyJWT(defaultTenant, sessionToken, CEREMONY_TFA)
+	assert.NotNil(t, err, "Session shouldn't pass for a ceremony token")
 
 	testAuthMiddlewareEnd()
 }
 
 func initSuiteAndRepoForVerifyJWT(t *testing.T) {
 	expirationTime := time.Now().Add(HOURS_IN_DAY * time.Hour)
-	jwtToken, err := generateJWT(authenticatedUser, expirationTime)
+	jwtToken, err := generateJWT(defaultTenant, authenticatedUser, nil, expirationTime)
 	require.Nil(t, err)
 
 	validTokenCookie := http.Cookie{
@@ -81,7 +171,7 @@ func initSuiteAndRepoForVerifyJWT(t *testing.T) {
 	temperedTokenReq.AddCookie(&invalidTokenCookie)
 
 	pastTime := time.Now().Add(-1 * HOURS_IN_DAY * time.Hour)
-	expiredToken, err := generateJWT(authenticatedUser, pastTime)
+	expiredToken, err := generateJWT(defaultTenant, authenticatedUser, nil, pastTime)
 	require.Nil(t, err)
 
 	expiredTokenCookie := http.Cookie{
@@ -92,7 +182,15 @@ func initSuiteAndRepoForVerifyJWT(t *testing.T) {
 	expiredTokenReq, _ = http.NewRequest(http.MethodGet, "/with/auth", nil)
 	expiredTokenReq.AddCookie(&expiredTokenCookie)
 
-	mockRepo.EXPECT().getUserById(authenticatedUser.Id).Return(&authenticatedUser, nil)
+	revokedUser := authenticatedUser
+	revokedUser.TokenVersion = authenticatedUser.TokenVersion + 1
+	revokedTokenReq, _ = http.NewRequest(http.MethodGet, "/with/auth", nil)
+	revokedTokenReq.AddCookie(&validTokenCookie)
+
+	gomock.InOrder(
+		mockRepo.EXPECT().getUserById(tenancy.DEFAULT_TENANT_ID, authenticatedUser.Id).Return(&authenticatedUser, nil),
+		mockRepo.EXPECT().getUserById(tenancy.DEFAULT_TENANT_ID, authenticatedUser.Id).Return(&revokedUser, nil),
+	)
 }
 
 func testJWTVerificationRequest(t *testing.T, req *http.Request, expectedStatusCode int) {
@@ -100,3 +198,168 @@ func testJWTVerificationRequest(t *testing.T, req *http.Request, expectedStatusC
 	router.ServeHTTP(res, req)
 	assert.Equal(t, expectedStatusCode, res.Code)
 }
+
+func TestWithOptionalJWT(t *testing.T) {
+	testAuthMiddlewareInit(t)
+	token, err := generateJWT(defaultTenant, authenticatedUser, nil, time.Now().Add(time.Hour))
+	require.Nil(t, err)
+	mockRepo.EXPECT().getUserById(tenancy.DEFAULT_TENANT_ID, authenticatedUser.Id).Return(&authenticatedUser, nil)
+
+	req, _ := http.NewRequest(http.MethodGet, "/with/optional/auth", nil)
+	testJWTVerificationRequest(t, req, http.StatusNoContent)
+
+	req, _ = http.NewRequest(http.MethodGet, "/with/optional/auth", nil)
+	req.AddCookie(&http.Cookie{Name: SESSION_COOKIE, Value: token})
+	testJWTVerificationRequest(t, req, http.StatusOK)
+
+	req, _ = http.NewRequest(http.MethodGet, "/with/optional/auth", nil)
+	req.AddCookie(&http.Cookie{Name: SESSION_COOKIE, Value: token[:len(token)-1]})
+	testJWTVerificationRequest(t, req, http.StatusNoContent)
+
+	testAuthMiddlewareEnd()
+}
+
+func TestWithRecentAuth(t *testing.T) {
+	testAuthMiddlewareInit(t)
+	mockRepo.EXPECT().getUserById(tenancy.DEFAULT_TENANT_ID, authenticatedUser.Id).Return(&authenticatedUser, nil).Times(3)
+
+	expirationTime := time.Now().Add(HOURS_IN_DAY * time.Hour)
+	freshToken, err := generateJWT(defaultTenant, authenticatedUser, nil, expirationTime)
+	require.Nil(t, err)
+	testJWTVerificationRequest(t, recentAuthRequest(freshToken), http.StatusOK)
+
+	staleToken, err := signClaims(defaultTenant, Claims{
+		UserId:         authenticatedUser.Id,
+		TenantId:       defaultTenant.Id,
+		AuthTime:       time.Now().Add(-HOURS_IN_DAY * time.Hour).Unix(),
+		StandardClaims: jwt.StandardClaims{ExpiresAt: expirationTime.Unix()},
+	})
+	require.Nil(t, err)
+	testJWTVerificationRequest(t, recentAuthRequest(staleToken), http.StatusForbidden)
+
+	untimedToken, err := signClaims(defaultTenant, Claims{
+		UserId:         authenticatedUser.Id,
+		TenantId:       defaultTenant.Id,
+		StandardClaims: jwt.StandardClaims{ExpiresAt: expirationTime.Unix()},
+	})
+	require.Nil(t, err)
+	testJWTVerificationRequest(t, recentAuthRequest(untimedToken), http.StatusForbidden)
+
+	testAuthMiddlewareEnd()
+}
+
+func recentAuthRequest(token string) *http.Request {
+	req, _ := http.NewRequest(http.MethodGet, "/with/recent/auth", nil)
+	req.AddCookie(&http.Cookie{Name: SESSION_COOKIE, Value: token})
+	return req
+}
+
+func TestRequirePermission(t *testing.T) {
+	testAuthMiddlewareInit(t)
+	expirationTime := time.Now().Add(HOURS_IN_DAY * time.Hour)
+	adminUser := authenticatedUser
+	adminUser.RolesVersion = 1
+
+	adminToken, err := generateJWT(defaultTenant, adminUser, []string{ROLE_ADMIN}, expirationTime)
+	require.Nil(t, err)
+	mockRepo.EXPECT().getUserById(tenancy.DEFAULT_TENANT_ID, adminUser.Id).Return(&adminUser, nil)
+	mockRoleRepo.EXPECT().getRolesPermissions([]string{ROLE_ADMIN}).Return([]string{PERMISSION_USERS_READ}, nil)
+	res := servePermissionRequest(adminToken)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Empty(t, res.Result().Cookies(), "Session with fresh roles should not be reissued")
+
+	rolelessToken, err := generateJWT(defaultTenant, adminUser, nil, expirationTime)
+	require.Nil(t, err)
+	mockRepo.EXPECT().getUserById(tenancy.DEFAULT_TENANT_ID, adminUser.Id).Return(&adminUser, nil)
+	res = servePermissionRequest(rolelessToken)
+	assert.Equal(t, http.StatusForbidden, res.Code)
+
+	supportToken, err := generateJWT(defaultTenant, adminUser, []string{"support"}, expirationTime)
+	require.Nil(t, err)
+	mockRepo.EXPECT().getUserById(tenancy.DEFAULT_TENANT_ID, adminUser.Id).Return(&adminUser, nil)
+	mockRoleRepo.EXPECT().getRolesPermissions([]string{"support"}).Return([]string{}, nil)
+	res = servePermissionRequest(supportToken)
+	assert.Equal(t, http.StatusForbidden, res.Code)
+
+	testAuthMiddlewareEnd()
+}
+
+func TestRequirePermissionRefreshesStaleRoles(t *testing.T) {
+	testAuthMiddlewareInit(t)
+	expirationTime := time.Now().Add(HOURS_IN_DAY * time.Hour)
+	staleToken, err := generateJWT(defaultTenant, authenticatedUser, nil, expirationTime)
+	require.Nil(t, err)
+
+	promotedUser := authenticatedUser
+	promotedUser.RolesVersion = authenticatedUser.RolesVersion + 1
+	gomock.InOrder(
+		mockRepo.EXPECT().getUserById(tenancy.DEFAULT_TENANT_ID, authenticatedUser.Id).Return(&promotedUser, nil),
+		mockRoleRepo.EXPECT().getUserRoles(tenancy.DEFAULT_TENANT_ID, authenticatedUser.Id).Return([]string{ROLE_ADMIN}, nil),
+		mockRoleRepo.EXPECT().getRolesPermissions([]string{ROLE_ADMIN}).Return([]string{PERMISSION_USERS_READ}, nil),
+	)
+	res := servePermissionRequest(staleToken)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	cookies := res.Result().Cookies()
+	require.Len(t, cookies, 1, "Session with stale roles should be reissued")
+	claims := &Claims{}
+	_, err = jwt.ParseWithClaims(cookies[0].Value, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(""), nil
+	})
+	require.Nil(t, err)
+	assert.Equal(t, []string{ROLE_ADMIN}, claims.Roles)
+	assert.Equal(t, promotedUser.RolesVersion, claims.RolesVersion)
+
+	testAuthMiddlewareEnd()
+}
+
+func servePermissionRequest(token string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(http.MethodGet, "/with/permission", nil)
+	req.AddCookie(&http.Cookie{Name: SESSION_COOKIE, Value: token})
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	return res
+}
+
+func TestImpersonatedSession(t *testing.T) {
+	testAuthMiddlewareInit(t)
+	adminId := 10
+	token, err := generateImpersonationJWT(defaultTenant, authenticatedUser, adminId, time.Now().Add(time.Hour))
+	require.Nil(t, err)
+	newRequest := func(url string) *http.Request {
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		req.AddCookie(&http.Cookie{Name: SESSION_COOKIE, Value: token})
+		return req
+	}
+
+	mockRepo.EXPECT().getUserById(tenancy.DEFAULT_TENANT_ID, authenticatedUser.Id).Return(&authenticatedUser, nil).Times(5)
+	mockAuditLogger.EXPECT().Record(gomock.Any()).DoAndReturn(func(event audit.Event) error {
+		assert.Equal(t, adminId, event.ActorId, "Impersonated request should be recorded with the admin behind it")
+		assert.Equal(t, authenticatedUser.Id, event.UserId)
+		assert.Equal(t, ACTION_IMPERSONATED_REQUEST, event.Action)
+		return nil
+	}).Times(4)
+
+	testJWTVerificationRequest(t, newRequest("/with/auth"), http.StatusOK)
+	testJWTVerificationRequest(t, newRequest("/without/impersonation"), http.StatusForbidden)
+	testJWTVerificationRequest(t, newRequest("/with/recent/auth"), http.StatusForbidden)
+	testJWTVerificationRequest(t, newRequest("/with/permission"), http.StatusForbidden)
+
+	mockAuditLogger.EXPECT().Record(gomock.Any()).Return(errors.New(""))
+	testJWTVerificationRequest(t, newRequest("/with/auth"), http.StatusInternalServerError)
+
+	testAuthMiddlewareEnd()
+}
+
+func TestWithoutImpersonation(t *testing.T) {
+	testAuthMiddlewareInit(t)
+	token, err := generateJWT(defaultTenant, authenticatedUser, nil, time.Now().Add(time.Hour))
+	require.Nil(t, err)
+
+	mockRepo.EXPECT().getUserById(tenancy.DEFAULT_TENANT_ID, authenticatedUser.Id).Return(&authenticatedUser, nil)
+	req, _ := http.NewRequest(http.MethodGet, "/without/impersonation", nil)
+	req.AddCookie(&http.Cookie{Name: SESSION_COOKIE, Value: token})
+	testJWTVerificationRequest(t, req, http.StatusOK)
+
+	testAuthMiddlewareEnd()
+}
@@ -4,32 +4,78 @@ import (
 	"database/sql"
 	"regexp"
 	"time"
+	"userland/webauthn"
+
+	"github.com/lib/pq"
+)
+
+var (
+	emailFormat = regexp.MustCompile(`^[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,4}$`)
 )
 
 type User struct {
-	Id                 int            `json:"id"`
-	Fullname           string         `json:"fullname"`
-	Email              string         `json:"email"`
-	Password           string         `json:"password"`
-	Location           sql.NullString `json:"location"`
-	Bio                sql.NullString `json:"bio"`
-	Web                sql.NullString `json:"web"`
-	Verified           bool           `json:"verified"`
-	ProfilePicture     []byte         `json:"picture" db:"picture"`
-	VerificationToken  sql.NullString `json:"verification_token" db:"verification_token"`
-	ResetPasswordToken sql.NullString `json:"reset_password_token" db:"reset_password_token"`
-	CreatedAt          time.Time      `json:"created_at" db:"created_at"`
+	Id                    int            `json:"id"`
+	Fullname              string         `json:"fullname"`
+	Email                 string         `json:"email"`
+	Password              string         `json:"password"`
+	Location              sql.NullString `json:"location"`
+	Bio                   sql.NullString `json:"bio"`
+	Web                   sql.NullString `json:"web"`
+	Verified              bool           `json:"verified"`
+	PictureKey            sql.NullString `json:"picture_key" db:"picture_key"`
+	PictureContentType    sql.NullString `json:"picture_content_type" db:"picture_content_type"`
+	PictureHash           sql.NullString `json:"picture_hash" db:"picture_hash"`
+	VerificationToken     sql.NullString `json:"verification_token" db:"verification_token"`
+	ResetPasswordToken    sql.NullString `json:"reset_password_token" db:"reset_password_token"`
+	CreatedAt             time.Time      `json:"created_at" db:"created_at"`
+	LoginToken            sql.NullString `json:"login_token" db:"login_token"`
+	LoginCode             sql.NullString `json:"login_code" db:"login_code"`
+	LoginTokenExpiry      sql.NullTime   `json:"login_token_expires_at" db:"login_token_expires_at"`
+	TokenVersion          int            `json:"token_version" db:"token_version"`
+	PasswordResetRequired bool           `json:"password_reset_required" db:"password_reset_required"`
+	RolesVersion          int            `json:"roles_version" db:"roles_version"`
+	Suspension            sql.NullString `json:"suspension"`
+	SuspensionReason      sql.NullString `json:"suspension_reason" db:"suspension_reason"`
+	SuspendedBy           sql.NullInt64  `json:"suspended_by" db:"suspended_by"`
+	SuspendedAt           sql.NullTime   `json:"suspended_at" db:"suspended_at"`
+	SuspendedUntil        sql.NullTime   `json:"suspended_until" db:"suspended_until"`
+	TenantId              int            `json:"tenant_id" db:"tenant_id"`
+	ExternalId            sql.NullString `json:"external_id" db:"external_id"`
+	DeactivatedAt         sql.NullTime   `json:"deactivated_at" db:"deactivated_at"`
+	DeletedAt             sql.NullTime   `json:"deleted_at" db:"deleted_at"`
+	PurgeAt               sql.NullTime   `json:"purge_at" db:"purge_at"`
+	RestoreToken          sql.NullString `json:"restore_token" db:"restore_token"`
+	Username              sql.NullString `json:"username"`
+	LocationVisibility    string         `json:"location_visibility" db:"location_visibility"`
+	BioVisibility         string         `json:"bio_visibility" db:"bio_visibility"`
+	WebVisibility         string         `json:"web_visibility" db:"web_visibility"`
+	PictureVisibility     string         `json:"picture_visibility" db:"picture_visibility"`
+	ProfileVersion        int            `json:"profile_version" db:"profile_version"`
+	DirectoryDN           sql.NullString `json:"directory_dn" db:"directory_dn"`
 }
 
 func (u *User) ableToLogin() bool {
 	return u.Email != "" && u.Password != ""
 }
 
+// activeSuspension returns SUSPENSION_SUSPENDED or SUSPENSION_BANNED while the
+// account is locked, and an empty string once it isn't or the expiry passed.
+func (u *User) activeSuspension() string {
+	if !u.Suspension.Valid {
+		return ""
+	}
+	if u.SuspendedUntil.Valid && !u.SuspendedUntil.Time.After(time.Now()) {
+		return ""
+	}
+	return u.Suspension.String
+}
+
 type userRegistration struct {
 	Fullname        string `json:"fullname"`
 	Email           string `json:"email"`
 	Password        string `json:"password"`
 	PasswordConfirm string `json:"password_confirm"`
+	InvitationCode  string `json:"invitation_code,omitempty"`
 }
 
 func (u *userRegistration) hasCompleteData() bool {
@@ -49,7 +95,7 @@ func (u *userRegistration) hasValidFullname() bool {
 }
 
 func (u *userRegistration) hasValidEmail() bool {
-	emailFormatValid := regexp.MustCompile(`^[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,4}$`).MatchString(u.Email)
+	emailFormatValid := emailFormat.MatchString(u.Email)
 	return len(u.Email) <= 128 && emailFormatValid
 }
 
@@ -84,3 +130,108 @@ func (req resetPasswordRequest) hasMatchingPassword() bool {
 func (req resetPasswordRequest) hasValidPassword() bool {
 	return len(req.Password) >= 6 && len(req.Password) <= 128
 }
+
+type usernameAvailability struct {
+	Name      string `json:"name"`
+	Available bool   `json:"available"`
+}
+
+type restoreAccountRequest struct {
+	Token string `json:"token"`
+}
+
+type emailLoginRequest struct {
+	Email string `json:"email"`
+}
+
+func (req emailLoginRequest) isValid() bool {
+	return req.Email != ""
+}
+
+type emailLoginExchangeRequest struct {
+	Token string `json:"token"`
+	Email string `json:"email"`
+	Code  string `json:"code"`
+}
+
+func (req emailLoginExchangeRequest) isValid() bool {
+	return req.Token != "" || req.usesCode()
+}
+
+func (req emailLoginExchangeRequest) usesCode() bool {
+	return req.Email != "" && req.Code != ""
+}
+
+type WebAuthnCredential struct {
+	Id           int            `json:"id"`
+	UserId       int            `json:"user_id" db:"user_id"`
+	CredentialId []byte         `json:"credential_id" db:"credential_id"`
+	PublicKey    []byte         `json:"public_key" db:"public_key"`
+	SignCount    int64          `json:"sign_count" db:"sign_count"`
+	Transports   pq.StringArray `json:"transports"`
+	CreatedAt    time.Time      `json:"created_at" db:"created_at"`
+	LastUsedAt   sql.NullTime   `json:"last_used_at" db:"last_used_at"`
+}
+
+func (credential WebAuthnCredential) asWebAuthnCredential() webauthn.Credential {
+	return webauthn.Credential{
+		Id:         credential.CredentialId,
+		PublicKey:  credential.PublicKey,
+		SignCount:  uint32(credential.SignCount),
+		Transports: credential.Transports,
+	}
+}
+
+type webauthnCredentialInfo struct {
+	Id           int        `json:"id"`
+	CredentialId []byte     `json:"credential_id"`
+	Transports   []string   `json:"transports"`
+	CreatedAt    time.Time  `json:"created_at"`
+	LastUsedAt   *time.Time `json:"last_used_at"`
+}
+
+func (credential WebAuthnCredential) info() webauthnCredentialInfo {
+	info := webauthnCredentialInfo{
+		Id:           credential.Id,
+		CredentialId: credential.CredentialId,
+		Transports:   credential.Transports,
+		CreatedAt:    credential.CreatedAt,
+	}
+	if credential.LastUsedAt.Valid {
+		info.LastUsedAt = &credential.LastUsedAt.Time
+	}
+	return info
+}
+
+type UserDevice struct {
+	Id          int       `json:"id"`
+	UserId      int       `json:"user_id" db:"user_id"`
+	Fingerprint string    `json:"fingerprint"`
+	UserAgent   string    `json:"user_agent" db:"user_agent"`
+	IPAddress   string    `json:"ip_address" db:"ip_address"`
+	ReportToken string    `json:"report_token" db:"report_token"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	LastSeenAt  time.Time `json:"last_seen_at" db:"last_seen_at"`
+
+	ReportTokenExpiresAt time.Time `json:"report_token_expires_at" db:"report_token_expires_at"`
+}
+
+type reauthRequest struct {
+	Password  string                      `json:"password"`
+	Assertion *webauthn.AssertionResponse `json:"assertion"`
+}
+
+func (req reauthRequest) isValid() bool {
+	return req.Password != "" || req.Assertion != nil
+}
+
+type deviceReportRequest struct {
+	Token string `json:"token"`
+}
+
+type Role struct {
+	Id          int            `json:"id"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Permissions pq.StringArray `json:"permissions"`
+}
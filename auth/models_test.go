@@ -31,7 +31,6 @@ func resetUserModel() {
 		Bio:                sql.NullString{String: "Hi there", Valid: true},
 		Web:                sql.NullString{String: "https://example.com", Valid: true},
 		Verified:           true,
-		ProfilePicture:     nil,
 		VerificationToken:  sql.NullString{String: "", Valid: false},
 		ResetPasswordToken: sql.NullString{String: "", Valid: false},
 		CreatedAt:          time.Now(),
@@ -175,3 +174,31 @@ func TestResetPasswordRequestHasMatchingPassword(t *testing.T) {
 
 	resetResetPasswordRequestModel()
 }
+
+func TestEmailLoginExchangeRequestValidity(t *testing.T) {
+	exchangeReq := emailLoginExchangeRequest{Token: "newtokennewtokennewtokennewtoken"}
+	assert.True(t, exchangeReq.isValid(), "Exchange request should be valid when token is provided")
+	assert.False(t, exchangeReq.usesCode(), "Exchange request should not use code when only token is provided")
+
+	exchangeReq = emailLoginExchangeRequest{Email: "user@example.com", Code: "123456"}
+	assert.True(t, exchangeReq.isValid(), "Exchange request should be valid when email and code are provided")
+	assert.True(t, exchangeReq.usesCode(), "Exchange request should use code when email and code are provided")
+
+	exchangeReq = emailLoginExchangeRequest{Code: "123456"}
+	assert.False(t, exchangeReq.isValid(), "Exchange request should not be valid when code is provided without email")
+}
+
+func TestUserActiveSuspension(t *testing.T) {
+	suspendedUser := User{}
+	assert.Empty(t, suspendedUser.activeSuspension())
+
+	suspendedUser.Suspension = sql.NullString{String: SUSPENSION_BANNED, Valid: true}
+	assert.Equal(t, SUSPENSION_BANNED, suspendedUser.activeSuspension(), "Suspension without expiry should apply indefinitely")
+
+	suspendedUser.Suspension.String = SUSPENSION_SUSPENDED
+	suspendedUser.SuspendedUntil = sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true}
+	assert.Equal(t, SUSPENSION_SUSPENDED, suspendedUser.activeSuspension())
+
+	suspendedUser.SuspendedUntil.Time = time.Now().Add(-time.Minute)
+	assert.Empty(t, suspendedUser.activeSuspension(), "Suspension should be lifted once it expires")
+}
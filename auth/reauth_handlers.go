@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	ulanderrors "userland/errors"
+	"userland/request"
+	"userland/response"
+	"userland/tenancy"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// BeginReauthentication starts an assertion ceremony over the user's own
+// passkeys, whose result is then sent to Reauthenticate.
+func (handler AuthHandler) BeginReauthentication(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*User)
+
+	credentials, err := handler.WebAuthnRepo.getCredentialsByUserId(tenancy.FromRequest(r).Id, user.Id)
+	if err != nil {
+		log.Warn(err)
+		response.RespondInternalError(w, ulanderrors.ErrWebAuthnQueryExec)
+		return
+	}
+
+	if len(credentials) == 0 {
+		log.Info("User has no passkey to re-authenticate with")
+		response.RespondBadRequest(w, ulanderrors.ErrWebAuthnCredentialNotFound)
+		return
+	}
+
+	challenge, ok := handler.startCeremony(w, r, CEREMONY_WEBAUTHN_REAUTH, user.Id)
+	if !ok {
+		return
+	}
+
+	options := handler.RelyingParty.LoginOptions(challenge, credentialDescriptors(credentials))
+
+	log.Info("WebAuthn re-authentication started")
+	response.RespondSuccessWithBody(w, map[string]interface{}{"publicKey": options})
+}
+
+// Reauthenticate checks the password or a passkey assertion of the logged in
+// user and marks the session as recently authenticated, which WithRecentAuth
+// requires for sensitive routes.
+func (handler AuthHandler) Reauthenticate(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*User)
+	claims := r.Context().Value("claims").(*Claims)
+
+	var reauthReq reauthRequest
+	err = request.ParseJSON(r.Body, &reauthReq)
+	if err != nil {
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrParseBody)
+		return
+	}
+
+	if !reauthReq.isValid() {
+		log.Info("Re-authentication request is incomplete")
+		response.RespondBadRequest(w, ulanderrors.ErrReauthIncomplete)
+		return
+	}
+
+	if !handler.ReauthAttemptLimiter.Allow(strconv.Itoa(user.Id)) {
+		log.Info("Re-authentication attempts for the user exceeded the limit")
+		response.RespondTooManyRequests(w, ulanderrors.ErrReauthRateLimited)
+		return
+	}
+
+	if reauthReq.Assertion != nil {
+		ceremony, err := handler.finishCeremony(r, CEREMONY_WEBAUTHN_REAUTH)
+		if err != nil || ceremony.UserId != user.Id {
+			log.Info("WebAuthn re-authentication ceremony is missing or belongs to another user")
+			response.RespondBadRequest(w, ulanderrors.ErrWebAuthnCeremonyNotStarted)
+			return
+		}
+
+		_, _, ok := handler.verifyAssertion(w, r, ceremony, *reauthReq.Assertion)
+		if !ok {
+			return
+		}
+		clearCeremonyCookie(w, WEBAUTHN_SESSION_COOKIE)
+	} else {
+		err = handler.authenticator().verify(user, reauthReq.Password)
+		if err != nil {
+			log.Info(err)
+			response.RespondUnauthorized(w, ulanderrors.ErrReauthIncorrectCredential)
+			return
+		}
+	}
+
+	reauthenticatedClaims := *claims
+	reauthenticatedClaims.AuthTime = time.Now().Unix()
+	err = reissueSession(w, r, reauthenticatedClaims)
+	if err != nil {
+		log.Info(err)
+		response.RespondInternalError(w, ulanderrors.ErrLoginJWT)
+		return
+	}
+
+	handler.ReauthAttemptLimiter.Reset(strconv.Itoa(user.Id))
+
+	log.Info("Re-authentication successful")
+	response.RespondSuccess(w)
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+	"userland/tenancy"
+	"userland/webauthn/webauthntest"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	SAMPLE_REAUTH_PASSWORD = "password"
+)
+
+var (
+	staleSessionClaims = Claims{
+		UserId:         webauthnUser.Id,
+		AuthTime:       time.Now().Add(-HOURS_IN_DAY * time.Hour).Unix(),
+		StandardClaims: jwt.StandardClaims{ExpiresAt: time.Now().Add(time.Hour).Unix()},
+	}
+)
+
+// withSession puts the user and a session which was authenticated long ago
+// in the request context, as WithVerifyJWT would.
+func withSession(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		passwordHash, _ := bcrypt.GenerateFromPassword([]byte(SAMPLE_REAUTH_PASSWORD), bcrypt.MinCost)
+		user := webauthnUser
+		user.Password = string(passwordHash)
+		claims := staleSessionClaims
+
+		ctx := context.WithValue(r.Context(), "user", &user)
+		ctx = context.WithValue(ctx, "claims", &claims)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+func parseSessionCookie(t *testing.T, cookies []*http.Cookie) *Claims {
+	cookie := findCookie(cookies, SESSION_COOKIE)
+	require.NotNil(t, cookie, "Response should reissue the session cookie")
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(cookie.Value, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(""), nil
+	})
+	require.Nil(t, err)
+	return claims
+}
+
+func TestReauthenticateWithPassword(t *testing.T) {
+	testAuthHandlerInit(t)
+
+	res := serveWithCookies(t, http.MethodPost, "/me/reauth", reauthRequest{}, nil)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+
+	res = serveWithCookies(t, http.MethodPost, "/me/reauth", reauthRequest{Password: "wrongpassword"}, nil)
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+	assert.Nil(t, findCookie(res.Result().Cookies(), SESSION_COOKIE))
+
+	res = serveWithCookies(t, http.MethodPost, "/me/reauth", reauthRequest{Password: SAMPLE_REAUTH_PASSWORD}, nil)
+	assert.Equal(t, http.StatusOK, res.Code)
+	claims := parseSessionCookie(t, res.Result().Cookies())
+	assert.True(t, claims.authenticatedWithin(time.Minute), "Session should be marked as recently authenticated")
+	assert.Equal(t, staleSessionClaims.ExpiresAt, claims.ExpiresAt, "Session should keep its expiry")
+
+	testAuthHandlerEnd()
+}
+
+func TestReauthenticateIsRateLimited(t *testing.T) {
+	testAuthHandlerInit(t)
+
+	for i := 0; i < REAUTH_ATTEMPT_LIMIT; i++ {
+		res := serveWithCookies(t, http.MethodPost, "/me/reauth", reauthRequest{Password: "wrongpassword"}, nil)
+		require.Equal(t, http.StatusUnauthorized, res.Code)
+	}
+
+	res := serveWithCookies(t, http.MethodPost, "/me/reauth", reauthRequest{Password: SAMPLE_REAUTH_PASSWORD}, nil)
+	assert.Equal(t, http.StatusTooManyRequests, res.Code)
+
+	testAuthHandlerEnd()
+}
+
+func TestReauthenticateWithPasskey(t *testing.T) {
+	testAuthHandlerInit(t)
+	authenticator := webauthntest.NewAuthenticator(SAMPLE_RP_ID, SAMPLE_RP_ORIGIN)
+	authenticator.UserVerified = false
+	credential := storedCredential(authenticator)
+
+	mockWebAuthnRepo.EXPECT().getCredentialsByUserId(tenancy.DEFAULT_TENANT_ID, webauthnUser.Id).Return([]WebAuthnCredential{}, nil)
+	res := serveWithCookies(t, http.MethodPost, "/me/reauth/webauthn", nil, nil)
+	assert.Equal(t, http.StatusBadRequest, res.Code, "Re-authentication with a passkey needs a registered passkey")
+
+	mockWebAuthnRepo.EXPECT().getCredentialsByUserId(tenancy.DEFAULT_TENANT_ID, webauthnUser.Id).Return([]WebAuthnCredential{*credential}, nil)
+	challenge, cookies := beginCeremony(t, "/me/reauth/webauthn", nil, nil)
+
+	assertion := authenticator.Assert(challenge)
+	res = serveWithCookies(t, http.MethodPost, "/me/reauth", reauthRequest{Assertion: &assertion}, nil)
+	assert.Equal(t, http.StatusBadRequest, res.Code, "Re-authentication without a started ceremony should fail")
+
+	gomock.InOrder(
+		mockWebAuthnRepo.EXPECT().getCredentialByCredentialId(tenancy.DEFAULT_TENANT_ID, authenticator.CredentialId).Return(credential, nil),
+		mockWebAuthnRepo.EXPECT().updateSignCount(tenancy.DEFAULT_TENANT_ID, credential, uint32(2)).Return(nil),
+		mockRepo.EXPECT().getUserById(tenancy.DEFAULT_TENANT_ID, webauthnUser.Id).Return(&webauthnUser, nil),
+	)
+	assertion = authenticator.Assert(challenge)
+	res = serveWithCookies(t, http.MethodPost, "/me/reauth", reauthRequest{Assertion: &assertion}, cookies)
+	assert.Equal(t, http.StatusOK, res.Code)
+	claims := parseSessionCookie(t, res.Result().Cookies())
+	assert.True(t, claims.authenticatedWithin(time.Minute))
+
+	testAuthHandlerEnd()
+}
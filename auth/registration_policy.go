@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"strings"
+	"userland/config"
+	ulanderrors "userland/errors"
+	"userland/tenancy"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	errRegistrationInvitationInvalid = errors.New("Registration invitation is invalid, expired or already used")
+)
+
+// RegistrationPolicy decides who may sign up. Its zero value lets anyone in.
+type RegistrationPolicy struct {
+	Mode              string
+	AllowedDomains    []string
+	DisposableDomains map[string]bool
+}
+
+// GetRegistrationPolicy reads the policy from the configuration. A missing
+// disposable domain list is logged and leaves the denylist empty.
+func GetRegistrationPolicy() RegistrationPolicy {
+	disposableDomains, err := loadDomainList(config.GetDisposableDomainsFile())
+	if err != nil {
+		log.Warn(err)
+	}
+	return RegistrationPolicy{
+		Mode:              config.GetRegistrationMode(),
+		AllowedDomains:    config.GetRegistrationAllowedDomains(),
+		DisposableDomains: disposableDomains,
+	}
+}
+
+// forTenant applies the tenant's own registration mode and allowed domains,
+// if it has any. The disposable domain denylist is shared by every tenant.
+func (policy RegistrationPolicy) forTenant(tenant tenancy.Tenant) RegistrationPolicy {
+	if !tenant.RegistrationMode.Valid {
+		return policy
+	}
+
+	allowedDomains := []string{}
+	for _, domain := range tenant.RegistrationAllowedDomains {
+		allowedDomains = append(allowedDomains, strings.ToLower(strings.TrimSpace(domain)))
+	}
+	return RegistrationPolicy{
+		Mode:              config.ParseRegistrationMode(tenant.RegistrationMode.String),
+		AllowedDomains:    allowedDomains,
+		DisposableDomains: policy.DisposableDomains,
+	}
+}
+
+// refusal returns the reason the registration is refused, if it is. The
+// invitation code itself is only checked when the user is created.
+func (policy RegistrationPolicy) refusal(registration userRegistration) (ulanderrors.UserlandError, bool) {
+	if policy.Mode == config.REGISTRATION_MODE_CLOSED {
+		return ulanderrors.ErrRegistrationClosed, true
+	}
+
+	domain := emailDomain(registration.Email)
+	if policy.isDisposable(domain) {
+		return ulanderrors.ErrRegistrationEmailDomainDisposable, true
+	}
+
+	switch policy.Mode {
+	case config.REGISTRATION_MODE_DOMAIN_ALLOWLIST:
+		if !policy.isAllowed(domain) {
+			return ulanderrors.ErrRegistrationEmailDomainNotAllowed, true
+		}
+	case config.REGISTRATION_MODE_INVITE_ONLY:
+		if registration.InvitationCode == "" {
+			return ulanderrors.ErrRegistrationInvitationRequired, true
+		}
+	}
+	return ulanderrors.UserlandError{}, false
+}
+
+func (policy RegistrationPolicy) requiresInvitation() bool {
+	return policy.Mode == config.REGISTRATION_MODE_INVITE_ONLY
+}
+
+func (policy RegistrationPolicy) isAllowed(domain string) bool {
+	for _, allowed := range policy.AllowedDomains {
+		if domain == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// isDisposable checks the domain and every domain it's a subdomain of.
+func (policy RegistrationPolicy) isDisposable(domain string) bool {
+	for domain != "" {
+		if policy.DisposableDomains[domain] {
+			return true
+		}
+		dot := strings.Index(domain, ".")
+		if dot < 0 {
+			break
+		}
+		domain = domain[dot+1:]
+	}
+	return false
+}
+
+func emailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(email[at+1:])
+}
+
+// loadDomainList reads one domain per line, skipping blank lines and lines
+// starting with #.
+func loadDomainList(path string) (map[string]bool, error) {
+	domains := map[string]bool{}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return domains, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if line != "" && !strings.HasPrefix(line, "#") {
+			domains[line] = true
+		}
+	}
+	return domains, scanner.Err()
+}
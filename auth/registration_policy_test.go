@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"testing"
+	"userland/config"
+	ulanderrors "userland/errors"
+	"userland/tenancy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistrationPolicyRefusal(t *testing.T) {
+	registration := userRegistration{Email: "user@ourcompany.com"}
+	disposableDomains := map[string]bool{"mailinator.com": true}
+
+	_, refused := RegistrationPolicy{}.refusal(registration)
+	assert.False(t, refused, "Zero policy should let anyone in")
+
+	refusal, refused := RegistrationPolicy{Mode: config.REGISTRATION_MODE_CLOSED}.refusal(registration)
+	assert.True(t, refused)
+	assert.Equal(t, ulanderrors.ErrRegistrationClosed, refusal)
+
+	openPolicy := RegistrationPolicy{Mode: config.REGISTRATION_MODE_OPEN, DisposableDomains: disposableDomains}
+	refusal, refused = openPolicy.refusal(userRegistration{Email: "user@eu.mailinator.com"})
+	assert.True(t, refused, "Subdomains of disposable domains should be refused")
+	assert.Equal(t, ulanderrors.ErrRegistrationEmailDomainDisposable, refusal)
+
+	allowlistPolicy := RegistrationPolicy{Mode: config.REGISTRATION_MODE_DOMAIN_ALLOWLIST, AllowedDomains: []string{"ourcompany.com"}}
+	_, refused = allowlistPolicy.refusal(userRegistration{Email: "user@OurCompany.com"})
+	assert.False(t, refused)
+	refusal, refused = allowlistPolicy.refusal(userRegistration{Email: "user@ourcompany.com.evil.com"})
+	assert.True(t, refused)
+	assert.Equal(t, ulanderrors.ErrRegistrationEmailDomainNotAllowed, refusal)
+
+	invitePolicy := RegistrationPolicy{Mode: config.REGISTRATION_MODE_INVITE_ONLY}
+	refusal, refused = invitePolicy.refusal(registration)
+	assert.True(t, refused)
+	assert.Equal(t, ulanderrors.ErrRegistrationInvitationRequired, refusal)
+	registration.InvitationCode = "code"
+	_, refused = invitePolicy.refusal(registration)
+	assert.False(t, refused)
+	assert.True(t, invitePolicy.requiresInvitation())
+}
+
+func TestLoadDomainList(t *testing.T) {
+	file, err := ioutil.TempFile("", "domains")
+	require.Nil(t, err)
+	defer os.Remove(file.Name())
+	_, err = file.WriteString("# comment\n\nMailinator.com\n  yopmail.com  \n")
+	require.Nil(t, err)
+	file.Close()
+
+	domains, err := loadDomainList(file.Name())
+	require.Nil(t, err)
+	assert.Equal(t, map[string]bool{"mailinator.com": true, "yopmail.com": true}, domains)
+
+	domains, err = loadDomainList("../" + config.DEFAULT_DISPOSABLE_DOMAINS_FILE)
+	require.Nil(t, err, "Bundled disposable domain list should load")
+	assert.True(t, domains["mailinator.com"])
+
+	_, err = loadDomainList(file.Name() + ".missing")
+	assert.NotNil(t, err)
+}
+
+func TestRegistrationPolicyForTenant(t *testing.T) {
+	disposableDomains := map[string]bool{"mailinator.com": true}
+	policy := RegistrationPolicy{Mode: config.REGISTRATION_MODE_OPEN, DisposableDomains: disposableDomains}
+
+	assert.Equal(t, policy, policy.forTenant(tenancy.Tenant{Id: 2}), "Tenant without its own mode should keep the configured policy")
+
+	brandPolicy := policy.forTenant(tenancy.Tenant{
+		Id:                         2,
+		RegistrationMode:           sql.NullString{String: "Domain_Allowlist", Valid: true},
+		RegistrationAllowedDomains: []string{" Brand.com "},
+	})
+	assert.Equal(t, config.REGISTRATION_MODE_DOMAIN_ALLOWLIST, brandPolicy.Mode)
+	assert.Equal(t, []string{"brand.com"}, brandPolicy.AllowedDomains)
+	assert.Equal(t, disposableDomains, brandPolicy.DisposableDomains)
+
+	_, refused := brandPolicy.refusal(userRegistration{Email: "user@brand.com"})
+	assert.False(t, refused)
+	_, refused = brandPolicy.refusal(userRegistration{Email: "user@ourcompany.com"})
+	assert.True(t, refused)
+}
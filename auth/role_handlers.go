@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"userland/audit"
+	ulanderrors "userland/errors"
+	"userland/response"
+	"userland/tenancy"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	ACTION_ROLE_ASSIGN = "admin.role.assign"
+	ACTION_ROLE_REVOKE = "admin.role.revoke"
+)
+
+func (handler AuthHandler) GetRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := handler.RoleRepo.getRoles()
+	if err != nil {
+		log.Warn(err)
+		response.RespondInternalError(w, ulanderrors.ErrRoleQueryExec)
+		return
+	}
+
+	log.Info("Get roles successful")
+	response.RespondSuccessWithBody(w, map[string]interface{}{"roles": roles})
+}
+
+func (handler AuthHandler) GetUserRoles(w http.ResponseWriter, r *http.Request) {
+	userId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		log.Info(err)
+		response.RespondNotFound(w, ulanderrors.ErrRoleNotFound)
+		return
+	}
+
+	roles, err := handler.RoleRepo.getUserRoles(tenancy.FromRequest(r).Id, userId)
+	if err != nil {
+		log.Warn(err)
+		response.RespondInternalError(w, ulanderrors.ErrRoleQueryExec)
+		return
+	}
+
+	log.Info("Get user roles successful")
+	response.RespondSuccessWithBody(w, map[string]interface{}{"roles": roles})
+}
+
+func (handler AuthHandler) AssignUserRole(w http.ResponseWriter, r *http.Request) {
+	userId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		log.Info(err)
+		response.RespondNotFound(w, ulanderrors.ErrRoleNotFound)
+		return
+	}
+
+	err = handler.RoleRepo.assignRole(tenancy.FromRequest(r).Id, userId, mux.Vars(r)["role"])
+	if err != nil {
+		respondRoleError(w, err)
+		return
+	}
+
+	handler.recordAdminAction(r, ACTION_ROLE_ASSIGN, userId, mux.Vars(r)["role"])
+
+	log.Info("Assign user role successful")
+	response.RespondSuccess(w)
+}
+
+func (handler AuthHandler) RevokeUserRole(w http.ResponseWriter, r *http.Request) {
+	userId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		log.Info(err)
+		response.RespondNotFound(w, ulanderrors.ErrRoleNotFound)
+		return
+	}
+
+	err = handler.RoleRepo.revokeRole(tenancy.FromRequest(r).Id, userId, mux.Vars(r)["role"])
+	if err != nil {
+		respondRoleError(w, err)
+		return
+	}
+
+	handler.recordAdminAction(r, ACTION_ROLE_REVOKE, userId, mux.Vars(r)["role"])
+
+	log.Info("Revoke user role successful")
+	response.RespondSuccess(w)
+}
+
+func (handler AuthHandler) recordAdminAction(r *http.Request, action string, userId int, role string) {
+	admin := r.Context().Value("user").(*User)
+	err := handler.AuditLogger.Record(audit.NewEvent(r, admin.Id, action, userId, map[string]interface{}{"role": role}))
+	if err != nil {
+		log.Warn(err)
+	}
+}
+
+func respondRoleError(w http.ResponseWriter, err error) {
+	if err == sql.ErrNoRows {
+		log.Info(err)
+		response.RespondNotFound(w, ulanderrors.ErrRoleNotFound)
+		return
+	}
+	log.Warn(err)
+	response.RespondInternalError(w, ulanderrors.ErrRoleQueryExec)
+}
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"userland/audit"
+	"userland/tenancy"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRoleHandlersInit(t *testing.T) {
+	testAuthHandlerInit(t)
+	router.HandleFunc("/admin/roles", handler.GetRoles).Methods(http.MethodGet)
+	router.HandleFunc("/admin/users/{id}/roles", handler.GetUserRoles).Methods(http.MethodGet)
+	router.HandleFunc("/admin/users/{id}/roles/{role}", withUser(handler.AssignUserRole)).Methods(http.MethodPut)
+	router.HandleFunc("/admin/users/{id}/roles/{role}", withUser(handler.RevokeUserRole)).Methods(http.MethodDelete)
+}
+
+func TestGetRoles(t *testing.T) {
+	testRoleHandlersInit(t)
+	adminRole := Role{Id: 1, Name: ROLE_ADMIN, Permissions: []string{PERMISSION_ROLES_MANAGE, PERMISSION_USERS_READ}}
+
+	mockRoleRepo.EXPECT().getRoles().Return([]Role{adminRole}, nil)
+	res := serveWithCookies(t, http.MethodGet, "/admin/roles", nil, nil)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Body.String(), PERMISSION_USERS_READ)
+
+	sessionRoles = map[int][]string{2: {ROLE_ADMIN}}
+	res = serveWithCookies(t, http.MethodGet, "/admin/users/2/roles", nil, nil)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.JSONEq(t, `{"roles": ["admin"]}`, res.Body.String())
+	sessionRoles = nil
+
+	testAuthHandlerEnd()
+}
+
+func TestAssignAndRevokeUserRole(t *testing.T) {
+	testRoleHandlersInit(t)
+
+	mockRoleRepo.EXPECT().assignRole(tenancy.DEFAULT_TENANT_ID, 2, ROLE_ADMIN).Return(nil)
+	expectRoleAudit(t, ACTION_ROLE_ASSIGN, 2)
+	mockRoleRepo.EXPECT().assignRole(tenancy.DEFAULT_TENANT_ID, 2, "unknown").Return(sql.ErrNoRows)
+	assert.Equal(t, http.StatusOK, serveWithCookies(t, http.MethodPut, "/admin/users/2/roles/admin", nil, nil).Code)
+	assert.Equal(t, http.StatusNotFound, serveWithCookies(t, http.MethodPut, "/admin/users/2/roles/unknown", nil, nil).Code)
+	assert.Equal(t, http.StatusNotFound, serveWithCookies(t, http.MethodPut, "/admin/users/abc/roles/admin", nil, nil).Code)
+
+	mockRoleRepo.EXPECT().revokeRole(tenancy.DEFAULT_TENANT_ID, 2, ROLE_ADMIN).Return(nil)
+	expectRoleAudit(t, ACTION_ROLE_REVOKE, 2)
+	mockRoleRepo.EXPECT().revokeRole(tenancy.DEFAULT_TENANT_ID, 3, ROLE_ADMIN).Return(errors.New(""))
+	assert.Equal(t, http.StatusOK, serveWithCookies(t, http.MethodDelete, "/admin/users/2/roles/admin", nil, nil).Code)
+	assert.Equal(t, http.StatusInternalServerError, serveWithCookies(t, http.MethodDelete, "/admin/users/3/roles/admin", nil, nil).Code)
+
+	testAuthHandlerEnd()
+}
+
+func TestAssignRoleToUserOfAnotherTenant(t *testing.T) {
+	testRoleHandlersInit(t)
+	otherTenant := tenancy.Tenant{Id: 2, Slug: "other"}
+
+	mockRoleRepo.EXPECT().assignRole(otherTenant.Id, 2, ROLE_ADMIN).Return(sql.ErrNoRows)
+	mockRoleRepo.EXPECT().revokeRole(otherTenant.Id, 2, ROLE_ADMIN).Return(sql.ErrNoRows)
+	for _, method := range []string{http.MethodPut, http.MethodDelete} {
+		req, err := http.NewRequest(method, "/admin/users/2/roles/admin", nil)
+		require.Nil(t, err)
+		req = req.WithContext(context.WithValue(req.Context(), "tenant", &otherTenant))
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		assert.Equal(t, http.StatusNotFound, res.Code)
+	}
+
+	testAuthHandlerEnd()
+}
+
+func TestSessionCarriesRoles(t *testing.T) {
+	testAuthHandlerInit(t)
+	adminUser := webauthnUser
+	adminUser.RolesVersion = 3
+	sessionRoles = map[int][]string{adminUser.Id: {ROLE_ADMIN}}
+
+	gomock.InOrder(
+		mockRepo.EXPECT().loginUser(tenancy.DEFAULT_TENANT_ID, adminUser.Email, adminUser.Password).Return(nil),
+		mockRepo.EXPECT().getUserByEmail(tenancy.DEFAULT_TENANT_ID, adminUser.Email).Return(&adminUser, nil),
+		mockWebAuthnRepo.EXPECT().getCredentialsByUserId(tenancy.DEFAULT_TENANT_ID, adminUser.Id).Return([]WebAuthnCredential{}, nil),
+		expectKnownDevice(&adminUser),
+	)
+	res := serveWithCookies(t, http.MethodPost, "/auth/login", adminUser, nil)
+	require.Equal(t, http.StatusOK, res.Code)
+	sessionRoles = nil
+
+	claims := parseSessionCookie(t, res.Result().Cookies())
+	assert.Equal(t, []string{ROLE_ADMIN}, claims.Roles)
+	assert.Equal(t, adminUser.RolesVersion, claims.RolesVersion)
+
+	testAuthHandlerEnd()
+}
+
+func expectRoleAudit(t *testing.T, action string, userId int) {
+	mockAuditLogger.EXPECT().Record(gomock.Any()).DoAndReturn(func(event audit.Event) error {
+		assert.Equal(t, webauthnUser.Id, event.ActorId)
+		assert.Equal(t, action, event.Action)
+		assert.Equal(t, userId, event.UserId)
+		assert.JSONEq(t, `{"role": "admin"}`, event.Details.String())
+		return nil
+	})
+}
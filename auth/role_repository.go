@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"database/sql"
+	"userland/appcontext"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const (
+	ROLE_ADMIN = "admin"
+
+	PERMISSION_USERS_READ        = "users:read"
+	PERMISSION_USERS_WRITE       = "users:write"
+	PERMISSION_USERS_DELETE      = "users:delete"
+	PERMISSION_USERS_SUSPEND     = "users:suspend"
+	PERMISSION_USERS_IMPERSONATE = "users:impersonate"
+	PERMISSION_ROLES_MANAGE      = "roles:manage"
+)
+
+const (
+	SELECT_ROLES_QUERY             = "SELECT role.id, role.name, role.description, array_remove(array_agg(permission.name ORDER BY permission.name), NULL) AS permissions FROM role LEFT JOIN role_permission ON role_permission.role_id=role.id LEFT JOIN permission ON permission.id=role_permission.permission_id GROUP BY role.id ORDER BY role.id"
+	SELECT_USER_ROLES_QUERY        = "SELECT role.name FROM role JOIN user_role ON user_role.role_id=role.id WHERE user_role.user_id=$1 AND EXISTS (SELECT 1 FROM \"user\" WHERE id=$1 AND tenant_id=$2) ORDER BY role.name"
+	SELECT_ROLES_PERMISSIONS_QUERY = "SELECT DISTINCT permission.name FROM permission JOIN role_permission ON role_permission.permission_id=permission.id JOIN role ON role.id=role_permission.role_id WHERE role.name=ANY($1)"
+	SELECT_ROLE_ID_BY_NAME_QUERY   = "SELECT id FROM role WHERE name=$1"
+	INSERT_USER_ROLE_QUERY         = "INSERT INTO user_role (user_id, role_id) SELECT $1, $2 WHERE EXISTS (SELECT 1 FROM \"user\" WHERE id=$1 AND tenant_id=$3) ON CONFLICT DO NOTHING"
+	DELETE_USER_ROLE_QUERY         = "DELETE FROM user_role USING role WHERE user_role.role_id=role.id AND user_role.user_id=$1 AND role.name=$2 AND EXISTS (SELECT 1 FROM \"user\" WHERE id=$1 AND tenant_id=$3)"
+	BUMP_ROLES_VERSION_QUERY       = "UPDATE \"user\" SET roles_version=roles_version+1 WHERE id=$1 AND tenant_id=$2"
+)
+
+type roleRepositoryInterface interface {
+	getRoles() ([]Role, error)
+	getUserRoles(tenantId int, userId int) ([]string, error)
+	getRolesPermissions(roles []string) ([]string, error)
+	assignRole(tenantId int, userId int, role string) error
+	revokeRole(tenantId int, userId int, role string) error
+}
+
+type roleRepository struct {
+	db *sqlx.DB
+}
+
+func GetRoleRepository() *roleRepository {
+	repo := roleRepository{appcontext.GetDB()}
+	return &repo
+}
+
+func (repo *roleRepository) getRoles() ([]Role, error) {
+	roles := []Role{}
+	err := repo.db.Select(&roles, SELECT_ROLES_QUERY)
+	return roles, err
+}
+
+// getUserRoles lists the roles of a user in the tenant, none for users of
+// other tenants.
+func (repo *roleRepository) getUserRoles(tenantId int, userId int) ([]string, error) {
+	roles := []string{}
+	err := repo.db.Select(&roles, SELECT_USER_ROLES_QUERY, userId, tenantId)
+	return roles, err
+}
+
+func (repo *roleRepository) getRolesPermissions(roles []string) ([]string, error) {
+	permissions := []string{}
+	err := repo.db.Select(&permissions, SELECT_ROLES_PERMISSIONS_QUERY, pq.StringArray(roles))
+	return permissions, err
+}
+
+// assignRole attaches the role to the user and bumps their roles version, so
+// that sessions carrying the previous roles are refreshed. Users of other
+// tenants are reported as missing with sql.ErrNoRows.
+func (repo *roleRepository) assignRole(tenantId int, userId int, role string) error {
+	tx, err := repo.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var roleId int
+	err = tx.Get(&roleId, SELECT_ROLE_ID_BY_NAME_QUERY, role)
+	if err != nil {
+		return err
+	}
+
+	err = bumpRolesVersion(tx, tenantId, userId)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(INSERT_USER_ROLE_QUERY, userId, roleId, tenantId)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (repo *roleRepository) revokeRole(tenantId int, userId int, role string) error {
+	tx, err := repo.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(DELETE_USER_ROLE_QUERY, userId, role, tenantId)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	err = bumpRolesVersion(tx, tenantId, userId)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func bumpRolesVersion(tx *sqlx.Tx, tenantId int, userId int) error {
+	result, err := tx.Exec(BUMP_ROLES_VERSION_QUERY, userId, tenantId)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err == nil && affected == 0 {
+		return sql.ErrNoRows
+	}
+	return err
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"time"
+	"userland/tenancy"
+)
+
+const (
+	SESSION_COOKIE = "token"
+)
+
+func setSessionCookie(w http.ResponseWriter, token string, expirationTime time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:    SESSION_COOKIE,
+		Value:   token,
+		Expires: expirationTime,
+	})
+}
+
+// RenewSession re-signs the session of an authenticated request with the
+// user's current token version, keeping its original expiry. It lets the
+// caller stay logged in after an operation revoked every other session.
+func RenewSession(w http.ResponseWriter, r *http.Request, user *User) error {
+	claims, ok := r.Context().Value("claims").(*Claims)
+	if !ok {
+		return errors.New("Request doesn't carry a session")
+	}
+
+	renewedClaims := *claims
+	renewedClaims.TokenVersion = user.TokenVersion
+	return reissueSession(w, r, renewedClaims)
+}
+
+// SwitchOrganization re-signs the session of an authenticated request with
+// the given organization as its active one, or none when it's zero. Callers
+// have to check the user's membership first.
+func SwitchOrganization(w http.ResponseWriter, r *http.Request, organizationId int) error {
+	claims, ok := r.Context().Value("claims").(*Claims)
+	if !ok {
+		return errors.New("Request doesn't carry a session")
+	}
+
+	switchedClaims := *claims
+	switchedClaims.OrganizationId = organizationId
+	return reissueSession(w, r, switchedClaims)
+}
+
+// ActiveOrganization returns the organization the session is switched to, or
+// zero when there is none. Membership may have ended since it was switched.
+func ActiveOrganization(r *http.Request) int {
+	claims, ok := r.Context().Value("claims").(*Claims)
+	if !ok {
+		return 0
+	}
+	return claims.OrganizationId
+}
+
+func reissueSession(w http.ResponseWriter, r *http.Request, claims Claims) error {
+	token, err := signClaims(tenancy.FromRequest(r), claims)
+	if err != nil {
+		return err
+	}
+
+	setSessionCookie(w, token, time.Unix(claims.ExpiresAt, 0))
+	return nil
+}
+
+func EndSession(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:   SESSION_COOKIE,
+		Value:  "",
+		MaxAge: -1,
+	})
+}
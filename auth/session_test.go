@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenewSession(t *testing.T) {
+	expirationTime := time.Now().Add(time.Hour).Truncate(time.Second)
+	claims := &Claims{
+		UserId:         1,
+		TokenVersion:   0,
+		StandardClaims: jwt.StandardClaims{ExpiresAt: expirationTime.Unix()},
+	}
+	user := User{Id: 1, TokenVersion: 1}
+
+	req := httptest.NewRequest(http.MethodPost, "/me/password", nil)
+	res := httptest.NewRecorder()
+	assert.NotNil(t, RenewSession(res, req, &user), "Request without a session can't be renewed")
+
+	req = req.WithContext(context.WithValue(req.Context(), "claims", claims))
+	require.Nil(t, RenewSession(res, req, &user))
+
+	cookie := findCookie(res.Result().Cookies(), SESSION_COOKIE)
+	require.NotNil(t, cookie)
+	renewedClaims := &Claims{}
+	_, err := jwt.ParseWithClaims(cookie.Value, renewedClaims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(""), nil
+	})
+	require.Nil(t, err)
+	assert.Equal(t, user.TokenVersion, renewedClaims.TokenVersion)
+	assert.Equal(t, expirationTime.Unix(), renewedClaims.ExpiresAt, "Renewed session should keep its expiry")
+	assert.Equal(t, 0, claims.TokenVersion, "Original claims should not be modified")
+}
+
+func TestSwitchOrganization(t *testing.T) {
+	claims := &Claims{
+		UserId:         1,
+		StandardClaims: jwt.StandardClaims{ExpiresAt: time.Now().Add(time.Hour).Unix()},
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/me/orgs/active", nil)
+	res := httptest.NewRecorder()
+	assert.Equal(t, 0, ActiveOrganization(req))
+	assert.NotNil(t, SwitchOrganization(res, req, 2), "Request without a session can't be switched")
+
+	req = req.WithContext(context.WithValue(req.Context(), "claims", claims))
+	require.Nil(t, SwitchOrganization(res, req, 2))
+
+	switchedClaims := parseSessionCookie(t, res.Result().Cookies())
+	assert.Equal(t, 2, switchedClaims.OrganizationId)
+	assert.Equal(t, claims.ExpiresAt, switchedClaims.ExpiresAt)
+	assert.Equal(t, 0, ActiveOrganization(req), "Original claims should not be modified")
+}
+
+func TestEndSession(t *testing.T) {
+	res := httptest.NewRecorder()
+	EndSession(res)
+
+	cookies := res.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, SESSION_COOKIE, cookies[0].Name)
+	assert.True(t, cookies[0].MaxAge < 0)
+}
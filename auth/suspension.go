@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"net/http"
+	ulanderrors "userland/errors"
+	"userland/response"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	SUSPENSION_SUSPENDED = "suspended"
+	SUSPENSION_BANNED    = "banned"
+)
+
+// refuseSuspendedUser responds with the reason the account is locked, if it
+// currently is, and tells whether it did so.
+func refuseSuspendedUser(w http.ResponseWriter, user *User) bool {
+	refusal, locked := user.lockout()
+	if locked {
+		log.Info("User is locked out: ", refusal.Message)
+		response.RespondForbidden(w, refusal)
+	}
+	return locked
+}
+
+// lockout returns the reason the account is locked, if it currently is.
+// Accounts deprovisioned by the identity provider are locked until it
+// activates them again, and deleted ones until they're restored or purged.
+func (u *User) lockout() (ulanderrors.UserlandError, bool) {
+	if u.DeletedAt.Valid {
+		return ulanderrors.ErrAccountPendingDeletion, true
+	}
+	if u.DeactivatedAt.Valid {
+		return ulanderrors.ErrAccountDeactivated, true
+	}
+
+	switch u.activeSuspension() {
+	case SUSPENSION_BANNED:
+		return ulanderrors.ErrAccountBanned, true
+	case SUSPENSION_SUSPENDED:
+		return ulanderrors.ErrAccountSuspended, true
+	}
+	return ulanderrors.UserlandError{}, false
+}
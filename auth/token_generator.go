@@ -1,47 +1,169 @@
 package auth
 
 import (
-	"math/rand"
+	"crypto/rand"
+	"errors"
+	"math/big"
 	"time"
-	"userland/config"
+	"userland/tenancy"
 
 	"github.com/dgrijalva/jwt-go"
 )
 
-var seededRand *rand.Rand = rand.New(
-	rand.NewSource(time.Now().UnixNano()),
-)
-
 const (
 	TOKEN_CHARS  = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 	TOKEN_LENGTH = 32
 
+	CODE_CHARS  = "0123456789"
+	CODE_LENGTH = 6
+
 	HOURS_IN_DAY = 24
+
+	CEREMONY_WEBAUTHN_REGISTRATION = "webauthn.registration"
+	CEREMONY_WEBAUTHN_LOGIN        = "webauthn.login"
+	CEREMONY_WEBAUTHN_REAUTH       = "webauthn.reauth"
+	CEREMONY_TFA                   = "tfa"
+
+	// Sessions and ceremony tokens are signed with the same key, so each
+	// kind names itself in the audience and refuses the other.
+	SESSION_TOKEN_AUDIENCE  = "session"
+	CEREMONY_TOKEN_AUDIENCE = "ceremony"
 )
 
 type Claims struct {
+	UserId       int   `json:"user_id"`
+	TokenVersion int   `json:"token_version"`
+	AuthTime     int64 `json:"auth_time,omitempty"`
+
+	Roles        []string `json:"roles,omitempty"`
+	RolesVersion int      `json:"roles_version"`
+
+	OrganizationId int `json:"org_id,omitempty"`
+	TenantId       int `json:"tenant_id"`
+
+	Act *Actor `json:"act,omitempty"`
+	jwt.StandardClaims
+}
+
+// Actor is the admin actually acting behind a session issued on behalf of
+// another user, following the act claim of RFC 8693.
+type Actor struct {
 	UserId int `json:"user_id"`
+}
+
+func (claims Claims) impersonated() bool {
+	return claims.Act != nil
+}
+
+// authenticatedWithin tells whether the credentials behind the session were
+// entered, at login or through re-authentication, within the given window.
+func (claims Claims) authenticatedWithin(window time.Duration) bool {
+	return claims.AuthTime != 0 && time.Since(time.Unix(claims.AuthTime, 0)) <= window
+}
+
+// ceremonyClaims carry the state of a multi-step login or registration
+// between requests, e.g. the WebAuthn challenge or a pending second factor.
+type ceremonyClaims struct {
+	UserId    int    `json:"user_id"`
+	TenantId  int    `json:"tenant_id"`
+	Ceremony  string `json:"ceremony"`
+	Challenge []byte `json:"challenge,omitempty"`
 	jwt.StandardClaims
 }
 
-func generateToken() string {
-	token := make([]byte, TOKEN_LENGTH)
-	for i := range token {
-		token[i] = TOKEN_CHARS[seededRand.Intn(len(TOKEN_CHARS))]
+// generateToken and generateCode make bearer credentials, such as login
+// links, one-time codes and reset tokens, so they come from crypto/rand.
+func generateToken() (string, error) {
+	return generateRandomString(TOKEN_CHARS, TOKEN_LENGTH)
+}
+
+func generateCode() (string, error) {
+	return generateRandomString(CODE_CHARS, CODE_LENGTH)
+}
+
+func generateRandomString(chars string, length int) (string, error) {
+	max := big.NewInt(int64(len(chars)))
+	result := make([]byte, length)
+	for i := range result {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		result[i] = chars[n.Int64()]
 	}
-	return string(token)
+	return string(result), nil
 }
 
-func generateJWT(user User, expirationTime time.Time) (string, error) {
+func generateJWT(tenant tenancy.Tenant, user User, roles []string, expirationTime time.Time) (string, error) {
 	claims := Claims{
-		UserId: user.Id,
+		UserId:       user.Id,
+		TenantId:     tenant.Id,
+		TokenVersion: user.TokenVersion,
+		AuthTime:     time.Now().Unix(),
+		Roles:        roles,
+		RolesVersion: user.RolesVersion,
 		StandardClaims: jwt.StandardClaims{
 			ExpiresAt: expirationTime.Unix(),
 		},
 	}
 
+	return signClaims(tenant, claims)
+}
+
+// generateImpersonationJWT issues a session of the user for the admin. It
+// carries no roles and no authentication time, so it can neither reach admin
+// routes nor pass re-authentication checks.
+func generateImpersonationJWT(tenant tenancy.Tenant, user User, adminId int, expirationTime time.Time) (string, error) {
+	claims := Claims{
+		UserId:       user.Id,
+		TenantId:     tenant.Id,
+		TokenVersion: user.TokenVersion,
+		RolesVersion: user.RolesVersion,
+		Act:          &Actor{UserId: adminId},
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: expirationTime.Unix(),
+		},
+	}
+
+	return signClaims(tenant, claims)
+}
+
+// signClaims signs a session, which WithVerifyJWT only accepts with the
+// session audience.
+func signClaims(tenant tenancy.Tenant, claims Claims) (string, error) {
+	claims.Audience = SESSION_TOKEN_AUDIENCE
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(config.GetJWTKey()))
+	tokenString, err := token.SignedString(tenant.SigningKey())
 
 	return tokenString, err
 }
+
+func generateCeremonyJWT(tenant tenancy.Tenant, ceremony string, userId int, challenge []byte, expirationTime time.Time) (string, error) {
+	claims := ceremonyClaims{
+		UserId:    userId,
+		TenantId:  tenant.Id,
+		Ceremony:  ceremony,
+		Challenge: challenge,
+		StandardClaims: jwt.StandardClaims{
+			Audience:  CEREMONY_TOKEN_AUDIENCE,
+			ExpiresAt: expirationTime.Unix(),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(tenant.SigningKey())
+}
+
+func parseCeremonyJWT(tenant tenancy.Tenant, tokenString string, ceremony string) (*ceremonyClaims, error) {
+	claims := &ceremonyClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return tenant.SigningKey(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid || !claims.VerifyAudience(CEREMONY_TOKEN_AUDIENCE, true) || claims.Ceremony != ceremony || claims.TenantId != tenant.Id {
+		return nil, errors.New("Ceremony token is invalid")
+	}
+	return claims, nil
+}
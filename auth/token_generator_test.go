@@ -3,13 +3,80 @@ package auth
 import (
 	"regexp"
 	"testing"
+	"time"
+	"userland/config"
+	"userland/tenancy"
 
+	"github.com/dgrijalva/jwt-go"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGenerateToken(t *testing.T) {
-	token := generateToken()
+	token, err := generateToken()
+	require.Nil(t, err)
 	assert.Equal(t, TOKEN_LENGTH, len(token), "Token should have length of 32 when generated")
 	tokenRegex := regexp.MustCompile(`[a-zA-Z0-9]{32}`)
 	assert.True(t, tokenRegex.MatchString(token), "Token should only contain lower and uppercased alphabet and numbers")
 }
+
+func TestGenerateCode(t *testing.T) {
+	code, err := generateCode()
+	require.Nil(t, err)
+	assert.Equal(t, CODE_LENGTH, len(code), "Code should have length of 6 when generated")
+	codeRegex := regexp.MustCompile(`^[0-9]{6}$`)
+	assert.True(t, codeRegex.MatchString(code), "Code should only contain digits")
+}
+
+func TestHashLoginCredential(t *testing.T) {
+	token, err := generateToken()
+	require.Nil(t, err)
+	assert.Len(t, hashLoginCredential(token), 64)
+	assert.NotEqual(t, token, hashLoginCredential(token), "Login tokens shouldn't be stored as is")
+	assert.Equal(t, hashLoginCredential(token), hashLoginCredential(token))
+	assert.NotEqual(t, hashLoginCredential("123456"), hashLoginCredential("654321"))
+}
+
+func TestCeremonyJWT(t *testing.T) {
+	challenge := []byte("challenge")
+	token, err := generateCeremonyJWT(defaultTenant, CEREMONY_WEBAUTHN_LOGIN, 1, challenge, time.Now().Add(time.Minute))
+	require.Nil(t, err)
+
+	claims, err := parseCeremonyJWT(defaultTenant, token, CEREMONY_WEBAUTHN_LOGIN)
+	require.Nil(t, err)
+	assert.Equal(t, 1, claims.UserId)
+	assert.Equal(t, challenge, claims.Challenge)
+
+	_, err = parseCeremonyJWT(defaultTenant, token, CEREMONY_TFA)
+	assert.NotNil(t, err, "Ceremony token should not be accepted for another ceremony")
+
+	_, err = parseCeremonyJWT(tenancy.Tenant{Id: 2}, token, CEREMONY_WEBAUTHN_LOGIN)
+	assert.NotNil(t, err, "Ceremony token should not be accepted by another tenant")
+
+	expiredToken, err := generateCeremonyJWT(defaultTenant, CEREMONY_TFA, 1, nil, time.Now().Add(-time.Minute))
+	require.Nil(t, err)
+	_, err = parseCeremonyJWT(defaultTenant, expiredToken, CEREMONY_TFA)
+	assert.NotNil(t, err, "Expired ceremony token should not be accepted")
+}
+
+func TestImpersonationJWT(t *testing.T) {
+	token, err := generateJWT(defaultTenant, User{Id: 2}, nil, time.Now().Add(time.Minute))
+	require.Nil(t, err)
+	claims := &Claims{}
+	_, err = jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(config.GetJWTKey()), nil
+	})
+	require.Nil(t, err)
+	assert.False(t, claims.impersonated())
+
+	token, err = generateImpersonationJWT(defaultTenant, User{Id: 2}, 1, time.Now().Add(time.Minute))
+	require.Nil(t, err)
+	claims = &Claims{}
+	_, err = jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(config.GetJWTKey()), nil
+	})
+	require.Nil(t, err)
+	assert.Equal(t, 2, claims.UserId)
+	require.True(t, claims.impersonated())
+	assert.Equal(t, 1, claims.Act.UserId)
+}
@@ -1,6 +1,10 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
 	"errors"
 	"userland/appcontext"
 
@@ -9,25 +13,43 @@ import (
 )
 
 const (
-	CREATE_USER_QUERY                     = "INSERT INTO \"user\" (fullname, email, password, verification_token) VALUES ($1, $2, $3, $4)"
-	SELECT_USER_BY_EMAIL_QUERY            = "SELECT * FROM \"user\" WHERE email=$1"
-	UPDATE_VERIF_TOKEN_QUERY              = "UPDATE \"user\" SET verification_token=$1 WHERE id=$2"
-	UPDATE_RESET_PASS_TOKEN_QUERY         = "UPDATE \"user\" SET reset_password_token=$1 WHERE id=$2"
-	SELECT_USER_BY_RESET_PASS_TOKEN_QUERY = "SELECT * FROM \"user\" WHERE reset_password_token=$1"
-	RESET_PASSWORD_QUERY                  = "UPDATE \"user\" SET password=$1, reset_password_token=NULL WHERE id=$2"
-	SELECT_USER_BY_ID_QUERY               = "SELECT * FROM \"user\" WHERE id=$1"
-	UPDATE_VERIFIED_QUERY                 = "UPDATE \"user\" SET verification_token=NULL, verified=true WHERE id=$1"
+	CREATE_USER_QUERY                     = "INSERT INTO \"user\" (tenant_id, fullname, email, password, verification_token) VALUES ($1, $2, $3, $4, $5)"
+	SELECT_USER_BY_EMAIL_QUERY            = "SELECT * FROM \"user\" WHERE tenant_id=$1 AND email=$2"
+	UPDATE_VERIF_TOKEN_QUERY              = "UPDATE \"user\" SET verification_token=$1 WHERE id=$2 AND tenant_id=$3"
+	UPDATE_RESET_PASS_TOKEN_QUERY         = "UPDATE \"user\" SET reset_password_token=$1 WHERE id=$2 AND tenant_id=$3"
+	SELECT_USER_BY_RESET_PASS_TOKEN_QUERY = "SELECT * FROM \"user\" WHERE tenant_id=$1 AND reset_password_token=$2"
+	RESET_PASSWORD_QUERY                  = "UPDATE \"user\" SET password=$1, reset_password_token=NULL, password_reset_required=false, token_version=token_version+1 WHERE id=$2 AND tenant_id=$3"
+	SELECT_USER_BY_ID_QUERY               = "SELECT * FROM \"user\" WHERE tenant_id=$1 AND id=$2"
+	UPDATE_VERIFIED_QUERY                 = "UPDATE \"user\" SET verification_token=NULL, verified=true WHERE id=$1 AND tenant_id=$2"
+	UPDATE_LOGIN_TOKEN_QUERY              = "UPDATE \"user\" SET login_token=$1, login_code=$2, login_token_expires_at=now() + $3 * interval '1 minute' WHERE id=$4 AND tenant_id=$5"
+	CONSUME_LOGIN_TOKEN_QUERY             = "UPDATE \"user\" SET login_token=NULL, login_code=NULL, login_token_expires_at=NULL WHERE tenant_id=$1 AND login_token=$2 AND login_token_expires_at > now() RETURNING *"
+	CREATE_INVITED_USER_QUERY             = "INSERT INTO \"user\" (tenant_id, fullname, email, password, verification_token) VALUES ($1, $2, $3, $4, $5) RETURNING id"
+	CONSUME_REGISTRATION_INVITE_QUERY     = "UPDATE registration_invitation SET used_by=$1, used_at=now() WHERE tenant_id=$2 AND code=$3 AND used_at IS NULL AND expires_at > now() AND (email IS NULL OR email=lower($4))"
+	UPSERT_DIRECTORY_USER_QUERY           = "INSERT INTO \"user\" (tenant_id, fullname, email, password, verified, directory_dn) VALUES ($1, $2, $3, $4, true, $5) ON CONFLICT (tenant_id, email) DO UPDATE SET fullname=EXCLUDED.fullname, verified=true, profile_version=\"user\".profile_version + CASE WHEN \"user\".fullname<>EXCLUDED.fullname THEN 1 ELSE 0 END WHERE \"user\".directory_dn=EXCLUDED.directory_dn RETURNING *"
+	CONSUME_LOGIN_CODE_QUERY              = "UPDATE \"user\" SET login_token=NULL, login_code=NULL, login_token_expires_at=NULL WHERE tenant_id=$1 AND email=$2 AND login_code=$3 AND login_token_expires_at > now() RETURNING *"
+	RESTORE_USER_QUERY                    = "UPDATE \"user\" SET deleted_at=NULL, purge_at=NULL, restore_token=NULL WHERE tenant_id=$1 AND restore_token=$2 AND purge_at > now() RETURNING *"
+	USERNAME_TAKEN_QUERY                  = "SELECT EXISTS (SELECT 1 FROM \"user\" WHERE tenant_id=$1 AND lower(username)=lower($2)) OR EXISTS (SELECT 1 FROM username_redirect WHERE tenant_id=$1 AND lower(username)=lower($2) AND expires_at > now())"
 )
 
+// userRepositoryInterface scopes every query by the tenant, given explicitly
+// or taken from the user it's about, so that one tenant's users are never
+// reachable from another tenant, not even by id.
 type userRepositoryInterface interface {
-	createNewUser(user userRegistration) error
-	verifyUser(recipient string, token string) error
-	loginUser(email string, password string) error
-	forgetPassword(email string) error
-	getUserByEmail(email string) (*User, error)
-	resetPassword(token string, password string) error
-	getUserByResetPasswordToken(token string) (*User, error)
-	getUserById(id int) (*User, error)
+	createNewUser(tenantId int, user userRegistration) error
+	createInvitedUser(tenantId int, user userRegistration) error
+	verifyUser(tenantId int, recipient string, token string) error
+	loginUser(tenantId int, email string, password string) error
+	forgetPassword(tenantId int, email string) error
+	getUserByEmail(tenantId int, email string) (*User, error)
+	resetPassword(tenantId int, token string, password string) error
+	getUserByResetPasswordToken(tenantId int, token string) (*User, error)
+	getUserById(tenantId int, id int) (*User, error)
+	createLoginToken(user *User) (string, string, error)
+	consumeLoginToken(tenantId int, token string) (*User, error)
+	consumeLoginCode(tenantId int, email string, code string) (*User, error)
+	upsertDirectoryUser(tenantId int, dn string, fullname string, email string) (*User, error)
+	restoreUser(tenantId int, token string) (*User, error)
+	isUsernameTaken(tenantId int, name string) (bool, error)
 }
 
 type userRepository struct {
@@ -39,21 +61,64 @@ func GetUserRepository() *userRepository {
 	return &repo
 }
 
-func (repo *userRepository) createNewUser(user userRegistration) error {
+func (repo *userRepository) createNewUser(tenantId int, user userRegistration) error {
 	passwordHash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.MinCost)
 	if err != nil {
 		return err
 	}
+	verificationToken, err := generateToken()
+	if err != nil {
+		return err
+	}
 	stmt, err := repo.db.Preparex(CREATE_USER_QUERY)
 	if err != nil {
 		return err
 	}
-	_, err = stmt.Queryx(user.Fullname, user.Email, string(passwordHash), generateToken())
+	_, err = stmt.Queryx(tenantId, user.Fullname, user.Email, string(passwordHash), verificationToken)
 	return err
 }
 
-func (repo *userRepository) verifyUser(recipient string, token string) error {
-	user, err := repo.getUserByEmail(recipient)
+// createInvitedUser creates the user and uses up their invitation code in the
+// same transaction, so that a code can't be used twice.
+func (repo *userRepository) createInvitedUser(tenantId int, user userRegistration) error {
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.MinCost)
+	if err != nil {
+		return err
+	}
+
+	verificationToken, err := generateToken()
+	if err != nil {
+		return err
+	}
+
+	tx, err := repo.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var userId int
+	err = tx.Get(&userId, CREATE_INVITED_USER_QUERY, tenantId, user.Fullname, user.Email, string(passwordHash), verificationToken)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(CONSUME_REGISTRATION_INVITE_QUERY, userId, tenantId, user.InvitationCode, user.Email)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errRegistrationInvitationInvalid
+	}
+	return tx.Commit()
+}
+
+func (repo *userRepository) verifyUser(tenantId int, recipient string, token string) error {
+	user, err := repo.getUserByEmail(tenantId, recipient)
 	if err != nil {
 		return err
 	}
@@ -66,12 +131,12 @@ func (repo *userRepository) verifyUser(recipient string, token string) error {
 	if err != nil {
 		return err
 	}
-	_, err = stmt.Queryx(user.Id)
+	_, err = stmt.Queryx(user.Id, user.TenantId)
 	return err
 }
 
-func (repo *userRepository) loginUser(email string, password string) error {
-	user, err := repo.getUserByEmail(email)
+func (repo *userRepository) loginUser(tenantId int, email string, password string) error {
+	user, err := repo.getUserByEmail(tenantId, email)
 	if err != nil {
 		return err
 	}
@@ -79,8 +144,12 @@ func (repo *userRepository) loginUser(email string, password string) error {
 	return err
 }
 
-func (repo *userRepository) forgetPassword(email string) error {
-	user, err := repo.getUserByEmail(email)
+func (repo *userRepository) forgetPassword(tenantId int, email string) error {
+	user, err := repo.getUserByEmail(tenantId, email)
+	if err != nil {
+		return err
+	}
+	resetToken, err := generateToken()
 	if err != nil {
 		return err
 	}
@@ -88,22 +157,27 @@ func (repo *userRepository) forgetPassword(email string) error {
 	if err != nil {
 		return err
 	}
-	_, err = stmt.Queryx(generateToken(), user.Id)
+	_, err = stmt.Queryx(resetToken, user.Id, user.TenantId)
 	return err
 }
 
-func (repo *userRepository) getUserByEmail(email string) (*User, error) {
+func (repo *userRepository) getUserByEmail(tenantId int, email string) (*User, error) {
 	stmt, err := repo.db.Preparex(SELECT_USER_BY_EMAIL_QUERY)
 	if err != nil {
 		return nil, err
 	}
-	row, err := stmt.Queryx(email)
+	row, err := stmt.Queryx(tenantId, email)
 	if err != nil {
 		return nil, err
 	}
 	var user User
-	row.Next()
 	defer row.Close()
+	if !row.Next() {
+		if row.Err() != nil {
+			return nil, row.Err()
+		}
+		return nil, sql.ErrNoRows
+	}
 	err = row.StructScan(&user)
 	if err != nil {
 		return nil, err
@@ -111,8 +185,8 @@ func (repo *userRepository) getUserByEmail(email string) (*User, error) {
 	return &user, nil
 }
 
-func (repo *userRepository) resetPassword(token string, password string) error {
-	user, err := repo.getUserByResetPasswordToken(token)
+func (repo *userRepository) resetPassword(tenantId int, token string, password string) error {
+	user, err := repo.getUserByResetPasswordToken(tenantId, token)
 	if err != nil {
 		return err
 	}
@@ -126,16 +200,16 @@ func (repo *userRepository) resetPassword(token string, password string) error {
 	if err != nil {
 		return err
 	}
-	_, err = stmt.Queryx(passwordHash, user.Id)
+	_, err = stmt.Queryx(passwordHash, user.Id, user.TenantId)
 	return err
 }
 
-func (repo *userRepository) getUserByResetPasswordToken(token string) (*User, error) {
+func (repo *userRepository) getUserByResetPasswordToken(tenantId int, token string) (*User, error) {
 	stmt, err := repo.db.Preparex(SELECT_USER_BY_RESET_PASS_TOKEN_QUERY)
 	if err != nil {
 		return nil, err
 	}
-	row, err := stmt.Queryx(token)
+	row, err := stmt.Queryx(tenantId, token)
 	if err != nil {
 		return nil, err
 	}
@@ -149,12 +223,12 @@ func (repo *userRepository) getUserByResetPasswordToken(token string) (*User, er
 	return &user, nil
 }
 
-func (repo *userRepository) getUserById(id int) (*User, error) {
+func (repo *userRepository) getUserById(tenantId int, id int) (*User, error) {
 	stmt, err := repo.db.Preparex(SELECT_USER_BY_ID_QUERY)
 	if err != nil {
 		return nil, err
 	}
-	row, err := stmt.Queryx(id)
+	row, err := stmt.Queryx(tenantId, id)
 	if err != nil {
 		return nil, err
 	}
@@ -167,3 +241,102 @@ func (repo *userRepository) getUserById(id int) (*User, error) {
 	}
 	return &user, nil
 }
+
+func (repo *userRepository) createLoginToken(user *User) (string, string, error) {
+	token, err := generateToken()
+	if err != nil {
+		return "", "", err
+	}
+	code, err := generateCode()
+	if err != nil {
+		return "", "", err
+	}
+	stmt, err := repo.db.Preparex(UPDATE_LOGIN_TOKEN_QUERY)
+	if err != nil {
+		return "", "", err
+	}
+	_, err = stmt.Exec(hashLoginCredential(token), hashLoginCredential(code), EMAIL_LOGIN_TOKEN_LIFETIME_MINUTES, user.Id, user.TenantId)
+	if err != nil {
+		return "", "", err
+	}
+	return token, code, nil
+}
+
+func (repo *userRepository) consumeLoginToken(tenantId int, token string) (*User, error) {
+	return repo.consumeLoginCredential(CONSUME_LOGIN_TOKEN_QUERY, tenantId, hashLoginCredential(token))
+}
+
+func (repo *userRepository) consumeLoginCode(tenantId int, email string, code string) (*User, error) {
+	return repo.consumeLoginCredential(CONSUME_LOGIN_CODE_QUERY, tenantId, email, hashLoginCredential(code))
+}
+
+// hashLoginCredential is how login tokens and codes are stored, so that the
+// database alone can't be used to log in.
+func hashLoginCredential(credential string) string {
+	hash := sha256.Sum256([]byte(credential))
+	return hex.EncodeToString(hash[:])
+}
+
+// consumeLoginCredential clears the login token and code in the same statement
+// that matches them, so each one can only be exchanged once.
+func (repo *userRepository) consumeLoginCredential(query string, args ...interface{}) (*User, error) {
+	stmt, err := repo.db.Preparex(query)
+	if err != nil {
+		return nil, err
+	}
+	row, err := stmt.Queryx(args...)
+	if err != nil {
+		return nil, err
+	}
+	var user User
+	row.Next()
+	defer row.Close()
+	err = row.StructScan(&user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// upsertDirectoryUser creates the user an external directory vouched for, or
+// refreshes the name of the one created from the same entry. New users get an
+// unguessable local password, as their password is checked against the
+// directory. An account with the email address that wasn't created from the
+// entry is left alone, and sql.ErrNoRows is returned.
+func (repo *userRepository) upsertDirectoryUser(tenantId int, dn string, fullname string, email string) (*User, error) {
+	password := make([]byte, TOKEN_LENGTH)
+	_, err := rand.Read(password)
+	if err != nil {
+		return nil, err
+	}
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(hex.EncodeToString(password)), bcrypt.MinCost)
+	if err != nil {
+		return nil, err
+	}
+
+	var user User
+	err = repo.db.Get(&user, UPSERT_DIRECTORY_USER_QUERY, tenantId, fullname, email, string(passwordHash), dn)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// restoreUser takes back the deletion of the account the restore token was
+// sent for, as long as it hasn't been purged yet.
+func (repo *userRepository) restoreUser(tenantId int, token string) (*User, error) {
+	var user User
+	err := repo.db.QueryRowx(RESTORE_USER_QUERY, tenantId, token).StructScan(&user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// isUsernameTaken tells whether someone has the username, whatever its case,
+// or had it recently enough that it still points at them.
+func (repo *userRepository) isUsernameTaken(tenantId int, name string) (bool, error) {
+	var taken bool
+	err := repo.db.Get(&taken, USERNAME_TAKEN_QUERY, tenantId, name)
+	return taken, err
+}
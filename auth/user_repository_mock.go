@@ -34,116 +34,221 @@ func (m *MockuserRepositoryInterface) EXPECT() *MockuserRepositoryInterfaceMockR
 }
 
 // createNewUser mocks base method
-func (m *MockuserRepositoryInterface) createNewUser(user userRegistration) error {
+func (m *MockuserRepositoryInterface) createNewUser(tenantId int, user userRegistration) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "createNewUser", user)
+	ret := m.ctrl.Call(m, "createNewUser", tenantId, user)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // createNewUser indicates an expected call of createNewUser
-func (mr *MockuserRepositoryInterfaceMockRecorder) createNewUser(user interface{}) *gomock.Call {
+func (mr *MockuserRepositoryInterfaceMockRecorder) createNewUser(tenantId, user interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "createNewUser", reflect.TypeOf((*MockuserRepositoryInterface)(nil).createNewUser), user)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "createNewUser", reflect.TypeOf((*MockuserRepositoryInterface)(nil).createNewUser), tenantId, user)
+}
+
+// createInvitedUser mocks base method
+func (m *MockuserRepositoryInterface) createInvitedUser(tenantId int, user userRegistration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "createInvitedUser", tenantId, user)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// createInvitedUser indicates an expected call of createInvitedUser
+func (mr *MockuserRepositoryInterfaceMockRecorder) createInvitedUser(tenantId, user interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "createInvitedUser", reflect.TypeOf((*MockuserRepositoryInterface)(nil).createInvitedUser), tenantId, user)
 }
 
 // verifyUser mocks base method
-func (m *MockuserRepositoryInterface) verifyUser(recipient, token string) error {
+func (m *MockuserRepositoryInterface) verifyUser(tenantId int, recipient, token string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "verifyUser", recipient, token)
+	ret := m.ctrl.Call(m, "verifyUser", tenantId, recipient, token)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // verifyUser indicates an expected call of verifyUser
-func (mr *MockuserRepositoryInterfaceMockRecorder) verifyUser(recipient, token interface{}) *gomock.Call {
+func (mr *MockuserRepositoryInterfaceMockRecorder) verifyUser(tenantId, recipient, token interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "verifyUser", reflect.TypeOf((*MockuserRepositoryInterface)(nil).verifyUser), recipient, token)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "verifyUser", reflect.TypeOf((*MockuserRepositoryInterface)(nil).verifyUser), tenantId, recipient, token)
 }
 
 // loginUser mocks base method
-func (m *MockuserRepositoryInterface) loginUser(email, password string) error {
+func (m *MockuserRepositoryInterface) loginUser(tenantId int, email, password string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "loginUser", email, password)
+	ret := m.ctrl.Call(m, "loginUser", tenantId, email, password)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // loginUser indicates an expected call of loginUser
-func (mr *MockuserRepositoryInterfaceMockRecorder) loginUser(email, password interface{}) *gomock.Call {
+func (mr *MockuserRepositoryInterfaceMockRecorder) loginUser(tenantId, email, password interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "loginUser", reflect.TypeOf((*MockuserRepositoryInterface)(nil).loginUser), email, password)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "loginUser", reflect.TypeOf((*MockuserRepositoryInterface)(nil).loginUser), tenantId, email, password)
 }
 
 // forgetPassword mocks base method
-func (m *MockuserRepositoryInterface) forgetPassword(email string) error {
+func (m *MockuserRepositoryInterface) forgetPassword(tenantId int, email string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "forgetPassword", email)
+	ret := m.ctrl.Call(m, "forgetPassword", tenantId, email)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // forgetPassword indicates an expected call of forgetPassword
-func (mr *MockuserRepositoryInterfaceMockRecorder) forgetPassword(email interface{}) *gomock.Call {
+func (mr *MockuserRepositoryInterfaceMockRecorder) forgetPassword(tenantId, email interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "forgetPassword", reflect.TypeOf((*MockuserRepositoryInterface)(nil).forgetPassword), email)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "forgetPassword", reflect.TypeOf((*MockuserRepositoryInterface)(nil).forgetPassword), tenantId, email)
 }
 
 // getUserByEmail mocks base method
-func (m *MockuserRepositoryInterface) getUserByEmail(email string) (*User, error) {
+func (m *MockuserRepositoryInterface) getUserByEmail(tenantId int, email string) (*User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "getUserByEmail", email)
+	ret := m.ctrl.Call(m, "getUserByEmail", tenantId, email)
 	ret0, _ := ret[0].(*User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // getUserByEmail indicates an expected call of getUserByEmail
-func (mr *MockuserRepositoryInterfaceMockRecorder) getUserByEmail(email interface{}) *gomock.Call {
+func (mr *MockuserRepositoryInterfaceMockRecorder) getUserByEmail(tenantId, email interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "getUserByEmail", reflect.TypeOf((*MockuserRepositoryInterface)(nil).getUserByEmail), email)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "getUserByEmail", reflect.TypeOf((*MockuserRepositoryInterface)(nil).getUserByEmail), tenantId, email)
 }
 
 // resetPassword mocks base method
-func (m *MockuserRepositoryInterface) resetPassword(token, password string) error {
+func (m *MockuserRepositoryInterface) resetPassword(tenantId int, token, password string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "resetPassword", token, password)
+	ret := m.ctrl.Call(m, "resetPassword", tenantId, token, password)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // resetPassword indicates an expected call of resetPassword
-func (mr *MockuserRepositoryInterfaceMockRecorder) resetPassword(token, password interface{}) *gomock.Call {
+func (mr *MockuserRepositoryInterfaceMockRecorder) resetPassword(tenantId, token, password interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "resetPassword", reflect.TypeOf((*MockuserRepositoryInterface)(nil).resetPassword), token, password)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "resetPassword", reflect.TypeOf((*MockuserRepositoryInterface)(nil).resetPassword), tenantId, token, password)
 }
 
 // getUserByResetPasswordToken mocks base method
-func (m *MockuserRepositoryInterface) getUserByResetPasswordToken(token string) (*User, error) {
+func (m *MockuserRepositoryInterface) getUserByResetPasswordToken(tenantId int, token string) (*User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "getUserByResetPasswordToken", token)
+	ret := m.ctrl.Call(m, "getUserByResetPasswordToken", tenantId, token)
 	ret0, _ := ret[0].(*User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // getUserByResetPasswordToken indicates an expected call of getUserByResetPasswordToken
-func (mr *MockuserRepositoryInterfaceMockRecorder) getUserByResetPasswordToken(token interface{}) *gomock.Call {
+func (mr *MockuserRepositoryInterfaceMockRecorder) getUserByResetPasswordToken(tenantId, token interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "getUserByResetPasswordToken", reflect.TypeOf((*MockuserRepositoryInterface)(nil).getUserByResetPasswordToken), token)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "getUserByResetPasswordToken", reflect.TypeOf((*MockuserRepositoryInterface)(nil).getUserByResetPasswordToken), tenantId, token)
 }
 
 // getUserById mocks base method
-func (m *MockuserRepositoryInterface) getUserById(id int) (*User, error) {
+func (m *MockuserRepositoryInterface) getUserById(tenantId, id int) (*User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "getUserById", id)
+	ret := m.ctrl.Call(m, "getUserById", tenantId, id)
 	ret0, _ := ret[0].(*User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // getUserById indicates an expected call of getUserById
-func (mr *MockuserRepositoryInterfaceMockRecorder) getUserById(id interface{}) *gomock.Call {
+func (mr *MockuserRepositoryInterfaceMockRecorder) getUserById(tenantId, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "getUserById", reflect.TypeOf((*MockuserRepositoryInterface)(nil).getUserById), tenantId, id)
+}
+
+// createLoginToken mocks base method
+func (m *MockuserRepositoryInterface) createLoginToken(user *User) (string, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "createLoginToken", user)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// createLoginToken indicates an expected call of createLoginToken
+func (mr *MockuserRepositoryInterfaceMockRecorder) createLoginToken(user interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "createLoginToken", reflect.TypeOf((*MockuserRepositoryInterface)(nil).createLoginToken), user)
+}
+
+// consumeLoginToken mocks base method
+func (m *MockuserRepositoryInterface) consumeLoginToken(tenantId int, token string) (*User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "consumeLoginToken", tenantId, token)
+	ret0, _ := ret[0].(*User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// consumeLoginToken indicates an expected call of consumeLoginToken
+func (mr *MockuserRepositoryInterfaceMockRecorder) consumeLoginToken(tenantId, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "consumeLoginToken", reflect.TypeOf((*MockuserRepositoryInterface)(nil).consumeLoginToken), tenantId, token)
+}
+
+// consumeLoginCode mocks base method
+func (m *MockuserRepositoryInterface) consumeLoginCode(tenantId int, email, code string) (*User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "consumeLoginCode", tenantId, email, code)
+	ret0, _ := ret[0].(*User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// consumeLoginCode indicates an expected call of consumeLoginCode
+func (mr *MockuserRepositoryInterfaceMockRecorder) consumeLoginCode(tenantId, email, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "consumeLoginCode", reflect.TypeOf((*MockuserRepositoryInterface)(nil).consumeLoginCode), tenantId, email, code)
+}
+
+// upsertDirectoryUser mocks base method
+func (m *MockuserRepositoryInterface) upsertDirectoryUser(tenantId int, dn, fullname, email string) (*User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "upsertDirectoryUser", tenantId, dn, fullname, email)
+	ret0, _ := ret[0].(*User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// upsertDirectoryUser indicates an expected call of upsertDirectoryUser
+func (mr *MockuserRepositoryInterfaceMockRecorder) upsertDirectoryUser(tenantId, dn, fullname, email interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "upsertDirectoryUser", reflect.TypeOf((*MockuserRepositoryInterface)(nil).upsertDirectoryUser), tenantId, dn, fullname, email)
+}
+
+// restoreUser mocks base method
+func (m *MockuserRepositoryInterface) restoreUser(tenantId int, token string) (*User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "restoreUser", tenantId, token)
+	ret0, _ := ret[0].(*User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// restoreUser indicates an expected call of restoreUser
+func (mr *MockuserRepositoryInterfaceMockRecorder) restoreUser(tenantId, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "restoreUser", reflect.TypeOf((*MockuserRepositoryInterface)(nil).restoreUser), tenantId, token)
+}
+
+// isUsernameTaken mocks base method
+func (m *MockuserRepositoryInterface) isUsernameTaken(tenantId int, name string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "isUsernameTaken", tenantId, name)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// isUsernameTaken indicates an expected call of isUsernameTaken
+func (mr *MockuserRepositoryInterfaceMockRecorder) isUsernameTaken(tenantId, name interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "getUserById", reflect.TypeOf((*MockuserRepositoryInterface)(nil).getUserById), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "isUsernameTaken", reflect.TypeOf((*MockuserRepositoryInterface)(nil).isUsernameTaken), tenantId, name)
 }
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"regexp"
+	"strings"
+	ulanderrors "userland/errors"
+)
+
+const (
+	USERNAME_MIN_LENGTH = 3
+	USERNAME_MAX_LENGTH = 30
+)
+
+var (
+	usernameFormat = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+	usernameDigits = regexp.MustCompile(`^[0-9]+$`)
+
+	// reservedUsernames can't be taken by anyone, as they name routes, roles
+	// or the service itself, or could pass for a message from it.
+	reservedUsernames = map[string]bool{
+		"about":         true,
+		"account":       true,
+		"admin":         true,
+		"administrator": true,
+		"api":           true,
+		"auth":          true,
+		"help":          true,
+		"login":         true,
+		"logout":        true,
+		"me":            true,
+		"moderator":     true,
+		"null":          true,
+		"owner":         true,
+		"privacy":       true,
+		"register":      true,
+		"root":          true,
+		"security":      true,
+		"settings":      true,
+		"signup":        true,
+		"staff":         true,
+		"support":       true,
+		"system":        true,
+		"undefined":     true,
+		"userland":      true,
+		"users":         true,
+	}
+)
+
+// UsernameRefusal returns the reason the username can't be taken, if it
+// can't, regardless of whether someone already has it. Usernames are never
+// only digits, so that they don't get mistaken for user IDs.
+func UsernameRefusal(name string) (ulanderrors.UserlandError, bool) {
+	if len(name) < USERNAME_MIN_LENGTH || len(name) > USERNAME_MAX_LENGTH ||
+		!usernameFormat.MatchString(name) || usernameDigits.MatchString(name) {
+		return ulanderrors.ErrUsernameInvalid, true
+	}
+	if reservedUsernames[strings.ToLower(name)] {
+		return ulanderrors.ErrUsernameReserved, true
+	}
+	return ulanderrors.UserlandError{}, false
+}
@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	ulanderrors "userland/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsernameRefusal(t *testing.T) {
+	for _, name := range []string{"bob", "Jane_Doe", "user42", "___", strings.Repeat("a", USERNAME_MAX_LENGTH)} {
+		_, refused := UsernameRefusal(name)
+		assert.False(t, refused, name)
+	}
+
+	for _, name := range []string{"", "ab", strings.Repeat("a", USERNAME_MAX_LENGTH+1), "jane.doe", "jane doe", "zoë", "12345"} {
+		refusal, refused := UsernameRefusal(name)
+		assert.True(t, refused, name)
+		assert.Equal(t, ulanderrors.ErrUsernameInvalid, refusal, name)
+	}
+
+	for _, name := range []string{"admin", "ROOT", "Support"} {
+		refusal, refused := UsernameRefusal(name)
+		assert.True(t, refused, name)
+		assert.Equal(t, ulanderrors.ErrUsernameReserved, refusal, name)
+	}
+}
@@ -0,0 +1,342 @@
+package auth
+
+import (
+	"bytes"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+	ulanderrors "userland/errors"
+	"userland/request"
+	"userland/response"
+	"userland/tenancy"
+	"userland/webauthn"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	WEBAUTHN_SESSION_COOKIE = "webauthn_session"
+	TFA_SESSION_COOKIE      = "tfa_session"
+)
+
+func (handler AuthHandler) BeginWebAuthnRegistration(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*User)
+
+	credentials, err := handler.WebAuthnRepo.getCredentialsByUserId(tenancy.FromRequest(r).Id, user.Id)
+	if err != nil {
+		log.Warn(err)
+		response.RespondInternalError(w, ulanderrors.ErrWebAuthnQueryExec)
+		return
+	}
+
+	challenge, ok := handler.startCeremony(w, r, CEREMONY_WEBAUTHN_REGISTRATION, user.Id)
+	if !ok {
+		return
+	}
+
+	options := handler.RelyingParty.RegistrationOptions(challenge, webauthnUserEntity(user), credentialDescriptors(credentials))
+
+	log.Info("WebAuthn registration started")
+	response.RespondSuccessWithBody(w, map[string]interface{}{"publicKey": options})
+}
+
+func (handler AuthHandler) FinishWebAuthnRegistration(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*User)
+
+	var attestation webauthn.AttestationResponse
+	err = request.ParseJSON(r.Body, &attestation)
+	if err != nil {
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrParseBody)
+		return
+	}
+
+	claims, err := handler.finishCeremony(r, CEREMONY_WEBAUTHN_REGISTRATION)
+	if err != nil || claims.UserId != user.Id {
+		log.Info("WebAuthn registration ceremony is missing or belongs to another user")
+		response.RespondBadRequest(w, ulanderrors.ErrWebAuthnCeremonyNotStarted)
+		return
+	}
+
+	credential, err := handler.RelyingParty.VerifyRegistration(claims.Challenge, attestation)
+	if err != nil {
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrWebAuthnResponseInvalid)
+		return
+	}
+
+	err = handler.WebAuthnRepo.createCredential(user, credential)
+	if err != nil {
+		log.Warn(err)
+		response.RespondBadRequest(w, ulanderrors.ErrWebAuthnQueryExec)
+		return
+	}
+
+	clearCeremonyCookie(w, WEBAUTHN_SESSION_COOKIE)
+
+	log.Info("WebAuthn registration successful")
+	response.RespondSuccess(w)
+}
+
+func (handler AuthHandler) GetWebAuthnCredentials(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*User)
+
+	credentials, err := handler.WebAuthnRepo.getCredentialsByUserId(tenancy.FromRequest(r).Id, user.Id)
+	if err != nil {
+		log.Warn(err)
+		response.RespondInternalError(w, ulanderrors.ErrWebAuthnQueryExec)
+		return
+	}
+
+	infos := make([]webauthnCredentialInfo, 0, len(credentials))
+	for _, credential := range credentials {
+		infos = append(infos, credential.info())
+	}
+
+	log.Info("Get WebAuthn credentials successful")
+	response.RespondSuccessWithBody(w, map[string]interface{}{"credentials": infos})
+}
+
+func (handler AuthHandler) DeleteWebAuthnCredential(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*User)
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrWebAuthnCredentialNotFound)
+		return
+	}
+
+	err = handler.WebAuthnRepo.deleteCredential(user, id)
+	if err != nil {
+		log.Warn(err)
+		response.RespondBadRequest(w, ulanderrors.ErrWebAuthnCredentialNotFound)
+		return
+	}
+
+	log.Info("Delete WebAuthn credential successful")
+	response.RespondSuccess(w)
+}
+
+// BeginWebAuthnLogin starts an assertion ceremony. The allowed credentials are
+// only narrowed to one account once a password login is waiting for its second
+// factor; otherwise any discoverable passkey is accepted, so that anonymous
+// callers can't tell which accounts exist or list their credentials.
+func (handler AuthHandler) BeginWebAuthnLogin(w http.ResponseWriter, r *http.Request) {
+	userId := 0
+	if tfaClaims, err := readCeremonyFromCookie(r, TFA_SESSION_COOKIE, CEREMONY_TFA); err == nil {
+		userId = tfaClaims.UserId
+	}
+
+	allowCredentials := []webauthn.CredentialDescriptor{}
+	if userId != 0 {
+		credentials, err := handler.WebAuthnRepo.getCredentialsByUserId(tenancy.FromRequest(r).Id, userId)
+		if err != nil {
+			log.Warn(err)
+			response.RespondInternalError(w, ulanderrors.ErrWebAuthnQueryExec)
+			return
+		}
+		allowCredentials = credentialDescriptors(credentials)
+	}
+
+	challenge, ok := handler.startCeremony(w, r, CEREMONY_WEBAUTHN_LOGIN, userId)
+	if !ok {
+		return
+	}
+
+	options := handler.RelyingParty.LoginOptions(challenge, allowCredentials)
+
+	log.Info("WebAuthn login started")
+	response.RespondSuccessWithBody(w, map[string]interface{}{"publicKey": options})
+}
+
+// FinishWebAuthnLogin completes the assertion ceremony. A passkey which verified
+// the user logs in on its own; one which only proved presence is accepted as
+// the second factor of a password login for the same account.
+func (handler AuthHandler) FinishWebAuthnLogin(w http.ResponseWriter, r *http.Request) {
+	var assertionRes webauthn.AssertionResponse
+	err = request.ParseJSON(r.Body, &assertionRes)
+	if err != nil {
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrParseBody)
+		return
+	}
+
+	claims, err := handler.finishCeremony(r, CEREMONY_WEBAUTHN_LOGIN)
+	if err != nil {
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrWebAuthnCeremonyNotStarted)
+		return
+	}
+
+	user, assertion, ok := handler.verifyAssertion(w, r, claims, assertionRes)
+	if !ok {
+		return
+	}
+
+	if !assertion.UserVerified {
+		tfaClaims, err := readCeremonyFromCookie(r, TFA_SESSION_COOKIE, CEREMONY_TFA)
+		if err != nil || tfaClaims.UserId != user.Id {
+			log.Info("Passkey didn't verify the user and no password login is pending")
+			response.RespondUnauthorized(w, ulanderrors.ErrWebAuthnUserVerificationRequired)
+			return
+		}
+	}
+
+	if !user.Verified {
+		log.Info("User hasn't been verified by the system")
+		response.RespondUnauthorized(w, ulanderrors.ErrLoginUnverified)
+		return
+	}
+
+	if refuseSuspendedUser(w, user) || refusePasswordResetRequired(w, user) {
+		return
+	}
+
+	clearCeremonyCookie(w, WEBAUTHN_SESSION_COOKIE)
+	clearCeremonyCookie(w, TFA_SESSION_COOKIE)
+	handler.startSession(w, r, user)
+}
+
+// verifyAssertion checks an assertion against the stored credential and the
+// account the ceremony was started for, and records the new sign count.
+func (handler AuthHandler) verifyAssertion(w http.ResponseWriter, r *http.Request, claims *ceremonyClaims, assertionRes webauthn.AssertionResponse) (*User, *webauthn.Assertion, bool) {
+	credential, err := handler.WebAuthnRepo.getCredentialByCredentialId(tenancy.FromRequest(r).Id, assertionRes.RawId)
+	if err == sql.ErrNoRows {
+		log.Info(err)
+		response.RespondUnauthorized(w, ulanderrors.ErrWebAuthnCredentialNotFound)
+		return nil, nil, false
+	}
+	if err != nil {
+		log.Warn(err)
+		response.RespondInternalError(w, ulanderrors.ErrWebAuthnQueryExec)
+		return nil, nil, false
+	}
+
+	userHandle := []byte(strconv.Itoa(credential.UserId))
+	if (claims.UserId != 0 && claims.UserId != credential.UserId) ||
+		(len(assertionRes.Response.UserHandle) > 0 && !bytes.Equal(assertionRes.Response.UserHandle, userHandle)) {
+		log.Info("Passkey belongs to another user")
+		response.RespondUnauthorized(w, ulanderrors.ErrWebAuthnResponseInvalid)
+		return nil, nil, false
+	}
+
+	assertion, err := handler.RelyingParty.VerifyAssertion(claims.Challenge, credential.asWebAuthnCredential(), assertionRes)
+	if err != nil {
+		log.Info(err)
+		response.RespondUnauthorized(w, ulanderrors.ErrWebAuthnResponseInvalid)
+		return nil, nil, false
+	}
+
+	err = handler.WebAuthnRepo.updateSignCount(tenancy.FromRequest(r).Id, credential, assertion.SignCount)
+	if err != nil {
+		log.Warn(err)
+		response.RespondInternalError(w, ulanderrors.ErrWebAuthnQueryExec)
+		return nil, nil, false
+	}
+
+	// The credential was found within the tenant, so its user is expected to
+	// be too; one that isn't is refused like an unknown credential.
+	user, err := handler.UserRepo.getUserById(tenancy.FromRequest(r).Id, credential.UserId)
+	if err == sql.ErrNoRows {
+		log.Info(err)
+		response.RespondUnauthorized(w, ulanderrors.ErrWebAuthnCredentialNotFound)
+		return nil, nil, false
+	}
+	if err != nil {
+		log.Warn(err)
+		response.RespondInternalError(w, ulanderrors.ErrWebAuthnQueryExec)
+		return nil, nil, false
+	}
+	return user, assertion, true
+}
+
+func (handler AuthHandler) startCeremony(w http.ResponseWriter, r *http.Request, ceremony string, userId int) ([]byte, bool) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		log.Warn(err)
+		response.RespondInternalError(w, ulanderrors.ErrWebAuthnQueryExec)
+		return nil, false
+	}
+
+	expirationTime := time.Now().Add(webauthn.CEREMONY_TIMEOUT)
+	err = handler.WebAuthnRepo.createChallenge(tenancy.FromRequest(r).Id, ceremony, challenge, expirationTime)
+	if err != nil {
+		log.Warn(err)
+		response.RespondInternalError(w, ulanderrors.ErrWebAuthnQueryExec)
+		return nil, false
+	}
+
+	token, err := generateCeremonyJWT(tenancy.FromRequest(r), ceremony, userId, challenge, expirationTime)
+	if err != nil {
+		log.Info(err)
+		response.RespondInternalError(w, ulanderrors.ErrLoginJWT)
+		return nil, false
+	}
+	setCeremonyCookie(w, WEBAUTHN_SESSION_COOKIE, token, expirationTime)
+	return challenge, true
+}
+
+// finishCeremony reads the ceremony started for the client and uses its
+// challenge up, so that each ceremony is finished at most once.
+func (handler AuthHandler) finishCeremony(r *http.Request, ceremony string) (*ceremonyClaims, error) {
+	claims, err := readCeremonyFromCookie(r, WEBAUTHN_SESSION_COOKIE, ceremony)
+	if err != nil {
+		return nil, err
+	}
+	err = handler.WebAuthnRepo.consumeChallenge(tenancy.FromRequest(r).Id, ceremony, claims.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func readCeremonyFromCookie(r *http.Request, cookieName string, ceremony string) (*ceremonyClaims, error) {
+	cookie, err := r.Cookie(cookieName)
+	if err != nil {
+		return nil, err
+	}
+	return parseCeremonyJWT(tenancy.FromRequest(r), cookie.Value, ceremony)
+}
+
+func setCeremonyCookie(w http.ResponseWriter, name string, value string, expirationTime time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/api",
+		Expires:  expirationTime,
+		HttpOnly: true,
+	})
+}
+
+func clearCeremonyCookie(w http.ResponseWriter, name string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    "",
+		Path:     "/api",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+}
+
+func webauthnUserEntity(user *User) webauthn.UserEntity {
+	return webauthn.UserEntity{
+		Id:          []byte(strconv.Itoa(user.Id)),
+		Name:        user.Email,
+		DisplayName: user.Fullname,
+	}
+}
+
+func credentialDescriptors(credentials []WebAuthnCredential) []webauthn.CredentialDescriptor {
+	descriptors := make([]webauthn.CredentialDescriptor, 0, len(credentials))
+	for _, credential := range credentials {
+		descriptors = append(descriptors, webauthn.CredentialDescriptor{
+			Type:       webauthn.PUBLIC_KEY_CREDENTIAL_TYPE,
+			Id:         credential.CredentialId,
+			Transports: credential.Transports,
+		})
+	}
+	return descriptors
+}
@@ -0,0 +1,287 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"userland/tenancy"
+	"userland/webauthn"
+	"userland/webauthn/webauthntest"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	webauthnUser = User{
+		Id:       1,
+		Fullname: "user",
+		Email:    "user@example.com",
+		Password: "password",
+		Verified: true,
+	}
+)
+
+type ceremonyOptionsResponse struct {
+	PublicKey struct {
+		Challenge webauthn.URLEncodedBytes `json:"challenge"`
+	} `json:"publicKey"`
+}
+
+func withUser(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), "user", &webauthnUser)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+func serveWithCookies(t *testing.T, method string, url string, body interface{}, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	reqBody, err := json.Marshal(body)
+	require.Nil(t, err)
+	req, err := http.NewRequest(method, url, bytes.NewReader(reqBody))
+	require.Nil(t, err)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	return res
+}
+
+func beginCeremony(t *testing.T, url string, body interface{}, cookies []*http.Cookie) ([]byte, []*http.Cookie) {
+	res := serveWithCookies(t, http.MethodPost, url, body, cookies)
+	require.Equal(t, http.StatusOK, res.Code)
+
+	var options ceremonyOptionsResponse
+	require.Nil(t, json.Unmarshal(res.Body.Bytes(), &options))
+	return options.PublicKey.Challenge, res.Result().Cookies()
+}
+
+func findCookie(cookies []*http.Cookie, name string) *http.Cookie {
+	for _, cookie := range cookies {
+		if cookie.Name == name && cookie.Value != "" {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func storedCredential(authenticator *webauthntest.Authenticator) *WebAuthnCredential {
+	return &WebAuthnCredential{
+		Id:           1,
+		UserId:       webauthnUser.Id,
+		CredentialId: authenticator.CredentialId,
+		PublicKey:    authenticator.PublicKey(),
+		SignCount:    int64(authenticator.SignCount),
+	}
+}
+
+func TestWebAuthnRegistration(t *testing.T) {
+	testAuthHandlerInit(t)
+	authenticator := webauthntest.NewAuthenticator(SAMPLE_RP_ID, SAMPLE_RP_ORIGIN)
+
+	mockWebAuthnRepo.EXPECT().getCredentialsByUserId(tenancy.DEFAULT_TENANT_ID, webauthnUser.Id).Return([]WebAuthnCredential{}, nil)
+	challenge, cookies := beginCeremony(t, "/me/webauthn/register/begin", nil, nil)
+
+	res := serveWithCookies(t, http.MethodPost, "/me/webauthn/register/finish", authenticator.Register(challenge, []byte("1")), nil)
+	assert.Equal(t, http.StatusBadRequest, res.Code, "Registration without a started ceremony should fail")
+
+	res = serveWithCookies(t, http.MethodPost, "/me/webauthn/register/finish", authenticator.Register([]byte("otherchallenge"), []byte("1")), cookies)
+	assert.Equal(t, http.StatusBadRequest, res.Code, "Registration for another challenge should fail")
+
+	res = serveWithCookies(t, http.MethodPost, "/me/webauthn/register/finish", authenticator.Register(challenge, []byte("1")), cookies)
+	assert.Equal(t, http.StatusBadRequest, res.Code, "Failed attempt should use the challenge up")
+
+	mockWebAuthnRepo.EXPECT().getCredentialsByUserId(tenancy.DEFAULT_TENANT_ID, webauthnUser.Id).Return([]WebAuthnCredential{}, nil)
+	challenge, cookies = beginCeremony(t, "/me/webauthn/register/begin", nil, nil)
+	mockWebAuthnRepo.EXPECT().createCredential(&webauthnUser, gomock.Any()).DoAndReturn(func(user *User, credential *webauthn.Credential) error {
+		assert.Equal(t, authenticator.CredentialId, credential.Id)
+		assert.Equal(t, authenticator.PublicKey(), credential.PublicKey)
+		return nil
+	})
+	res = serveWithCookies(t, http.MethodPost, "/me/webauthn/register/finish", authenticator.Register(challenge, []byte("1")), cookies)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	testAuthHandlerEnd()
+}
+
+func TestGetAndDeleteWebAuthnCredentials(t *testing.T) {
+	testAuthHandlerInit(t)
+	authenticator := webauthntest.NewAuthenticator(SAMPLE_RP_ID, SAMPLE_RP_ORIGIN)
+
+	mockWebAuthnRepo.EXPECT().getCredentialsByUserId(tenancy.DEFAULT_TENANT_ID, webauthnUser.Id).Return([]WebAuthnCredential{*storedCredential(authenticator)}, nil)
+	res := serveWithCookies(t, http.MethodGet, "/me/webauthn", nil, nil)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.NotContains(t, res.Body.String(), "public_key", "Credential list should not expose public keys")
+
+	gomock.InOrder(
+		mockWebAuthnRepo.EXPECT().deleteCredential(&webauthnUser, 1).Return(nil),
+		mockWebAuthnRepo.EXPECT().deleteCredential(&webauthnUser, 2).Return(errors.New("")),
+	)
+	assert.Equal(t, http.StatusOK, serveWithCookies(t, http.MethodDelete, "/me/webauthn/1", nil, nil).Code)
+	assert.Equal(t, http.StatusBadRequest, serveWithCookies(t, http.MethodDelete, "/me/webauthn/2", nil, nil).Code)
+	assert.Equal(t, http.StatusBadRequest, serveWithCookies(t, http.MethodDelete, "/me/webauthn/abc", nil, nil).Code)
+
+	testAuthHandlerEnd()
+}
+
+func TestPasswordlessWebAuthnLogin(t *testing.T) {
+	testAuthHandlerInit(t)
+	authenticator := webauthntest.NewAuthenticator(SAMPLE_RP_ID, SAMPLE_RP_ORIGIN)
+	authenticator.UserHandle = []byte("1")
+	credential := storedCredential(authenticator)
+
+	challenge, cookies := beginCeremony(t, "/auth/webauthn/login/begin", nil, nil)
+
+	gomock.InOrder(
+		mockWebAuthnRepo.EXPECT().getCredentialByCredentialId(tenancy.DEFAULT_TENANT_ID, authenticator.CredentialId).Return(credential, nil),
+		mockWebAuthnRepo.EXPECT().updateSignCount(tenancy.DEFAULT_TENANT_ID, credential, uint32(1)).Return(nil),
+		mockRepo.EXPECT().getUserById(tenancy.DEFAULT_TENANT_ID, webauthnUser.Id).Return(&webauthnUser, nil),
+		expectKnownDevice(&webauthnUser),
+	)
+	assertion := authenticator.Assert(challenge)
+	res := serveWithCookies(t, http.MethodPost, "/auth/webauthn/login/finish", assertion, cookies)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.NotNil(t, findCookie(res.Result().Cookies(), "token"), "Passkey login should start a session")
+
+	res = serveWithCookies(t, http.MethodPost, "/auth/webauthn/login/finish", assertion, cookies)
+	assert.Equal(t, http.StatusBadRequest, res.Code, "Replayed assertion should be refused")
+
+	testAuthHandlerEnd()
+}
+
+func TestPasswordlessWebAuthnLoginPasswordResetRequired(t *testing.T) {
+	testAuthHandlerInit(t)
+	authenticator := webauthntest.NewAuthenticator(SAMPLE_RP_ID, SAMPLE_RP_ORIGIN)
+	authenticator.UserHandle = []byte("1")
+	credential := storedCredential(authenticator)
+	user := webauthnUser
+	user.PasswordResetRequired = true
+
+	challenge, cookies := beginCeremony(t, "/auth/webauthn/login/begin", nil, nil)
+
+	gomock.InOrder(
+		mockWebAuthnRepo.EXPECT().getCredentialByCredentialId(tenancy.DEFAULT_TENANT_ID, authenticator.CredentialId).Return(credential, nil),
+		mockWebAuthnRepo.EXPECT().updateSignCount(tenancy.DEFAULT_TENANT_ID, credential, uint32(1)).Return(nil),
+		mockRepo.EXPECT().getUserById(tenancy.DEFAULT_TENANT_ID, user.Id).Return(&user, nil),
+	)
+	res := serveWithCookies(t, http.MethodPost, "/auth/webauthn/login/finish", authenticator.Assert(challenge), cookies)
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+	assert.Nil(t, findCookie(res.Result().Cookies(), "token"), "Passkeys shouldn't get around a required password reset")
+
+	testAuthHandlerEnd()
+}
+
+func TestWebAuthnLoginRequiresUserVerificationWithoutPassword(t *testing.T) {
+	testAuthHandlerInit(t)
+	authenticator := webauthntest.NewAuthenticator(SAMPLE_RP_ID, SAMPLE_RP_ORIGIN)
+	authenticator.UserVerified = false
+	credential := storedCredential(authenticator)
+
+	challenge, cookies := beginCeremony(t, "/auth/webauthn/login/begin", nil, nil)
+
+	gomock.InOrder(
+		mockWebAuthnRepo.EXPECT().getCredentialByCredentialId(tenancy.DEFAULT_TENANT_ID, authenticator.CredentialId).Return(credential, nil),
+		mockWebAuthnRepo.EXPECT().updateSignCount(tenancy.DEFAULT_TENANT_ID, credential, uint32(1)).Return(nil),
+		mockRepo.EXPECT().getUserById(tenancy.DEFAULT_TENANT_ID, webauthnUser.Id).Return(&webauthnUser, nil),
+	)
+	res := serveWithCookies(t, http.MethodPost, "/auth/webauthn/login/finish", authenticator.Assert(challenge), cookies)
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+
+	testAuthHandlerEnd()
+}
+
+func TestBeginWebAuthnLoginDoesNotRevealAccounts(t *testing.T) {
+	testAuthHandlerInit(t)
+
+	for _, email := range []string{webauthnUser.Email, "nobody@example.com"} {
+		res := serveWithCookies(t, http.MethodPost, "/auth/webauthn/login/begin", map[string]string{"email": email}, nil)
+		require.Equal(t, http.StatusOK, res.Code)
+
+		var options struct {
+			PublicKey webauthn.RequestOptions `json:"publicKey"`
+		}
+		require.Nil(t, json.Unmarshal(res.Body.Bytes(), &options))
+		assert.Empty(t, options.PublicKey.AllowCredentials, "Credentials shouldn't be listed before the password step")
+	}
+
+	testAuthHandlerEnd()
+}
+
+func TestWebAuthnAsSecondFactor(t *testing.T) {
+	testAuthHandlerInit(t)
+	authenticator := webauthntest.NewAuthenticator(SAMPLE_RP_ID, SAMPLE_RP_ORIGIN)
+	authenticator.UserVerified = false
+	credential := storedCredential(authenticator)
+
+	gomock.InOrder(
+		mockRepo.EXPECT().loginUser(tenancy.DEFAULT_TENANT_ID, webauthnUser.Email, webauthnUser.Password).Return(nil),
+		mockRepo.EXPECT().getUserByEmail(tenancy.DEFAULT_TENANT_ID, webauthnUser.Email).Return(&webauthnUser, nil),
+		mockWebAuthnRepo.EXPECT().getCredentialsByUserId(tenancy.DEFAULT_TENANT_ID, webauthnUser.Id).Return([]WebAuthnCredential{*credential}, nil),
+	)
+	res := serveWithCookies(t, http.MethodPost, "/auth/login", webauthnUser, nil)
+	require.Equal(t, http.StatusOK, res.Code)
+	assert.JSONEq(t, `{"require_tfa": true}`, res.Body.String())
+	assert.Nil(t, findCookie(res.Result().Cookies(), "token"), "Password alone should not start a session when a passkey is registered")
+	tfaCookie := findCookie(res.Result().Cookies(), TFA_SESSION_COOKIE)
+	require.NotNil(t, tfaCookie)
+
+	mockWebAuthnRepo.EXPECT().getCredentialsByUserId(tenancy.DEFAULT_TENANT_ID, webauthnUser.Id).Return([]WebAuthnCredential{*credential}, nil)
+	challenge, cookies := beginCeremony(t, "/auth/webauthn/login/begin", nil, []*http.Cookie{tfaCookie})
+
+	gomock.InOrder(
+		mockWebAuthnRepo.EXPECT().getCredentialByCredentialId(tenancy.DEFAULT_TENANT_ID, authenticator.CredentialId).Return(credential, nil),
+		mockWebAuthnRepo.EXPECT().updateSignCount(tenancy.DEFAULT_TENANT_ID, credential, uint32(1)).Return(nil),
+		mockRepo.EXPECT().getUserById(tenancy.DEFAULT_TENANT_ID, webauthnUser.Id).Return(&webauthnUser, nil),
+		expectKnownDevice(&webauthnUser),
+	)
+	res = serveWithCookies(t, http.MethodPost, "/auth/webauthn/login/finish", authenticator.Assert(challenge), append(cookies, tfaCookie))
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.NotNil(t, findCookie(res.Result().Cookies(), "token"))
+
+	testAuthHandlerEnd()
+}
+
+func TestWebAuthnLoginWithUnknownCredential(t *testing.T) {
+	testAuthHandlerInit(t)
+	authenticator := webauthntest.NewAuthenticator(SAMPLE_RP_ID, SAMPLE_RP_ORIGIN)
+
+	challenge, cookies := beginCeremony(t, "/auth/webauthn/login/begin", nil, nil)
+
+	mockWebAuthnRepo.EXPECT().getCredentialByCredentialId(tenancy.DEFAULT_TENANT_ID, authenticator.CredentialId).Return(nil, sql.ErrNoRows)
+	res := serveWithCookies(t, http.MethodPost, "/auth/webauthn/login/finish", authenticator.Assert(challenge), cookies)
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+
+	res = serveWithCookies(t, http.MethodPost, "/auth/webauthn/login/finish", authenticator.Assert(challenge), nil)
+	assert.Equal(t, http.StatusBadRequest, res.Code, "Login without a started ceremony should fail")
+
+	challenge, cookies = beginCeremony(t, "/auth/webauthn/login/begin", nil, nil)
+	mockWebAuthnRepo.EXPECT().getCredentialByCredentialId(tenancy.DEFAULT_TENANT_ID, authenticator.CredentialId).Return(nil, errors.New(""))
+	res = serveWithCookies(t, http.MethodPost, "/auth/webauthn/login/finish", authenticator.Assert(challenge), cookies)
+	assert.Equal(t, http.StatusInternalServerError, res.Code)
+
+	testAuthHandlerEnd()
+}
+
+func TestWebAuthnLoginWithUserOutsideTenant(t *testing.T) {
+	testAuthHandlerInit(t)
+	authenticator := webauthntest.NewAuthenticator(SAMPLE_RP_ID, SAMPLE_RP_ORIGIN)
+	credential := storedCredential(authenticator)
+
+	challenge, cookies := beginCeremony(t, "/auth/webauthn/login/begin", nil, nil)
+	gomock.InOrder(
+		mockWebAuthnRepo.EXPECT().getCredentialByCredentialId(tenancy.DEFAULT_TENANT_ID, authenticator.CredentialId).Return(credential, nil),
+		mockWebAuthnRepo.EXPECT().updateSignCount(tenancy.DEFAULT_TENANT_ID, credential, uint32(1)).Return(nil),
+		mockRepo.EXPECT().getUserById(tenancy.DEFAULT_TENANT_ID, webauthnUser.Id).Return(nil, sql.ErrNoRows),
+	)
+	res := serveWithCookies(t, http.MethodPost, "/auth/webauthn/login/finish", authenticator.Assert(challenge), cookies)
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+
+	testAuthHandlerEnd()
+}
@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"time"
+	"userland/appcontext"
+	"userland/webauthn"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const (
+	CREATE_WEBAUTHN_CREDENTIAL_QUERY                  = "INSERT INTO webauthn_credential (user_id, credential_id, public_key, sign_count, transports) SELECT $1, $2, $3, $4, $5 WHERE EXISTS (SELECT 1 FROM \"user\" WHERE id=$1 AND tenant_id=$6)"
+	SELECT_WEBAUTHN_CREDENTIALS_BY_USER_ID_QUERY      = "SELECT * FROM webauthn_credential WHERE user_id=$1 AND EXISTS (SELECT 1 FROM \"user\" WHERE id=$1 AND tenant_id=$2) ORDER BY id"
+	SELECT_WEBAUTHN_CREDENTIAL_BY_CREDENTIAL_ID_QUERY = "SELECT webauthn_credential.* FROM webauthn_credential JOIN \"user\" ON \"user\".id=webauthn_credential.user_id WHERE webauthn_credential.credential_id=$1 AND \"user\".tenant_id=$2"
+	UPDATE_WEBAUTHN_SIGN_COUNT_QUERY                  = "UPDATE webauthn_credential SET sign_count=$1, last_used_at=now() WHERE id=$2 AND EXISTS (SELECT 1 FROM \"user\" WHERE id=webauthn_credential.user_id AND tenant_id=$3)"
+	DELETE_WEBAUTHN_CREDENTIAL_QUERY                  = "DELETE FROM webauthn_credential WHERE id=$1 AND user_id=$2 AND EXISTS (SELECT 1 FROM \"user\" WHERE id=$2 AND tenant_id=$3)"
+	CREATE_WEBAUTHN_CHALLENGE_QUERY                   = "INSERT INTO webauthn_challenge (challenge_hash, tenant_id, ceremony, expires_at) VALUES ($1, $2, $3, $4)"
+	DELETE_EXPIRED_WEBAUTHN_CHALLENGES_QUERY          = "DELETE FROM webauthn_challenge WHERE expires_at <= now()"
+	CONSUME_WEBAUTHN_CHALLENGE_QUERY                  = "DELETE FROM webauthn_challenge WHERE challenge_hash=$1 AND tenant_id=$2 AND ceremony=$3 AND expires_at > now()"
+)
+
+type webauthnRepositoryInterface interface {
+	createCredential(user *User, credential *webauthn.Credential) error
+	getCredentialsByUserId(tenantId int, userId int) ([]WebAuthnCredential, error)
+	getCredentialByCredentialId(tenantId int, credentialId []byte) (*WebAuthnCredential, error)
+	updateSignCount(tenantId int, credential *WebAuthnCredential, signCount uint32) error
+	deleteCredential(user *User, id int) error
+	createChallenge(tenantId int, ceremony string, challenge []byte, expiresAt time.Time) error
+	consumeChallenge(tenantId int, ceremony string, challenge []byte) error
+}
+
+type webauthnRepository struct {
+	db *sqlx.DB
+}
+
+func GetWebAuthnRepository() *webauthnRepository {
+	repo := webauthnRepository{appcontext.GetDB()}
+	return &repo
+}
+
+func (repo *webauthnRepository) createCredential(user *User, credential *webauthn.Credential) error {
+	stmt, err := repo.db.Preparex(CREATE_WEBAUTHN_CREDENTIAL_QUERY)
+	if err != nil {
+		return err
+	}
+	_, err = stmt.Exec(user.Id, credential.Id, credential.PublicKey, credential.SignCount, pq.StringArray(credential.Transports), user.TenantId)
+	return err
+}
+
+func (repo *webauthnRepository) getCredentialsByUserId(tenantId int, userId int) ([]WebAuthnCredential, error) {
+	credentials := []WebAuthnCredential{}
+	err := repo.db.Select(&credentials, SELECT_WEBAUTHN_CREDENTIALS_BY_USER_ID_QUERY, userId, tenantId)
+	return credentials, err
+}
+
+// getCredentialByCredentialId finds the credential among those of the
+// tenant's users, returning sql.ErrNoRows for credentials of other tenants.
+func (repo *webauthnRepository) getCredentialByCredentialId(tenantId int, credentialId []byte) (*WebAuthnCredential, error) {
+	var credential WebAuthnCredential
+	err := repo.db.Get(&credential, SELECT_WEBAUTHN_CREDENTIAL_BY_CREDENTIAL_ID_QUERY, credentialId, tenantId)
+	if err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+func (repo *webauthnRepository) updateSignCount(tenantId int, credential *WebAuthnCredential, signCount uint32) error {
+	stmt, err := repo.db.Preparex(UPDATE_WEBAUTHN_SIGN_COUNT_QUERY)
+	if err != nil {
+		return err
+	}
+	_, err = stmt.Exec(signCount, credential.Id, tenantId)
+	return err
+}
+
+func (repo *webauthnRepository) deleteCredential(user *User, id int) error {
+	stmt, err := repo.db.Preparex(DELETE_WEBAUTHN_CREDENTIAL_QUERY)
+	if err != nil {
+		return err
+	}
+	result, err := stmt.Exec(id, user.Id, user.TenantId)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err == nil && affected == 0 {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// createChallenge remembers the challenge of a ceremony being started, and
+// forgets those of ceremonies that were never finished.
+func (repo *webauthnRepository) createChallenge(tenantId int, ceremony string, challenge []byte, expiresAt time.Time) error {
+	_, err := repo.db.Exec(DELETE_EXPIRED_WEBAUTHN_CHALLENGES_QUERY)
+	if err != nil {
+		return err
+	}
+	_, err = repo.db.Exec(CREATE_WEBAUTHN_CHALLENGE_QUERY, hashChallenge(challenge), tenantId, ceremony, expiresAt)
+	return err
+}
+
+// consumeChallenge uses the challenge up, returning sql.ErrNoRows when it was
+// never issued for the ceremony, has expired or was already used.
+func (repo *webauthnRepository) consumeChallenge(tenantId int, ceremony string, challenge []byte) error {
+	result, err := repo.db.Exec(CONSUME_WEBAUTHN_CHALLENGE_QUERY, hashChallenge(challenge), tenantId, ceremony)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err == nil && affected == 0 {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+func hashChallenge(challenge []byte) string {
+	hash := sha256.Sum256(challenge)
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	DEFAULT_ACCOUNT_DELETION_GRACE_DAYS    = 30
+	DEFAULT_ACCOUNT_PURGE_INTERVAL_MINUTES = 60
+	DEFAULT_DATA_EXPORT_INTERVAL_SECONDS   = 30
+	DEFAULT_DATA_EXPORT_LINK_HOURS         = 24
+	DEFAULT_USERNAME_REDIRECT_DAYS         = 90
+)
+
+// GetAccountDeletionGracePeriod returns how long a deleted account can still
+// be restored before it's purged.
+func GetAccountDeletionGracePeriod() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("ACCOUNT_DELETION_GRACE_DAYS"))
+	if err != nil || days < 0 {
+		days = DEFAULT_ACCOUNT_DELETION_GRACE_DAYS
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// GetAccountPurgeInterval returns how often accounts past their grace period
+// are looked for and purged.
+func GetAccountPurgeInterval() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("ACCOUNT_PURGE_INTERVAL_MINUTES"))
+	if err != nil || minutes <= 0 {
+		minutes = DEFAULT_ACCOUNT_PURGE_INTERVAL_MINUTES
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// GetDataExportInterval returns how often requested data exports are looked
+// for and built.
+func GetDataExportInterval() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("DATA_EXPORT_INTERVAL_SECONDS"))
+	if err != nil || seconds <= 0 {
+		seconds = DEFAULT_DATA_EXPORT_INTERVAL_SECONDS
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// GetDataExportLinkLifetime returns how long the link to download a data
+// export stays valid once the archive is ready.
+func GetDataExportLinkLifetime() time.Duration {
+	hours, err := strconv.Atoi(os.Getenv("DATA_EXPORT_LINK_HOURS"))
+	if err != nil || hours <= 0 {
+		hours = DEFAULT_DATA_EXPORT_LINK_HOURS
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// GetUsernameRedirectLifetime returns how long a username a user moved away
+// from keeps pointing at them, during which nobody else can take it.
+func GetUsernameRedirectLifetime() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("USERNAME_REDIRECT_DAYS"))
+	if err != nil || days < 0 {
+		days = DEFAULT_USERNAME_REDIRECT_DAYS
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccountDeletionGracePeriod(t *testing.T) {
+	assert.Equal(t, DEFAULT_ACCOUNT_DELETION_GRACE_DAYS*24*time.Hour, GetAccountDeletionGracePeriod())
+
+	os.Setenv("ACCOUNT_DELETION_GRACE_DAYS", "7")
+	assert.Equal(t, 7*24*time.Hour, GetAccountDeletionGracePeriod())
+
+	os.Setenv("ACCOUNT_DELETION_GRACE_DAYS", "0")
+	assert.Equal(t, time.Duration(0), GetAccountDeletionGracePeriod())
+
+	os.Setenv("ACCOUNT_DELETION_GRACE_DAYS", "-1")
+	assert.Equal(t, DEFAULT_ACCOUNT_DELETION_GRACE_DAYS*24*time.Hour, GetAccountDeletionGracePeriod())
+
+	os.Unsetenv("ACCOUNT_DELETION_GRACE_DAYS")
+}
+
+func TestAccountPurgeInterval(t *testing.T) {
+	assert.Equal(t, DEFAULT_ACCOUNT_PURGE_INTERVAL_MINUTES*time.Minute, GetAccountPurgeInterval())
+
+	os.Setenv("ACCOUNT_PURGE_INTERVAL_MINUTES", "5")
+	assert.Equal(t, 5*time.Minute, GetAccountPurgeInterval())
+
+	os.Setenv("ACCOUNT_PURGE_INTERVAL_MINUTES", "often")
+	assert.Equal(t, DEFAULT_ACCOUNT_PURGE_INTERVAL_MINUTES*time.Minute, GetAccountPurgeInterval())
+
+	os.Unsetenv("ACCOUNT_PURGE_INTERVAL_MINUTES")
+}
+
+func TestDataExportInterval(t *testing.T) {
+	assert.Equal(t, DEFAULT_DATA_EXPORT_INTERVAL_SECONDS*time.Second, GetDataExportInterval())
+
+	os.Setenv("DATA_EXPORT_INTERVAL_SECONDS", "5")
+	assert.Equal(t, 5*time.Second, GetDataExportInterval())
+
+	os.Setenv("DATA_EXPORT_INTERVAL_SECONDS", "0")
+	assert.Equal(t, DEFAULT_DATA_EXPORT_INTERVAL_SECONDS*time.Second, GetDataExportInterval())
+
+	os.Unsetenv("DATA_EXPORT_INTERVAL_SECONDS")
+}
+
+func TestDataExportLinkLifetime(t *testing.T) {
+	assert.Equal(t, DEFAULT_DATA_EXPORT_LINK_HOURS*time.Hour, GetDataExportLinkLifetime())
+
+	os.Setenv("DATA_EXPORT_LINK_HOURS", "48")
+	assert.Equal(t, 48*time.Hour, GetDataExportLinkLifetime())
+
+	os.Setenv("DATA_EXPORT_LINK_HOURS", "0")
+	assert.Equal(t, DEFAULT_DATA_EXPORT_LINK_HOURS*time.Hour, GetDataExportLinkLifetime())
+
+	os.Unsetenv("DATA_EXPORT_LINK_HOURS")
+}
+
+func TestUsernameRedirectLifetime(t *testing.T) {
+	assert.Equal(t, DEFAULT_USERNAME_REDIRECT_DAYS*24*time.Hour, GetUsernameRedirectLifetime())
+
+	os.Setenv("USERNAME_REDIRECT_DAYS", "30")
+	assert.Equal(t, 30*24*time.Hour, GetUsernameRedirectLifetime())
+
+	os.Setenv("USERNAME_REDIRECT_DAYS", "0")
+	assert.Equal(t, time.Duration(0), GetUsernameRedirectLifetime())
+
+	os.Setenv("USERNAME_REDIRECT_DAYS", "forever")
+	assert.Equal(t, DEFAULT_USERNAME_REDIRECT_DAYS*24*time.Hour, GetUsernameRedirectLifetime())
+
+	os.Unsetenv("USERNAME_REDIRECT_DAYS")
+}
@@ -0,0 +1,9 @@
+package config
+
+import (
+	"os"
+)
+
+func GetAppURL() string {
+	return os.Getenv("APP_URL")
+}
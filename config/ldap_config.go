@@ -0,0 +1,90 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	AUTHENTICATOR_LOCAL = "local"
+	AUTHENTICATOR_LDAP  = "ldap"
+
+	DEFAULT_LDAP_USER_FILTER        = "(mail=%s)"
+	DEFAULT_LDAP_FULLNAME_ATTRIBUTE = "cn"
+	DEFAULT_LDAP_EMAIL_ATTRIBUTE    = "mail"
+	DEFAULT_LDAP_TIMEOUT_SECONDS    = 5
+)
+
+// GetAuthenticators returns the backends passwords are checked against, in
+// order, read from a comma-separated list. Only the local password hashes
+// are checked unless configured otherwise.
+func GetAuthenticators() []string {
+	authenticators := []string{}
+	for _, authenticator := range strings.Split(os.Getenv("AUTHENTICATORS"), ",") {
+		authenticator = strings.ToLower(strings.TrimSpace(authenticator))
+		if authenticator != "" {
+			authenticators = append(authenticators, authenticator)
+		}
+	}
+	if len(authenticators) == 0 {
+		return []string{AUTHENTICATOR_LOCAL}
+	}
+	return authenticators
+}
+
+// GetLDAPURL returns the ldap:// or ldaps:// URL of the directory.
+func GetLDAPURL() string {
+	return os.Getenv("LDAP_URL")
+}
+
+// GetLDAPBindDN returns the DN of the service account searching for users,
+// which is empty to search anonymously.
+func GetLDAPBindDN() string {
+	return os.Getenv("LDAP_BIND_DN")
+}
+
+func GetLDAPBindPassword() string {
+	return os.Getenv("LDAP_BIND_PASSWORD")
+}
+
+func GetLDAPBaseDN() string {
+	return os.Getenv("LDAP_BASE_DN")
+}
+
+// GetLDAPUserFilter returns the filter finding a user by the email address
+// logged in with, which takes the place of %s.
+func GetLDAPUserFilter() string {
+	filter := os.Getenv("LDAP_USER_FILTER")
+	if filter == "" {
+		return DEFAULT_LDAP_USER_FILTER
+	}
+	return filter
+}
+
+func GetLDAPFullnameAttribute() string {
+	attribute := os.Getenv("LDAP_FULLNAME_ATTRIBUTE")
+	if attribute == "" {
+		return DEFAULT_LDAP_FULLNAME_ATTRIBUTE
+	}
+	return attribute
+}
+
+func GetLDAPEmailAttribute() string {
+	attribute := os.Getenv("LDAP_EMAIL_ATTRIBUTE")
+	if attribute == "" {
+		return DEFAULT_LDAP_EMAIL_ATTRIBUTE
+	}
+	return attribute
+}
+
+// GetLDAPTimeout returns how long connecting to the directory and each of its
+// requests may take.
+func GetLDAPTimeout() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("LDAP_TIMEOUT_SECONDS"))
+	if err != nil || seconds <= 0 {
+		seconds = DEFAULT_LDAP_TIMEOUT_SECONDS
+	}
+	return time.Duration(seconds) * time.Second
+}
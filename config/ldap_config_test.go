@@ -0,0 +1,33 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthenticators(t *testing.T) {
+	assert.Equal(t, []string{AUTHENTICATOR_LOCAL}, GetAuthenticators())
+
+	os.Setenv("AUTHENTICATORS", " LDAP, local,")
+	assert.Equal(t, []string{AUTHENTICATOR_LDAP, AUTHENTICATOR_LOCAL}, GetAuthenticators())
+
+	os.Unsetenv("AUTHENTICATORS")
+}
+
+func TestLDAPDefaults(t *testing.T) {
+	assert.Equal(t, DEFAULT_LDAP_USER_FILTER, GetLDAPUserFilter())
+	assert.Equal(t, DEFAULT_LDAP_FULLNAME_ATTRIBUTE, GetLDAPFullnameAttribute())
+	assert.Equal(t, DEFAULT_LDAP_EMAIL_ATTRIBUTE, GetLDAPEmailAttribute())
+	assert.Equal(t, DEFAULT_LDAP_TIMEOUT_SECONDS*time.Second, GetLDAPTimeout())
+
+	os.Setenv("LDAP_USER_FILTER", "(&(objectClass=user)(userPrincipalName=%s))")
+	os.Setenv("LDAP_TIMEOUT_SECONDS", "2")
+	assert.Equal(t, "(&(objectClass=user)(userPrincipalName=%s))", GetLDAPUserFilter())
+	assert.Equal(t, 2*time.Second, GetLDAPTimeout())
+
+	os.Unsetenv("LDAP_USER_FILTER")
+	os.Unsetenv("LDAP_TIMEOUT_SECONDS")
+}
@@ -0,0 +1,26 @@
+package config
+
+import (
+	"fmt"
+	"os"
+)
+
+func GetSMTPHost() string {
+	return os.Getenv("SMTP_HOST")
+}
+
+func GetSMTPAddress() string {
+	return fmt.Sprintf("%s:%s", os.Getenv("SMTP_HOST"), os.Getenv("SMTP_PORT"))
+}
+
+func GetSMTPUsername() string {
+	return os.Getenv("SMTP_USER")
+}
+
+func GetSMTPPassword() string {
+	return os.Getenv("SMTP_PASS")
+}
+
+func GetMailSender() string {
+	return os.Getenv("MAIL_SENDER")
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	mailConfigVars map[string]string
+)
+
+func testMailConfigInit() {
+	mailConfigVars = map[string]string{
+		"SMTP_HOST":   "smtp.example.com",
+		"SMTP_PORT":   "587",
+		"SMTP_USER":   "mailer",
+		"SMTP_PASS":   "mailerpassword",
+		"MAIL_SENDER": "no-reply@example.com",
+	}
+
+	for key, val := range mailConfigVars {
+		os.Setenv(key, val)
+	}
+}
+
+func testMailConfigEnd() {
+	for key := range mailConfigVars {
+		os.Unsetenv(key)
+	}
+}
+
+func TestMailConfig(t *testing.T) {
+	testMailConfigInit()
+
+	assert.Equal(t, mailConfigVars["SMTP_HOST"], GetSMTPHost())
+	assert.Equal(t, "smtp.example.com:587", GetSMTPAddress())
+	assert.Equal(t, mailConfigVars["SMTP_USER"], GetSMTPUsername())
+	assert.Equal(t, mailConfigVars["SMTP_PASS"], GetSMTPPassword())
+	assert.Equal(t, mailConfigVars["MAIL_SENDER"], GetMailSender())
+
+	testMailConfigEnd()
+}
+
+func TestAppURL(t *testing.T) {
+	os.Setenv("APP_URL", "https://userland.example.com")
+	assert.Equal(t, "https://userland.example.com", GetAppURL())
+	os.Unsetenv("APP_URL")
+}
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"os"
+	"strconv"
+)
+
+const (
+	DEFAULT_PICTURE_MAX_BYTES     = 5 << 20
+	DEFAULT_PICTURE_MAX_DIMENSION = 4096
+)
+
+// GetPictureMaxBytes returns the largest profile picture upload accepted, in
+// bytes.
+func GetPictureMaxBytes() int64 {
+	maxBytes, err := strconv.ParseInt(os.Getenv("PICTURE_MAX_BYTES"), 10, 64)
+	if err != nil || maxBytes <= 0 {
+		maxBytes = DEFAULT_PICTURE_MAX_BYTES
+	}
+	return maxBytes
+}
+
+// GetPictureMaxDimension returns the largest width or height of a profile
+// picture accepted, in pixels. It's checked before decoding, so that small
+// files can't expand into huge images.
+func GetPictureMaxDimension() int {
+	dimension, err := strconv.Atoi(os.Getenv("PICTURE_MAX_DIMENSION"))
+	if err != nil || dimension <= 0 {
+		dimension = DEFAULT_PICTURE_MAX_DIMENSION
+	}
+	return dimension
+}
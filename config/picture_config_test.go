@@ -0,0 +1,32 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPictureMaxBytes(t *testing.T) {
+	assert.Equal(t, int64(DEFAULT_PICTURE_MAX_BYTES), GetPictureMaxBytes())
+
+	os.Setenv("PICTURE_MAX_BYTES", "1048576")
+	assert.Equal(t, int64(1048576), GetPictureMaxBytes())
+
+	os.Setenv("PICTURE_MAX_BYTES", "0")
+	assert.Equal(t, int64(DEFAULT_PICTURE_MAX_BYTES), GetPictureMaxBytes())
+
+	os.Unsetenv("PICTURE_MAX_BYTES")
+}
+
+func TestPictureMaxDimension(t *testing.T) {
+	assert.Equal(t, DEFAULT_PICTURE_MAX_DIMENSION, GetPictureMaxDimension())
+
+	os.Setenv("PICTURE_MAX_DIMENSION", "2048")
+	assert.Equal(t, 2048, GetPictureMaxDimension())
+
+	os.Setenv("PICTURE_MAX_DIMENSION", "huge")
+	assert.Equal(t, DEFAULT_PICTURE_MAX_DIMENSION, GetPictureMaxDimension())
+
+	os.Unsetenv("PICTURE_MAX_DIMENSION")
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+const (
+	REGISTRATION_MODE_OPEN             = "open"
+	REGISTRATION_MODE_INVITE_ONLY      = "invite_only"
+	REGISTRATION_MODE_DOMAIN_ALLOWLIST = "domain_allowlist"
+	REGISTRATION_MODE_CLOSED           = "closed"
+
+	DEFAULT_DISPOSABLE_DOMAINS_FILE = "disposable_email_domains.txt"
+)
+
+// GetRegistrationMode returns who may sign up. Registration is open unless
+// configured otherwise, and closed when the mode isn't recognized.
+func GetRegistrationMode() string {
+	return ParseRegistrationMode(os.Getenv("REGISTRATION_MODE"))
+}
+
+// ParseRegistrationMode normalizes a registration mode the way
+// GetRegistrationMode does, for modes configured elsewhere than the
+// environment.
+func ParseRegistrationMode(mode string) string {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	switch mode {
+	case "":
+		return REGISTRATION_MODE_OPEN
+	case REGISTRATION_MODE_OPEN, REGISTRATION_MODE_INVITE_ONLY, REGISTRATION_MODE_DOMAIN_ALLOWLIST, REGISTRATION_MODE_CLOSED:
+		return mode
+	}
+	return REGISTRATION_MODE_CLOSED
+}
+
+// GetRegistrationAllowedDomains returns the email domains which may sign up
+// in the domain allowlist mode, read from a comma-separated list.
+func GetRegistrationAllowedDomains() []string {
+	domains := []string{}
+	for _, domain := range strings.Split(os.Getenv("REGISTRATION_ALLOWED_DOMAINS"), ",") {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	return domains
+}
+
+func GetDisposableDomainsFile() string {
+	path := os.Getenv("DISPOSABLE_DOMAINS_FILE")
+	if path == "" {
+		return DEFAULT_DISPOSABLE_DOMAINS_FILE
+	}
+	return path
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistrationMode(t *testing.T) {
+	assert.Equal(t, REGISTRATION_MODE_OPEN, GetRegistrationMode())
+
+	os.Setenv("REGISTRATION_MODE", "Invite_Only")
+	assert.Equal(t, REGISTRATION_MODE_INVITE_ONLY, GetRegistrationMode())
+
+	os.Setenv("REGISTRATION_MODE", "everyone")
+	assert.Equal(t, REGISTRATION_MODE_CLOSED, GetRegistrationMode(), "Unknown mode should not let anyone in")
+
+	os.Unsetenv("REGISTRATION_MODE")
+}
+
+func TestRegistrationAllowedDomains(t *testing.T) {
+	assert.Empty(t, GetRegistrationAllowedDomains())
+
+	os.Setenv("REGISTRATION_ALLOWED_DOMAINS", " OurCompany.com, ,subsidiary.com")
+	assert.Equal(t, []string{"ourcompany.com", "subsidiary.com"}, GetRegistrationAllowedDomains())
+
+	os.Unsetenv("REGISTRATION_ALLOWED_DOMAINS")
+}
+
+func TestDisposableDomainsFile(t *testing.T) {
+	assert.Equal(t, DEFAULT_DISPOSABLE_DOMAINS_FILE, GetDisposableDomainsFile())
+
+	os.Setenv("DISPOSABLE_DOMAINS_FILE", "/etc/userland/disposable.txt")
+	assert.Equal(t, "/etc/userland/disposable.txt", GetDisposableDomainsFile())
+
+	os.Unsetenv("DISPOSABLE_DOMAINS_FILE")
+}
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	DEFAULT_REAUTH_WINDOW_MINUTES = 10
+	DEFAULT_IMPERSONATION_MINUTES = 15
+)
+
+// GetReauthWindow returns how long a session counts as recently authenticated
+// after logging in or re-entering credentials.
+func GetReauthWindow() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("REAUTH_WINDOW_MINUTES"))
+	if err != nil || minutes <= 0 {
+		minutes = DEFAULT_REAUTH_WINDOW_MINUTES
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// GetImpersonationDuration returns how long a session an admin opened on
+// behalf of another user stays valid.
+func GetImpersonationDuration() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("IMPERSONATION_MINUTES"))
+	if err != nil || minutes <= 0 {
+		minutes = DEFAULT_IMPERSONATION_MINUTES
+	}
+	return time.Duration(minutes) * time.Minute
+}
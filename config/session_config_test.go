@@ -0,0 +1,33 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReauthWindow(t *testing.T) {
+	assert.Equal(t, DEFAULT_REAUTH_WINDOW_MINUTES*time.Minute, GetReauthWindow())
+
+	os.Setenv("REAUTH_WINDOW_MINUTES", "30")
+	assert.Equal(t, 30*time.Minute, GetReauthWindow())
+
+	os.Setenv("REAUTH_WINDOW_MINUTES", "soon")
+	assert.Equal(t, DEFAULT_REAUTH_WINDOW_MINUTES*time.Minute, GetReauthWindow())
+
+	os.Unsetenv("REAUTH_WINDOW_MINUTES")
+}
+
+func TestImpersonationDuration(t *testing.T) {
+	assert.Equal(t, DEFAULT_IMPERSONATION_MINUTES*time.Minute, GetImpersonationDuration())
+
+	os.Setenv("IMPERSONATION_MINUTES", "5")
+	assert.Equal(t, 5*time.Minute, GetImpersonationDuration())
+
+	os.Setenv("IMPERSONATION_MINUTES", "-5")
+	assert.Equal(t, DEFAULT_IMPERSONATION_MINUTES*time.Minute, GetImpersonationDuration())
+
+	os.Unsetenv("IMPERSONATION_MINUTES")
+}
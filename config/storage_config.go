@@ -0,0 +1,65 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+const (
+	STORAGE_BACKEND_FILESYSTEM = "filesystem"
+	STORAGE_BACKEND_S3         = "s3"
+
+	DEFAULT_STORAGE_PATH = "blobs"
+	DEFAULT_S3_REGION    = "us-east-1"
+)
+
+// GetStorageBackend returns where blobs such as profile pictures are kept,
+// on the filesystem unless configured otherwise.
+func GetStorageBackend() string {
+	backend := strings.ToLower(strings.TrimSpace(os.Getenv("STORAGE_BACKEND")))
+	if backend == "" {
+		return STORAGE_BACKEND_FILESYSTEM
+	}
+	return backend
+}
+
+// GetStoragePath returns the directory of the filesystem backend.
+func GetStoragePath() string {
+	path := os.Getenv("STORAGE_PATH")
+	if path == "" {
+		return DEFAULT_STORAGE_PATH
+	}
+	return path
+}
+
+// GetS3Endpoint returns the base URL of the S3-compatible API, e.g.
+// https://s3.amazonaws.com.
+func GetS3Endpoint() string {
+	return os.Getenv("S3_ENDPOINT")
+}
+
+func GetS3Region() string {
+	region := os.Getenv("S3_REGION")
+	if region == "" {
+		return DEFAULT_S3_REGION
+	}
+	return region
+}
+
+func GetS3Bucket() string {
+	return os.Getenv("S3_BUCKET")
+}
+
+func GetS3AccessKeyId() string {
+	return os.Getenv("S3_ACCESS_KEY_ID")
+}
+
+func GetS3SecretAccessKey() string {
+	return os.Getenv("S3_SECRET_ACCESS_KEY")
+}
+
+// GetS3PathStyle tells whether the bucket goes into the path of the URLs
+// rather than into the host name.
+func GetS3PathStyle() bool {
+	return strings.ToLower(os.Getenv("S3_PATH_STYLE")) == "true"
+}
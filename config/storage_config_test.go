@@ -0,0 +1,48 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorageBackend(t *testing.T) {
+	assert.Equal(t, STORAGE_BACKEND_FILESYSTEM, GetStorageBackend())
+
+	os.Setenv("STORAGE_BACKEND", " S3 ")
+	assert.Equal(t, STORAGE_BACKEND_S3, GetStorageBackend())
+
+	os.Unsetenv("STORAGE_BACKEND")
+}
+
+func TestStoragePath(t *testing.T) {
+	assert.Equal(t, DEFAULT_STORAGE_PATH, GetStoragePath())
+
+	os.Setenv("STORAGE_PATH", "/var/lib/userland")
+	assert.Equal(t, "/var/lib/userland", GetStoragePath())
+
+	os.Unsetenv("STORAGE_PATH")
+}
+
+func TestS3Config(t *testing.T) {
+	assert.Equal(t, DEFAULT_S3_REGION, GetS3Region())
+	assert.False(t, GetS3PathStyle())
+
+	os.Setenv("S3_ENDPOINT", "http://localhost:9000")
+	os.Setenv("S3_REGION", "eu-west-1")
+	os.Setenv("S3_BUCKET", "userland")
+	os.Setenv("S3_ACCESS_KEY_ID", "access")
+	os.Setenv("S3_SECRET_ACCESS_KEY", "secret")
+	os.Setenv("S3_PATH_STYLE", "true")
+	assert.Equal(t, "http://localhost:9000", GetS3Endpoint())
+	assert.Equal(t, "eu-west-1", GetS3Region())
+	assert.Equal(t, "userland", GetS3Bucket())
+	assert.Equal(t, "access", GetS3AccessKeyId())
+	assert.Equal(t, "secret", GetS3SecretAccessKey())
+	assert.True(t, GetS3PathStyle())
+
+	for _, name := range []string{"S3_ENDPOINT", "S3_REGION", "S3_BUCKET", "S3_ACCESS_KEY_ID", "S3_SECRET_ACCESS_KEY", "S3_PATH_STYLE"} {
+		os.Unsetenv(name)
+	}
+}
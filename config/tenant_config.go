@@ -0,0 +1,32 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+const (
+	DEFAULT_TENANT_HEADER = "X-Tenant"
+	DEFAULT_TENANT_SLUG   = "default"
+)
+
+// GetTenantHeader returns the request header naming the tenant explicitly,
+// which takes precedence over the host name.
+func GetTenantHeader() string {
+	header := os.Getenv("TENANT_HEADER")
+	if header == "" {
+		return DEFAULT_TENANT_HEADER
+	}
+	return header
+}
+
+// GetDefaultTenant returns the slug of the tenant serving requests whose host
+// name doesn't belong to any tenant. Setting DEFAULT_TENANT to an empty value
+// refuses those requests instead.
+func GetDefaultTenant() string {
+	slug, ok := os.LookupEnv("DEFAULT_TENANT")
+	if !ok {
+		return DEFAULT_TENANT_SLUG
+	}
+	return strings.ToLower(strings.TrimSpace(slug))
+}
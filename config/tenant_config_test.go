@@ -0,0 +1,29 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantHeader(t *testing.T) {
+	assert.Equal(t, DEFAULT_TENANT_HEADER, GetTenantHeader())
+
+	os.Setenv("TENANT_HEADER", "X-Brand")
+	assert.Equal(t, "X-Brand", GetTenantHeader())
+
+	os.Unsetenv("TENANT_HEADER")
+}
+
+func TestDefaultTenant(t *testing.T) {
+	assert.Equal(t, DEFAULT_TENANT_SLUG, GetDefaultTenant())
+
+	os.Setenv("DEFAULT_TENANT", " Brand ")
+	assert.Equal(t, "brand", GetDefaultTenant())
+
+	os.Setenv("DEFAULT_TENANT", "")
+	assert.Equal(t, "", GetDefaultTenant(), "Empty value should turn the fallback off")
+
+	os.Unsetenv("DEFAULT_TENANT")
+}
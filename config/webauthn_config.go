@@ -0,0 +1,17 @@
+package config
+
+import (
+	"os"
+)
+
+func GetWebAuthnRPId() string {
+	return os.Getenv("WEBAUTHN_RP_ID")
+}
+
+func GetWebAuthnRPName() string {
+	return os.Getenv("WEBAUTHN_RP_NAME")
+}
+
+func GetWebAuthnRPOrigin() string {
+	return os.Getenv("WEBAUTHN_RP_ORIGIN")
+}
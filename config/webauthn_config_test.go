@@ -0,0 +1,40 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	webAuthnConfigVars map[string]string
+)
+
+func testWebAuthnConfigInit() {
+	webAuthnConfigVars = map[string]string{
+		"WEBAUTHN_RP_ID":     "localhost",
+		"WEBAUTHN_RP_NAME":   "Userland",
+		"WEBAUTHN_RP_ORIGIN": "http://localhost:3000",
+	}
+
+	for key, val := range webAuthnConfigVars {
+		os.Setenv(key, val)
+	}
+}
+
+func testWebAuthnConfigEnd() {
+	for key := range webAuthnConfigVars {
+		os.Unsetenv(key)
+	}
+}
+
+func TestWebAuthnConfig(t *testing.T) {
+	testWebAuthnConfigInit()
+
+	assert.Equal(t, webAuthnConfigVars["WEBAUTHN_RP_ID"], GetWebAuthnRPId())
+	assert.Equal(t, webAuthnConfigVars["WEBAUTHN_RP_NAME"], GetWebAuthnRPName())
+	assert.Equal(t, webAuthnConfigVars["WEBAUTHN_RP_ORIGIN"], GetWebAuthnRPOrigin())
+
+	testWebAuthnConfigEnd()
+}
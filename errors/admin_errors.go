@@ -0,0 +1,53 @@
+package errors
+
+var (
+	ErrAdminUserNotFound = UserlandError{
+		Code:    ADMIN_USER_NOT_FOUND,
+		Message: ADMIN_USER_NOT_FOUND_MESSAGE,
+	}
+
+	ErrAdminQueryExec = UserlandError{
+		Code:    ADMIN_UNABLE_TO_EXEC_QUERY,
+		Message: ADMIN_GENERAL_MESSAGE,
+	}
+
+	ErrAdminUserFilterInvalid = UserlandError{
+		Code:    ADMIN_USER_FILTER_INVALID,
+		Message: ADMIN_USER_FILTER_INVALID_MESSAGE,
+	}
+
+	ErrAdminUserDataInvalid = UserlandError{
+		Code:    ADMIN_USER_DATA_INVALID,
+		Message: ADMIN_USER_DATA_INVALID_MESSAGE,
+	}
+
+	ErrAdminSendEmail = UserlandError{
+		Code:    ADMIN_UNABLE_TO_SEND_EMAIL,
+		Message: ADMIN_UNABLE_TO_SEND_EMAIL_MESSAGE,
+	}
+
+	ErrAdminCannotDeleteSelf = UserlandError{
+		Code:    ADMIN_CANNOT_DELETE_SELF,
+		Message: ADMIN_CANNOT_DELETE_SELF_MESSAGE,
+	}
+
+	ErrAdminSuspensionInvalid = UserlandError{
+		Code:    ADMIN_SUSPENSION_INVALID,
+		Message: ADMIN_SUSPENSION_INVALID_MESSAGE,
+	}
+
+	ErrAdminCannotSuspendSelf = UserlandError{
+		Code:    ADMIN_CANNOT_SUSPEND_SELF,
+		Message: ADMIN_CANNOT_SUSPEND_SELF_MESSAGE,
+	}
+
+	ErrAdminScimTokenNotFound = UserlandError{
+		Code:    ADMIN_SCIM_TOKEN_NOT_FOUND,
+		Message: ADMIN_SCIM_TOKEN_NOT_FOUND_MESSAGE,
+	}
+
+	ErrAdminAudit = UserlandError{
+		Code:    ADMIN_UNABLE_TO_AUDIT,
+		Message: ADMIN_UNABLE_TO_AUDIT_MESSAGE,
+	}
+)
@@ -110,4 +110,199 @@ var (
 		Code:    TOKEN_USER_ID_DOES_NOT_EXIST,
 		Message: TOKEN_GENERAL_MESSAGE,
 	}
+
+	ErrEmailLoginIncomplete = UserlandError{
+		Code:    EMAIL_LOGIN_INCOMPLETE,
+		Message: EMAIL_LOGIN_INCOMPLETE_MESSAGE,
+	}
+
+	ErrEmailLoginRateLimited = UserlandError{
+		Code:    EMAIL_LOGIN_RATE_LIMITED,
+		Message: EMAIL_LOGIN_RATE_LIMITED_MESSAGE,
+	}
+
+	ErrEmailLoginQueryExec = UserlandError{
+		Code:    EMAIL_LOGIN_UNABLE_TO_EXEC_QUERY,
+		Message: EMAIL_LOGIN_GENERAL_MESSAGE,
+	}
+
+	ErrEmailLoginSendEmail = UserlandError{
+		Code:    EMAIL_LOGIN_UNABLE_TO_SEND_EMAIL,
+		Message: EMAIL_LOGIN_GENERAL_MESSAGE,
+	}
+
+	ErrEmailLoginTokenIncomplete = UserlandError{
+		Code:    EMAIL_LOGIN_TOKEN_INCOMPLETE,
+		Message: EMAIL_LOGIN_TOKEN_INCOMPLETE_MESSAGE,
+	}
+
+	ErrEmailLoginTokenInvalid = UserlandError{
+		Code:    EMAIL_LOGIN_TOKEN_INVALID,
+		Message: EMAIL_LOGIN_TOKEN_INVALID_MESSAGE,
+	}
+
+	ErrWebAuthnQueryExec = UserlandError{
+		Code:    WEBAUTHN_UNABLE_TO_EXEC_QUERY,
+		Message: WEBAUTHN_UNABLE_TO_EXEC_QUERY_MESSAGE,
+	}
+
+	ErrWebAuthnCeremonyNotStarted = UserlandError{
+		Code:    WEBAUTHN_CEREMONY_NOT_STARTED,
+		Message: WEBAUTHN_CEREMONY_NOT_STARTED_MESSAGE,
+	}
+
+	ErrWebAuthnResponseInvalid = UserlandError{
+		Code:    WEBAUTHN_RESPONSE_INVALID,
+		Message: WEBAUTHN_RESPONSE_INVALID_MESSAGE,
+	}
+
+	ErrWebAuthnUserVerificationRequired = UserlandError{
+		Code:    WEBAUTHN_USER_VERIFICATION_REQUIRED,
+		Message: WEBAUTHN_USER_VERIFICATION_REQUIRED_MESSAGE,
+	}
+
+	ErrWebAuthnCredentialNotFound = UserlandError{
+		Code:    WEBAUTHN_CREDENTIAL_NOT_FOUND,
+		Message: WEBAUTHN_CREDENTIAL_NOT_FOUND_MESSAGE,
+	}
+
+	ErrTokenRevoked = UserlandError{
+		Code:    TOKEN_REVOKED,
+		Message: TOKEN_REVOKED_MESSAGE,
+	}
+
+	ErrLoginPasswordResetRequired = UserlandError{
+		Code:    LOGIN_PASSWORD_RESET_REQUIRED,
+		Message: LOGIN_PASSWORD_RESET_REQUIRED_MESSAGE,
+	}
+
+	ErrDeviceReportIncomplete = UserlandError{
+		Code:    DEVICE_REPORT_INCOMPLETE,
+		Message: DEVICE_REPORT_INCOMPLETE_MESSAGE,
+	}
+
+	ErrDeviceReportQueryExec = UserlandError{
+		Code:    DEVICE_REPORT_UNABLE_TO_EXEC_QUERY,
+		Message: DEVICE_REPORT_GENERAL_MESSAGE,
+	}
+
+	ErrReauthRequired = UserlandError{
+		Code:    REAUTH_REQUIRED,
+		Message: REAUTH_REQUIRED_MESSAGE,
+	}
+
+	ErrReauthIncomplete = UserlandError{
+		Code:    REAUTH_INCOMPLETE,
+		Message: REAUTH_INCOMPLETE_MESSAGE,
+	}
+
+	ErrReauthIncorrectCredential = UserlandError{
+		Code:    REAUTH_INCORRECT_CREDENTIAL,
+		Message: REAUTH_INCORRECT_CREDENTIAL_MESSAGE,
+	}
+
+	ErrReauthRateLimited = UserlandError{
+		Code:    REAUTH_RATE_LIMITED,
+		Message: REAUTH_RATE_LIMITED_MESSAGE,
+	}
+
+	ErrAccountSuspended = UserlandError{
+		Code:    ACCOUNT_SUSPENDED,
+		Message: ACCOUNT_SUSPENDED_MESSAGE,
+	}
+
+	ErrAccountBanned = UserlandError{
+		Code:    ACCOUNT_BANNED,
+		Message: ACCOUNT_BANNED_MESSAGE,
+	}
+
+	ErrImpersonationForbidden = UserlandError{
+		Code:    IMPERSONATION_FORBIDDEN,
+		Message: IMPERSONATION_FORBIDDEN_MESSAGE,
+	}
+
+	ErrImpersonationTargetInvalid = UserlandError{
+		Code:    IMPERSONATION_TARGET_INVALID,
+		Message: IMPERSONATION_TARGET_INVALID_MESSAGE,
+	}
+
+	ErrImpersonationAudit = UserlandError{
+		Code:    IMPERSONATION_UNABLE_TO_AUDIT,
+		Message: IMPERSONATION_UNABLE_TO_AUDIT_MESSAGE,
+	}
+
+	ErrRegistrationClosed = UserlandError{
+		Code:    REGISTRATION_CLOSED,
+		Message: REGISTRATION_CLOSED_MESSAGE,
+	}
+
+	ErrRegistrationInvitationRequired = UserlandError{
+		Code:    REGISTRATION_INVITATION_REQUIRED,
+		Message: REGISTRATION_INVITATION_REQUIRED_MESSAGE,
+	}
+
+	ErrRegistrationInvitationInvalid = UserlandError{
+		Code:    REGISTRATION_INVITATION_INVALID,
+		Message: REGISTRATION_INVITATION_INVALID_MESSAGE,
+	}
+
+	ErrRegistrationEmailDomainNotAllowed = UserlandError{
+		Code:    REGISTRATION_EMAIL_DOMAIN_NOT_ALLOWED,
+		Message: REGISTRATION_EMAIL_DOMAIN_NOT_ALLOWED_MESSAGE,
+	}
+
+	ErrRegistrationEmailDomainDisposable = UserlandError{
+		Code:    REGISTRATION_EMAIL_DOMAIN_DISPOSABLE,
+		Message: REGISTRATION_EMAIL_DOMAIN_DISPOSABLE_MESSAGE,
+	}
+
+	ErrTokenTenantMismatch = UserlandError{
+		Code:    TOKEN_TENANT_MISMATCH,
+		Message: TOKEN_TENANT_MISMATCH_MESSAGE,
+	}
+
+	ErrAccountDeactivated = UserlandError{
+		Code:    ACCOUNT_DEACTIVATED,
+		Message: ACCOUNT_DEACTIVATED_MESSAGE,
+	}
+
+	ErrAccountPendingDeletion = UserlandError{
+		Code:    ACCOUNT_PENDING_DELETION,
+		Message: ACCOUNT_PENDING_DELETION_MESSAGE,
+	}
+
+	ErrAccountRestoreIncomplete = UserlandError{
+		Code:    ACCOUNT_RESTORE_INCOMPLETE,
+		Message: ACCOUNT_RESTORE_INCOMPLETE_MESSAGE,
+	}
+
+	ErrAccountRestoreQueryExec = UserlandError{
+		Code:    ACCOUNT_RESTORE_UNABLE_TO_EXEC_QUERY,
+		Message: ACCOUNT_RESTORE_GENERAL_MESSAGE,
+	}
+
+	ErrUsernameInvalid = UserlandError{
+		Code:    USERNAME_INVALID,
+		Message: USERNAME_INVALID_MESSAGE,
+	}
+
+	ErrUsernameReserved = UserlandError{
+		Code:    USERNAME_RESERVED,
+		Message: USERNAME_RESERVED_MESSAGE,
+	}
+
+	ErrUsernameTaken = UserlandError{
+		Code:    USERNAME_TAKEN,
+		Message: USERNAME_TAKEN_MESSAGE,
+	}
+
+	ErrUsernameAvailabilityRateLimited = UserlandError{
+		Code:    USERNAME_AVAILABILITY_RATE_LIMITED,
+		Message: USERNAME_AVAILABILITY_RATE_LIMITED_MESSAGE,
+	}
+
+	ErrUsernameQueryExec = UserlandError{
+		Code:    USERNAME_UNABLE_TO_EXEC_QUERY,
+		Message: USERNAME_UNABLE_TO_EXEC_QUERY_MESSAGE,
+	}
 )
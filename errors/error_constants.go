@@ -62,6 +62,121 @@ const (
 	TOKEN_USER_ID_DOES_NOT_EXIST = 1122
 	TOKEN_GENERAL_MESSAGE        = "invalid token"
 
+	EMAIL_LOGIN_INCOMPLETE         = 1123
+	EMAIL_LOGIN_INCOMPLETE_MESSAGE = "email is required to login via email"
+
+	EMAIL_LOGIN_RATE_LIMITED         = 1124
+	EMAIL_LOGIN_RATE_LIMITED_MESSAGE = "too many email login attempts, please try again later"
+
+	EMAIL_LOGIN_UNABLE_TO_EXEC_QUERY = 1125
+	EMAIL_LOGIN_UNABLE_TO_SEND_EMAIL = 1126
+	EMAIL_LOGIN_GENERAL_MESSAGE      = "unable to send login email"
+
+	EMAIL_LOGIN_TOKEN_INCOMPLETE         = 1127
+	EMAIL_LOGIN_TOKEN_INCOMPLETE_MESSAGE = "login token or email and code are required"
+
+	EMAIL_LOGIN_TOKEN_INVALID         = 1128
+	EMAIL_LOGIN_TOKEN_INVALID_MESSAGE = "login token or code is invalid or has expired"
+
+	WEBAUTHN_UNABLE_TO_EXEC_QUERY         = 1129
+	WEBAUTHN_UNABLE_TO_EXEC_QUERY_MESSAGE = "unable to process passkey"
+
+	WEBAUTHN_CEREMONY_NOT_STARTED         = 1130
+	WEBAUTHN_CEREMONY_NOT_STARTED_MESSAGE = "passkey ceremony hasn't been started or has expired"
+
+	WEBAUTHN_RESPONSE_INVALID         = 1131
+	WEBAUTHN_RESPONSE_INVALID_MESSAGE = "passkey response is invalid"
+
+	WEBAUTHN_USER_VERIFICATION_REQUIRED         = 1132
+	WEBAUTHN_USER_VERIFICATION_REQUIRED_MESSAGE = "passkey must verify the user to login without a password"
+
+	WEBAUTHN_CREDENTIAL_NOT_FOUND         = 1133
+	WEBAUTHN_CREDENTIAL_NOT_FOUND_MESSAGE = "passkey is not registered"
+
+	TOKEN_REVOKED         = 1134
+	TOKEN_REVOKED_MESSAGE = "session has been revoked, please login again"
+
+	LOGIN_PASSWORD_RESET_REQUIRED         = 1135
+	LOGIN_PASSWORD_RESET_REQUIRED_MESSAGE = "password has to be reset before logging in"
+
+	DEVICE_REPORT_INCOMPLETE         = 1136
+	DEVICE_REPORT_INCOMPLETE_MESSAGE = "device report token is required"
+
+	DEVICE_REPORT_UNABLE_TO_EXEC_QUERY = 1137
+	DEVICE_REPORT_GENERAL_MESSAGE      = "unable to report device"
+
+	REAUTH_REQUIRED         = 1138
+	REAUTH_REQUIRED_MESSAGE = "please re-enter your credentials to continue"
+
+	REAUTH_INCOMPLETE         = 1139
+	REAUTH_INCOMPLETE_MESSAGE = "password or passkey assertion is required"
+
+	REAUTH_INCORRECT_CREDENTIAL         = 1140
+	REAUTH_INCORRECT_CREDENTIAL_MESSAGE = "credential is incorrect"
+
+	REAUTH_RATE_LIMITED         = 1141
+	REAUTH_RATE_LIMITED_MESSAGE = "too many re-authentication attempts, try again later"
+
+	ACCOUNT_SUSPENDED         = 1142
+	ACCOUNT_SUSPENDED_MESSAGE = "account is suspended"
+
+	ACCOUNT_BANNED         = 1143
+	ACCOUNT_BANNED_MESSAGE = "account is banned"
+
+	IMPERSONATION_FORBIDDEN         = 1144
+	IMPERSONATION_FORBIDDEN_MESSAGE = "action is not allowed while impersonating a user"
+
+	IMPERSONATION_TARGET_INVALID         = 1145
+	IMPERSONATION_TARGET_INVALID_MESSAGE = "user can't be impersonated"
+
+	IMPERSONATION_UNABLE_TO_AUDIT         = 1146
+	IMPERSONATION_UNABLE_TO_AUDIT_MESSAGE = "unable to record the impersonated request"
+
+	REGISTRATION_CLOSED         = 1147
+	REGISTRATION_CLOSED_MESSAGE = "registration is closed"
+
+	REGISTRATION_INVITATION_REQUIRED         = 1148
+	REGISTRATION_INVITATION_REQUIRED_MESSAGE = "registration requires an invitation code"
+
+	REGISTRATION_INVITATION_INVALID         = 1149
+	REGISTRATION_INVITATION_INVALID_MESSAGE = "invitation code is invalid, expired or already used"
+
+	REGISTRATION_EMAIL_DOMAIN_NOT_ALLOWED         = 1150
+	REGISTRATION_EMAIL_DOMAIN_NOT_ALLOWED_MESSAGE = "registration isn't open to this email domain"
+
+	REGISTRATION_EMAIL_DOMAIN_DISPOSABLE         = 1151
+	REGISTRATION_EMAIL_DOMAIN_DISPOSABLE_MESSAGE = "disposable email addresses can't be used to register"
+
+	TOKEN_TENANT_MISMATCH         = 1152
+	TOKEN_TENANT_MISMATCH_MESSAGE = "token was issued for another tenant"
+
+	ACCOUNT_DEACTIVATED         = 1153
+	ACCOUNT_DEACTIVATED_MESSAGE = "account has been deactivated by your organization"
+
+	ACCOUNT_PENDING_DELETION         = 1154
+	ACCOUNT_PENDING_DELETION_MESSAGE = "account is scheduled for deletion, use the link sent by email to restore it"
+
+	ACCOUNT_RESTORE_INCOMPLETE         = 1155
+	ACCOUNT_RESTORE_INCOMPLETE_MESSAGE = "restore token is empty"
+
+	ACCOUNT_RESTORE_UNABLE_TO_EXEC_QUERY = 1156
+	ACCOUNT_RESTORE_GENERAL_MESSAGE      = "restore link is invalid or has expired"
+
+	USERNAME_INVALID         = 1157
+	USERNAME_INVALID_MESSAGE = "username must be 3 to 30 letters, digits or underscores, and not only digits"
+
+	USERNAME_RESERVED         = 1158
+	USERNAME_RESERVED_MESSAGE = "username is reserved"
+
+	USERNAME_TAKEN         = 1159
+	USERNAME_TAKEN_MESSAGE = "username is already taken"
+
+	USERNAME_AVAILABILITY_RATE_LIMITED         = 1160
+	USERNAME_AVAILABILITY_RATE_LIMITED_MESSAGE = "too many username checks, try again later"
+
+	USERNAME_UNABLE_TO_EXEC_QUERY         = 1161
+	USERNAME_UNABLE_TO_EXEC_QUERY_MESSAGE = "unable to check username"
+
 	// profile errors
 	UPDATE_PROFILE_USER_INFO_INVALID         = 1201
 	UPDATE_PROFILE_USER_INFO_INVALID_MESSAGE = "new user info is invalid"
@@ -91,4 +206,154 @@ const (
 	PICTURE_CANNOT_BE_FETCHED_FROM_FORM = 1210
 	PICTURE_CANNOT_BE_READ              = 1211
 	PICTURE_FORMAT_GENERAL_MESSAGE      = "picture is sent in invalid format"
+
+	PICTURE_TOO_LARGE         = 1220
+	PICTURE_TOO_LARGE_MESSAGE = "picture exceeds the maximum file size"
+
+	PICTURE_TYPE_NOT_ALLOWED         = 1221
+	PICTURE_TYPE_NOT_ALLOWED_MESSAGE = "picture must be a JPEG, PNG, WebP or GIF image"
+
+	PICTURE_DIMENSIONS_TOO_LARGE         = 1222
+	PICTURE_DIMENSIONS_TOO_LARGE_MESSAGE = "picture exceeds the maximum width or height"
+
+	PICTURE_CANNOT_BE_DECODED         = 1223
+	PICTURE_CANNOT_BE_DECODED_MESSAGE = "picture is corrupt or cannot be decoded"
+
+	PICTURE_SIZE_INVALID         = 1224
+	PICTURE_SIZE_INVALID_MESSAGE = "picture size is not available"
+
+	PICTURE_FORMAT_INVALID         = 1225
+	PICTURE_FORMAT_INVALID_MESSAGE = "picture format must be png or svg"
+
+	DELETE_ACCOUNT_UNABLE_TO_EXEC_QUERY = 1212
+	DELETE_ACCOUNT_GENERAL_MESSAGE      = "unable to delete account"
+
+	DATA_EXPORT_IN_PROGRESS         = 1213
+	DATA_EXPORT_IN_PROGRESS_MESSAGE = "a data export is already being prepared"
+
+	DATA_EXPORT_UNABLE_TO_EXEC_QUERY = 1214
+	DATA_EXPORT_GENERAL_MESSAGE      = "unable to export data"
+
+	DATA_EXPORT_TOKEN_EMPTY         = 1215
+	DATA_EXPORT_TOKEN_EMPTY_MESSAGE = "export token is empty"
+
+	DATA_EXPORT_LINK_INVALID         = 1216
+	DATA_EXPORT_LINK_INVALID_MESSAGE = "export link is invalid, expired or already used"
+
+	PICTURE_CANNOT_BE_STORED = 1217
+
+	PICTURE_NOT_FOUND         = 1218
+	PICTURE_NOT_FOUND_MESSAGE = "user doesn't exist"
+
+	PICTURE_CANNOT_BE_LOADED         = 1219
+	PICTURE_CANNOT_BE_LOADED_MESSAGE = "unable to load profile picture"
+
+	PUBLIC_PROFILE_NOT_FOUND         = 1226
+	PUBLIC_PROFILE_NOT_FOUND_MESSAGE = "user doesn't exist"
+
+	PUBLIC_PROFILE_UNABLE_TO_EXEC_QUERY         = 1227
+	PUBLIC_PROFILE_UNABLE_TO_EXEC_QUERY_MESSAGE = "unable to get profile"
+
+	PRIVACY_SETTINGS_INVALID         = 1228
+	PRIVACY_SETTINGS_INVALID_MESSAGE = "visibility must be public, authenticated or private"
+
+	PRIVACY_UNABLE_TO_EXEC_QUERY         = 1229
+	PRIVACY_UNABLE_TO_EXEC_QUERY_MESSAGE = "unable to update privacy settings"
+
+	USER_SEARCH_INVALID         = 1230
+	USER_SEARCH_INVALID_MESSAGE = "one or more search parameter is invalid"
+
+	USER_SEARCH_UNABLE_TO_EXEC_QUERY         = 1231
+	USER_SEARCH_UNABLE_TO_EXEC_QUERY_MESSAGE = "unable to search users"
+
+	PROFILE_VERSION_REQUIRED         = 1232
+	PROFILE_VERSION_REQUIRED_MESSAGE = "If-Match header with the profile ETag is required"
+
+	PROFILE_VERSION_MISMATCH         = 1233
+	PROFILE_VERSION_MISMATCH_MESSAGE = "profile was changed since it was read"
+
+	PROFILE_DIRECTORY_ACCOUNT         = 1234
+	PROFILE_DIRECTORY_ACCOUNT_MESSAGE = "account is managed by the directory, change it there"
+
+	// role errors
+	PERMISSION_DENIED         = 1301
+	PERMISSION_DENIED_MESSAGE = "you don't have permission to perform this action"
+
+	ROLE_UNABLE_TO_EXEC_QUERY = 1302
+	ROLE_GENERAL_MESSAGE      = "unable to process roles"
+
+	ROLE_NOT_FOUND         = 1303
+	ROLE_NOT_FOUND_MESSAGE = "user or role doesn't exist"
+
+	// admin errors
+	ADMIN_USER_NOT_FOUND         = 1401
+	ADMIN_USER_NOT_FOUND_MESSAGE = "user doesn't exist"
+
+	ADMIN_UNABLE_TO_EXEC_QUERY = 1402
+	ADMIN_GENERAL_MESSAGE      = "unable to manage user"
+
+	ADMIN_USER_FILTER_INVALID         = 1403
+	ADMIN_USER_FILTER_INVALID_MESSAGE = "one or more user filter is invalid"
+
+	ADMIN_USER_DATA_INVALID         = 1404
+	ADMIN_USER_DATA_INVALID_MESSAGE = "one or more user data component is invalid"
+
+	ADMIN_UNABLE_TO_SEND_EMAIL         = 1405
+	ADMIN_UNABLE_TO_SEND_EMAIL_MESSAGE = "unable to send email to user"
+
+	ADMIN_CANNOT_DELETE_SELF         = 1406
+	ADMIN_CANNOT_DELETE_SELF_MESSAGE = "admins can't delete their own account here"
+
+	ADMIN_SUSPENSION_INVALID         = 1407
+	ADMIN_SUSPENSION_INVALID_MESSAGE = "suspension needs a reason and an expiry in the future, if any"
+
+	ADMIN_CANNOT_SUSPEND_SELF         = 1408
+	ADMIN_CANNOT_SUSPEND_SELF_MESSAGE = "admins can't suspend their own account"
+
+	ADMIN_SCIM_TOKEN_NOT_FOUND         = 1409
+	ADMIN_SCIM_TOKEN_NOT_FOUND_MESSAGE = "SCIM token doesn't exist"
+
+	ADMIN_UNABLE_TO_AUDIT         = 1410
+	ADMIN_UNABLE_TO_AUDIT_MESSAGE = "unable to record the admin action"
+
+	// organization errors
+	ORG_NOT_FOUND         = 1501
+	ORG_NOT_FOUND_MESSAGE = "organization doesn't exist or you aren't a member of it"
+
+	ORG_UNABLE_TO_EXEC_QUERY = 1502
+	ORG_GENERAL_MESSAGE      = "unable to process organization"
+
+	ORG_DATA_INVALID         = 1503
+	ORG_DATA_INVALID_MESSAGE = "organization data is invalid"
+
+	ORG_ROLE_INSUFFICIENT         = 1504
+	ORG_ROLE_INSUFFICIENT_MESSAGE = "your role in the organization doesn't allow this action"
+
+	ORG_MEMBER_NOT_FOUND         = 1505
+	ORG_MEMBER_NOT_FOUND_MESSAGE = "user isn't a member of the organization"
+
+	ORG_LAST_OWNER         = 1506
+	ORG_LAST_OWNER_MESSAGE = "organization needs at least one owner"
+
+	ORG_INVITATION_NOT_FOUND         = 1507
+	ORG_INVITATION_NOT_FOUND_MESSAGE = "invitation doesn't exist or has expired"
+
+	ORG_INVITATION_INVALID         = 1508
+	ORG_INVITATION_INVALID_MESSAGE = "invitation email or role is invalid"
+
+	ORG_ALREADY_MEMBER         = 1509
+	ORG_ALREADY_MEMBER_MESSAGE = "user is already a member of the organization"
+
+	ORG_UNABLE_TO_SEND_EMAIL         = 1510
+	ORG_UNABLE_TO_SEND_EMAIL_MESSAGE = "unable to send invitation email"
+
+	ORG_UNABLE_TO_SWITCH         = 1511
+	ORG_UNABLE_TO_SWITCH_MESSAGE = "unable to switch the active organization"
+
+	// tenant errors
+	TENANT_NOT_FOUND         = 1601
+	TENANT_NOT_FOUND_MESSAGE = "tenant doesn't exist"
+
+	TENANT_UNABLE_TO_EXEC_QUERY = 1602
+	TENANT_GENERAL_MESSAGE      = "unable to resolve tenant"
 )
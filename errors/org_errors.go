@@ -0,0 +1,58 @@
+package errors
+
+var (
+	ErrOrgNotFound = UserlandError{
+		Code:    ORG_NOT_FOUND,
+		Message: ORG_NOT_FOUND_MESSAGE,
+	}
+
+	ErrOrgQueryExec = UserlandError{
+		Code:    ORG_UNABLE_TO_EXEC_QUERY,
+		Message: ORG_GENERAL_MESSAGE,
+	}
+
+	ErrOrgDataInvalid = UserlandError{
+		Code:    ORG_DATA_INVALID,
+		Message: ORG_DATA_INVALID_MESSAGE,
+	}
+
+	ErrOrgRoleInsufficient = UserlandError{
+		Code:    ORG_ROLE_INSUFFICIENT,
+		Message: ORG_ROLE_INSUFFICIENT_MESSAGE,
+	}
+
+	ErrOrgMemberNotFound = UserlandError{
+		Code:    ORG_MEMBER_NOT_FOUND,
+		Message: ORG_MEMBER_NOT_FOUND_MESSAGE,
+	}
+
+	ErrOrgLastOwner = UserlandError{
+		Code:    ORG_LAST_OWNER,
+		Message: ORG_LAST_OWNER_MESSAGE,
+	}
+
+	ErrOrgInvitationNotFound = UserlandError{
+		Code:    ORG_INVITATION_NOT_FOUND,
+		Message: ORG_INVITATION_NOT_FOUND_MESSAGE,
+	}
+
+	ErrOrgInvitationInvalid = UserlandError{
+		Code:    ORG_INVITATION_INVALID,
+		Message: ORG_INVITATION_INVALID_MESSAGE,
+	}
+
+	ErrOrgAlreadyMember = UserlandError{
+		Code:    ORG_ALREADY_MEMBER,
+		Message: ORG_ALREADY_MEMBER_MESSAGE,
+	}
+
+	ErrOrgSendEmail = UserlandError{
+		Code:    ORG_UNABLE_TO_SEND_EMAIL,
+		Message: ORG_UNABLE_TO_SEND_EMAIL_MESSAGE,
+	}
+
+	ErrOrgSwitch = UserlandError{
+		Code:    ORG_UNABLE_TO_SWITCH,
+		Message: ORG_UNABLE_TO_SWITCH_MESSAGE,
+	}
+)
@@ -41,6 +41,31 @@ var (
 		Message: INCORRECT_PASSWORD_GENERAL_MESSAGE,
 	}
 
+	ErrDeleteAccountQueryExec = UserlandError{
+		Code:    DELETE_ACCOUNT_UNABLE_TO_EXEC_QUERY,
+		Message: DELETE_ACCOUNT_GENERAL_MESSAGE,
+	}
+
+	ErrDataExportInProgress = UserlandError{
+		Code:    DATA_EXPORT_IN_PROGRESS,
+		Message: DATA_EXPORT_IN_PROGRESS_MESSAGE,
+	}
+
+	ErrDataExportQueryExec = UserlandError{
+		Code:    DATA_EXPORT_UNABLE_TO_EXEC_QUERY,
+		Message: DATA_EXPORT_GENERAL_MESSAGE,
+	}
+
+	ErrDataExportTokenEmpty = UserlandError{
+		Code:    DATA_EXPORT_TOKEN_EMPTY,
+		Message: DATA_EXPORT_TOKEN_EMPTY_MESSAGE,
+	}
+
+	ErrDataExportLinkInvalid = UserlandError{
+		Code:    DATA_EXPORT_LINK_INVALID,
+		Message: DATA_EXPORT_LINK_INVALID_MESSAGE,
+	}
+
 	ErrUpdatePictureQueryExec = UserlandError{
 		Code:    PICTURE_UNABLE_TO_EXEC_QUERY,
 		Message: PICTURE_GENERAL_MESSAGE,
@@ -55,4 +80,94 @@ var (
 		Code:    PICTURE_CANNOT_BE_READ,
 		Message: PICTURE_FORMAT_GENERAL_MESSAGE,
 	}
+
+	ErrPictureTooLarge = UserlandError{
+		Code:    PICTURE_TOO_LARGE,
+		Message: PICTURE_TOO_LARGE_MESSAGE,
+	}
+
+	ErrPictureTypeNotAllowed = UserlandError{
+		Code:    PICTURE_TYPE_NOT_ALLOWED,
+		Message: PICTURE_TYPE_NOT_ALLOWED_MESSAGE,
+	}
+
+	ErrPictureDimensionsTooLarge = UserlandError{
+		Code:    PICTURE_DIMENSIONS_TOO_LARGE,
+		Message: PICTURE_DIMENSIONS_TOO_LARGE_MESSAGE,
+	}
+
+	ErrPictureCantBeDecoded = UserlandError{
+		Code:    PICTURE_CANNOT_BE_DECODED,
+		Message: PICTURE_CANNOT_BE_DECODED_MESSAGE,
+	}
+
+	ErrUpdatePictureCantBeStored = UserlandError{
+		Code:    PICTURE_CANNOT_BE_STORED,
+		Message: PICTURE_GENERAL_MESSAGE,
+	}
+
+	ErrPictureNotFound = UserlandError{
+		Code:    PICTURE_NOT_FOUND,
+		Message: PICTURE_NOT_FOUND_MESSAGE,
+	}
+
+	ErrPictureSizeInvalid = UserlandError{
+		Code:    PICTURE_SIZE_INVALID,
+		Message: PICTURE_SIZE_INVALID_MESSAGE,
+	}
+
+	ErrPictureFormatInvalid = UserlandError{
+		Code:    PICTURE_FORMAT_INVALID,
+		Message: PICTURE_FORMAT_INVALID_MESSAGE,
+	}
+
+	ErrPictureCantBeLoaded = UserlandError{
+		Code:    PICTURE_CANNOT_BE_LOADED,
+		Message: PICTURE_CANNOT_BE_LOADED_MESSAGE,
+	}
+
+	ErrPublicProfileNotFound = UserlandError{
+		Code:    PUBLIC_PROFILE_NOT_FOUND,
+		Message: PUBLIC_PROFILE_NOT_FOUND_MESSAGE,
+	}
+
+	ErrPublicProfileQueryExec = UserlandError{
+		Code:    PUBLIC_PROFILE_UNABLE_TO_EXEC_QUERY,
+		Message: PUBLIC_PROFILE_UNABLE_TO_EXEC_QUERY_MESSAGE,
+	}
+
+	ErrPrivacySettingsInvalid = UserlandError{
+		Code:    PRIVACY_SETTINGS_INVALID,
+		Message: PRIVACY_SETTINGS_INVALID_MESSAGE,
+	}
+
+	ErrPrivacyQueryExec = UserlandError{
+		Code:    PRIVACY_UNABLE_TO_EXEC_QUERY,
+		Message: PRIVACY_UNABLE_TO_EXEC_QUERY_MESSAGE,
+	}
+
+	ErrUserSearchInvalid = UserlandError{
+		Code:    USER_SEARCH_INVALID,
+		Message: USER_SEARCH_INVALID_MESSAGE,
+	}
+
+	ErrUserSearchQueryExec = UserlandError{
+		Code:    USER_SEARCH_UNABLE_TO_EXEC_QUERY,
+		Message: USER_SEARCH_UNABLE_TO_EXEC_QUERY_MESSAGE,
+	}
+
+	ErrProfileVersionRequired = UserlandError{
+		Code:    PROFILE_VERSION_REQUIRED,
+		Message: PROFILE_VERSION_REQUIRED_MESSAGE,
+	}
+
+	ErrProfileVersionMismatch = UserlandError{
+		Code:    PROFILE_VERSION_MISMATCH,
+		Message: PROFILE_VERSION_MISMATCH_MESSAGE,
+	}
+
+	ErrProfileDirectoryAccount = UserlandError{
+		Code:    PROFILE_DIRECTORY_ACCOUNT,
+		Message: PROFILE_DIRECTORY_ACCOUNT_MESSAGE,
+	}
 )
@@ -0,0 +1,18 @@
+package errors
+
+var (
+	ErrPermissionDenied = UserlandError{
+		Code:    PERMISSION_DENIED,
+		Message: PERMISSION_DENIED_MESSAGE,
+	}
+
+	ErrRoleQueryExec = UserlandError{
+		Code:    ROLE_UNABLE_TO_EXEC_QUERY,
+		Message: ROLE_GENERAL_MESSAGE,
+	}
+
+	ErrRoleNotFound = UserlandError{
+		Code:    ROLE_NOT_FOUND,
+		Message: ROLE_NOT_FOUND_MESSAGE,
+	}
+)
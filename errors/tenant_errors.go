@@ -0,0 +1,13 @@
+package errors
+
+var (
+	ErrTenantNotFound = UserlandError{
+		Code:    TENANT_NOT_FOUND,
+		Message: TENANT_NOT_FOUND_MESSAGE,
+	}
+
+	ErrTenantQueryExec = UserlandError{
+		Code:    TENANT_UNABLE_TO_EXEC_QUERY,
+		Message: TENANT_GENERAL_MESSAGE,
+	}
+)
@@ -0,0 +1,193 @@
+package ldap
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+const (
+	CLASS_UNIVERSAL   = 0x00
+	CLASS_APPLICATION = 0x40
+	CLASS_CONTEXT     = 0x80
+
+	TAG_BOOLEAN      = 0x01
+	TAG_INTEGER      = 0x02
+	TAG_OCTET_STRING = 0x04
+	TAG_ENUMERATED   = 0x0a
+	TAG_SEQUENCE     = 0x10
+	TAG_SET          = 0x11
+
+	constructedBit = 0x20
+	classMask      = 0xc0
+	tagMask        = 0x1f
+
+	// MAX_PACKET_LENGTH bounds what a peer can make us allocate.
+	MAX_PACKET_LENGTH = 1 << 20
+)
+
+var (
+	errPacketMalformed = errors.New("BER packet is malformed")
+	errPacketTooLong   = errors.New("BER packet is too long")
+)
+
+// Packet is a BER element, the encoding of LDAP messages. Primitive elements
+// carry their content in Value and constructed ones in Children. Only the
+// low tag numbers LDAP uses are supported.
+type Packet struct {
+	Class       byte
+	Constructed bool
+	Tag         byte
+	Value       []byte
+	Children    []*Packet
+}
+
+func NewSequence(class byte, tag byte, children ...*Packet) *Packet {
+	return &Packet{Class: class, Constructed: true, Tag: tag, Children: children}
+}
+
+func NewOctetString(class byte, tag byte, value string) *Packet {
+	return &Packet{Class: class, Tag: tag, Value: []byte(value)}
+}
+
+func NewInteger(class byte, tag byte, value int64) *Packet {
+	encoded := []byte{}
+	for {
+		encoded = append([]byte{byte(value)}, encoded...)
+		value >>= 8
+		if (value == 0 && encoded[0]&0x80 == 0) || (value == -1 && encoded[0]&0x80 != 0) {
+			break
+		}
+	}
+	return &Packet{Class: class, Tag: tag, Value: encoded}
+}
+
+func NewBoolean(class byte, tag byte, value bool) *Packet {
+	if value {
+		return &Packet{Class: class, Tag: tag, Value: []byte{0xff}}
+	}
+	return &Packet{Class: class, Tag: tag, Value: []byte{0x00}}
+}
+
+// Is tells whether the packet has the given class and tag.
+func (p *Packet) Is(class byte, tag byte) bool {
+	return p.Class == class && p.Tag == tag
+}
+
+func (p *Packet) Int() int64 {
+	var value int64
+	for i, b := range p.Value {
+		if i == 0 && b&0x80 != 0 {
+			value = -1
+		}
+		value = value<<8 | int64(b)
+	}
+	return value
+}
+
+func (p *Packet) String() string {
+	return string(p.Value)
+}
+
+func (p *Packet) Bool() bool {
+	return len(p.Value) > 0 && p.Value[0] != 0
+}
+
+// Child returns the i-th child, or an empty packet when there isn't one, so
+// that reading a malformed message doesn't panic.
+func (p *Packet) Child(i int) *Packet {
+	if i < 0 || i >= len(p.Children) {
+		return &Packet{}
+	}
+	return p.Children[i]
+}
+
+func (p *Packet) Bytes() []byte {
+	content := p.Value
+	if p.Constructed {
+		var buffer bytes.Buffer
+		for _, child := range p.Children {
+			buffer.Write(child.Bytes())
+		}
+		content = buffer.Bytes()
+	}
+
+	identifier := p.Class | p.Tag
+	if p.Constructed {
+		identifier |= constructedBit
+	}
+	encoded := append([]byte{identifier}, encodeLength(len(content))...)
+	return append(encoded, content...)
+}
+
+func encodeLength(length int) []byte {
+	if length < 0x80 {
+		return []byte{byte(length)}
+	}
+	encoded := []byte{}
+	for ; length > 0; length >>= 8 {
+		encoded = append([]byte{byte(length)}, encoded...)
+	}
+	return append([]byte{0x80 | byte(len(encoded))}, encoded...)
+}
+
+// ReadPacket reads a single element from the reader.
+func ReadPacket(r io.Reader) (*Packet, error) {
+	header := make([]byte, 2)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return nil, err
+	}
+
+	length := int(header[1])
+	if length&0x80 != 0 {
+		size := length &^ 0x80
+		if size == 0 || size > 4 {
+			return nil, errPacketMalformed
+		}
+		encoded := make([]byte, size)
+		_, err = io.ReadFull(r, encoded)
+		if err != nil {
+			return nil, err
+		}
+		length = 0
+		for _, b := range encoded {
+			length = length<<8 | int(b)
+		}
+	}
+	if length > MAX_PACKET_LENGTH {
+		return nil, errPacketTooLong
+	}
+
+	content := make([]byte, length)
+	_, err = io.ReadFull(r, content)
+	if err != nil {
+		return nil, err
+	}
+	return newPacket(header[0], content)
+}
+
+func newPacket(identifier byte, content []byte) (*Packet, error) {
+	if identifier&tagMask == tagMask {
+		return nil, errPacketMalformed
+	}
+	packet := &Packet{
+		Class:       identifier & classMask,
+		Constructed: identifier&constructedBit != 0,
+		Tag:         identifier & tagMask,
+	}
+	if !packet.Constructed {
+		packet.Value = content
+		return packet, nil
+	}
+
+	reader := bytes.NewReader(content)
+	for reader.Len() > 0 {
+		child, err := ReadPacket(reader)
+		if err != nil {
+			return nil, errPacketMalformed
+		}
+		packet.Children = append(packet.Children, child)
+	}
+	return packet, nil
+}
@@ -0,0 +1,61 @@
+package ldap
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPacketRoundTrip(t *testing.T) {
+	long := strings.Repeat("a", 300)
+	packet := NewSequence(CLASS_UNIVERSAL, TAG_SEQUENCE,
+		NewInteger(CLASS_UNIVERSAL, TAG_INTEGER, 1),
+		NewSequence(CLASS_APPLICATION, APPLICATION_BIND_REQUEST,
+			NewInteger(CLASS_UNIVERSAL, TAG_INTEGER, PROTOCOL_VERSION),
+			NewOctetString(CLASS_UNIVERSAL, TAG_OCTET_STRING, long),
+			NewOctetString(CLASS_CONTEXT, AUTHENTICATION_SIMPLE, "secret"),
+		),
+	)
+
+	encoded := packet.Bytes()
+	assert.Equal(t, []byte{0x30, 0x82, 0x01, 0x42}, encoded[:4], "Long content should use the long length form")
+
+	decoded, err := ReadPacket(bytes.NewReader(encoded))
+	require.Nil(t, err)
+	assert.Equal(t, int64(1), decoded.Child(0).Int())
+	bind := decoded.Child(1)
+	assert.True(t, bind.Is(CLASS_APPLICATION, APPLICATION_BIND_REQUEST))
+	assert.True(t, bind.Constructed)
+	assert.Equal(t, long, bind.Child(1).String())
+	assert.Equal(t, "secret", bind.Child(2).String())
+	assert.Equal(t, "", bind.Child(5).String(), "Missing child should read as empty")
+}
+
+func TestIntegerEncoding(t *testing.T) {
+	for value, encoded := range map[int64][]byte{
+		0:    {0x00},
+		127:  {0x7f},
+		128:  {0x00, 0x80},
+		256:  {0x01, 0x00},
+		-1:   {0xff},
+		-129: {0xff, 0x7f},
+	} {
+		packet := NewInteger(CLASS_UNIVERSAL, TAG_INTEGER, value)
+		assert.Equal(t, encoded, packet.Value, value)
+		assert.Equal(t, value, packet.Int())
+	}
+}
+
+func TestReadPacketMalformed(t *testing.T) {
+	_, err := ReadPacket(bytes.NewReader([]byte{0x04, 0x05, 'a'}))
+	assert.NotNil(t, err, "Content shorter than its declared length should fail")
+
+	_, err = ReadPacket(bytes.NewReader([]byte{0x04, 0x84, 0x7f, 0xff, 0xff, 0xff}))
+	assert.Equal(t, errPacketTooLong, err)
+
+	_, err = ReadPacket(bytes.NewReader([]byte{0x30, 0x02, 0x04, 0x05}))
+	assert.Equal(t, errPacketMalformed, err, "Child overrunning its parent should fail")
+}
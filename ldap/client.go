@@ -0,0 +1,245 @@
+// Package ldap is a minimal LDAPv3 client, covering what's needed to check a
+// password against a directory: simple binds and searches.
+package ldap
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	PROTOCOL_VERSION = 3
+
+	APPLICATION_BIND_REQUEST     = 0
+	APPLICATION_BIND_RESPONSE    = 1
+	APPLICATION_UNBIND_REQUEST   = 2
+	APPLICATION_SEARCH_REQUEST   = 3
+	APPLICATION_SEARCH_ENTRY     = 4
+	APPLICATION_SEARCH_DONE      = 5
+	APPLICATION_SEARCH_REFERENCE = 19
+
+	AUTHENTICATION_SIMPLE = 0
+
+	SCOPE_WHOLE_SUBTREE = 2
+	DEREF_NEVER         = 0
+
+	RESULT_SUCCESS             = 0
+	RESULT_INVALID_CREDENTIALS = 49
+
+	DEFAULT_PORT     = "389"
+	DEFAULT_TLS_PORT = "636"
+)
+
+var (
+	errUnsupportedScheme = errors.New("LDAP URL has to use the ldap or ldaps scheme")
+	errUnexpectedMessage = errors.New("LDAP server sent an unexpected message")
+)
+
+// Error is a result other than success returned by the server.
+type Error struct {
+	ResultCode int
+	Message    string
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("LDAP result code %d: %s", err.ResultCode, err.Message)
+}
+
+// IsInvalidCredentials tells whether the bind failed because of the DN or
+// the password, rather than because of the directory.
+func IsInvalidCredentials(err error) bool {
+	ldapErr, ok := err.(*Error)
+	return ok && ldapErr.ResultCode == RESULT_INVALID_CREDENTIALS
+}
+
+type Entry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+// Attribute returns the first value of the attribute, whose name is matched
+// regardless of case as in LDAP.
+func (entry Entry) Attribute(name string) string {
+	for attribute, values := range entry.Attributes {
+		if strings.EqualFold(attribute, name) && len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// Conn is a connection to a directory. Requests are sent one at a time, each
+// one having to complete within the timeout.
+type Conn struct {
+	conn      net.Conn
+	timeout   time.Duration
+	messageId int64
+}
+
+// Dial connects to the directory at the URL, over TLS for ldaps:// URLs.
+func Dial(rawURL string, timeout time.Duration) (*Conn, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	host := parsed.Hostname()
+	var conn net.Conn
+	switch parsed.Scheme {
+	case "ldap":
+		conn, err = dialer.Dial("tcp", net.JoinHostPort(host, portOrDefault(parsed, DEFAULT_PORT)))
+	case "ldaps":
+		conn, err = tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(host, portOrDefault(parsed, DEFAULT_TLS_PORT)), &tls.Config{ServerName: host})
+	default:
+		return nil, errUnsupportedScheme
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{conn: conn, timeout: timeout}, nil
+}
+
+func portOrDefault(parsed *url.URL, port string) string {
+	if parsed.Port() != "" {
+		return parsed.Port()
+	}
+	return port
+}
+
+// Bind authenticates the connection as the DN with the password. An empty
+// password would make an unauthenticated bind, which servers accept for any
+// DN, so it's refused here as invalid credentials.
+func (c *Conn) Bind(dn string, password string) error {
+	if password == "" && dn != "" {
+		return &Error{ResultCode: RESULT_INVALID_CREDENTIALS, Message: "empty password"}
+	}
+
+	request := NewSequence(CLASS_APPLICATION, APPLICATION_BIND_REQUEST,
+		NewInteger(CLASS_UNIVERSAL, TAG_INTEGER, PROTOCOL_VERSION),
+		NewOctetString(CLASS_UNIVERSAL, TAG_OCTET_STRING, dn),
+		NewOctetString(CLASS_CONTEXT, AUTHENTICATION_SIMPLE, password),
+	)
+	messageId, err := c.send(request)
+	if err != nil {
+		return err
+	}
+
+	response, err := c.receive(messageId)
+	if err != nil {
+		return err
+	}
+	if !response.Is(CLASS_APPLICATION, APPLICATION_BIND_RESPONSE) {
+		return errUnexpectedMessage
+	}
+	return resultError(response)
+}
+
+// Search returns the entries under the base DN matching the filter, with the
+// given attributes, or all of them when none are given.
+func (c *Conn) Search(baseDN string, filter string, attributes []string) ([]Entry, error) {
+	compiledFilter, err := CompileFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	attributeList := NewSequence(CLASS_UNIVERSAL, TAG_SEQUENCE)
+	for _, attribute := range attributes {
+		attributeList.Children = append(attributeList.Children, NewOctetString(CLASS_UNIVERSAL, TAG_OCTET_STRING, attribute))
+	}
+	request := NewSequence(CLASS_APPLICATION, APPLICATION_SEARCH_REQUEST,
+		NewOctetString(CLASS_UNIVERSAL, TAG_OCTET_STRING, baseDN),
+		NewInteger(CLASS_UNIVERSAL, TAG_ENUMERATED, SCOPE_WHOLE_SUBTREE),
+		NewInteger(CLASS_UNIVERSAL, TAG_ENUMERATED, DEREF_NEVER),
+		NewInteger(CLASS_UNIVERSAL, TAG_INTEGER, 0),
+		NewInteger(CLASS_UNIVERSAL, TAG_INTEGER, int64(c.timeout/time.Second)),
+		NewBoolean(CLASS_UNIVERSAL, TAG_BOOLEAN, false),
+		compiledFilter,
+		attributeList,
+	)
+	messageId, err := c.send(request)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []Entry{}
+	for {
+		response, err := c.receive(messageId)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case response.Is(CLASS_APPLICATION, APPLICATION_SEARCH_ENTRY):
+			entries = append(entries, readEntry(response))
+		case response.Is(CLASS_APPLICATION, APPLICATION_SEARCH_REFERENCE):
+			// Referrals to other servers aren't followed.
+		case response.Is(CLASS_APPLICATION, APPLICATION_SEARCH_DONE):
+			return entries, resultError(response)
+		default:
+			return nil, errUnexpectedMessage
+		}
+	}
+}
+
+// Close unbinds and closes the connection.
+func (c *Conn) Close() error {
+	c.send(&Packet{Class: CLASS_APPLICATION, Tag: APPLICATION_UNBIND_REQUEST})
+	return c.conn.Close()
+}
+
+func (c *Conn) send(operation *Packet) (int64, error) {
+	c.messageId++
+	message := NewSequence(CLASS_UNIVERSAL, TAG_SEQUENCE,
+		NewInteger(CLASS_UNIVERSAL, TAG_INTEGER, c.messageId),
+		operation,
+	)
+
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	_, err := c.conn.Write(message.Bytes())
+	return c.messageId, err
+}
+
+// receive reads the next message answering the request, returning its
+// operation.
+func (c *Conn) receive(messageId int64) (*Packet, error) {
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	for {
+		message, err := ReadPacket(c.conn)
+		if err != nil {
+			return nil, err
+		}
+		if !message.Is(CLASS_UNIVERSAL, TAG_SEQUENCE) || len(message.Children) < 2 {
+			return nil, errUnexpectedMessage
+		}
+		// Unsolicited notifications have the id 0 and are skipped.
+		if message.Child(0).Int() == messageId {
+			return message.Child(1), nil
+		}
+	}
+}
+
+func resultError(response *Packet) error {
+	resultCode := int(response.Child(0).Int())
+	if resultCode == RESULT_SUCCESS {
+		return nil
+	}
+	return &Error{ResultCode: resultCode, Message: response.Child(2).String()}
+}
+
+func readEntry(response *Packet) Entry {
+	entry := Entry{DN: response.Child(0).String(), Attributes: map[string][]string{}}
+	for _, attribute := range response.Child(1).Children {
+		values := []string{}
+		for _, value := range attribute.Child(1).Children {
+			values = append(values, value.String())
+		}
+		entry.Attributes[attribute.Child(0).String()] = values
+	}
+	return entry
+}
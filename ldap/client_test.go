@@ -0,0 +1,59 @@
+package ldap_test
+
+import (
+	"testing"
+	"time"
+	"userland/ldap"
+	"userland/ldap/ldaptest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	TIMEOUT = 5 * time.Second
+)
+
+func TestBindAndSearch(t *testing.T) {
+	server := ldaptest.NewServer(
+		ldaptest.Entry{
+			DN:         "uid=jane,ou=people,dc=example,dc=com",
+			Password:   "janepassword",
+			Attributes: map[string][]string{"uid": {"jane"}, "cn": {"Jane Doe"}, "mail": {"jane@example.com"}},
+		},
+		ldaptest.Entry{
+			DN:         "uid=john,ou=people,dc=example,dc=com",
+			Attributes: map[string][]string{"uid": {"john"}, "cn": {"John Doe"}},
+		},
+	)
+	defer server.Close()
+
+	conn, err := ldap.Dial(server.URL, TIMEOUT)
+	require.Nil(t, err)
+	defer conn.Close()
+
+	require.Nil(t, conn.Bind("", ""), "Anonymous bind should be accepted")
+
+	entries, err := conn.Search("dc=example,dc=com", "(&(uid=*)(mail=JANE@example.com))", []string{"cn"})
+	require.Nil(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "uid=jane,ou=people,dc=example,dc=com", entries[0].DN)
+	assert.Equal(t, "Jane Doe", entries[0].Attribute("CN"))
+	assert.Equal(t, "", entries[0].Attribute("mail"), "Only the requested attributes should be returned")
+
+	entries, err = conn.Search("dc=example,dc=com", "(uid=*)", nil)
+	require.Nil(t, err)
+	assert.Len(t, entries, 2)
+
+	err = conn.Bind(entries[0].DN, "wrong")
+	assert.True(t, ldap.IsInvalidCredentials(err))
+	err = conn.Bind(entries[0].DN, "")
+	assert.True(t, ldap.IsInvalidCredentials(err), "Empty password shouldn't make an unauthenticated bind")
+	assert.Nil(t, conn.Bind(entries[0].DN, "janepassword"))
+	assert.Equal(t, []string{"uid=jane,ou=people,dc=example,dc=com"}, server.Binds())
+}
+
+func TestDialUnsupportedScheme(t *testing.T) {
+	_, err := ldap.Dial("http://localhost", TIMEOUT)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,130 @@
+package ldap
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	FILTER_AND      = 0
+	FILTER_OR       = 1
+	FILTER_NOT      = 2
+	FILTER_EQUALITY = 3
+	FILTER_PRESENT  = 7
+)
+
+var (
+	errFilterInvalid = errors.New("LDAP filter is invalid or not supported")
+)
+
+// EscapeFilter escapes a value to be put into a filter, as in RFC 4515.
+func EscapeFilter(value string) string {
+	var builder strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '\\', '*', '(', ')', 0:
+			fmt.Fprintf(&builder, "\\%02x", c)
+		default:
+			builder.WriteByte(c)
+		}
+	}
+	return builder.String()
+}
+
+// CompileFilter encodes the string representation of a filter. Conjunctions,
+// disjunctions, negations, equality and presence are supported, which covers
+// looking up a user; substring and ordering matches aren't.
+func CompileFilter(filter string) (*Packet, error) {
+	packet, rest, err := compileFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	if rest != "" {
+		return nil, errFilterInvalid
+	}
+	return packet, nil
+}
+
+func compileFilter(filter string) (*Packet, string, error) {
+	if len(filter) < 2 || filter[0] != '(' {
+		return nil, "", errFilterInvalid
+	}
+
+	switch filter[1] {
+	case '&', '|':
+		tag := byte(FILTER_AND)
+		if filter[1] == '|' {
+			tag = FILTER_OR
+		}
+		packet := NewSequence(CLASS_CONTEXT, tag)
+		rest := filter[2:]
+		for !strings.HasPrefix(rest, ")") {
+			child, remaining, err := compileFilter(rest)
+			if err != nil {
+				return nil, "", err
+			}
+			packet.Children = append(packet.Children, child)
+			rest = remaining
+		}
+		if len(packet.Children) == 0 {
+			return nil, "", errFilterInvalid
+		}
+		return packet, rest[1:], nil
+	case '!':
+		child, rest, err := compileFilter(filter[2:])
+		if err != nil || !strings.HasPrefix(rest, ")") {
+			return nil, "", errFilterInvalid
+		}
+		return NewSequence(CLASS_CONTEXT, FILTER_NOT, child), rest[1:], nil
+	}
+
+	end := strings.IndexByte(filter, ')')
+	if end < 0 {
+		return nil, "", errFilterInvalid
+	}
+	item := filter[1:end]
+	separator := strings.IndexByte(item, '=')
+	if separator < 1 || strings.ContainsAny(item[:separator], "~<>:") {
+		return nil, "", errFilterInvalid
+	}
+
+	attribute, value := item[:separator], item[separator+1:]
+	if value == "*" {
+		return NewOctetString(CLASS_CONTEXT, FILTER_PRESENT, attribute), filter[end+1:], nil
+	}
+	if strings.Contains(value, "*") {
+		return nil, "", errFilterInvalid
+	}
+
+	unescaped, err := unescapeFilter(value)
+	if err != nil {
+		return nil, "", err
+	}
+	packet := NewSequence(CLASS_CONTEXT, FILTER_EQUALITY,
+		NewOctetString(CLASS_UNIVERSAL, TAG_OCTET_STRING, attribute),
+		NewOctetString(CLASS_UNIVERSAL, TAG_OCTET_STRING, unescaped),
+	)
+	return packet, filter[end+1:], nil
+}
+
+func unescapeFilter(value string) (string, error) {
+	var builder strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			builder.WriteByte(value[i])
+			continue
+		}
+		if i+3 > len(value) {
+			return "", errFilterInvalid
+		}
+		decoded, err := hex.DecodeString(value[i+1 : i+3])
+		if err != nil {
+			return "", errFilterInvalid
+		}
+		builder.Write(decoded)
+		i += 2
+	}
+	return builder.String(), nil
+}
@@ -0,0 +1,38 @@
+package ldap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEscapeFilter(t *testing.T) {
+	assert.Equal(t, `\2a\29\28\5c`, EscapeFilter(`*)(\`))
+	assert.Equal(t, "jane@example.com", EscapeFilter("jane@example.com"))
+}
+
+func TestCompileFilter(t *testing.T) {
+	filter, err := CompileFilter(`(&(objectClass=person)(|(mail=jane\2a@example.com)(uid=*))(!(disabled=TRUE)))`)
+	require.Nil(t, err)
+	assert.Equal(t, byte(FILTER_AND), filter.Tag)
+	require.Len(t, filter.Children, 3)
+
+	equality := filter.Child(0)
+	assert.Equal(t, byte(FILTER_EQUALITY), equality.Tag)
+	assert.Equal(t, "objectClass", equality.Child(0).String())
+	assert.Equal(t, "person", equality.Child(1).String())
+
+	or := filter.Child(1)
+	assert.Equal(t, byte(FILTER_OR), or.Tag)
+	assert.Equal(t, "jane*@example.com", or.Child(0).Child(1).String(), "Escaped values should be decoded")
+	assert.Equal(t, byte(FILTER_PRESENT), or.Child(1).Tag)
+	assert.Equal(t, "uid", or.Child(1).String())
+
+	assert.Equal(t, byte(FILTER_NOT), filter.Child(2).Tag)
+
+	for _, invalid := range []string{"", "mail=jane", "(mail=ja*ne)", "(mail>=a)", "(&)", "(mail=a)(uid=b)", `(mail=\4)`, "(mail=a"} {
+		_, err = CompileFilter(invalid)
+		assert.Equal(t, errFilterInvalid, err, invalid)
+	}
+}
@@ -0,0 +1,199 @@
+// Package ldaptest provides an in-process directory server for exercising
+// LDAP authentication in tests.
+package ldaptest
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"userland/ldap"
+)
+
+// Entry is a directory entry. Entries with a password can be bound to.
+type Entry struct {
+	DN         string
+	Password   string
+	Attributes map[string][]string
+}
+
+// Server answers simple binds and searches over its entries. Anonymous binds
+// are accepted.
+type Server struct {
+	URL     string
+	Entries []Entry
+
+	listener net.Listener
+	mutex    sync.Mutex
+	binds    []string
+}
+
+// NewServer starts a server on a local port, to be stopped with Close.
+func NewServer(entries ...Entry) *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+
+	server := &Server{
+		URL:      "ldap://" + listener.Addr().String(),
+		Entries:  entries,
+		listener: listener,
+	}
+	go server.serve()
+	return server
+}
+
+func (s *Server) Close() {
+	s.listener.Close()
+}
+
+// Binds returns the DNs of the successful binds so far.
+func (s *Server) Binds() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string{}, s.binds...)
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		message, err := ldap.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		messageId := message.Child(0).Int()
+		operation := message.Child(1)
+
+		switch {
+		case operation.Is(ldap.CLASS_APPLICATION, ldap.APPLICATION_BIND_REQUEST):
+			resultCode := s.bind(operation.Child(1).String(), operation.Child(2).String())
+			reply(conn, messageId, result(ldap.APPLICATION_BIND_RESPONSE, resultCode))
+		case operation.Is(ldap.CLASS_APPLICATION, ldap.APPLICATION_SEARCH_REQUEST):
+			for _, entry := range s.search(operation) {
+				reply(conn, messageId, entry)
+			}
+			reply(conn, messageId, result(ldap.APPLICATION_SEARCH_DONE, ldap.RESULT_SUCCESS))
+		default:
+			return
+		}
+	}
+}
+
+func (s *Server) bind(dn string, password string) int {
+	if dn == "" && password == "" {
+		return ldap.RESULT_SUCCESS
+	}
+	for _, entry := range s.Entries {
+		if strings.EqualFold(entry.DN, dn) && entry.Password != "" && entry.Password == password {
+			s.mutex.Lock()
+			s.binds = append(s.binds, entry.DN)
+			s.mutex.Unlock()
+			return ldap.RESULT_SUCCESS
+		}
+	}
+	return ldap.RESULT_INVALID_CREDENTIALS
+}
+
+func (s *Server) search(request *ldap.Packet) []*ldap.Packet {
+	baseDN := strings.ToLower(request.Child(0).String())
+	filter := request.Child(6)
+	requested := map[string]bool{}
+	for _, attribute := range request.Child(7).Children {
+		requested[strings.ToLower(attribute.String())] = true
+	}
+
+	results := []*ldap.Packet{}
+	for _, entry := range s.Entries {
+		if !strings.HasSuffix(strings.ToLower(entry.DN), baseDN) || !matches(entry, filter) {
+			continue
+		}
+
+		attributes := ldap.NewSequence(ldap.CLASS_UNIVERSAL, ldap.TAG_SEQUENCE)
+		for name, values := range entry.Attributes {
+			if len(requested) > 0 && !requested[strings.ToLower(name)] {
+				continue
+			}
+			valueSet := ldap.NewSequence(ldap.CLASS_UNIVERSAL, ldap.TAG_SET)
+			for _, value := range values {
+				valueSet.Children = append(valueSet.Children, ldap.NewOctetString(ldap.CLASS_UNIVERSAL, ldap.TAG_OCTET_STRING, value))
+			}
+			attributes.Children = append(attributes.Children, ldap.NewSequence(ldap.CLASS_UNIVERSAL, ldap.TAG_SEQUENCE,
+				ldap.NewOctetString(ldap.CLASS_UNIVERSAL, ldap.TAG_OCTET_STRING, name),
+				valueSet,
+			))
+		}
+		results = append(results, ldap.NewSequence(ldap.CLASS_APPLICATION, ldap.APPLICATION_SEARCH_ENTRY,
+			ldap.NewOctetString(ldap.CLASS_UNIVERSAL, ldap.TAG_OCTET_STRING, entry.DN),
+			attributes,
+		))
+	}
+	return results
+}
+
+// matches evaluates the filter against the entry, comparing values without
+// regard to case as most user attributes are.
+func matches(entry Entry, filter *ldap.Packet) bool {
+	switch filter.Tag {
+	case ldap.FILTER_AND:
+		for _, child := range filter.Children {
+			if !matches(entry, child) {
+				return false
+			}
+		}
+		return true
+	case ldap.FILTER_OR:
+		for _, child := range filter.Children {
+			if matches(entry, child) {
+				return true
+			}
+		}
+		return false
+	case ldap.FILTER_NOT:
+		return !matches(entry, filter.Child(0))
+	case ldap.FILTER_EQUALITY:
+		for _, value := range values(entry, filter.Child(0).String()) {
+			if strings.EqualFold(value, filter.Child(1).String()) {
+				return true
+			}
+		}
+		return false
+	case ldap.FILTER_PRESENT:
+		return len(values(entry, filter.String())) > 0
+	}
+	return false
+}
+
+func values(entry Entry, name string) []string {
+	for attribute, values := range entry.Attributes {
+		if strings.EqualFold(attribute, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+func result(application byte, resultCode int) *ldap.Packet {
+	return ldap.NewSequence(ldap.CLASS_APPLICATION, application,
+		ldap.NewInteger(ldap.CLASS_UNIVERSAL, ldap.TAG_ENUMERATED, int64(resultCode)),
+		ldap.NewOctetString(ldap.CLASS_UNIVERSAL, ldap.TAG_OCTET_STRING, ""),
+		ldap.NewOctetString(ldap.CLASS_UNIVERSAL, ldap.TAG_OCTET_STRING, ""),
+	)
+}
+
+func reply(conn net.Conn, messageId int64, operation *ldap.Packet) {
+	message := ldap.NewSequence(ldap.CLASS_UNIVERSAL, ldap.TAG_SEQUENCE,
+		ldap.NewInteger(ldap.CLASS_UNIVERSAL, ldap.TAG_INTEGER, messageId),
+		operation,
+	)
+	conn.Write(message.Bytes())
+}
@@ -0,0 +1,62 @@
+package mailer
+
+import (
+	"fmt"
+	"net/smtp"
+	"strings"
+	"userland/config"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type Mailer interface {
+	Send(recipient string, subject string, body string) error
+}
+
+type smtpMailer struct {
+	address  string
+	host     string
+	username string
+	password string
+	sender   string
+}
+
+type logMailer struct{}
+
+func GetMailer() Mailer {
+	if config.GetSMTPHost() == "" {
+		log.Warn("SMTP_HOST is not set, emails will only be logged")
+		return logMailer{}
+	}
+	return smtpMailer{
+		address:  config.GetSMTPAddress(),
+		host:     config.GetSMTPHost(),
+		username: config.GetSMTPUsername(),
+		password: config.GetSMTPPassword(),
+		sender:   config.GetMailSender(),
+	}
+}
+
+func (m smtpMailer) Send(recipient string, subject string, body string) error {
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+	return smtp.SendMail(m.address, auth, m.sender, []string{recipient}, buildMessage(m.sender, recipient, subject, body))
+}
+
+func (m logMailer) Send(recipient string, subject string, body string) error {
+	log.WithFields(log.Fields{"recipient": recipient, "subject": subject}).Info(body)
+	return nil
+}
+
+func buildMessage(sender string, recipient string, subject string, body string) []byte {
+	headers := []string{
+		fmt.Sprintf("From: %s", sender),
+		fmt.Sprintf("To: %s", recipient),
+		fmt.Sprintf("Subject: %s", subject),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=\"utf-8\"",
+	}
+	return []byte(strings.Join(headers, "\r\n") + "\r\n\r\n" + body)
+}
@@ -0,0 +1,31 @@
+package mailer
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetMailer(t *testing.T) {
+	os.Unsetenv("SMTP_HOST")
+	_, isLogMailer := GetMailer().(logMailer)
+	assert.True(t, isLogMailer, "Mailer should fall back to logging when SMTP is not configured")
+
+	os.Setenv("SMTP_HOST", "smtp.example.com")
+	_, isSMTPMailer := GetMailer().(smtpMailer)
+	assert.True(t, isSMTPMailer)
+	os.Unsetenv("SMTP_HOST")
+}
+
+func TestLogMailerSend(t *testing.T) {
+	assert.Nil(t, logMailer{}.Send("user@example.com", "subject", "body"))
+}
+
+func TestBuildMessage(t *testing.T) {
+	message := string(buildMessage("no-reply@example.com", "user@example.com", "Hello", "Hi there"))
+	assert.Contains(t, message, "From: no-reply@example.com\r\n")
+	assert.Contains(t, message, "To: user@example.com\r\n")
+	assert.Contains(t, message, "Subject: Hello\r\n")
+	assert.Contains(t, message, "\r\n\r\nHi there")
+}
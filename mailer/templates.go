@@ -0,0 +1,138 @@
+package mailer
+
+import (
+	"bytes"
+	"errors"
+	"text/template"
+)
+
+const (
+	EMAIL_LOGIN_TEMPLATE             = "email_login"
+	NEW_DEVICE_TEMPLATE              = "new_device"
+	PASSWORD_RESET_REQUIRED_TEMPLATE = "password_reset_required"
+	PASSWORD_RESET_TEMPLATE          = "password_reset"
+	ORGANIZATION_INVITATION_TEMPLATE = "organization_invitation"
+	ACCOUNT_DELETION_TEMPLATE        = "account_deletion"
+	DATA_EXPORT_TEMPLATE             = "data_export"
+)
+
+type Template struct {
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// TemplateSet overrides some of the default templates, e.g. with the wording
+// of a tenant. An override may leave the subject or the body empty to keep
+// the default one.
+type TemplateSet map[string]Template
+
+var templates = map[string]Template{
+	EMAIL_LOGIN_TEMPLATE: {
+		Subject: "Your Userland login link",
+		Body: "Hi {{.Fullname}},\n\n" +
+			"Use the link below to log in to Userland:\n\n{{.Link}}\n\n" +
+			"Or enter this code: {{.Code}}\n\n" +
+			"The link and code expire in {{.ExpiresInMinutes}} minutes and can only be used once. " +
+			"If you didn't request them, you can safely ignore this email.\n",
+	},
+	NEW_DEVICE_TEMPLATE: {
+		Subject: "New sign-in to your Userland account",
+		Body: "Hi {{.Fullname}},\n\n" +
+			"Your account was just signed in to from a new device.\n\n" +
+			"Device: {{.UserAgent}}\nIP address: {{.IPAddress}}\nTime: {{.Time}}\n\n" +
+			"If this was you, there's nothing else to do. If it wasn't, follow the link below " +
+			"to sign out everywhere and reset your password:\n\n{{.Link}}\n",
+	},
+	PASSWORD_RESET_REQUIRED_TEMPLATE: {
+		Subject: "Reset your Userland password",
+		Body: "Hi {{.Fullname}},\n\n" +
+			"We've signed your account out of every device. " +
+			"Choose a new password before logging in again:\n\n{{.Link}}\n",
+	},
+	PASSWORD_RESET_TEMPLATE: {
+		Subject: "Reset your Userland password",
+		Body: "Hi {{.Fullname}},\n\n" +
+			"A password reset was requested for your account. " +
+			"Follow the link below to choose a new password:\n\n{{.Link}}\n\n" +
+			"If you didn't expect this email, please contact support.\n",
+	},
+	ORGANIZATION_INVITATION_TEMPLATE: {
+		Subject: "You're invited to join {{.Organization}} on Userland",
+		Body: "Hi,\n\n" +
+			"{{.Inviter}} invited you to join {{.Organization}} as {{.Role}}. " +
+			"Log in or sign up with this email address to accept or decline:\n\n{{.Link}}\n\n" +
+			"The invitation expires in {{.ExpiresInDays}} days.\n",
+	},
+	ACCOUNT_DELETION_TEMPLATE: {
+		Subject: "Your Userland account will be deleted",
+		Body: "Hi {{.Fullname}},\n\n" +
+			"Your account has been deactivated and signed out of every device. " +
+			"It will be deleted for good on {{.PurgeDate}}.\n\n" +
+			"Changed your mind? Follow the link below before then to restore it:\n\n{{.Link}}\n",
+	},
+	DATA_EXPORT_TEMPLATE: {
+		Subject: "Your Userland data export is ready",
+		Body: "Hi {{.Fullname}},\n\n" +
+			"The copy of your data you asked for is ready. Download it from the link below:\n\n{{.Link}}\n\n" +
+			"The link expires in {{.ExpiresInHours}} hours and can only be used once.\n",
+	},
+}
+
+func Render(name string, data interface{}) (string, string, error) {
+	return TemplateSet(nil).Render(name, data)
+}
+
+func SendTemplate(m Mailer, recipient string, name string, data interface{}) error {
+	return TemplateSet(nil).Send(m, recipient, name, data)
+}
+
+func (set TemplateSet) Render(name string, data interface{}) (string, string, error) {
+	tmpl, ok := set.lookup(name)
+	if !ok {
+		return "", "", errors.New("Email template doesn't exist")
+	}
+
+	subject, err := execute(tmpl.Subject, data)
+	if err != nil {
+		return "", "", err
+	}
+	body, err := execute(tmpl.Body, data)
+	if err != nil {
+		return "", "", err
+	}
+	return subject, body, nil
+}
+
+func (set TemplateSet) Send(m Mailer, recipient string, name string, data interface{}) error {
+	subject, body, err := set.Render(name, data)
+	if err != nil {
+		return err
+	}
+	return m.Send(recipient, subject, body)
+}
+
+func (set TemplateSet) lookup(name string) (Template, bool) {
+	tmpl, ok := templates[name]
+	if !ok {
+		return Template{}, false
+	}
+
+	override := set[name]
+	if override.Subject != "" {
+		tmpl.Subject = override.Subject
+	}
+	if override.Body != "" {
+		tmpl.Body = override.Body
+	}
+	return tmpl, true
+}
+
+func execute(text string, data interface{}) (string, error) {
+	tmpl, err := template.New("").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, data)
+	return buf.String(), err
+}
@@ -0,0 +1,81 @@
+package mailer
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	data := map[string]interface{}{
+		"Fullname":         "user",
+		"Link":             "https://example.com/login?token=abc",
+		"Code":             "123456",
+		"ExpiresInMinutes": 15,
+	}
+	subject, body, err := Render(EMAIL_LOGIN_TEMPLATE, data)
+	require.Nil(t, err)
+	assert.NotEmpty(t, subject)
+	assert.Contains(t, body, "https://example.com/login?token=abc")
+	assert.Contains(t, body, "123456")
+
+	subject, body, err = Render(ORGANIZATION_INVITATION_TEMPLATE, map[string]interface{}{
+		"Inviter":       "user",
+		"Organization":  "Example",
+		"Role":          "member",
+		"Link":          "https://example.com/invitations",
+		"ExpiresInDays": 7,
+	})
+	require.Nil(t, err)
+	assert.Contains(t, subject, "Example")
+	assert.Contains(t, body, "https://example.com/invitations")
+
+	_, body, err = Render(ACCOUNT_DELETION_TEMPLATE, map[string]interface{}{
+		"Fullname":  "user",
+		"PurgeDate": "November 18, 2026",
+		"Link":      "https://example.com/account/restore?token=abc",
+	})
+	require.Nil(t, err)
+	assert.Contains(t, body, "November 18, 2026")
+	assert.Contains(t, body, "https://example.com/account/restore?token=abc")
+
+	_, _, err = Render("unknown_template", data)
+	assert.NotNil(t, err)
+}
+
+func TestSendTemplate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockMailer := NewMockMailer(ctrl)
+	mockMailer.EXPECT().Send("user@example.com", gomock.Any(), gomock.Any()).Return(nil)
+
+	err := SendTemplate(mockMailer, "user@example.com", EMAIL_LOGIN_TEMPLATE, map[string]interface{}{})
+	assert.Nil(t, err)
+}
+
+func TestTemplateSetRender(t *testing.T) {
+	set := TemplateSet{
+		EMAIL_LOGIN_TEMPLATE: {Subject: "Your Brand login link"},
+		PASSWORD_RESET_TEMPLATE: {
+			Subject: "Reset your Brand password",
+			Body:    "Hello {{.Fullname}}, reset your password at {{.Link}}",
+		},
+		"unknown_template": {Subject: "Unknown", Body: "Unknown"},
+	}
+
+	subject, body, err := set.Render(EMAIL_LOGIN_TEMPLATE, map[string]interface{}{"Code": "123456"})
+	require.Nil(t, err)
+	assert.Equal(t, "Your Brand login link", subject)
+	assert.Contains(t, body, "123456", "Body should fall back to the default one")
+
+	subject, body, err = set.Render(PASSWORD_RESET_TEMPLATE, map[string]interface{}{"Fullname": "user", "Link": "https://example.com/reset"})
+	require.Nil(t, err)
+	assert.Equal(t, "Reset your Brand password", subject)
+	assert.Equal(t, "Hello user, reset your password at https://example.com/reset", body)
+
+	_, _, err = set.Render("unknown_template", nil)
+	assert.NotNil(t, err, "Overrides shouldn't add new templates")
+}
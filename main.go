@@ -4,7 +4,11 @@ import (
 	"net/http"
 
 	"userland/appcontext"
+	"userland/config"
+	"userland/mailer"
+	"userland/profile"
 	"userland/router"
+	"userland/storage"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -13,6 +17,28 @@ func main() {
 	appcontext.InitContext()
 	router := router.GetRouter()
 
+	store := storage.GetBlobStore()
+	mover := profile.PictureMover{
+		ProfileRepo: profile.GetProfileRepository(),
+		Store:       store,
+	}
+	go mover.Run()
+
+	purger := profile.AccountPurger{
+		ProfileRepo: profile.GetProfileRepository(),
+		Store:       store,
+		Interval:    config.GetAccountPurgeInterval(),
+	}
+	go purger.Run(nil)
+
+	exporter := profile.DataExporter{
+		ProfileRepo: profile.GetProfileRepository(),
+		Store:       store,
+		Mailer:      mailer.GetMailer(),
+		Interval:    config.GetDataExportInterval(),
+	}
+	go exporter.Run(nil)
+
 	log.Info("Server is listening at 8080")
 	log.Fatal(http.ListenAndServe(":8080", router))
 }
@@ -0,0 +1,8 @@
+--
+-- Single-use, short-lived credentials for passwordless login via email
+--
+
+ALTER TABLE "user"
+    ADD COLUMN login_token character varying(32),
+    ADD COLUMN login_code character varying(6),
+    ADD COLUMN login_token_expires_at timestamp without time zone;
@@ -0,0 +1,17 @@
+--
+-- WebAuthn credentials (passkeys and security keys) registered by users
+--
+
+CREATE TABLE webauthn_credential (
+    id serial PRIMARY KEY,
+    user_id integer NOT NULL REFERENCES "user" (id) ON DELETE CASCADE,
+    credential_id bytea NOT NULL,
+    public_key bytea NOT NULL,
+    sign_count bigint DEFAULT 0 NOT NULL,
+    transports character varying(32)[] DEFAULT '{}' NOT NULL,
+    created_at timestamp without time zone DEFAULT now(),
+    last_used_at timestamp without time zone,
+    CONSTRAINT webauthn_credential_id_unique UNIQUE (credential_id)
+);
+
+CREATE INDEX webauthn_credential_user_id_index ON webauthn_credential (user_id);
@@ -0,0 +1,20 @@
+--
+-- Known sign-in devices, session revocation and forced password resets
+--
+
+ALTER TABLE "user"
+    ADD COLUMN token_version integer DEFAULT 0 NOT NULL,
+    ADD COLUMN password_reset_required boolean DEFAULT false NOT NULL;
+
+CREATE TABLE user_device (
+    id serial PRIMARY KEY,
+    user_id integer NOT NULL REFERENCES "user" (id) ON DELETE CASCADE,
+    fingerprint character varying(64) NOT NULL,
+    user_agent character varying(255) NOT NULL,
+    ip_address character varying(45) NOT NULL,
+    report_token character varying(32) NOT NULL,
+    created_at timestamp without time zone DEFAULT now(),
+    last_seen_at timestamp without time zone DEFAULT now(),
+    CONSTRAINT user_device_fingerprint_unique UNIQUE (user_id, fingerprint),
+    CONSTRAINT user_device_report_token_unique UNIQUE (report_token)
+);
@@ -0,0 +1,45 @@
+--
+-- Role-based access control
+--
+
+ALTER TABLE "user"
+    ADD COLUMN roles_version integer DEFAULT 0 NOT NULL;
+
+CREATE TABLE role (
+    id serial PRIMARY KEY,
+    name character varying(64) NOT NULL,
+    description character varying(255) DEFAULT '' NOT NULL,
+    CONSTRAINT role_name_unique UNIQUE (name)
+);
+
+CREATE TABLE permission (
+    id serial PRIMARY KEY,
+    name character varying(64) NOT NULL,
+    description character varying(255) DEFAULT '' NOT NULL,
+    CONSTRAINT permission_name_unique UNIQUE (name)
+);
+
+CREATE TABLE role_permission (
+    role_id integer NOT NULL REFERENCES role (id) ON DELETE CASCADE,
+    permission_id integer NOT NULL REFERENCES permission (id) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+CREATE TABLE user_role (
+    user_id integer NOT NULL REFERENCES "user" (id) ON DELETE CASCADE,
+    role_id integer NOT NULL REFERENCES role (id) ON DELETE CASCADE,
+    created_at timestamp without time zone DEFAULT now(),
+    PRIMARY KEY (user_id, role_id)
+);
+
+INSERT INTO role (name, description) VALUES
+    ('admin', 'Full access to user management');
+
+INSERT INTO permission (name, description) VALUES
+    ('users:read', 'View any user account'),
+    ('users:write', 'Create and edit any user account'),
+    ('users:delete', 'Delete any user account'),
+    ('roles:manage', 'Attach roles to and detach roles from users');
+
+INSERT INTO role_permission (role_id, permission_id)
+    SELECT role.id, permission.id FROM role, permission WHERE role.name = 'admin';
@@ -0,0 +1,16 @@
+--
+-- Audit log of administrative and security relevant actions
+--
+
+CREATE TABLE audit_event (
+    id serial PRIMARY KEY,
+    actor_id integer,
+    user_id integer,
+    action character varying(64) NOT NULL,
+    ip_address character varying(45) NOT NULL,
+    details jsonb DEFAULT '{}' NOT NULL,
+    created_at timestamp without time zone DEFAULT now()
+);
+
+CREATE INDEX audit_event_actor_id_index ON audit_event (actor_id);
+CREATE INDEX audit_event_user_id_index ON audit_event (user_id);
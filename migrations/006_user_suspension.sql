@@ -0,0 +1,16 @@
+--
+-- Account suspension and banning
+--
+
+ALTER TABLE "user"
+    ADD COLUMN suspension character varying(16),
+    ADD COLUMN suspension_reason character varying(255),
+    ADD COLUMN suspended_by integer,
+    ADD COLUMN suspended_at timestamp with time zone,
+    ADD COLUMN suspended_until timestamp with time zone;
+
+INSERT INTO permission (name, description) VALUES
+    ('users:suspend', 'Suspend, ban and reinstate any user account');
+
+INSERT INTO role_permission (role_id, permission_id)
+    SELECT role.id, permission.id FROM role, permission WHERE role.name = 'admin' AND permission.name = 'users:suspend';
@@ -0,0 +1,9 @@
+--
+-- Admin impersonation
+--
+
+INSERT INTO permission (name, description) VALUES
+    ('users:impersonate', 'Act on behalf of any user account');
+
+INSERT INTO role_permission (role_id, permission_id)
+    SELECT role.id, permission.id FROM role, permission WHERE role.name = 'admin' AND permission.name = 'users:impersonate';
@@ -0,0 +1,32 @@
+--
+-- Organizations, memberships and invitations
+--
+
+CREATE TABLE organization (
+    id serial PRIMARY KEY,
+    name character varying(128) NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+CREATE TABLE organization_member (
+    organization_id integer NOT NULL REFERENCES organization (id) ON DELETE CASCADE,
+    user_id integer NOT NULL REFERENCES "user" (id) ON DELETE CASCADE,
+    role character varying(16) NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    PRIMARY KEY (organization_id, user_id)
+);
+
+CREATE INDEX organization_member_user_id_idx ON organization_member (user_id);
+
+CREATE TABLE organization_invitation (
+    id serial PRIMARY KEY,
+    organization_id integer NOT NULL REFERENCES organization (id) ON DELETE CASCADE,
+    email character varying(128) NOT NULL,
+    role character varying(16) NOT NULL,
+    invited_by integer REFERENCES "user" (id) ON DELETE SET NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    expires_at timestamp with time zone NOT NULL,
+    CONSTRAINT organization_invitation_email_unique UNIQUE (organization_id, email)
+);
+
+CREATE INDEX organization_invitation_email_idx ON organization_invitation (email);
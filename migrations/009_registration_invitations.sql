@@ -0,0 +1,15 @@
+--
+-- Invitation codes for the invite-only registration mode
+--
+
+CREATE TABLE registration_invitation (
+    id serial PRIMARY KEY,
+    code character varying(32) NOT NULL,
+    email character varying(128),
+    created_by integer REFERENCES "user" (id) ON DELETE SET NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    expires_at timestamp with time zone NOT NULL,
+    used_by integer REFERENCES "user" (id) ON DELETE SET NULL,
+    used_at timestamp with time zone,
+    CONSTRAINT registration_invitation_code_unique UNIQUE (code)
+);
@@ -0,0 +1,38 @@
+--
+-- Tenants, each with their own users, JWT key, email templates and
+-- registration policy. Existing users and invitations move to the default one.
+--
+
+CREATE TABLE tenant (
+    id serial PRIMARY KEY,
+    slug character varying(64) NOT NULL,
+    name character varying(128) NOT NULL,
+    hostname character varying(255),
+    jwt_key character varying(255),
+    registration_mode character varying(32),
+    registration_allowed_domains text[] DEFAULT '{}' NOT NULL,
+    email_templates jsonb DEFAULT '{}' NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT tenant_slug_unique UNIQUE (slug),
+    CONSTRAINT tenant_hostname_unique UNIQUE (hostname)
+);
+
+INSERT INTO tenant (slug, name) VALUES ('default', 'Userland');
+
+ALTER TABLE "user"
+    ADD COLUMN tenant_id integer REFERENCES tenant (id);
+
+UPDATE "user" SET tenant_id = (SELECT id FROM tenant WHERE slug = 'default');
+
+ALTER TABLE "user"
+    ALTER COLUMN tenant_id SET NOT NULL,
+    DROP CONSTRAINT email_unique,
+    ADD CONSTRAINT email_unique UNIQUE (tenant_id, email);
+
+ALTER TABLE registration_invitation
+    ADD COLUMN tenant_id integer REFERENCES tenant (id);
+
+UPDATE registration_invitation SET tenant_id = (SELECT id FROM tenant WHERE slug = 'default');
+
+ALTER TABLE registration_invitation
+    ALTER COLUMN tenant_id SET NOT NULL;
@@ -0,0 +1,32 @@
+--
+-- SCIM provisioning: bearer tokens of the identity providers, the id they
+-- know each user by, deactivated accounts and organizations per tenant
+--
+
+ALTER TABLE "user"
+    ADD COLUMN external_id character varying(255),
+    ADD COLUMN deactivated_at timestamp with time zone,
+    ADD CONSTRAINT user_external_id_unique UNIQUE (tenant_id, external_id);
+
+ALTER TABLE organization
+    ADD COLUMN tenant_id integer REFERENCES tenant (id);
+
+UPDATE organization SET tenant_id = COALESCE(
+    (SELECT min("user".tenant_id) FROM organization_member JOIN "user" ON "user".id = organization_member.user_id
+        WHERE organization_member.organization_id = organization.id),
+    (SELECT id FROM tenant WHERE slug = 'default')
+);
+
+ALTER TABLE organization
+    ALTER COLUMN tenant_id SET NOT NULL;
+
+CREATE TABLE scim_token (
+    id serial PRIMARY KEY,
+    tenant_id integer NOT NULL REFERENCES tenant (id) ON DELETE CASCADE,
+    token_hash character(64) NOT NULL,
+    description character varying(128) NOT NULL,
+    created_by integer REFERENCES "user" (id) ON DELETE SET NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    last_used_at timestamp with time zone,
+    CONSTRAINT scim_token_hash_unique UNIQUE (token_hash)
+);
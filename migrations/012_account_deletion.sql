@@ -0,0 +1,11 @@
+--
+-- Accounts deleted by their users are kept for a grace period, during which
+-- the emailed restore token brings them back, before they're purged
+--
+
+ALTER TABLE "user"
+    ADD COLUMN deleted_at timestamp with time zone,
+    ADD COLUMN purge_at timestamp with time zone,
+    ADD COLUMN restore_token character varying(32);
+
+CREATE INDEX user_purge_at_index ON "user" (purge_at) WHERE purge_at IS NOT NULL;
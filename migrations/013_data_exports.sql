@@ -0,0 +1,19 @@
+--
+-- Archives of the data held about a user, built in the background and
+-- downloadable once through an emailed link
+--
+
+CREATE TABLE data_export (
+    id serial PRIMARY KEY,
+    user_id integer NOT NULL REFERENCES "user" (id) ON DELETE CASCADE,
+    status character varying(16) DEFAULT 'pending' NOT NULL,
+    archive bytea,
+    token_hash character(64),
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    completed_at timestamp with time zone,
+    expires_at timestamp with time zone,
+    downloaded_at timestamp with time zone,
+    CONSTRAINT data_export_token_hash_unique UNIQUE (token_hash)
+);
+
+CREATE INDEX data_export_user_id_index ON data_export (user_id);
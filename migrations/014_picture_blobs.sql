@@ -0,0 +1,19 @@
+--
+-- Profile pictures move to the blob store, the user row keeping only where
+-- the picture is and what it is. Existing pictures are parked in
+-- user_picture_migration until the server copies them to the store.
+--
+
+CREATE TABLE user_picture_migration (
+    user_id integer PRIMARY KEY REFERENCES "user" (id) ON DELETE CASCADE,
+    picture bytea NOT NULL
+);
+
+INSERT INTO user_picture_migration (user_id, picture)
+    SELECT id, picture FROM "user" WHERE picture IS NOT NULL AND length(picture) > 0;
+
+ALTER TABLE "user"
+    DROP COLUMN picture,
+    ADD COLUMN picture_key character varying(255),
+    ADD COLUMN picture_content_type character varying(64),
+    ADD COLUMN picture_hash character(64);
@@ -0,0 +1,20 @@
+--
+-- Optional usernames, unique within the tenant regardless of case, and the
+-- usernames users moved away from, which keep pointing at them for a while
+--
+
+ALTER TABLE "user"
+    ADD COLUMN username character varying(30);
+
+CREATE UNIQUE INDEX user_username_unique ON "user" (tenant_id, lower(username));
+
+CREATE TABLE username_redirect (
+    id serial PRIMARY KEY,
+    tenant_id integer NOT NULL REFERENCES tenant (id) ON DELETE CASCADE,
+    username character varying(30) NOT NULL,
+    user_id integer NOT NULL REFERENCES "user" (id) ON DELETE CASCADE,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    expires_at timestamp with time zone NOT NULL
+);
+
+CREATE UNIQUE INDEX username_redirect_username_unique ON username_redirect (tenant_id, lower(username));
@@ -0,0 +1,17 @@
+--
+-- Who, besides the user, gets to see each optional profile field: anyone,
+-- logged in users only, or nobody. Pictures were already served to anyone;
+-- the other fields were never shown to other users
+--
+
+ALTER TABLE "user"
+    ADD COLUMN location_visibility character varying(16) DEFAULT 'authenticated' NOT NULL,
+    ADD COLUMN bio_visibility character varying(16) DEFAULT 'authenticated' NOT NULL,
+    ADD COLUMN web_visibility character varying(16) DEFAULT 'authenticated' NOT NULL,
+    ADD COLUMN picture_visibility character varying(16) DEFAULT 'public' NOT NULL,
+    ADD CONSTRAINT user_visibility_check CHECK (
+        location_visibility IN ('public', 'authenticated', 'private') AND
+        bio_visibility IN ('public', 'authenticated', 'private') AND
+        web_visibility IN ('public', 'authenticated', 'private') AND
+        picture_visibility IN ('public', 'authenticated', 'private')
+    );
@@ -0,0 +1,13 @@
+--
+-- Trigram indexes for searching users by the start of a field or by similar
+-- words, and an index keeping them in name order
+--
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX user_fullname_trgm ON "user" USING gin (lower(fullname) gin_trgm_ops);
+CREATE INDEX user_username_trgm ON "user" USING gin (lower(username) gin_trgm_ops);
+CREATE INDEX user_email_trgm ON "user" USING gin (lower(email) gin_trgm_ops);
+CREATE INDEX user_location_trgm ON "user" USING gin (lower(location) gin_trgm_ops);
+
+CREATE INDEX user_fullname_order ON "user" (tenant_id, lower(fullname), id);
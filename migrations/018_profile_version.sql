@@ -0,0 +1,7 @@
+--
+-- Version of what users see of their own profile, bumped by every write to
+-- it, so that concurrent edits can tell when they'd overwrite each other
+--
+
+ALTER TABLE "user"
+    ADD COLUMN profile_version integer DEFAULT 1 NOT NULL;
@@ -0,0 +1,12 @@
+--
+-- Links to report an unrecognized device stop working after a while. Links
+-- already sent expire as long after the device was first seen
+--
+
+ALTER TABLE user_device
+    ADD COLUMN report_token_expires_at timestamp without time zone;
+
+UPDATE user_device SET report_token_expires_at = created_at + interval '7 days';
+
+ALTER TABLE user_device
+    ALTER COLUMN report_token_expires_at SET NOT NULL;
@@ -0,0 +1,9 @@
+--
+-- Accounts created by a directory remember the entry they were created from,
+-- so that a directory login only ever links to its own account. Accounts the
+-- directory created earlier can't be told apart from local ones, and are
+-- linked again by setting their entry's DN
+--
+
+ALTER TABLE "user"
+    ADD COLUMN directory_dn text;
@@ -0,0 +1,14 @@
+--
+-- Challenges of the WebAuthn ceremonies in progress, by their SHA-256 hash.
+-- Finishing a ceremony uses its challenge up, so an assertion can't be
+-- replayed
+--
+
+CREATE TABLE webauthn_challenge (
+    challenge_hash character(64) PRIMARY KEY,
+    tenant_id integer NOT NULL REFERENCES tenant (id) ON DELETE CASCADE,
+    ceremony character varying(32) NOT NULL,
+    expires_at timestamp with time zone NOT NULL
+);
+
+CREATE INDEX webauthn_challenge_expires_at_index ON webauthn_challenge (expires_at);
@@ -0,0 +1,10 @@
+--
+-- Login tokens and codes are stored as their SHA-256 hash. Those already sent
+-- were stored as is, so they are dropped and have to be requested again
+--
+
+UPDATE "user" SET login_token = NULL, login_code = NULL, login_token_expires_at = NULL;
+
+ALTER TABLE "user"
+    ALTER COLUMN login_token TYPE character(64),
+    ALTER COLUMN login_code TYPE character(64);
@@ -0,0 +1,17 @@
+--
+-- Data export archives are kept in the blob store, the row only pointing at
+-- them. Exports are built by a background job, which notes when it took one
+-- up so that other instances leave it alone. Archives already built were kept
+-- in the database and are dropped, so their links stop working
+--
+
+ALTER TABLE data_export
+    ADD COLUMN archive_key character varying(255),
+    ADD COLUMN started_at timestamp with time zone;
+
+UPDATE data_export SET status = 'failed', completed_at = now() WHERE status = 'ready';
+
+ALTER TABLE data_export
+    DROP COLUMN archive;
+
+CREATE INDEX data_export_status_index ON data_export (status);
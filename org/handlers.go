@@ -0,0 +1,447 @@
+package org
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"userland/auth"
+	"userland/config"
+	ulanderrors "userland/errors"
+	"userland/mailer"
+	"userland/request"
+	"userland/response"
+	"userland/tenancy"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+type OrgHandler struct {
+	OrgRepo orgRepositoryInterface
+	Mailer  mailer.Mailer
+}
+
+func (handler OrgHandler) GetMyOrganizations(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*auth.User)
+
+	memberships, err := handler.OrgRepo.getMemberships(user.Id)
+	if err != nil {
+		log.Warn(err)
+		response.RespondInternalError(w, ulanderrors.ErrOrgQueryExec)
+		return
+	}
+
+	activeOrganizationId := 0
+	for _, membership := range memberships {
+		if membership.Id == auth.ActiveOrganization(r) {
+			activeOrganizationId = membership.Id
+		}
+	}
+
+	log.Info("Get user organizations successful")
+	response.RespondSuccessWithBody(w, membershipListResponse{
+		Organizations:        memberships,
+		ActiveOrganizationId: activeOrganizationId,
+	})
+}
+
+// SwitchOrganization sets the organization the session acts in. Switching to
+// zero leaves the session without an active organization.
+func (handler OrgHandler) SwitchOrganization(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*auth.User)
+
+	var switchReq activeOrganizationRequest
+	err := request.ParseJSON(r.Body, &switchReq)
+	if err != nil {
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrParseBody)
+		return
+	}
+
+	if switchReq.OrganizationId != 0 {
+		_, err = handler.OrgRepo.getMembership(switchReq.OrganizationId, user.Id)
+		if err != nil {
+			respondOrgError(w, err, ulanderrors.ErrOrgNotFound)
+			return
+		}
+	}
+
+	err = auth.SwitchOrganization(w, r, switchReq.OrganizationId)
+	if err != nil {
+		log.Warn(err)
+		response.RespondInternalError(w, ulanderrors.ErrOrgSwitch)
+		return
+	}
+
+	log.Info("Switch active organization successful")
+	response.RespondSuccess(w)
+}
+
+func (handler OrgHandler) GetMyInvitations(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*auth.User)
+
+	invitations, err := handler.OrgRepo.getUserInvitations(tenancy.FromRequest(r).Id, user.Email)
+	if err != nil {
+		log.Warn(err)
+		response.RespondInternalError(w, ulanderrors.ErrOrgQueryExec)
+		return
+	}
+
+	log.Info("Get user invitations successful")
+	response.RespondSuccessWithBody(w, map[string]interface{}{"invitations": invitations})
+}
+
+func (handler OrgHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*auth.User)
+
+	invitationId, ok := idFromPath(w, r, "invitationId", ulanderrors.ErrOrgInvitationNotFound)
+	if !ok {
+		return
+	}
+
+	organizationId, err := handler.OrgRepo.acceptInvitation(tenancy.FromRequest(r).Id, invitationId, user.Id, user.Email)
+	if err != nil {
+		respondOrgError(w, err, ulanderrors.ErrOrgInvitationNotFound)
+		return
+	}
+
+	log.Info("Accept invitation successful")
+	response.RespondSuccessWithBody(w, map[string]int{"organization_id": organizationId})
+}
+
+func (handler OrgHandler) DeclineInvitation(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*auth.User)
+
+	invitationId, ok := idFromPath(w, r, "invitationId", ulanderrors.ErrOrgInvitationNotFound)
+	if !ok {
+		return
+	}
+
+	err := handler.OrgRepo.declineInvitation(tenancy.FromRequest(r).Id, invitationId, user.Email)
+	if err != nil {
+		respondOrgError(w, err, ulanderrors.ErrOrgInvitationNotFound)
+		return
+	}
+
+	log.Info("Decline invitation successful")
+	response.RespondSuccess(w)
+}
+
+func (handler OrgHandler) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*auth.User)
+
+	var orgReq organizationRequest
+	err := request.ParseJSON(r.Body, &orgReq)
+	if err != nil {
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrParseBody)
+		return
+	}
+
+	if !orgReq.isValid() {
+		log.Info("Organization data is invalid")
+		response.RespondBadRequest(w, ulanderrors.ErrOrgDataInvalid)
+		return
+	}
+
+	organization, err := handler.OrgRepo.createOrganization(user.Id, orgReq)
+	if err != nil {
+		log.Warn(err)
+		response.RespondInternalError(w, ulanderrors.ErrOrgQueryExec)
+		return
+	}
+
+	log.Info("Create organization successful")
+	response.RespondSuccessWithBody(w, Membership{Organization: *organization, Role: ROLE_OWNER})
+}
+
+func (handler OrgHandler) GetOrganization(w http.ResponseWriter, r *http.Request) {
+	membership, ok := handler.authorize(w, r, ROLE_MEMBER)
+	if !ok {
+		return
+	}
+
+	log.Info("Get organization successful")
+	response.RespondSuccessWithBody(w, membership)
+}
+
+func (handler OrgHandler) UpdateOrganization(w http.ResponseWriter, r *http.Request) {
+	membership, ok := handler.authorize(w, r, ROLE_ADMIN)
+	if !ok {
+		return
+	}
+
+	var orgReq organizationRequest
+	err := request.ParseJSON(r.Body, &orgReq)
+	if err != nil {
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrParseBody)
+		return
+	}
+
+	if !orgReq.isValid() {
+		log.Info("Organization data is invalid")
+		response.RespondBadRequest(w, ulanderrors.ErrOrgDataInvalid)
+		return
+	}
+
+	err = handler.OrgRepo.updateOrganization(membership.Id, orgReq)
+	if err != nil {
+		respondOrgError(w, err, ulanderrors.ErrOrgNotFound)
+		return
+	}
+
+	log.Info("Update organization successful")
+	response.RespondSuccess(w)
+}
+
+func (handler OrgHandler) DeleteOrganization(w http.ResponseWriter, r *http.Request) {
+	membership, ok := handler.authorize(w, r, ROLE_OWNER)
+	if !ok {
+		return
+	}
+
+	err := handler.OrgRepo.deleteOrganization(membership.Id)
+	if err != nil {
+		respondOrgError(w, err, ulanderrors.ErrOrgNotFound)
+		return
+	}
+
+	log.Info("Delete organization successful")
+	response.RespondSuccess(w)
+}
+
+func (handler OrgHandler) GetMembers(w http.ResponseWriter, r *http.Request) {
+	membership, ok := handler.authorize(w, r, ROLE_MEMBER)
+	if !ok {
+		return
+	}
+
+	members, err := handler.OrgRepo.getMembers(membership.Id)
+	if err != nil {
+		log.Warn(err)
+		response.RespondInternalError(w, ulanderrors.ErrOrgQueryExec)
+		return
+	}
+
+	log.Info("Get organization members successful")
+	response.RespondSuccessWithBody(w, map[string]interface{}{"members": members})
+}
+
+func (handler OrgHandler) UpdateMemberRole(w http.ResponseWriter, r *http.Request) {
+	membership, ok := handler.authorize(w, r, ROLE_OWNER)
+	if !ok {
+		return
+	}
+
+	userId, ok := idFromPath(w, r, "userId", ulanderrors.ErrOrgMemberNotFound)
+	if !ok {
+		return
+	}
+
+	var roleReq memberRoleRequest
+	err := request.ParseJSON(r.Body, &roleReq)
+	if err != nil {
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrParseBody)
+		return
+	}
+
+	if !isValidRole(roleReq.Role) {
+		log.Info("Organization role is invalid")
+		response.RespondBadRequest(w, ulanderrors.ErrOrgDataInvalid)
+		return
+	}
+
+	err = handler.OrgRepo.updateMemberRole(membership.Id, userId, roleReq.Role)
+	if err != nil {
+		respondOrgError(w, err, ulanderrors.ErrOrgMemberNotFound)
+		return
+	}
+
+	log.Info("Update organization member role successful")
+	response.RespondSuccess(w)
+}
+
+// RemoveMember lets admins remove members and owners remove anyone. Members
+// may always remove themselves to leave the organization, as long as it
+// keeps an owner.
+func (handler OrgHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*auth.User)
+
+	membership, ok := handler.authorize(w, r, ROLE_MEMBER)
+	if !ok {
+		return
+	}
+
+	userId, ok := idFromPath(w, r, "userId", ulanderrors.ErrOrgMemberNotFound)
+	if !ok {
+		return
+	}
+
+	if userId != user.Id {
+		target, err := handler.OrgRepo.getMembership(membership.Id, userId)
+		if err != nil {
+			respondOrgError(w, err, ulanderrors.ErrOrgMemberNotFound)
+			return
+		}
+		if !membership.hasRole(ROLE_ADMIN) || !membership.hasRole(target.Role) {
+			log.Info("Member's role doesn't allow removing the other member")
+			response.RespondForbidden(w, ulanderrors.ErrOrgRoleInsufficient)
+			return
+		}
+	}
+
+	err := handler.OrgRepo.removeMember(membership.Id, userId)
+	if err != nil {
+		respondOrgError(w, err, ulanderrors.ErrOrgMemberNotFound)
+		return
+	}
+
+	log.Info("Remove organization member successful")
+	response.RespondSuccess(w)
+}
+
+func (handler OrgHandler) GetInvitations(w http.ResponseWriter, r *http.Request) {
+	membership, ok := handler.authorize(w, r, ROLE_ADMIN)
+	if !ok {
+		return
+	}
+
+	invitations, err := handler.OrgRepo.getInvitations(membership.Id)
+	if err != nil {
+		log.Warn(err)
+		response.RespondInternalError(w, ulanderrors.ErrOrgQueryExec)
+		return
+	}
+
+	log.Info("Get organization invitations successful")
+	response.RespondSuccessWithBody(w, map[string]interface{}{"invitations": invitations})
+}
+
+// InviteMember emails an invitation to join the organization. Only owners
+// may invite other owners.
+func (handler OrgHandler) InviteMember(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*auth.User)
+
+	membership, ok := handler.authorize(w, r, ROLE_ADMIN)
+	if !ok {
+		return
+	}
+
+	var inviteReq invitationRequest
+	err := request.ParseJSON(r.Body, &inviteReq)
+	if err != nil {
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrParseBody)
+		return
+	}
+
+	if !inviteReq.isValid() {
+		log.Info("Invitation data is invalid")
+		response.RespondBadRequest(w, ulanderrors.ErrOrgInvitationInvalid)
+		return
+	}
+
+	if !membership.hasRole(inviteReq.Role) {
+		log.Info("Member's role doesn't allow inviting with the role")
+		response.RespondForbidden(w, ulanderrors.ErrOrgRoleInsufficient)
+		return
+	}
+
+	invitation, err := handler.OrgRepo.createInvitation(membership.Id, user.Id, inviteReq)
+	if err != nil {
+		respondOrgError(w, err, ulanderrors.ErrOrgNotFound)
+		return
+	}
+
+	err = tenancy.FromRequest(r).Templates().Send(handler.Mailer, invitation.Email, mailer.ORGANIZATION_INVITATION_TEMPLATE, map[string]interface{}{
+		"Inviter":       user.Fullname,
+		"Organization":  membership.Name,
+		"Role":          invitation.Role,
+		"Link":          fmt.Sprintf("%s/invitations", config.GetAppURL()),
+		"ExpiresInDays": INVITATION_EXPIRATION_DAYS,
+	})
+	if err != nil {
+		log.Warn(err)
+		response.RespondInternalError(w, ulanderrors.ErrOrgSendEmail)
+		return
+	}
+
+	log.Info("Invite organization member successful")
+	response.RespondSuccessWithBody(w, invitation)
+}
+
+func (handler OrgHandler) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	membership, ok := handler.authorize(w, r, ROLE_ADMIN)
+	if !ok {
+		return
+	}
+
+	invitationId, ok := idFromPath(w, r, "invitationId", ulanderrors.ErrOrgInvitationNotFound)
+	if !ok {
+		return
+	}
+
+	err := handler.OrgRepo.deleteInvitation(membership.Id, invitationId)
+	if err != nil {
+		respondOrgError(w, err, ulanderrors.ErrOrgInvitationNotFound)
+		return
+	}
+
+	log.Info("Revoke organization invitation successful")
+	response.RespondSuccess(w)
+}
+
+// authorize looks up the user's membership of the organization in the path
+// and checks that their role is at least the given one. Organizations the user
+// isn't a member of are reported as not found.
+func (handler OrgHandler) authorize(w http.ResponseWriter, r *http.Request, role string) (*Membership, bool) {
+	user := r.Context().Value("user").(*auth.User)
+
+	organizationId, ok := idFromPath(w, r, "id", ulanderrors.ErrOrgNotFound)
+	if !ok {
+		return nil, false
+	}
+
+	membership, err := handler.OrgRepo.getMembership(organizationId, user.Id)
+	if err != nil {
+		respondOrgError(w, err, ulanderrors.ErrOrgNotFound)
+		return nil, false
+	}
+
+	if !membership.hasRole(role) {
+		log.Info("Member's role doesn't allow the action")
+		response.RespondForbidden(w, ulanderrors.ErrOrgRoleInsufficient)
+		return nil, false
+	}
+	return membership, true
+}
+
+func idFromPath(w http.ResponseWriter, r *http.Request, name string, notFound ulanderrors.UserlandError) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)[name])
+	if err != nil {
+		log.Info(err)
+		response.RespondBadRequest(w, notFound)
+		return 0, false
+	}
+	return id, true
+}
+
+func respondOrgError(w http.ResponseWriter, err error, notFound ulanderrors.UserlandError) {
+	switch err {
+	case sql.ErrNoRows:
+		log.Info(err)
+		response.RespondBadRequest(w, notFound)
+	case errLastOwner:
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrOrgLastOwner)
+	case errAlreadyMember:
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrOrgAlreadyMember)
+	default:
+		log.Warn(err)
+		response.RespondInternalError(w, ulanderrors.ErrOrgQueryExec)
+	}
+}
@@ -0,0 +1,333 @@
+package org
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"userland/auth"
+	"userland/mailer"
+	"userland/tenancy"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	handler OrgHandler
+	router  *mux.Router
+
+	ctrl       *gomock.Controller
+	mockRepo   *MockorgRepositoryInterface
+	mockMailer *mailer.MockMailer
+
+	sessionUser = auth.User{
+		Id:       1,
+		Fullname: "user",
+		Email:    "user@example.com",
+	}
+	sessionClaims auth.Claims
+
+	organization = Organization{Id: 10, Name: "Example"}
+)
+
+func testOrgHandlerInit(t *testing.T) {
+	ctrl = gomock.NewController(t)
+	mockRepo = NewMockorgRepositoryInterface(ctrl)
+	mockMailer = mailer.NewMockMailer(ctrl)
+
+	handler = OrgHandler{OrgRepo: mockRepo, Mailer: mockMailer}
+	sessionClaims = auth.Claims{
+		UserId:         sessionUser.Id,
+		StandardClaims: jwt.StandardClaims{ExpiresAt: time.Now().Add(time.Hour).Unix()},
+	}
+
+	router = mux.NewRouter()
+	router.HandleFunc("/me/orgs", withSession(handler.GetMyOrganizations)).Methods(http.MethodGet)
+	router.HandleFunc("/me/orgs/active", withSession(handler.SwitchOrganization)).Methods(http.MethodPut)
+	router.HandleFunc("/me/orgs/invitations", withSession(handler.GetMyInvitations)).Methods(http.MethodGet)
+	router.HandleFunc("/me/orgs/invitations/{invitationId}/accept", withSession(handler.AcceptInvitation)).Methods(http.MethodPost)
+	router.HandleFunc("/me/orgs/invitations/{invitationId}/decline", withSession(handler.DeclineInvitation)).Methods(http.MethodPost)
+	router.HandleFunc("/orgs", withSession(handler.CreateOrganization)).Methods(http.MethodPost)
+	router.HandleFunc("/orgs/{id}", withSession(handler.GetOrganization)).Methods(http.MethodGet)
+	router.HandleFunc("/orgs/{id}", withSession(handler.UpdateOrganization)).Methods(http.MethodPut)
+	router.HandleFunc("/orgs/{id}", withSession(handler.DeleteOrganization)).Methods(http.MethodDelete)
+	router.HandleFunc("/orgs/{id}/members", withSession(handler.GetMembers)).Methods(http.MethodGet)
+	router.HandleFunc("/orgs/{id}/members/{userId}", withSession(handler.UpdateMemberRole)).Methods(http.MethodPut)
+	router.HandleFunc("/orgs/{id}/members/{userId}", withSession(handler.RemoveMember)).Methods(http.MethodDelete)
+	router.HandleFunc("/orgs/{id}/invitations", withSession(handler.GetInvitations)).Methods(http.MethodGet)
+	router.HandleFunc("/orgs/{id}/invitations", withSession(handler.InviteMember)).Methods(http.MethodPost)
+	router.HandleFunc("/orgs/{id}/invitations/{invitationId}", withSession(handler.RevokeInvitation)).Methods(http.MethodDelete)
+}
+
+func testOrgHandlerEnd() {
+	ctrl.Finish()
+}
+
+func withSession(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), "user", &sessionUser)
+		ctx = context.WithValue(ctx, "claims", &sessionClaims)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+func serve(t *testing.T, method string, url string, body interface{}) *httptest.ResponseRecorder {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		reqBody, err = json.Marshal(body)
+		require.Nil(t, err)
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(reqBody))
+	require.Nil(t, err)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	return res
+}
+
+// expectMembership makes the session user a member of the organization with the role.
+func expectMembership(role string) *gomock.Call {
+	return mockRepo.EXPECT().getMembership(organization.Id, sessionUser.Id).Return(&Membership{Organization: organization, Role: role}, nil)
+}
+
+func TestGetMyOrganizations(t *testing.T) {
+	testOrgHandlerInit(t)
+	memberships := []Membership{{Organization: organization, Role: ROLE_MEMBER}}
+
+	mockRepo.EXPECT().getMemberships(sessionUser.Id).Return(memberships, nil).Times(2)
+
+	res := serve(t, http.MethodGet, "/me/orgs", nil)
+	assert.Equal(t, http.StatusOK, res.Code)
+	var list membershipListResponse
+	require.Nil(t, json.Unmarshal(res.Body.Bytes(), &list))
+	assert.Len(t, list.Organizations, 1)
+	assert.Equal(t, 0, list.ActiveOrganizationId)
+
+	sessionClaims.OrganizationId = organization.Id
+	res = serve(t, http.MethodGet, "/me/orgs", nil)
+	require.Nil(t, json.Unmarshal(res.Body.Bytes(), &list))
+	assert.Equal(t, organization.Id, list.ActiveOrganizationId)
+
+	testOrgHandlerEnd()
+}
+
+func TestSwitchOrganization(t *testing.T) {
+	testOrgHandlerInit(t)
+
+	expectMembership(ROLE_MEMBER)
+	res := serve(t, http.MethodPut, "/me/orgs/active", activeOrganizationRequest{OrganizationId: organization.Id})
+	assert.Equal(t, http.StatusOK, res.Code)
+	var sessionCookie *http.Cookie
+	for _, cookie := range res.Result().Cookies() {
+		if cookie.Name == auth.SESSION_COOKIE {
+			sessionCookie = cookie
+		}
+	}
+	require.NotNil(t, sessionCookie, "Switching should reissue the session")
+	claims := &auth.Claims{}
+	_, err := jwt.ParseWithClaims(sessionCookie.Value, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(""), nil
+	})
+	require.Nil(t, err)
+	assert.Equal(t, organization.Id, claims.OrganizationId)
+
+	mockRepo.EXPECT().getMembership(11, sessionUser.Id).Return(nil, sql.ErrNoRows)
+	res = serve(t, http.MethodPut, "/me/orgs/active", activeOrganizationRequest{OrganizationId: 11})
+	assert.Equal(t, http.StatusBadRequest, res.Code, "Users should only switch to organizations they belong to")
+
+	res = serve(t, http.MethodPut, "/me/orgs/active", activeOrganizationRequest{})
+	assert.Equal(t, http.StatusOK, res.Code, "Users should be able to leave the organization context")
+
+	testOrgHandlerEnd()
+}
+
+func TestCreateOrganization(t *testing.T) {
+	testOrgHandlerInit(t)
+
+	orgReq := organizationRequest{Name: "Example"}
+	mockRepo.EXPECT().createOrganization(sessionUser.Id, orgReq).Return(&organization, nil)
+	res := serve(t, http.MethodPost, "/orgs", orgReq)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Body.String(), `"role":"owner"`)
+
+	assert.Equal(t, http.StatusBadRequest, serve(t, http.MethodPost, "/orgs", organizationRequest{Name: " "}).Code)
+
+	testOrgHandlerEnd()
+}
+
+func TestOrganizationRoles(t *testing.T) {
+	testOrgHandlerInit(t)
+
+	expectMembership(ROLE_MEMBER)
+	assert.Equal(t, http.StatusOK, serve(t, http.MethodGet, "/orgs/10", nil).Code)
+
+	mockRepo.EXPECT().getMembership(11, sessionUser.Id).Return(nil, sql.ErrNoRows)
+	assert.Equal(t, http.StatusBadRequest, serve(t, http.MethodGet, "/orgs/11", nil).Code, "Outsiders should not see the organization")
+
+	expectMembership(ROLE_MEMBER)
+	assert.Equal(t, http.StatusForbidden, serve(t, http.MethodPut, "/orgs/10", organizationRequest{Name: "Renamed"}).Code)
+
+	expectMembership(ROLE_ADMIN)
+	mockRepo.EXPECT().updateOrganization(organization.Id, organizationRequest{Name: "Renamed"}).Return(nil)
+	assert.Equal(t, http.StatusOK, serve(t, http.MethodPut, "/orgs/10", organizationRequest{Name: "Renamed"}).Code)
+
+	expectMembership(ROLE_ADMIN)
+	assert.Equal(t, http.StatusForbidden, serve(t, http.MethodDelete, "/orgs/10", nil).Code, "Only owners should delete the organization")
+
+	expectMembership(ROLE_OWNER)
+	mockRepo.EXPECT().deleteOrganization(organization.Id).Return(nil)
+	assert.Equal(t, http.StatusOK, serve(t, http.MethodDelete, "/orgs/10", nil).Code)
+
+	testOrgHandlerEnd()
+}
+
+func TestMembers(t *testing.T) {
+	testOrgHandlerInit(t)
+
+	expectMembership(ROLE_MEMBER)
+	mockRepo.EXPECT().getMembers(organization.Id).Return([]Member{{UserId: 1, Role: ROLE_MEMBER}}, nil)
+	assert.Equal(t, http.StatusOK, serve(t, http.MethodGet, "/orgs/10/members", nil).Code)
+
+	expectMembership(ROLE_OWNER)
+	mockRepo.EXPECT().updateMemberRole(organization.Id, 2, ROLE_ADMIN).Return(nil)
+	assert.Equal(t, http.StatusOK, serve(t, http.MethodPut, "/orgs/10/members/2", memberRoleRequest{Role: ROLE_ADMIN}).Code)
+
+	expectMembership(ROLE_OWNER)
+	assert.Equal(t, http.StatusBadRequest, serve(t, http.MethodPut, "/orgs/10/members/2", memberRoleRequest{Role: "boss"}).Code)
+
+	expectMembership(ROLE_OWNER)
+	mockRepo.EXPECT().updateMemberRole(organization.Id, 1, ROLE_MEMBER).Return(errLastOwner)
+	assert.Equal(t, http.StatusBadRequest, serve(t, http.MethodPut, "/orgs/10/members/1", memberRoleRequest{Role: ROLE_MEMBER}).Code)
+
+	expectMembership(ROLE_ADMIN)
+	assert.Equal(t, http.StatusForbidden, serve(t, http.MethodPut, "/orgs/10/members/2", memberRoleRequest{Role: ROLE_ADMIN}).Code)
+
+	testOrgHandlerEnd()
+}
+
+func TestRemoveMember(t *testing.T) {
+	testOrgHandlerInit(t)
+
+	expectMembership(ROLE_MEMBER)
+	mockRepo.EXPECT().removeMember(organization.Id, sessionUser.Id).Return(nil)
+	assert.Equal(t, http.StatusOK, serve(t, http.MethodDelete, "/orgs/10/members/1", nil).Code, "Members should be able to leave")
+
+	expectMembership(ROLE_MEMBER)
+	mockRepo.EXPECT().getMembership(organization.Id, 2).Return(&Membership{Organization: organization, Role: ROLE_MEMBER}, nil)
+	assert.Equal(t, http.StatusForbidden, serve(t, http.MethodDelete, "/orgs/10/members/2", nil).Code)
+
+	expectMembership(ROLE_ADMIN)
+	mockRepo.EXPECT().getMembership(organization.Id, 2).Return(&Membership{Organization: organization, Role: ROLE_OWNER}, nil)
+	assert.Equal(t, http.StatusForbidden, serve(t, http.MethodDelete, "/orgs/10/members/2", nil).Code, "Admins should not remove owners")
+
+	expectMembership(ROLE_ADMIN)
+	mockRepo.EXPECT().getMembership(organization.Id, 2).Return(&Membership{Organization: organization, Role: ROLE_MEMBER}, nil)
+	mockRepo.EXPECT().removeMember(organization.Id, 2).Return(nil)
+	assert.Equal(t, http.StatusOK, serve(t, http.MethodDelete, "/orgs/10/members/2", nil).Code)
+
+	expectMembership(ROLE_OWNER)
+	mockRepo.EXPECT().removeMember(organization.Id, sessionUser.Id).Return(errLastOwner)
+	assert.Equal(t, http.StatusBadRequest, serve(t, http.MethodDelete, "/orgs/10/members/1", nil).Code, "Last owner should not leave")
+
+	testOrgHandlerEnd()
+}
+
+func TestInviteMember(t *testing.T) {
+	testOrgHandlerInit(t)
+	inviteReq := invitationRequest{Email: "invited@example.com", Role: ROLE_MEMBER}
+	invitation := Invitation{Id: 5, OrganizationId: organization.Id, OrganizationName: organization.Name, Email: inviteReq.Email, Role: inviteReq.Role}
+
+	gomock.InOrder(
+		expectMembership(ROLE_ADMIN),
+		mockRepo.EXPECT().createInvitation(organization.Id, sessionUser.Id, inviteReq).Return(&invitation, nil),
+		mockMailer.EXPECT().Send(inviteReq.Email, gomock.Any(), gomock.Any()).DoAndReturn(func(recipient string, subject string, body string) error {
+			assert.Contains(t, subject, organization.Name)
+			assert.Contains(t, body, "/invitations")
+			return nil
+		}),
+	)
+	assert.Equal(t, http.StatusOK, serve(t, http.MethodPost, "/orgs/10/invitations", inviteReq).Code)
+
+	expectMembership(ROLE_ADMIN)
+	assert.Equal(t, http.StatusForbidden, serve(t, http.MethodPost, "/orgs/10/invitations", invitationRequest{Email: "invited@example.com", Role: ROLE_OWNER}).Code, "Admins should not invite owners")
+
+	expectMembership(ROLE_ADMIN)
+	assert.Equal(t, http.StatusBadRequest, serve(t, http.MethodPost, "/orgs/10/invitations", invitationRequest{Email: "invited", Role: ROLE_MEMBER}).Code)
+
+	expectMembership(ROLE_ADMIN)
+	mockRepo.EXPECT().createInvitation(organization.Id, sessionUser.Id, inviteReq).Return(nil, errAlreadyMember)
+	assert.Equal(t, http.StatusBadRequest, serve(t, http.MethodPost, "/orgs/10/invitations", inviteReq).Code)
+
+	gomock.InOrder(
+		expectMembership(ROLE_ADMIN),
+		mockRepo.EXPECT().createInvitation(organization.Id, sessionUser.Id, inviteReq).Return(&invitation, nil),
+		mockMailer.EXPECT().Send(inviteReq.Email, gomock.Any(), gomock.Any()).Return(errors.New("")),
+	)
+	assert.Equal(t, http.StatusInternalServerError, serve(t, http.MethodPost, "/orgs/10/invitations", inviteReq).Code)
+
+	expectMembership(ROLE_ADMIN)
+	mockRepo.EXPECT().getInvitations(organization.Id).Return([]Invitation{invitation}, nil)
+	assert.Equal(t, http.StatusOK, serve(t, http.MethodGet, "/orgs/10/invitations", nil).Code)
+
+	expectMembership(ROLE_ADMIN)
+	mockRepo.EXPECT().deleteInvitation(organization.Id, 5).Return(nil)
+	assert.Equal(t, http.StatusOK, serve(t, http.MethodDelete, "/orgs/10/invitations/5", nil).Code)
+
+	testOrgHandlerEnd()
+}
+
+func TestRespondToInvitation(t *testing.T) {
+	testOrgHandlerInit(t)
+
+	mockRepo.EXPECT().getUserInvitations(tenancy.DEFAULT_TENANT_ID, sessionUser.Email).Return([]Invitation{}, nil)
+	assert.Equal(t, http.StatusOK, serve(t, http.MethodGet, "/me/orgs/invitations", nil).Code)
+
+	mockRepo.EXPECT().acceptInvitation(tenancy.DEFAULT_TENANT_ID, 5, sessionUser.Id, sessionUser.Email).Return(organization.Id, nil)
+	res := serve(t, http.MethodPost, "/me/orgs/invitations/5/accept", nil)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.JSONEq(t, `{"organization_id": 10}`, res.Body.String())
+
+	mockRepo.EXPECT().acceptInvitation(tenancy.DEFAULT_TENANT_ID, 6, sessionUser.Id, sessionUser.Email).Return(0, sql.ErrNoRows)
+	assert.Equal(t, http.StatusBadRequest, serve(t, http.MethodPost, "/me/orgs/invitations/6/accept", nil).Code)
+
+	mockRepo.EXPECT().declineInvitation(tenancy.DEFAULT_TENANT_ID, 7, sessionUser.Email).Return(nil)
+	assert.Equal(t, http.StatusOK, serve(t, http.MethodPost, "/me/orgs/invitations/7/decline", nil).Code)
+
+	testOrgHandlerEnd()
+}
+
+func TestRespondToInvitationOfAnotherTenant(t *testing.T) {
+	testOrgHandlerInit(t)
+	otherTenant := tenancy.Tenant{Id: 2, Slug: "other"}
+
+	serveInTenant := func(method string, url string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, url, nil)
+		require.Nil(t, err)
+		req = req.WithContext(context.WithValue(req.Context(), "tenant", &otherTenant))
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		return res
+	}
+
+	mockRepo.EXPECT().getUserInvitations(otherTenant.Id, sessionUser.Email).Return([]Invitation{}, nil)
+	res := serveInTenant(http.MethodGet, "/me/orgs/invitations")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.JSONEq(t, `{"invitations": []}`, res.Body.String())
+
+	mockRepo.EXPECT().acceptInvitation(otherTenant.Id, 5, sessionUser.Id, sessionUser.Email).Return(0, sql.ErrNoRows)
+	assert.Equal(t, http.StatusBadRequest, serveInTenant(http.MethodPost, "/me/orgs/invitations/5/accept").Code)
+
+	mockRepo.EXPECT().declineInvitation(otherTenant.Id, 5, sessionUser.Email).Return(sql.ErrNoRows)
+	assert.Equal(t, http.StatusBadRequest, serveInTenant(http.MethodPost, "/me/orgs/invitations/5/decline").Code)
+
+	testOrgHandlerEnd()
+}
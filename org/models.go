@@ -0,0 +1,92 @@
+package org
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	ROLE_OWNER  = "owner"
+	ROLE_ADMIN  = "admin"
+	ROLE_MEMBER = "member"
+
+	INVITATION_EXPIRATION_DAYS = 7
+)
+
+var roleRanks = map[string]int{
+	ROLE_MEMBER: 1,
+	ROLE_ADMIN:  2,
+	ROLE_OWNER:  3,
+}
+
+type Organization struct {
+	Id        int       `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// Membership is an organization seen by one of its members.
+type Membership struct {
+	Organization
+	Role string `json:"role"`
+}
+
+// hasRole tells whether the member's role is at least the given one.
+func (membership Membership) hasRole(role string) bool {
+	return roleRanks[membership.Role] >= roleRanks[role]
+}
+
+type Member struct {
+	UserId   int       `json:"user_id" db:"user_id"`
+	Fullname string    `json:"fullname"`
+	Email    string    `json:"email"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at" db:"joined_at"`
+}
+
+type Invitation struct {
+	Id               int       `json:"id"`
+	OrganizationId   int       `json:"organization_id" db:"organization_id"`
+	OrganizationName string    `json:"organization_name" db:"organization_name"`
+	Email            string    `json:"email"`
+	Role             string    `json:"role"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+	ExpiresAt        time.Time `json:"expires_at" db:"expires_at"`
+}
+
+type organizationRequest struct {
+	Name string `json:"name"`
+}
+
+func (req organizationRequest) isValid() bool {
+	return strings.TrimSpace(req.Name) != "" && len(req.Name) <= 128
+}
+
+type invitationRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+func (req invitationRequest) isValid() bool {
+	emailFormatValid := regexp.MustCompile(`^[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,4}$`).MatchString(req.Email)
+	return len(req.Email) <= 128 && emailFormatValid && isValidRole(req.Role)
+}
+
+type memberRoleRequest struct {
+	Role string `json:"role"`
+}
+
+type activeOrganizationRequest struct {
+	OrganizationId int `json:"organization_id"`
+}
+
+type membershipListResponse struct {
+	Organizations        []Membership `json:"organizations"`
+	ActiveOrganizationId int          `json:"active_organization_id"`
+}
+
+func isValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
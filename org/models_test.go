@@ -0,0 +1,28 @@
+package org
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMembershipHasRole(t *testing.T) {
+	owner := Membership{Role: ROLE_OWNER}
+	assert.True(t, owner.hasRole(ROLE_OWNER))
+	assert.True(t, owner.hasRole(ROLE_MEMBER))
+
+	member := Membership{Role: ROLE_MEMBER}
+	assert.True(t, member.hasRole(ROLE_MEMBER))
+	assert.False(t, member.hasRole(ROLE_ADMIN))
+
+	assert.False(t, Membership{Role: "unknown"}.hasRole(ROLE_MEMBER))
+}
+
+func TestOrganizationRequestValidity(t *testing.T) {
+	assert.True(t, organizationRequest{Name: "Example"}.isValid())
+	assert.False(t, organizationRequest{Name: "  "}.isValid())
+
+	assert.True(t, invitationRequest{Email: "user@example.com", Role: ROLE_ADMIN}.isValid())
+	assert.False(t, invitationRequest{Email: "user@example.com", Role: "boss"}.isValid())
+	assert.False(t, invitationRequest{Email: "user", Role: ROLE_MEMBER}.isValid())
+}
@@ -0,0 +1,269 @@
+package org
+
+import (
+	"database/sql"
+	"errors"
+	"userland/appcontext"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	INSERT_ORGANIZATION_QUERY          = "INSERT INTO organization (tenant_id, name) SELECT tenant_id, $2 FROM \"user\" WHERE id=$1 RETURNING id, name, created_at"
+	UPDATE_ORGANIZATION_QUERY          = "UPDATE organization SET name=$1 WHERE id=$2"
+	DELETE_ORGANIZATION_QUERY          = "DELETE FROM organization WHERE id=$1"
+	SELECT_MEMBERSHIP_QUERY            = "SELECT organization.id, organization.name, organization.created_at, organization_member.role FROM organization JOIN organization_member ON organization_member.organization_id=organization.id WHERE organization.id=$1 AND organization_member.user_id=$2"
+	SELECT_MEMBERSHIPS_QUERY           = "SELECT organization.id, organization.name, organization.created_at, organization_member.role FROM organization JOIN organization_member ON organization_member.organization_id=organization.id WHERE organization_member.user_id=$1 ORDER BY organization.name, organization.id"
+	SELECT_MEMBERS_QUERY               = "SELECT \"user\".id AS user_id, \"user\".fullname, \"user\".email, organization_member.role, organization_member.created_at AS joined_at FROM organization_member JOIN \"user\" ON \"user\".id=organization_member.user_id WHERE organization_member.organization_id=$1 ORDER BY organization_member.created_at, \"user\".id"
+	INSERT_MEMBER_QUERY                = "INSERT INTO organization_member (organization_id, user_id, role) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING"
+	SELECT_MEMBER_ROLE_QUERY           = "SELECT role FROM organization_member WHERE organization_id=$1 AND user_id=$2 FOR UPDATE"
+	SELECT_OWNERS_QUERY                = "SELECT user_id FROM organization_member WHERE organization_id=$1 AND role='" + ROLE_OWNER + "' FOR UPDATE"
+	UPDATE_MEMBER_ROLE_QUERY           = "UPDATE organization_member SET role=$1 WHERE organization_id=$2 AND user_id=$3"
+	DELETE_MEMBER_QUERY                = "DELETE FROM organization_member WHERE organization_id=$1 AND user_id=$2"
+	SELECT_IS_MEMBER_BY_EMAIL_QUERY    = "SELECT EXISTS (SELECT 1 FROM organization_member JOIN \"user\" ON \"user\".id=organization_member.user_id WHERE organization_member.organization_id=$1 AND lower(\"user\".email)=lower($2))"
+	UPSERT_INVITATION_QUERY            = "INSERT INTO organization_invitation (organization_id, email, role, invited_by, expires_at) VALUES ($1, lower($2), $3, $4, now() + $5 * interval '1 day') ON CONFLICT (organization_id, email) DO UPDATE SET role=EXCLUDED.role, invited_by=EXCLUDED.invited_by, created_at=now(), expires_at=EXCLUDED.expires_at RETURNING id"
+	INVITATION_COLUMNS                 = "organization_invitation.id, organization_invitation.organization_id, organization.name AS organization_name, organization_invitation.email, organization_invitation.role, organization_invitation.created_at, organization_invitation.expires_at"
+	SELECT_INVITATION_QUERY            = "SELECT " + INVITATION_COLUMNS + " FROM organization_invitation JOIN organization ON organization.id=organization_invitation.organization_id WHERE organization_invitation.id=$1"
+	SELECT_INVITATIONS_QUERY           = "SELECT " + INVITATION_COLUMNS + " FROM organization_invitation JOIN organization ON organization.id=organization_invitation.organization_id WHERE organization_invitation.organization_id=$1 AND organization_invitation.expires_at > now() ORDER BY organization_invitation.created_at DESC"
+	SELECT_USER_INVITATIONS_QUERY      = "SELECT " + INVITATION_COLUMNS + " FROM organization_invitation JOIN organization ON organization.id=organization_invitation.organization_id WHERE organization_invitation.email=lower($1) AND organization.tenant_id=$2 AND organization_invitation.expires_at > now() ORDER BY organization_invitation.created_at DESC"
+	SELECT_PENDING_INVITATION_QUERY    = "SELECT organization_invitation.organization_id, organization_invitation.role FROM organization_invitation JOIN organization ON organization.id=organization_invitation.organization_id WHERE organization_invitation.id=$1 AND organization_invitation.email=lower($2) AND organization.tenant_id=$3 AND organization_invitation.expires_at > now() FOR UPDATE OF organization_invitation"
+	DELETE_INVITATION_QUERY            = "DELETE FROM organization_invitation WHERE organization_id=$1 AND id=$2"
+	DELETE_INVITATION_BY_EMAIL_QUERY   = "DELETE FROM organization_invitation USING organization WHERE organization.id=organization_invitation.organization_id AND organization_invitation.id=$1 AND organization_invitation.email=lower($2) AND organization.tenant_id=$3"
+	DELETE_INVITATION_AFTER_JOIN_QUERY = "DELETE FROM organization_invitation WHERE id=$1"
+)
+
+var (
+	errLastOwner     = errors.New("Organization would be left without an owner")
+	errAlreadyMember = errors.New("User is already a member of the organization")
+)
+
+type orgRepositoryInterface interface {
+	createOrganization(userId int, req organizationRequest) (*Organization, error)
+	updateOrganization(organizationId int, req organizationRequest) error
+	deleteOrganization(organizationId int) error
+	getMembership(organizationId int, userId int) (*Membership, error)
+	getMemberships(userId int) ([]Membership, error)
+	getMembers(organizationId int) ([]Member, error)
+	updateMemberRole(organizationId int, userId int, role string) error
+	removeMember(organizationId int, userId int) error
+	createInvitation(organizationId int, invitedBy int, req invitationRequest) (*Invitation, error)
+	getInvitations(organizationId int) ([]Invitation, error)
+	deleteInvitation(organizationId int, invitationId int) error
+	getUserInvitations(tenantId int, email string) ([]Invitation, error)
+	acceptInvitation(tenantId int, invitationId int, userId int, email string) (int, error)
+	declineInvitation(tenantId int, invitationId int, email string) error
+}
+
+type orgRepository struct {
+	db *sqlx.DB
+}
+
+func GetOrgRepository() *orgRepository {
+	repo := orgRepository{appcontext.GetDB()}
+	return &repo
+}
+
+// createOrganization creates the organization with the user as its owner, in
+// the user's tenant.
+func (repo *orgRepository) createOrganization(userId int, req organizationRequest) (*Organization, error) {
+	tx, err := repo.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var organization Organization
+	err = tx.Get(&organization, INSERT_ORGANIZATION_QUERY, userId, req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(INSERT_MEMBER_QUERY, organization.Id, userId, ROLE_OWNER)
+	if err != nil {
+		return nil, err
+	}
+	return &organization, tx.Commit()
+}
+
+func (repo *orgRepository) updateOrganization(organizationId int, req organizationRequest) error {
+	return execForRow(repo.db, UPDATE_ORGANIZATION_QUERY, req.Name, organizationId)
+}
+
+func (repo *orgRepository) deleteOrganization(organizationId int) error {
+	return execForRow(repo.db, DELETE_ORGANIZATION_QUERY, organizationId)
+}
+
+// getMembership returns sql.ErrNoRows when the organization doesn't exist or
+// the user isn't one of its members.
+func (repo *orgRepository) getMembership(organizationId int, userId int) (*Membership, error) {
+	var membership Membership
+	err := repo.db.Get(&membership, SELECT_MEMBERSHIP_QUERY, organizationId, userId)
+	if err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+func (repo *orgRepository) getMemberships(userId int) ([]Membership, error) {
+	memberships := []Membership{}
+	err := repo.db.Select(&memberships, SELECT_MEMBERSHIPS_QUERY, userId)
+	return memberships, err
+}
+
+func (repo *orgRepository) getMembers(organizationId int) ([]Member, error) {
+	members := []Member{}
+	err := repo.db.Select(&members, SELECT_MEMBERS_QUERY, organizationId)
+	return members, err
+}
+
+func (repo *orgRepository) updateMemberRole(organizationId int, userId int, role string) error {
+	tx, err := repo.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = ensureOwnerRemains(tx, organizationId, userId, role)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(UPDATE_MEMBER_ROLE_QUERY, role, organizationId, userId)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (repo *orgRepository) removeMember(organizationId int, userId int) error {
+	tx, err := repo.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = ensureOwnerRemains(tx, organizationId, userId, "")
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(DELETE_MEMBER_QUERY, organizationId, userId)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// createInvitation invites the email address to the organization. Inviting
+// an address again replaces the pending invitation and restarts its expiry.
+func (repo *orgRepository) createInvitation(organizationId int, invitedBy int, req invitationRequest) (*Invitation, error) {
+	var isMember bool
+	err := repo.db.Get(&isMember, SELECT_IS_MEMBER_BY_EMAIL_QUERY, organizationId, req.Email)
+	if err != nil {
+		return nil, err
+	}
+	if isMember {
+		return nil, errAlreadyMember
+	}
+
+	var invitationId int
+	err = repo.db.Get(&invitationId, UPSERT_INVITATION_QUERY, organizationId, req.Email, req.Role, invitedBy, INVITATION_EXPIRATION_DAYS)
+	if err != nil {
+		return nil, err
+	}
+
+	var invitation Invitation
+	err = repo.db.Get(&invitation, SELECT_INVITATION_QUERY, invitationId)
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (repo *orgRepository) getInvitations(organizationId int) ([]Invitation, error) {
+	invitations := []Invitation{}
+	err := repo.db.Select(&invitations, SELECT_INVITATIONS_QUERY, organizationId)
+	return invitations, err
+}
+
+func (repo *orgRepository) deleteInvitation(organizationId int, invitationId int) error {
+	return execForRow(repo.db, DELETE_INVITATION_QUERY, organizationId, invitationId)
+}
+
+// getUserInvitations lists the invitations sent to the email address by the
+// tenant's organizations. The same address may be invited in other tenants.
+func (repo *orgRepository) getUserInvitations(tenantId int, email string) ([]Invitation, error) {
+	invitations := []Invitation{}
+	err := repo.db.Select(&invitations, SELECT_USER_INVITATIONS_QUERY, email, tenantId)
+	return invitations, err
+}
+
+// acceptInvitation makes the user a member with the invited role and returns
+// the organization joined. The invitation has to be addressed to the email
+// by an organization of the user's tenant.
+func (repo *orgRepository) acceptInvitation(tenantId int, invitationId int, userId int, email string) (int, error) {
+	tx, err := repo.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var invitation Invitation
+	err = tx.Get(&invitation, SELECT_PENDING_INVITATION_QUERY, invitationId, email, tenantId)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(INSERT_MEMBER_QUERY, invitation.OrganizationId, userId, invitation.Role)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(DELETE_INVITATION_AFTER_JOIN_QUERY, invitationId)
+	if err != nil {
+		return 0, err
+	}
+	return invitation.OrganizationId, tx.Commit()
+}
+
+func (repo *orgRepository) declineInvitation(tenantId int, invitationId int, email string) error {
+	return execForRow(repo.db, DELETE_INVITATION_BY_EMAIL_QUERY, invitationId, email, tenantId)
+}
+
+// ensureOwnerRemains locks the member's row and refuses to take the owner
+// role from the last owner of the organization. An empty role stands for the
+// member leaving. It returns sql.ErrNoRows when the user isn't a member.
+func ensureOwnerRemains(tx *sqlx.Tx, organizationId int, userId int, role string) error {
+	var currentRole string
+	err := tx.Get(&currentRole, SELECT_MEMBER_ROLE_QUERY, organizationId, userId)
+	if err != nil {
+		return err
+	}
+	if currentRole != ROLE_OWNER || role == ROLE_OWNER {
+		return nil
+	}
+
+	owners := []int{}
+	err = tx.Select(&owners, SELECT_OWNERS_QUERY, organizationId)
+	if err != nil {
+		return err
+	}
+	if len(owners) <= 1 {
+		return errLastOwner
+	}
+	return nil
+}
+
+// execForRow runs a statement on a single row, returning sql.ErrNoRows when
+// the row doesn't exist.
+func execForRow(db *sqlx.DB, query string, args ...interface{}) error {
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err == nil && affected == 0 {
+		return sql.ErrNoRows
+	}
+	return err
+}
@@ -0,0 +1,151 @@
+package profile
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+)
+
+const (
+	AVATAR_FORMAT_PNG   = "png"
+	AVATAR_FORMAT_SVG   = "svg"
+	AVATAR_DEFAULT_SIZE = 256
+
+	// AVATAR_STYLE goes into every avatar hash, so that changing the way
+	// avatars look also changes their URLs and ETags.
+	AVATAR_STYLE = "identicon-1"
+
+	// Avatars are a grid of cells, mirrored around the middle column, with
+	// half a cell of margin around it.
+	AVATAR_GRID = 5
+)
+
+var (
+	avatarBackground = color.NRGBA{R: 0xf0, G: 0xf0, B: 0xf0, A: 0xff}
+
+	avatarContentTypes = map[string]string{
+		AVATAR_FORMAT_PNG: "image/png",
+		AVATAR_FORMAT_SVG: "image/svg+xml",
+	}
+)
+
+// avatar is the identicon of a user without a picture. It only depends on
+// the user ID, so it never changes for a user.
+type avatar struct {
+	Color color.NRGBA
+	Cells [AVATAR_GRID][AVATAR_GRID]bool
+}
+
+// avatarHash stands for the picture hash of users without a picture, in
+// their picture URL and ETag.
+func avatarHash(userId int) string {
+	return hashPicture([]byte(fmt.Sprintf("%s:%d", AVATAR_STYLE, userId)))
+}
+
+func newAvatar(hash string) avatar {
+	seed, _ := hex.DecodeString(hash)
+	var a avatar
+
+	bit := 0
+	for column := 0; column < (AVATAR_GRID+1)/2; column++ {
+		for row := 0; row < AVATAR_GRID; row++ {
+			filled := seed[bit/8]&(1<<uint(bit%8)) != 0
+			a.Cells[row][column] = filled
+			a.Cells[row][AVATAR_GRID-1-column] = filled
+			bit++
+		}
+	}
+
+	hue := float64(int(seed[len(seed)-2])<<8|int(seed[len(seed)-1])) / 65536 * 360
+	a.Color = hslColor(hue, 0.55, 0.55)
+	return a
+}
+
+func (a avatar) render(format string, size int) ([]byte, error) {
+	if format == AVATAR_FORMAT_SVG {
+		return a.svg(size), nil
+	}
+	return a.png(size)
+}
+
+func (a avatar) png(size int) ([]byte, error) {
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{avatarBackground, a.Color})
+	// A cell spans from one grid line to the next, grid lines being placed on
+	// a scale of AVATAR_GRID+1 units so that the margins are half a unit.
+	line := func(position int) int {
+		return int(math.Round((float64(position) + 0.5) * float64(size) / (AVATAR_GRID + 1)))
+	}
+	for row := 0; row < AVATAR_GRID; row++ {
+		for column := 0; column < AVATAR_GRID; column++ {
+			if !a.Cells[row][column] {
+				continue
+			}
+			for y := line(row); y < line(row+1); y++ {
+				for x := line(column); x < line(column+1); x++ {
+					img.SetColorIndex(x, y, 1)
+				}
+			}
+		}
+	}
+
+	var buffer bytes.Buffer
+	err := png.Encode(&buffer, img)
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (a avatar) svg(size int) []byte {
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		size, size, AVATAR_GRID+1, AVATAR_GRID+1)
+	fmt.Fprintf(&buffer, `<rect width="100%%" height="100%%" fill="%s"/>`, hexColor(avatarBackground))
+	fmt.Fprintf(&buffer, `<g fill="%s">`, hexColor(a.Color))
+	for row := 0; row < AVATAR_GRID; row++ {
+		for column := 0; column < AVATAR_GRID; column++ {
+			if a.Cells[row][column] {
+				fmt.Fprintf(&buffer, `<rect x="%d.5" y="%d.5" width="1" height="1"/>`, column, row)
+			}
+		}
+	}
+	buffer.WriteString(`</g></svg>`)
+	return buffer.Bytes()
+}
+
+func hexColor(c color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// hslColor converts a hue in degrees, saturation and lightness, which keeps
+// the colors of avatars equally readable whatever their hue.
+func hslColor(hue float64, saturation float64, lightness float64) color.NRGBA {
+	chroma := (1 - math.Abs(2*lightness-1)) * saturation
+	x := chroma * (1 - math.Abs(math.Mod(hue/60, 2)-1))
+	var r, g, b float64
+	switch {
+	case hue < 60:
+		r, g, b = chroma, x, 0
+	case hue < 120:
+		r, g, b = x, chroma, 0
+	case hue < 180:
+		r, g, b = 0, chroma, x
+	case hue < 240:
+		r, g, b = 0, x, chroma
+	case hue < 300:
+		r, g, b = x, 0, chroma
+	default:
+		r, g, b = chroma, 0, x
+	}
+	m := lightness - chroma/2
+	return color.NRGBA{
+		R: uint8(math.Round((r + m) * 255)),
+		G: uint8(math.Round((g + m) * 255)),
+		B: uint8(math.Round((b + m) * 255)),
+		A: 0xff,
+	}
+}
@@ -0,0 +1,51 @@
+package profile
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAvatar(t *testing.T) {
+	assert.Equal(t, avatarHash(2), avatarHash(2))
+	assert.NotEqual(t, avatarHash(2), avatarHash(3))
+
+	a := newAvatar(avatarHash(2))
+	assert.Equal(t, a, newAvatar(avatarHash(2)))
+	for row := 0; row < AVATAR_GRID; row++ {
+		for column := 0; column < AVATAR_GRID; column++ {
+			assert.Equal(t, a.Cells[row][column], a.Cells[row][AVATAR_GRID-1-column])
+		}
+	}
+
+	content, err := a.render(AVATAR_FORMAT_PNG, 60)
+	require.Nil(t, err)
+	img := decodeTestPicture(t, content)
+	require.Equal(t, image.Rect(0, 0, 60, 60), img.Bounds())
+	// Cells are 10 pixels wide, after a margin of 5 pixels.
+	for row := 0; row < AVATAR_GRID; row++ {
+		for column := 0; column < AVATAR_GRID; column++ {
+			expected := color.Color(avatarBackground)
+			if a.Cells[row][column] {
+				expected = a.Color
+			}
+			assert.Equal(t, color.NRGBAModel.Convert(expected), color.NRGBAModel.Convert(img.At(10+10*column, 10+10*row)))
+		}
+	}
+	assert.Equal(t, color.NRGBAModel.Convert(avatarBackground), color.NRGBAModel.Convert(img.At(2, 2)))
+
+	content, err = a.render(AVATAR_FORMAT_SVG, 64)
+	require.Nil(t, err)
+	assert.Contains(t, string(content), `<svg xmlns="http://www.w3.org/2000/svg" width="64" height="64"`)
+	assert.Contains(t, string(content), hexColor(a.Color))
+}
+
+func TestHSLColor(t *testing.T) {
+	assert.Equal(t, color.NRGBA{R: 255, G: 0, B: 0, A: 255}, hslColor(0, 1, 0.5))
+	assert.Equal(t, color.NRGBA{R: 0, G: 255, B: 0, A: 255}, hslColor(120, 1, 0.5))
+	assert.Equal(t, color.NRGBA{R: 0, G: 0, B: 255, A: 255}, hslColor(240, 1, 0.5))
+	assert.Equal(t, color.NRGBA{R: 128, G: 128, B: 128, A: 255}, hslColor(90, 0, 0.5))
+}
@@ -0,0 +1,291 @@
+package profile
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+	"userland/auth"
+	"userland/config"
+	"userland/mailer"
+	"userland/storage"
+
+	"github.com/jmoiron/sqlx/types"
+	"github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DATA_EXPORT_PENDING    = "pending"
+	DATA_EXPORT_READY      = "ready"
+	DATA_EXPORT_FAILED     = "failed"
+	DATA_EXPORT_DOWNLOADED = "downloaded"
+
+	DATA_EXPORT_TOKEN_BYTES = 32
+	DATA_EXPORT_BATCH_SIZE  = 10
+
+	EXPORT_DATA_FILE    = "data.json"
+	EXPORT_PICTURE_FILE = "picture"
+)
+
+var pictureExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+type DataExport struct {
+	Id        int       `json:"id"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// pendingDataExport is an export claimed by DataExporter to be built.
+type pendingDataExport struct {
+	Id       int `db:"id"`
+	UserId   int `db:"user_id"`
+	TenantId int `db:"tenant_id"`
+}
+
+// exportData is the content of the archive's data file. It's made of
+// dedicated types listing every field, so that password hashes and tokens
+// never make it into an export.
+type exportData struct {
+	Profile     exportProfile      `json:"profile"`
+	Sessions    []exportSession    `json:"sessions"`
+	AuditEvents []exportAuditEvent `json:"audit_events"`
+	Identities  exportIdentities   `json:"identities"`
+	ExportedAt  time.Time          `json:"exported_at"`
+}
+
+type exportProfile struct {
+	Id        int       `json:"id"`
+	Fullname  string    `json:"fullname"`
+	Email     string    `json:"email"`
+	Location  string    `json:"location"`
+	Bio       string    `json:"bio"`
+	Web       string    `json:"web"`
+	Verified  bool      `json:"verified"`
+	Picture   string    `json:"picture,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type exportSession struct {
+	UserAgent  string     `json:"user_agent" db:"user_agent"`
+	IPAddress  string     `json:"ip_address" db:"ip_address"`
+	CreatedAt  *time.Time `json:"created_at" db:"created_at"`
+	LastSeenAt *time.Time `json:"last_seen_at" db:"last_seen_at"`
+}
+
+type exportAuditEvent struct {
+	Action    string         `json:"action"`
+	Details   types.JSONText `json:"details"`
+	CreatedAt *time.Time     `json:"created_at" db:"created_at"`
+}
+
+type exportIdentities struct {
+	ExternalId          string             `json:"external_id,omitempty"`
+	WebAuthnCredentials []exportCredential `json:"webauthn_credentials"`
+}
+
+type exportCredential struct {
+	Transports pq.StringArray `json:"transports"`
+	CreatedAt  *time.Time     `json:"created_at" db:"created_at"`
+	LastUsedAt *time.Time     `json:"last_used_at" db:"last_used_at"`
+}
+
+func newExportProfile(user *auth.User) exportProfile {
+	return exportProfile{
+		Id:        user.Id,
+		Fullname:  user.Fullname,
+		Email:     user.Email,
+		Location:  user.Location.String,
+		Bio:       user.Bio.String,
+		Web:       user.Web.String,
+		Verified:  user.Verified,
+		CreatedAt: user.CreatedAt,
+	}
+}
+
+// buildArchive zips the data file together with the raw profile picture,
+// if the user has one.
+func buildArchive(data exportData, picture []byte) ([]byte, error) {
+	if len(picture) > 0 {
+		extension, ok := pictureExtensions[http.DetectContentType(picture)]
+		if !ok {
+			extension = ".bin"
+		}
+		data.Profile.Picture = EXPORT_PICTURE_FILE + extension
+	}
+
+	encoded, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+	archive := zip.NewWriter(&buffer)
+	files := []struct {
+		name    string
+		content []byte
+	}{
+		{EXPORT_DATA_FILE, encoded},
+		{data.Profile.Picture, picture},
+	}
+	for _, file := range files {
+		if file.name == "" {
+			continue
+		}
+		writer, err := archive.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: data.ExportedAt})
+		if err != nil {
+			return nil, err
+		}
+		_, err = writer.Write(file.content)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = archive.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// hashExportToken is how download tokens are stored, so that the database
+// alone doesn't give the archives away.
+func hashExportToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// dataExportKey is where the archive of an export is kept.
+func dataExportKey(export pendingDataExport) string {
+	return fmt.Sprintf("exports/%d/%d/%d.zip", export.TenantId, export.UserId, export.Id)
+}
+
+// DataExporter builds the requested data exports in the background and
+// emails each user a link to download theirs. Requests wait in the database
+// until they're built, so those made before a restart are built after it.
+type DataExporter struct {
+	ProfileRepo profileRepositoryInterface
+	Store       storage.BlobStore
+	Mailer      mailer.Mailer
+	Interval    time.Duration
+}
+
+// Run builds the pending exports right away and then on every interval,
+// until the stop channel is closed.
+func (exporter DataExporter) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(exporter.Interval)
+	defer ticker.Stop()
+
+	for {
+		exporter.exportPending()
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// exportPending builds every pending export. Exports that can't be built are
+// marked as failed, so that their users can ask again.
+func (exporter DataExporter) exportPending() {
+	exported := 0
+	for {
+		exports, err := exporter.ProfileRepo.claimDataExports(DATA_EXPORT_BATCH_SIZE)
+		if err != nil {
+			log.Warn(err)
+			return
+		}
+		if len(exports) == 0 {
+			break
+		}
+
+		for _, export := range exports {
+			err = exporter.export(export)
+			if err != nil {
+				log.Warn(err)
+				err = exporter.ProfileRepo.failDataExport(export.Id)
+				if err != nil {
+					log.Warn(err)
+				}
+				continue
+			}
+			exported++
+		}
+	}
+
+	if exported > 0 {
+		log.Info(fmt.Sprintf("Built %d data exports", exported))
+	}
+}
+
+// export builds and stores the archive, then emails the link to download it.
+// The export stays ready when the email can't be sent.
+func (exporter DataExporter) export(export pendingDataExport) error {
+	user, err := exporter.ProfileRepo.getExportUser(export.TenantId, export.UserId)
+	if err != nil {
+		return err
+	}
+	tenant, err := exporter.ProfileRepo.getExportTenant(export.TenantId)
+	if err != nil {
+		return err
+	}
+	data, err := exporter.ProfileRepo.getExportData(user)
+	if err != nil {
+		return err
+	}
+	var picture []byte
+	if user.PictureKey.Valid {
+		picture, err = exporter.Store.Get(user.PictureKey.String)
+		if err != nil {
+			return err
+		}
+	}
+	archive, err := buildArchive(*data, picture)
+	if err != nil {
+		return err
+	}
+
+	token := make([]byte, DATA_EXPORT_TOKEN_BYTES)
+	_, err = rand.Read(token)
+	if err != nil {
+		return err
+	}
+	encodedToken := hex.EncodeToString(token)
+
+	key := dataExportKey(export)
+	err = exporter.Store.Put(key, archive, "application/zip")
+	if err != nil {
+		return err
+	}
+	err = exporter.ProfileRepo.completeDataExport(export.Id, key, hashExportToken(encodedToken), config.GetDataExportLinkLifetime())
+	if err != nil {
+		deleteErr := exporter.Store.Delete(key)
+		if deleteErr != nil {
+			log.Warn(deleteErr)
+		}
+		return err
+	}
+
+	err = tenant.Templates().Send(exporter.Mailer, user.Email, mailer.DATA_EXPORT_TEMPLATE, map[string]interface{}{
+		"Fullname":       user.Fullname,
+		"Link":           fmt.Sprintf("%s/export/download?token=%s", config.GetAppURL(), url.QueryEscape(encodedToken)),
+		"ExpiresInHours": int(config.GetDataExportLinkLifetime().Hours()),
+	})
+	if err != nil {
+		log.Warn(err)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package profile
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"userland/auth"
+	"userland/config"
+	"userland/tenancy"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var pngPicture = append([]byte("\x89PNG\r\n\x1a\n"), []byte("rest of the picture")...)
+
+func readArchive(t *testing.T, archive []byte) map[string][]byte {
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	require.Nil(t, err)
+
+	files := map[string][]byte{}
+	for _, file := range reader.File {
+		opened, err := file.Open()
+		require.Nil(t, err)
+		content, err := ioutil.ReadAll(opened)
+		require.Nil(t, err)
+		opened.Close()
+		files[file.Name] = content
+	}
+	return files
+}
+
+func TestBuildArchive(t *testing.T) {
+	user := auth.User{
+		Id:                 1,
+		Fullname:           "userfullname",
+		Email:              "user@example.com",
+		Password:           "$2a$04$passwordhash",
+		Location:           sql.NullString{String: "Bandung, Indonesia", Valid: true},
+		Verified:           true,
+		VerificationToken:  sql.NullString{String: "verificationtoken", Valid: true},
+		ResetPasswordToken: sql.NullString{String: "resetpasswordtoken", Valid: true},
+		LoginToken:         sql.NullString{String: "logintoken", Valid: true},
+		RestoreToken:       sql.NullString{String: "restoretoken", Valid: true},
+		ExternalId:         sql.NullString{String: "external-1", Valid: true},
+		CreatedAt:          time.Now(),
+	}
+	data := exportData{
+		Profile:    newExportProfile(&user),
+		Sessions:   []exportSession{{UserAgent: "Firefox", IPAddress: "192.0.2.1"}},
+		Identities: exportIdentities{ExternalId: user.ExternalId.String},
+		ExportedAt: time.Now(),
+	}
+
+	archive, err := buildArchive(data, pngPicture)
+	require.Nil(t, err)
+	files := readArchive(t, archive)
+	require.Len(t, files, 2)
+	assert.Equal(t, pngPicture, files["picture.png"])
+
+	var decoded exportData
+	require.Nil(t, json.Unmarshal(files[EXPORT_DATA_FILE], &decoded))
+	assert.Equal(t, "user@example.com", decoded.Profile.Email)
+	assert.Equal(t, "Bandung, Indonesia", decoded.Profile.Location)
+	assert.Equal(t, "picture.png", decoded.Profile.Picture)
+	assert.Equal(t, "external-1", decoded.Identities.ExternalId)
+	assert.Equal(t, "Firefox", decoded.Sessions[0].UserAgent)
+
+	content := string(files[EXPORT_DATA_FILE])
+	for _, secret := range []string{user.Password, "verificationtoken", "resetpasswordtoken", "logintoken", "restoretoken"} {
+		assert.NotContains(t, content, secret)
+	}
+
+	archive, err = buildArchive(data, nil)
+	require.Nil(t, err)
+	files = readArchive(t, archive)
+	assert.Len(t, files, 1)
+	assert.NotContains(t, string(files[EXPORT_DATA_FILE]), `"picture"`)
+}
+
+func TestHashExportToken(t *testing.T) {
+	assert.Len(t, hashExportToken("token"), 64)
+	assert.Equal(t, hashExportToken("token"), hashExportToken("token"))
+	assert.NotEqual(t, hashExportToken("token"), hashExportToken("other"))
+}
+
+func TestDataExporter(t *testing.T) {
+	testProfileHandlerInit(t)
+	os.Setenv("APP_URL", "https://example.com")
+	defer os.Unsetenv("APP_URL")
+	exporter := DataExporter{ProfileRepo: mockRepo, Store: mockStore, Mailer: mockMailer, Interval: time.Hour}
+
+	user := authenticatedUser
+	user.Password = "$2a$04$passwordhash"
+	user.PictureKey = sql.NullString{String: "pictures/1/1/hash", Valid: true}
+	export := pendingDataExport{Id: 7, UserId: user.Id, TenantId: tenancy.DEFAULT_TENANT_ID}
+	data := exportData{Profile: newExportProfile(&user), ExportedAt: time.Now()}
+
+	var tokenHash string
+	var body string
+	gomock.InOrder(
+		mockRepo.EXPECT().claimDataExports(DATA_EXPORT_BATCH_SIZE).Return([]pendingDataExport{export}, nil),
+		mockRepo.EXPECT().getExportUser(export.TenantId, export.UserId).Return(&user, nil),
+		mockRepo.EXPECT().getExportTenant(export.TenantId).Return(&tenancy.Tenant{Id: tenancy.DEFAULT_TENANT_ID}, nil),
+		mockRepo.EXPECT().getExportData(&user).Return(&data, nil),
+		mockStore.EXPECT().Get(user.PictureKey.String).Return(pngPicture, nil),
+		mockStore.EXPECT().Put("exports/1/1/7.zip", gomock.Any(), "application/zip").DoAndReturn(func(key string, archive []byte, contentType string) error {
+			files := readArchive(t, archive)
+			assert.Equal(t, pngPicture, files["picture.png"])
+			assert.NotContains(t, string(files[EXPORT_DATA_FILE]), user.Password)
+			return nil
+		}),
+		mockRepo.EXPECT().completeDataExport(export.Id, "exports/1/1/7.zip", gomock.Any(), config.GetDataExportLinkLifetime()).DoAndReturn(func(exportId int, archiveKey string, hash string, lifetime time.Duration) error {
+			tokenHash = hash
+			return nil
+		}),
+		mockMailer.EXPECT().Send(user.Email, gomock.Any(), gomock.Any()).DoAndReturn(func(recipient string, subject string, sentBody string) error {
+			body = sentBody
+			return nil
+		}),
+		mockRepo.EXPECT().claimDataExports(DATA_EXPORT_BATCH_SIZE).Return([]pendingDataExport{}, nil),
+	)
+	exporter.exportPending()
+
+	assert.Contains(t, body, "https://example.com/export/download?token=")
+	token := body[strings.Index(body, "token=")+len("token="):]
+	token = token[:strings.IndexByte(token, '\n')]
+	assert.Equal(t, tokenHash, hashExportToken(token))
+
+	testProfileHandlerEnd()
+}
+
+func TestDataExporterFailure(t *testing.T) {
+	testProfileHandlerInit(t)
+	exporter := DataExporter{ProfileRepo: mockRepo, Store: mockStore, Mailer: mockMailer, Interval: time.Hour}
+
+	user := authenticatedUser
+	deleted := pendingDataExport{Id: 7, UserId: 2, TenantId: tenancy.DEFAULT_TENANT_ID}
+	unstored := pendingDataExport{Id: 8, UserId: user.Id, TenantId: tenancy.DEFAULT_TENANT_ID}
+	data := exportData{Profile: newExportProfile(&user), ExportedAt: time.Now()}
+	gomock.InOrder(
+		mockRepo.EXPECT().claimDataExports(DATA_EXPORT_BATCH_SIZE).Return([]pendingDataExport{deleted, unstored}, nil),
+		mockRepo.EXPECT().getExportUser(deleted.TenantId, deleted.UserId).Return(nil, sql.ErrNoRows),
+		mockRepo.EXPECT().failDataExport(deleted.Id).Return(nil),
+		mockRepo.EXPECT().getExportUser(unstored.TenantId, unstored.UserId).Return(&user, nil),
+		mockRepo.EXPECT().getExportTenant(unstored.TenantId).Return(&tenancy.Tenant{Id: tenancy.DEFAULT_TENANT_ID}, nil),
+		mockRepo.EXPECT().getExportData(&user).Return(&data, nil),
+		mockStore.EXPECT().Put("exports/1/1/8.zip", gomock.Any(), "application/zip").Return(nil),
+		mockRepo.EXPECT().completeDataExport(unstored.Id, "exports/1/1/8.zip", gomock.Any(), gomock.Any()).Return(sql.ErrConnDone),
+		mockStore.EXPECT().Delete("exports/1/1/8.zip").Return(nil),
+		mockRepo.EXPECT().failDataExport(unstored.Id).Return(nil),
+		mockRepo.EXPECT().claimDataExports(DATA_EXPORT_BATCH_SIZE).Return([]pendingDataExport{}, nil),
+	)
+	exporter.exportPending()
+
+	mockRepo.EXPECT().claimDataExports(DATA_EXPORT_BATCH_SIZE).Return(nil, errors.New("connection refused"))
+	exporter.exportPending()
+
+	testProfileHandlerEnd()
+}
+
+func TestDataExporterRun(t *testing.T) {
+	testProfileHandlerInit(t)
+	exporter := DataExporter{ProfileRepo: mockRepo, Store: mockStore, Mailer: mockMailer, Interval: time.Hour}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	mockRepo.EXPECT().claimDataExports(DATA_EXPORT_BATCH_SIZE).DoAndReturn(func(limit int) ([]pendingDataExport, error) {
+		close(stop)
+		return []pendingDataExport{}, nil
+	})
+	go func() {
+		exporter.Run(stop)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Exporter didn't stop")
+	}
+
+	testProfileHandlerEnd()
+}
@@ -1,38 +1,86 @@
 package profile
 
 import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 	"userland/auth"
+	"userland/config"
 	ulanderrors "userland/errors"
+	"userland/mailer"
 	"userland/request"
 	"userland/response"
+	"userland/storage"
+	"userland/tenancy"
 
+	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
 )
 
 var err error
 
 type ProfileHandler struct {
 	ProfileRepo profileRepositoryInterface
+	Mailer      mailer.Mailer
+	Store       storage.BlobStore
 }
 
 func (handler ProfileHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 	user := r.Context().Value("user").(*auth.User)
 	userProfile := UserProfile{
-		Id:             user.Id,
-		Fullname:       user.Fullname,
-		Location:       user.Location.String,
-		Bio:            user.Bio.String,
-		Web:            user.Web.String,
-		ProfilePicture: user.ProfilePicture,
-		CreatedAt:      user.CreatedAt,
+		Id:        user.Id,
+		Fullname:  user.Fullname,
+		Location:  user.Location.String,
+		Bio:       user.Bio.String,
+		Web:       user.Web.String,
+		CreatedAt: user.CreatedAt,
 	}
+
+	if user.Username.Valid {
+		userProfile.Username = &user.Username.String
+	}
+	if user.PictureKey.Valid {
+		userProfile.PictureURL = pictureURL(user.Id, user.PictureHash.String)
+	} else {
+		userProfile.PictureURL = pictureURL(user.Id, avatarHash(user.Id))
+	}
+
+	etag := profileETag(user.ProfileVersion)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", PROFILE_CACHE)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	log.Info("Get user profile successful")
 	response.RespondSuccessWithBody(w, userProfile)
 }
 
+// profileWriteVersions returns the profile versions a write may replace, as
+// told by If-Match, and answers the request when the header is missing.
+func profileWriteVersions(w http.ResponseWriter, r *http.Request) ([]int64, bool) {
+	versions, ok := profileVersionsOf(r.Header.Get("If-Match"))
+	if !ok {
+		log.Info("Profile write has no If-Match header")
+		response.RespondPreconditionRequired(w, ulanderrors.ErrProfileVersionRequired)
+	}
+	return versions, ok
+}
+
 func (handler ProfileHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 	user := r.Context().Value("user").(*auth.User)
+	versions, ok := profileWriteVersions(w, r)
+	if !ok {
+		return
+	}
 
 	var userInfo UserProfile
 	err = request.ParseJSON(r.Body, &userInfo)
@@ -48,18 +96,201 @@ func (handler ProfileHandler) UpdateProfile(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	err = handler.ProfileRepo.updateUserProfile(user, userInfo)
+	if userInfo.Username != nil && *userInfo.Username != "" {
+		refusal, refused := auth.UsernameRefusal(*userInfo.Username)
+		if refused {
+			log.Info("Username is refused")
+			response.RespondBadRequest(w, refusal)
+			return
+		}
+	}
+
+	err = handler.ProfileRepo.updateUserProfile(user, userInfo, versions, config.GetUsernameRedirectLifetime())
 
+	if err == errProfileVersionChanged {
+		log.Info(err)
+		response.RespondPreconditionFailed(w, ulanderrors.ErrProfileVersionMismatch)
+		return
+	}
+	if err == errUsernameTaken {
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrUsernameTaken)
+		return
+	}
 	if err != nil {
 		log.Warn(err)
 		response.RespondBadRequest(w, ulanderrors.ErrUpdateProfileQueryExec)
 		return
 	}
 
+	w.Header().Set("ETag", profileETag(user.ProfileVersion))
+
 	log.Info("Update user profile successful")
 	response.RespondSuccess(w)
 }
 
+// PatchProfile applies a JSON merge patch to the profile, so that clients
+// only send the fields they change.
+func (handler ProfileHandler) PatchProfile(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*auth.User)
+	versions, ok := profileWriteVersions(w, r)
+	if !ok {
+		return
+	}
+
+	var patch ProfilePatch
+	err = request.ParseJSON(r.Body, &patch)
+	if err != nil {
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrParseBody)
+		return
+	}
+
+	if !patch.hasValidProfile() {
+		log.Info("Patched user info is invalid")
+		response.RespondBadRequest(w, ulanderrors.ErrUpdateProfileUserInfoInvalid)
+		return
+	}
+
+	if patch.Username != nil && *patch.Username != "" {
+		refusal, refused := auth.UsernameRefusal(*patch.Username)
+		if refused {
+			log.Info("Username is refused")
+			response.RespondBadRequest(w, refusal)
+			return
+		}
+	}
+
+	err = handler.ProfileRepo.patchUserProfile(user, patch, versions, config.GetUsernameRedirectLifetime())
+
+	if err == errProfileVersionChanged {
+		log.Info(err)
+		response.RespondPreconditionFailed(w, ulanderrors.ErrProfileVersionMismatch)
+		return
+	}
+	if err == errUsernameTaken {
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrUsernameTaken)
+		return
+	}
+	if err != nil {
+		log.Warn(err)
+		response.RespondBadRequest(w, ulanderrors.ErrUpdateProfileQueryExec)
+		return
+	}
+
+	w.Header().Set("ETag", profileETag(user.ProfileVersion))
+
+	log.Info("Patch user profile successful")
+	response.RespondSuccess(w)
+}
+
+// GetPublicProfile looks a user up by ID or username, for anyone. A username
+// the user moved away from redirects to where they are now.
+func (handler ProfileHandler) GetPublicProfile(w http.ResponseWriter, r *http.Request) {
+	tenantId := tenancy.FromRequest(r).Id
+	handle := mux.Vars(r)["handle"]
+
+	var user *auth.User
+	redirected := false
+	// Usernames are never only digits, so they can't be taken for IDs.
+	userId, err := strconv.Atoi(handle)
+	if err == nil {
+		user, err = handler.ProfileRepo.getPublicUser(tenantId, userId)
+	} else {
+		user, redirected, err = handler.ProfileRepo.getPublicUserByUsername(tenantId, handle)
+	}
+
+	if err == sql.ErrNoRows {
+		log.Info("User doesn't exist")
+		response.RespondBadRequest(w, ulanderrors.ErrPublicProfileNotFound)
+		return
+	}
+
+	if err != nil {
+		log.Warn(err)
+		response.RespondBadRequest(w, ulanderrors.ErrPublicProfileQueryExec)
+		return
+	}
+
+	if redirected {
+		log.Info("Username has changed, redirecting")
+		http.Redirect(w, r, publicProfilePath(user), http.StatusFound)
+		return
+	}
+
+	log.Info("Get public profile successful")
+	response.RespondSuccessWithBody(w, publicProfileOf(user, viewerOf(r)))
+}
+
+// SearchUsers is the user directory, as users see it.
+func (handler ProfileHandler) SearchUsers(w http.ResponseWriter, r *http.Request) {
+	handler.searchUsers(w, r, false)
+}
+
+// AdminSearchUsers is the user directory for admins, who can search by email
+// and get it with the results.
+func (handler ProfileHandler) AdminSearchUsers(w http.ResponseWriter, r *http.Request) {
+	handler.searchUsers(w, r, true)
+}
+
+func (handler ProfileHandler) searchUsers(w http.ResponseWriter, r *http.Request, includeEmail bool) {
+	viewer := r.Context().Value("user").(*auth.User)
+
+	search, err := parseUserSearch(r.URL.Query())
+	if err != nil {
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrUserSearchInvalid)
+		return
+	}
+	search.IncludeEmail = includeEmail
+
+	users, err := handler.ProfileRepo.searchUsers(tenancy.FromRequest(r).Id, viewer, search)
+	if err != nil {
+		log.Warn(err)
+		response.RespondInternalError(w, ulanderrors.ErrUserSearchQueryExec)
+		return
+	}
+
+	log.Info("Search users successful")
+	response.RespondSuccessWithBody(w, searchResults(users, search, viewer))
+}
+
+func (handler ProfileHandler) GetPrivacySettings(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*auth.User)
+	log.Info("Get privacy settings successful")
+	response.RespondSuccessWithBody(w, privacySettingsOf(user))
+}
+
+func (handler ProfileHandler) UpdatePrivacySettings(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*auth.User)
+
+	var settings PrivacySettings
+	err = request.ParseJSON(r.Body, &settings)
+	if err != nil {
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrParseBody)
+		return
+	}
+
+	if !settings.isValid() {
+		log.Info("Privacy settings are invalid")
+		response.RespondBadRequest(w, ulanderrors.ErrPrivacySettingsInvalid)
+		return
+	}
+
+	err = handler.ProfileRepo.updatePrivacySettings(user, settings)
+
+	if err != nil {
+		log.Warn(err)
+		response.RespondBadRequest(w, ulanderrors.ErrPrivacyQueryExec)
+		return
+	}
+
+	log.Info("Update privacy settings successful")
+	response.RespondSuccess(w)
+}
+
 func (handler ProfileHandler) GetEmail(w http.ResponseWriter, r *http.Request) {
 	user := r.Context().Value("user").(*auth.User)
 	log.Info("Get user email successful")
@@ -92,6 +323,8 @@ func (handler ProfileHandler) ChangeEmailAddress(w http.ResponseWriter, r *http.
 		return
 	}
 
+	renewOrEndSession(w, r, user, emailReq.RevokeCurrentSession)
+
 	log.Info("Change email address successful")
 	response.RespondSuccess(w)
 }
@@ -120,6 +353,10 @@ func (handler ProfileHandler) ChangePassword(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	if refuseDirectoryAccount(w, user) {
+		return
+	}
+
 	err = handler.ProfileRepo.changeUserPassword(user, passwordReq.PasswordCurrent, passwordReq.Password)
 
 	if err != nil {
@@ -128,6 +365,8 @@ func (handler ProfileHandler) ChangePassword(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	renewOrEndSession(w, r, user, passwordReq.RevokeCurrentSession)
+
 	log.Info("User change password successful")
 	response.RespondSuccess(w)
 }
@@ -144,20 +383,118 @@ func (handler ProfileHandler) DeleteAccount(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	err = handler.ProfileRepo.deleteUser(user, delReq.Password)
+	if refuseDirectoryAccount(w, user) {
+		return
+	}
+
+	err = handler.ProfileRepo.deleteUser(user, delReq.Password, config.GetAccountDeletionGracePeriod())
+
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrDeleteAccountIncorrectPass)
+		return
+	}
 
 	if err != nil {
 		log.Warn(err)
-		response.RespondBadRequest(w, ulanderrors.ErrDeleteAccountIncorrectPass)
+		response.RespondBadRequest(w, ulanderrors.ErrDeleteAccountQueryExec)
 		return
 	}
 
-	log.Info("User delete account successful")
+	err = tenancy.FromRequest(r).Templates().Send(handler.Mailer, user.Email, mailer.ACCOUNT_DELETION_TEMPLATE, map[string]interface{}{
+		"Fullname":  user.Fullname,
+		"PurgeDate": user.PurgeAt.Time.Format("January 2, 2006"),
+		"Link":      fmt.Sprintf("%s/account/restore?token=%s", config.GetAppURL(), url.QueryEscape(user.RestoreToken.String)),
+	})
+	if err != nil {
+		log.Warn(err)
+	}
+
+	log.Info("User delete account successful, account scheduled for purge")
 	response.RespondSuccess(w)
 }
 
+// RequestDataExport queues an archive of the user's data, which DataExporter
+// builds in the background. The user is emailed a link to download it once
+// it's ready.
+func (handler ProfileHandler) RequestDataExport(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*auth.User)
+
+	export, err := handler.ProfileRepo.createDataExport(user)
+
+	if err == sql.ErrNoRows {
+		log.Info("Data export is already in progress")
+		response.RespondTooManyRequests(w, ulanderrors.ErrDataExportInProgress)
+		return
+	}
+
+	if err != nil {
+		log.Warn(err)
+		response.RespondBadRequest(w, ulanderrors.ErrDataExportQueryExec)
+		return
+	}
+
+	log.Info("Data export requested")
+	response.RespondAccepted(w, export)
+}
+
+// DownloadDataExport serves an export through the emailed link, which is
+// the only credential needed to use it, once.
+func (handler ProfileHandler) DownloadDataExport(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		log.Info("Data export token is empty")
+		response.RespondBadRequest(w, ulanderrors.ErrDataExportTokenEmpty)
+		return
+	}
+
+	archiveKey, err := handler.ProfileRepo.downloadDataExport(tenancy.FromRequest(r).Id, hashExportToken(token))
+
+	if err == sql.ErrNoRows {
+		log.Info("Data export link is invalid, expired or already used")
+		response.RespondBadRequest(w, ulanderrors.ErrDataExportLinkInvalid)
+		return
+	}
+
+	if err != nil {
+		log.Warn(err)
+		response.RespondBadRequest(w, ulanderrors.ErrDataExportQueryExec)
+		return
+	}
+
+	archive, err := handler.Store.Get(archiveKey)
+
+	if err != nil {
+		log.Warn(err)
+		response.RespondBadRequest(w, ulanderrors.ErrDataExportQueryExec)
+		return
+	}
+
+	log.Info("Data export downloaded")
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="userland-export.zip"`)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(archive)
+
+	// The link is used up, so the archive goes right away rather than with
+	// the next purge.
+	err = handler.Store.Delete(archiveKey)
+	if err != nil {
+		log.Warn(err)
+	}
+}
+
 func (handler ProfileHandler) UpdateProfilePicture(w http.ResponseWriter, r *http.Request) {
 	user := r.Context().Value("user").(*auth.User)
+	maxBytes := config.GetPictureMaxBytes()
+
+	if r.ContentLength > maxBytes+PICTURE_FORM_OVERHEAD {
+		log.Info("Picture upload is too large")
+		response.RespondBadRequest(w, ulanderrors.ErrPictureTooLarge)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+PICTURE_FORM_OVERHEAD)
+
 	file, fileHeader, err := r.FormFile("file")
 
 	if err != nil {
@@ -168,8 +505,13 @@ func (handler ProfileHandler) UpdateProfilePicture(w http.ResponseWriter, r *htt
 
 	defer file.Close()
 
-	picture := make([]byte, fileHeader.Size)
-	_, err = file.Read(picture)
+	if fileHeader.Size > maxBytes {
+		log.Info("Picture is too large")
+		response.RespondBadRequest(w, ulanderrors.ErrPictureTooLarge)
+		return
+	}
+
+	content, err := ioutil.ReadAll(io.LimitReader(file, maxBytes+1))
 
 	if err != nil {
 		log.Info(err)
@@ -177,7 +519,33 @@ func (handler ProfileHandler) UpdateProfilePicture(w http.ResponseWriter, r *htt
 		return
 	}
 
-	err = handler.ProfileRepo.updateUserPicture(user, picture)
+	if int64(len(content)) > maxBytes {
+		log.Info("Picture is too large")
+		response.RespondBadRequest(w, ulanderrors.ErrPictureTooLarge)
+		return
+	}
+
+	picture, err := processPicture(content, config.GetPictureMaxDimension())
+
+	if err != nil {
+		log.Info(err)
+		response.RespondBadRequest(w, pictureProcessingError(err))
+		return
+	}
+
+	previousKey := user.PictureKey
+	hash := hashPicture(picture.Content)
+	key := pictureKey(user.TenantId, user.Id, hash)
+
+	err = storePicture(handler.Store, key, picture)
+
+	if err != nil {
+		log.Warn(err)
+		response.RespondInternalError(w, ulanderrors.ErrUpdatePictureCantBeStored)
+		return
+	}
+
+	err = handler.ProfileRepo.updateUserPicture(user, key, picture.ContentType, hash)
 
 	if err != nil {
 		log.Warn(err)
@@ -185,13 +553,161 @@ func (handler ProfileHandler) UpdateProfilePicture(w http.ResponseWriter, r *htt
 		return
 	}
 
+	if previousKey.Valid && previousKey.String != key {
+		handler.deletePicture(previousKey.String)
+	}
+
 	log.Info("Update profile picture successful")
 	response.RespondSuccess(w)
 }
 
+// GetUserPicture serves the picture of any user of the tenant, or the
+// avatar generated for users without one, in the requested format. Requests
+// revalidating the current ETag are answered without reading the store.
+func (handler ProfileHandler) GetUserPicture(w http.ResponseWriter, r *http.Request) {
+	userId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		log.Info(err)
+		response.RespondBadRequest(w, ulanderrors.ErrPictureNotFound)
+		return
+	}
+
+	size := 0
+	if sizeParam := r.URL.Query().Get("size"); sizeParam != "" {
+		size, err = strconv.Atoi(sizeParam)
+		if err != nil || !isPictureVariantSize(size) {
+			log.Info("Picture size is not available")
+			response.RespondBadRequest(w, ulanderrors.ErrPictureSizeInvalid)
+			return
+		}
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = AVATAR_FORMAT_PNG
+	}
+	if _, ok := avatarContentTypes[format]; !ok {
+		log.Info("Picture format is not available")
+		response.RespondBadRequest(w, ulanderrors.ErrPictureFormatInvalid)
+		return
+	}
+
+	picture, err := handler.ProfileRepo.getUserPicture(tenancy.FromRequest(r).Id, userId)
+
+	if err == sql.ErrNoRows {
+		log.Info("User doesn't exist")
+		response.RespondBadRequest(w, ulanderrors.ErrPictureNotFound)
+		return
+	}
+
+	if err != nil {
+		log.Warn(err)
+		response.RespondBadRequest(w, ulanderrors.ErrUpdatePictureQueryExec)
+		return
+	}
+
+	// Viewers who may not see the picture get the avatar, as if there was
+	// none. The answer then depends on who asks, so it isn't to be shared.
+	shared := !picture.Key.Valid || picture.Visibility == VISIBILITY_PUBLIC
+	if !picture.Key.Valid || !visibleTo(picture.Visibility, userId, viewerOf(r)) {
+		serveAvatar(w, r, userId, size, format, shared)
+		return
+	}
+	handler.serveUploadedPicture(w, r, picture, size, shared)
+}
+
+// serveUploadedPicture serves the picture as it was stored, whatever the
+// format requested.
+func (handler ProfileHandler) serveUploadedPicture(w http.ResponseWriter, r *http.Request, picture *userPicture, size int, shared bool) {
+	key, etag := picture.Key.String, pictureETag(picture.Hash.String)
+	if size != 0 {
+		key, etag = pictureVariantKey(key, size), pictureETag(fmt.Sprintf("%s-%d", picture.Hash.String, size))
+	}
+
+	if setPictureValidators(w, r, picture.Hash.String, etag, shared) {
+		return
+	}
+
+	content, err := handler.Store.Get(key)
+
+	if err == storage.ErrBlobNotFound && key != picture.Key.String {
+		log.Info("Picture has no variants, serving it whole")
+		content, err = handler.Store.Get(picture.Key.String)
+	}
+
+	if err != nil {
+		log.Warn(err)
+		respondPictureNotLoaded(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", picture.ContentType.String)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+}
+
+func serveAvatar(w http.ResponseWriter, r *http.Request, userId int, size int, format string, shared bool) {
+	if size == 0 {
+		size = AVATAR_DEFAULT_SIZE
+	}
+	hash := avatarHash(userId)
+
+	if setPictureValidators(w, r, hash, pictureETag(fmt.Sprintf("%s-%d.%s", hash, size, format)), shared) {
+		return
+	}
+
+	content, err := newAvatar(hash).render(format, size)
+
+	if err != nil {
+		log.Warn(err)
+		respondPictureNotLoaded(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", avatarContentTypes[format])
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+}
+
+// setPictureValidators sets the caching headers of a picture, and answers
+// the request when the client's copy is still current. Pictures that aren't
+// shared stay out of shared caches and vary with the session.
+func setPictureValidators(w http.ResponseWriter, r *http.Request, hash string, etag string, shared bool) bool {
+	w.Header().Set("ETag", etag)
+	immutable := r.URL.Query().Get("v") == pictureVersion(hash)
+	switch {
+	case shared && immutable:
+		w.Header().Set("Cache-Control", PICTURE_CACHE_IMMUTABLE)
+	case shared:
+		w.Header().Set("Cache-Control", PICTURE_CACHE_REVALIDATED)
+	case immutable:
+		w.Header().Set("Cache-Control", PICTURE_CACHE_PRIVATE_IMMUTABLE)
+	default:
+		w.Header().Set("Cache-Control", PICTURE_CACHE_PRIVATE_REVALIDATED)
+	}
+	if shared {
+		w.Header().Set("Vary", config.GetTenantHeader())
+	} else {
+		w.Header().Set("Vary", config.GetTenantHeader()+", Cookie")
+	}
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// respondPictureNotLoaded drops the validators already set, so that the
+// error isn't cached in place of the picture.
+func respondPictureNotLoaded(w http.ResponseWriter) {
+	w.Header().Del("ETag")
+	w.Header().Del("Cache-Control")
+	response.RespondInternalError(w, ulanderrors.ErrPictureCantBeLoaded)
+}
+
 func (handler ProfileHandler) DeleteProfilePicture(w http.ResponseWriter, r *http.Request) {
 	user := r.Context().Value("user").(*auth.User)
 
+	previousKey := user.PictureKey
 	err = handler.ProfileRepo.deleteUserPicture(user)
 
 	if err != nil {
@@ -200,6 +716,58 @@ func (handler ProfileHandler) DeleteProfilePicture(w http.ResponseWriter, r *htt
 		return
 	}
 
+	if previousKey.Valid {
+		handler.deletePicture(previousKey.String)
+	}
+
 	log.Info("Delete profile picture successful")
 	response.RespondSuccess(w)
 }
+
+// deletePicture removes a picture the user row no longer refers to. Failing
+// to do so only leaves orphaned blobs behind, so it's just logged.
+func (handler ProfileHandler) deletePicture(key string) {
+	err := DeletePicture(handler.Store, key)
+	if err != nil {
+		log.Warn(err)
+	}
+}
+
+// pictureProcessingError tells the client which check an uploaded picture
+// failed.
+func pictureProcessingError(err error) ulanderrors.UserlandError {
+	switch err {
+	case errPictureTypeNotAllowed:
+		return ulanderrors.ErrPictureTypeNotAllowed
+	case errPictureDimensionsTooLarge:
+		return ulanderrors.ErrPictureDimensionsTooLarge
+	case errPictureCorrupt:
+		return ulanderrors.ErrPictureCantBeDecoded
+	}
+	return ulanderrors.ErrUpdatePictureCantBeRead
+}
+
+// renewOrEndSession is called after an operation that revoked every session of
+// the user. The current session survives it unless the user asked otherwise.
+func renewOrEndSession(w http.ResponseWriter, r *http.Request, user *auth.User, revokeCurrentSession bool) {
+	if revokeCurrentSession {
+		auth.EndSession(w)
+		return
+	}
+
+	err := auth.RenewSession(w, r, user)
+	if err != nil {
+		log.Warn(err)
+	}
+}
+
+// refuseDirectoryAccount answers with a 403 when the account was created from
+// a directory, whose password is checked there rather than against the
+// local hash.
+func refuseDirectoryAccount(w http.ResponseWriter, user *auth.User) bool {
+	if user.DirectoryDN.Valid {
+		log.Info("Directory account can't be changed here")
+		response.RespondForbidden(w, ulanderrors.ErrProfileDirectoryAccount)
+	}
+	return user.DirectoryDN.Valid
+}
@@ -5,36 +5,48 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
 	"time"
 	"userland/auth"
+	"userland/config"
+	ulanderrors "userland/errors"
+	"userland/mailer"
+	"userland/storage"
+	"userland/tenancy"
 
+	"github.com/dgrijalva/jwt-go"
 	gomock "github.com/golang/mock/gomock"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 )
 
 var (
 	handler ProfileHandler
 	router  *mux.Router
 
-	ctrl     *gomock.Controller
-	mockRepo *MockprofileRepositoryInterface
+	ctrl       *gomock.Controller
+	mockRepo   *MockprofileRepositoryInterface
+	mockMailer *mailer.MockMailer
+	mockStore  *storage.MockBlobStore
 
 	authenticatedUser = auth.User{
-		Id:             1,
-		Fullname:       "userfullname",
-		Email:          "user@example.com",
-		Location:       sql.NullString{String: "Bandung, Indonesia", Valid: true},
-		Bio:            sql.NullString{String: "my bio", Valid: true},
-		Web:            sql.NullString{String: "https://example.com", Valid: true},
-		ProfilePicture: nil,
-		CreatedAt:      time.Now(),
+		Id:        1,
+		Fullname:  "userfullname",
+		Email:     "user@example.com",
+		Location:  sql.NullString{String: "Bandung, Indonesia", Valid: true},
+		Bio:       sql.NullString{String: "my bio", Valid: true},
+		Web:       sql.NullString{String: "https://example.com", Valid: true},
+		CreatedAt: time.Now(),
 	}
 
 	validProfileUpdate           UserProfile
@@ -57,25 +69,35 @@ var (
 )
 
 const (
-	FILE_NAME         = "sample.jpg"
-	SAMPLE_IMAGE_BYTE = "sample_image_byte"
+	FILE_NAME = "sample.png"
 )
 
 func testProfileHandlerInit(t *testing.T) {
 	ctrl = gomock.NewController(t)
 	mockRepo = NewMockprofileRepositoryInterface(ctrl)
+	mockMailer = mailer.NewMockMailer(ctrl)
+	mockStore = storage.NewMockBlobStore(ctrl)
 
-	handler = ProfileHandler{ProfileRepo: mockRepo}
+	handler = ProfileHandler{ProfileRepo: mockRepo, Mailer: mockMailer, Store: mockStore}
 
 	router = mux.NewRouter()
 	router.HandleFunc("/api/me", handler.GetProfile).Methods(http.MethodGet)
 	router.HandleFunc("/api/me", handler.UpdateProfile).Methods(http.MethodPut)
+	router.HandleFunc("/api/me", handler.PatchProfile).Methods(http.MethodPatch)
 	router.HandleFunc("/api/me/email", handler.GetEmail).Methods(http.MethodGet)
 	router.HandleFunc("/api/me/email", handler.ChangeEmailAddress).Methods(http.MethodPut)
 	router.HandleFunc("/api/me/password", handler.ChangePassword).Methods(http.MethodPost)
 	router.HandleFunc("/api/me/delete", handler.DeleteAccount).Methods(http.MethodPost)
+	router.HandleFunc("/api/me/export", handler.RequestDataExport).Methods(http.MethodPost)
+	router.HandleFunc("/api/exports/download", handler.DownloadDataExport).Methods(http.MethodGet)
 	router.HandleFunc("/api/me/picture", handler.UpdateProfilePicture).Methods(http.MethodPut)
 	router.HandleFunc("/api/me/picture", handler.DeleteProfilePicture).Methods(http.MethodDelete)
+	router.HandleFunc("/api/users/{id}/picture", handler.GetUserPicture).Methods(http.MethodGet)
+	router.HandleFunc("/api/users", handler.SearchUsers).Methods(http.MethodGet)
+	router.HandleFunc("/api/admin/users/search", handler.AdminSearchUsers).Methods(http.MethodGet)
+	router.HandleFunc("/api/users/{handle}", handler.GetPublicProfile).Methods(http.MethodGet)
+	router.HandleFunc("/api/me/privacy", handler.GetPrivacySettings).Methods(http.MethodGet)
+	router.HandleFunc("/api/me/privacy", handler.UpdatePrivacySettings).Methods(http.MethodPut)
 }
 
 func testProfileHandlerEnd() {
@@ -84,22 +106,39 @@ func testProfileHandlerEnd() {
 
 func setRequestUserContext(req *http.Request, user *auth.User) *http.Request {
 	ctx := context.WithValue(req.Context(), "user", user)
+	ctx = context.WithValue(ctx, "claims", &auth.Claims{UserId: user.Id, TokenVersion: user.TokenVersion})
 	return req.WithContext(ctx)
 }
 
 func TestGetProfile(t *testing.T) {
 	testProfileHandlerInit(t)
-	testGetUserProfile(t, &authenticatedUser, http.StatusOK)
+	res := testGetUserProfile(t, &authenticatedUser, http.StatusOK)
+	var userProfile UserProfile
+	require.Nil(t, json.Unmarshal(res.Body.Bytes(), &userProfile))
+	assert.Equal(t, "/api/users/1/picture?v="+avatarHash(1)[:PICTURE_VERSION_LENGTH], userProfile.PictureURL)
+	assert.Nil(t, userProfile.Username)
+
+	user := authenticatedUser
+	user.PictureKey = sql.NullString{String: "pictures/1/1/hash", Valid: true}
+	user.PictureHash = sql.NullString{String: hashPicture(pngPicture), Valid: true}
+	user.Username = sql.NullString{String: "Test_User", Valid: true}
+	res = testGetUserProfile(t, &user, http.StatusOK)
+	require.Nil(t, json.Unmarshal(res.Body.Bytes(), &userProfile))
+	assert.Equal(t, "/api/users/1/picture?v="+hashPicture(pngPicture)[:PICTURE_VERSION_LENGTH], userProfile.PictureURL)
+	require.NotNil(t, userProfile.Username)
+	assert.Equal(t, "Test_User", *userProfile.Username)
+
 	testProfileHandlerEnd()
 }
 
-func testGetUserProfile(t *testing.T, user *auth.User, expectedStatusCode int) {
+func testGetUserProfile(t *testing.T, user *auth.User, expectedStatusCode int) *httptest.ResponseRecorder {
 	req, err := http.NewRequest(http.MethodGet, "/api/me", nil)
 	req = setRequestUserContext(req, user)
 	require.Nil(t, err)
 	res := httptest.NewRecorder()
 	router.ServeHTTP(res, req)
 	assert.Equal(t, expectedStatusCode, res.Code)
+	return res
 }
 
 func TestUpdateProfile(t *testing.T) {
@@ -151,13 +190,118 @@ func initSuiteAndRepoForUpdateProfile() {
 		Web:      "whatiwanttofill",
 	}
 
-	mockRepo.EXPECT().updateUserProfile(&authenticatedUser, validProfileUpdate).Return(nil)
+	mockRepo.EXPECT().updateUserProfile(&authenticatedUser, validProfileUpdate, []int64{0}, config.GetUsernameRedirectLifetime()).Return(nil)
+}
+
+func TestUpdateProfileUsername(t *testing.T) {
+	testProfileHandlerInit(t)
+	user := authenticatedUser
+	update := UserProfile{Fullname: "updateduser", Web: "https://example.com/newme"}
+	withUsername := func(name string) UserProfile {
+		profileUpdate := update
+		profileUpdate.Username = &name
+		return profileUpdate
+	}
+
+	mockRepo.EXPECT().updateUserProfile(&user, withUsername("new_name"), []int64{0}, config.GetUsernameRedirectLifetime()).Return(nil)
+	mockRepo.EXPECT().updateUserProfile(&user, withUsername(""), []int64{0}, config.GetUsernameRedirectLifetime()).Return(nil)
+	mockRepo.EXPECT().updateUserProfile(&user, withUsername("someone"), []int64{0}, config.GetUsernameRedirectLifetime()).Return(errUsernameTaken)
+
+	res := serveProfileWrite(t, http.MethodPut, &user, withUsername("new_name"))
+	assert.Equal(t, http.StatusOK, res.Code)
+	res = serveProfileWrite(t, http.MethodPut, &user, withUsername(""))
+	assert.Equal(t, http.StatusOK, res.Code, "An empty username should remove it")
+
+	res = serveProfileWrite(t, http.MethodPut, &user, withUsername("someone"))
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assertUserlandError(t, res, ulanderrors.ErrUsernameTaken)
+	res = serveProfileWrite(t, http.MethodPut, &user, withUsername("no"))
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assertUserlandError(t, res, ulanderrors.ErrUsernameInvalid)
+	res = serveProfileWrite(t, http.MethodPut, &user, withUsername("settings"))
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assertUserlandError(t, res, ulanderrors.ErrUsernameReserved)
+
+	testProfileHandlerEnd()
+}
+
+func TestPatchProfile(t *testing.T) {
+	testProfileHandlerInit(t)
+	user := authenticatedUser
+	bio := "my new bio"
+	username := "someone"
+
+	mockRepo.EXPECT().patchUserProfile(&user, ProfilePatch{Bio: &sql.NullString{String: bio, Valid: true}, Location: &sql.NullString{}}, []int64{0}, config.GetUsernameRedirectLifetime()).Return(nil)
+	mockRepo.EXPECT().patchUserProfile(&user, ProfilePatch{Username: &username}, []int64{0}, config.GetUsernameRedirectLifetime()).Return(errUsernameTaken)
+
+	res := serveProfileWrite(t, http.MethodPatch, &user, map[string]interface{}{"bio": bio, "location": nil})
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	res = serveProfileWrite(t, http.MethodPatch, &user, map[string]interface{}{"username": username})
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assertUserlandError(t, res, ulanderrors.ErrUsernameTaken)
+
+	res = serveProfileWrite(t, http.MethodPatch, &user, map[string]interface{}{"fullname": nil})
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assertUserlandError(t, res, ulanderrors.ErrParseBody)
+
+	res = serveProfileWrite(t, http.MethodPatch, &user, map[string]interface{}{"web": INVALID_WEB})
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assertUserlandError(t, res, ulanderrors.ErrUpdateProfileUserInfoInvalid)
+
+	res = serveProfileWrite(t, http.MethodPatch, &user, map[string]interface{}{"username": "settings"})
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assertUserlandError(t, res, ulanderrors.ErrUsernameReserved)
+
+	testProfileHandlerEnd()
+}
+
+func TestProfileVersion(t *testing.T) {
+	testProfileHandlerInit(t)
+	user := authenticatedUser
+	user.ProfileVersion = 4
+	bio := sql.NullString{String: "my new bio", Valid: true}
+
+	res := testGetUserProfile(t, &user, http.StatusOK)
+	assert.Equal(t, `"4"`, res.Header().Get("ETag"))
+	assert.Equal(t, PROFILE_CACHE, res.Header().Get("Cache-Control"))
+
+	req, err := http.NewRequest(http.MethodGet, "/api/me", nil)
+	require.Nil(t, err)
+	req.Header.Set("If-None-Match", `"4"`)
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, setRequestUserContext(req, &user))
+	assert.Equal(t, http.StatusNotModified, res.Code)
+	assert.Empty(t, res.Body.String())
+
+	mockRepo.EXPECT().patchUserProfile(&user, ProfilePatch{Bio: &bio}, []int64{4}, config.GetUsernameRedirectLifetime()).DoAndReturn(
+		func(user *auth.User, patch ProfilePatch, versions []int64, redirectLifetime time.Duration) error {
+			user.ProfileVersion = 5
+			return nil
+		})
+	res = serveProfileWrite(t, http.MethodPatch, &user, map[string]string{"bio": bio.String})
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, `"5"`, res.Header().Get("ETag"), "Writes should answer with the new version")
+
+	mockRepo.EXPECT().updateUserProfile(&user, gomock.Any(), []int64{5}, config.GetUsernameRedirectLifetime()).Return(errProfileVersionChanged)
+	res = serveProfileWrite(t, http.MethodPut, &user, UserProfile{Fullname: "updateduser", Web: "https://example.com/newme"})
+	assert.Equal(t, http.StatusPreconditionFailed, res.Code)
+	assertUserlandError(t, res, ulanderrors.ErrProfileVersionMismatch)
+
+	for _, method := range []string{http.MethodPut, http.MethodPatch} {
+		res = serveWithUser(t, method, "/api/me", &user, map[string]string{"bio": bio.String})
+		assert.Equal(t, http.StatusPreconditionRequired, res.Code)
+		assertUserlandError(t, res, ulanderrors.ErrProfileVersionRequired)
+	}
+
+	testProfileHandlerEnd()
 }
 
 func testUpdateUserProfile(t *testing.T, user *auth.User, profileUpdate UserProfile, expectedStatusCode int) {
 	profileUpdateData, err := json.Marshal(profileUpdate)
 	require.Nil(t, err)
 	req, err := http.NewRequest(http.MethodPut, "/api/me", bytes.NewReader(profileUpdateData))
+	req.Header.Set("If-Match", profileETag(user.ProfileVersion))
 	req = setRequestUserContext(req, user)
 	require.Nil(t, err)
 	res := httptest.NewRecorder()
@@ -165,6 +309,130 @@ func testUpdateUserProfile(t *testing.T, user *auth.User, profileUpdate UserProf
 	assert.Equal(t, expectedStatusCode, res.Code)
 }
 
+func TestGetPublicProfile(t *testing.T) {
+	testProfileHandlerInit(t)
+	user := authenticatedUser
+	user.Id = 2
+	user.Username = sql.NullString{String: "Other_User", Valid: true}
+	user.LocationVisibility = VISIBILITY_PUBLIC
+	user.BioVisibility = VISIBILITY_AUTHENTICATED
+	user.WebVisibility = VISIBILITY_PRIVATE
+	user.PictureVisibility = VISIBILITY_PUBLIC
+	mockRepo.EXPECT().getPublicUser(tenancy.DEFAULT_TENANT_ID, 2).Return(&user, nil).Times(2)
+	mockRepo.EXPECT().getPublicUserByUsername(tenancy.DEFAULT_TENANT_ID, "other_user").Return(&user, false, nil)
+	mockRepo.EXPECT().getPublicUser(tenancy.DEFAULT_TENANT_ID, 3).Return(nil, sql.ErrNoRows)
+
+	res := testGetPublicProfile(t, "2", nil, http.StatusOK)
+	var profile PublicProfile
+	require.Nil(t, json.Unmarshal(res.Body.Bytes(), &profile))
+	assert.Equal(t, "Other_User", *profile.Username)
+	assert.Equal(t, user.Location.String, profile.Location)
+	assert.Empty(t, profile.Bio, "Fields for authenticated users should be hidden from anonymous viewers")
+	assert.Empty(t, profile.Web)
+	assert.NotContains(t, res.Body.String(), user.Email)
+
+	res = testGetPublicProfile(t, "2", &authenticatedUser, http.StatusOK)
+	profile = PublicProfile{}
+	require.Nil(t, json.Unmarshal(res.Body.Bytes(), &profile))
+	assert.Equal(t, user.Bio.String, profile.Bio)
+	assert.Empty(t, profile.Web, "Private fields should only be shown to their owner")
+
+	testGetPublicProfile(t, "other_user", nil, http.StatusOK)
+	res = testGetPublicProfile(t, "3", nil, http.StatusBadRequest)
+	assertUserlandError(t, res, ulanderrors.ErrPublicProfileNotFound)
+
+	testProfileHandlerEnd()
+}
+
+func TestGetPublicProfileRedirect(t *testing.T) {
+	testProfileHandlerInit(t)
+	user := authenticatedUser
+	user.Username = sql.NullString{String: "new_name", Valid: true}
+	withoutUsername := authenticatedUser
+	mockRepo.EXPECT().getPublicUserByUsername(tenancy.DEFAULT_TENANT_ID, "old_name").Return(&user, true, nil)
+	mockRepo.EXPECT().getPublicUserByUsername(tenancy.DEFAULT_TENANT_ID, "older_name").Return(&withoutUsername, true, nil)
+
+	res := testGetPublicProfile(t, "old_name", nil, http.StatusFound)
+	assert.Equal(t, "/api/users/new_name", res.Header().Get("Location"))
+
+	res = testGetPublicProfile(t, "older_name", nil, http.StatusFound)
+	assert.Equal(t, "/api/users/1", res.Header().Get("Location"))
+
+	testProfileHandlerEnd()
+}
+
+func testGetPublicProfile(t *testing.T, handle string, viewer *auth.User, expectedStatusCode int) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(http.MethodGet, "/api/users/"+handle, nil)
+	require.Nil(t, err)
+	if viewer != nil {
+		req = setRequestUserContext(req, viewer)
+	}
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(t, expectedStatusCode, res.Code)
+	return res
+}
+
+func TestSearchUsers(t *testing.T) {
+	testProfileHandlerInit(t)
+	found := []searchedUser{{User: auth.User{Id: 2, Fullname: "Jo", Email: "jo@example.com"}, Key: "jo"}}
+	mockRepo.EXPECT().searchUsers(tenancy.DEFAULT_TENANT_ID, &authenticatedUser, gomock.Any()).DoAndReturn(
+		func(tenantId int, viewer *auth.User, search userSearch) ([]searchedUser, error) {
+			assert.Equal(t, "jo", search.Query)
+			assert.False(t, search.IncludeEmail)
+			return found, nil
+		})
+	mockRepo.EXPECT().searchUsers(tenancy.DEFAULT_TENANT_ID, &authenticatedUser, gomock.Any()).DoAndReturn(
+		func(tenantId int, viewer *auth.User, search userSearch) ([]searchedUser, error) {
+			assert.True(t, search.IncludeEmail)
+			return found, nil
+		})
+	mockRepo.EXPECT().searchUsers(tenancy.DEFAULT_TENANT_ID, &authenticatedUser, gomock.Any()).Return(nil, errors.New("connection refused"))
+
+	res := serveWithUser(t, http.MethodGet, "/api/users?q=jo", &authenticatedUser, nil)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.NotContains(t, res.Body.String(), "jo@example.com")
+
+	res = serveWithUser(t, http.MethodGet, "/api/admin/users/search?q=jo", &authenticatedUser, nil)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Body.String(), `"email":"jo@example.com"`)
+
+	res = serveWithUser(t, http.MethodGet, "/api/users?q=jo", &authenticatedUser, nil)
+	assert.Equal(t, http.StatusInternalServerError, res.Code)
+	assertUserlandError(t, res, ulanderrors.ErrUserSearchQueryExec)
+
+	res = serveWithUser(t, http.MethodGet, "/api/users?limit=1000", &authenticatedUser, nil)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assertUserlandError(t, res, ulanderrors.ErrUserSearchInvalid)
+
+	testProfileHandlerEnd()
+}
+
+func TestPrivacySettings(t *testing.T) {
+	testProfileHandlerInit(t)
+	user := authenticatedUser
+	user.LocationVisibility = VISIBILITY_AUTHENTICATED
+	user.BioVisibility = VISIBILITY_AUTHENTICATED
+	user.WebVisibility = VISIBILITY_PRIVATE
+	user.PictureVisibility = VISIBILITY_PUBLIC
+	settings := PrivacySettings{Location: VISIBILITY_PRIVATE, Bio: VISIBILITY_PUBLIC, Web: VISIBILITY_PUBLIC, Picture: VISIBILITY_AUTHENTICATED}
+	mockRepo.EXPECT().updatePrivacySettings(&user, settings).Return(nil)
+
+	res := serveWithUser(t, http.MethodGet, "/api/me/privacy", &user, nil)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.JSONEq(t, `{"location":"authenticated","bio":"authenticated","web":"private","picture":"public"}`, res.Body.String())
+
+	res = serveWithUser(t, http.MethodPut, "/api/me/privacy", &user, settings)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	settings.Picture = "friends"
+	res = serveWithUser(t, http.MethodPut, "/api/me/privacy", &user, settings)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assertUserlandError(t, res, ulanderrors.ErrPrivacySettingsInvalid)
+
+	testProfileHandlerEnd()
+}
+
 func TestGetEmail(t *testing.T) {
 	testProfileHandlerInit(t)
 
@@ -193,6 +461,26 @@ func TestChangeEmailAddress(t *testing.T) {
 	testProfileHandlerEnd()
 }
 
+func TestChangeEmailAddressSession(t *testing.T) {
+	testProfileHandlerInit(t)
+	user := authenticatedUser
+	emailReq := ChangeEmailRequest{NewEmail: "changedemail@example.com"}
+	mockRepo.EXPECT().changeUserEmail(&user, emailReq.NewEmail).Times(2).Do(func(user *auth.User, newEmail string) {
+		user.TokenVersion++
+	}).Return(nil)
+
+	res := serveWithUser(t, http.MethodPut, "/api/me/email", &user, emailReq)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assertSessionRenewed(t, res, user.TokenVersion)
+
+	emailReq.RevokeCurrentSession = true
+	res = serveWithUser(t, http.MethodPut, "/api/me/email", &user, emailReq)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assertSessionEnded(t, res)
+
+	testProfileHandlerEnd()
+}
+
 func initSuiteAndRepoForChangeEmail() {
 	validEmailReq = ChangeEmailRequest{
 		NewEmail: "changedemail@example.com",
@@ -227,6 +515,30 @@ func TestChangePassword(t *testing.T) {
 	testProfileHandlerEnd()
 }
 
+func TestChangePasswordSession(t *testing.T) {
+	testProfileHandlerInit(t)
+	user := authenticatedUser
+	passwordReq := ChangePasswordRequest{
+		PasswordCurrent: "password",
+		Password:        "passwordnew",
+		PasswordConfirm: "passwordnew",
+	}
+	mockRepo.EXPECT().changeUserPassword(&user, passwordReq.PasswordCurrent, passwordReq.Password).Times(2).Do(func(user *auth.User, oldPassword string, newPassword string) {
+		user.TokenVersion++
+	}).Return(nil)
+
+	res := serveWithUser(t, http.MethodPost, "/api/me/password", &user, passwordReq)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assertSessionRenewed(t, res, user.TokenVersion)
+
+	passwordReq.RevokeCurrentSession = true
+	res = serveWithUser(t, http.MethodPost, "/api/me/password", &user, passwordReq)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assertSessionEnded(t, res)
+
+	testProfileHandlerEnd()
+}
+
 func initSuiteAndRepoForChangePassword() {
 	validChangePassReq = ChangePasswordRequest{
 		PasswordCurrent: "password",
@@ -262,9 +574,43 @@ func testChangeUserPassword(t *testing.T, user *auth.User, changePassReq ChangeP
 
 func TestDeleteAccount(t *testing.T) {
 	testProfileHandlerInit(t)
-	mockRepo.EXPECT().deleteUser(&authenticatedUser, authenticatedUser.Password).Return(nil)
+	os.Setenv("APP_URL", "https://example.com")
+	defer os.Unsetenv("APP_URL")
+
+	user := authenticatedUser
+	gomock.InOrder(
+		mockRepo.EXPECT().deleteUser(&user, user.Password, config.GetAccountDeletionGracePeriod()).DoAndReturn(func(user *auth.User, password string, gracePeriod time.Duration) error {
+			user.DeletedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			user.PurgeAt = sql.NullTime{Time: time.Date(2026, time.November, 18, 0, 0, 0, 0, time.UTC), Valid: true}
+			user.RestoreToken = sql.NullString{String: "restoretoken", Valid: true}
+			return nil
+		}),
+		mockMailer.EXPECT().Send(user.Email, gomock.Any(), gomock.Any()).DoAndReturn(func(recipient string, subject string, body string) error {
+			assert.Contains(t, body, "November 18, 2026")
+			assert.Contains(t, body, "https://example.com/account/restore?token=restoretoken")
+			return nil
+		}),
+	)
+	testDeleteUserAccount(t, &user, http.StatusOK)
+
+	mockRepo.EXPECT().deleteUser(&user, user.Password, gomock.Any()).Return(bcrypt.ErrMismatchedHashAndPassword)
+	testDeleteUserAccount(t, &user, http.StatusBadRequest)
+
+	mockRepo.EXPECT().deleteUser(&user, user.Password, gomock.Any()).Return(sql.ErrConnDone)
+	testDeleteUserAccount(t, &user, http.StatusBadRequest)
 
-	testDeleteUserAccount(t, &authenticatedUser, http.StatusOK)
+	testProfileHandlerEnd()
+}
+
+func TestDirectoryAccountManagedByDirectory(t *testing.T) {
+	testProfileHandlerInit(t)
+	user := authenticatedUser
+	user.DirectoryDN = sql.NullString{String: "uid=jane,ou=people,dc=example,dc=com", Valid: true}
+
+	mockRepo.EXPECT().changeUserPassword(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+	mockRepo.EXPECT().deleteUser(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+	testChangeUserPassword(t, &user, ChangePasswordRequest{PasswordCurrent: "password", Password: "passwordnew", PasswordConfirm: "passwordnew"}, http.StatusForbidden)
+	testDeleteUserAccount(t, &user, http.StatusForbidden)
 
 	testProfileHandlerEnd()
 }
@@ -280,25 +626,152 @@ func testDeleteUserAccount(t *testing.T, user *auth.User, expectedStatusCode int
 	assert.Equal(t, expectedStatusCode, res.Code)
 }
 
+func TestRequestDataExport(t *testing.T) {
+	testProfileHandlerInit(t)
+
+	user := authenticatedUser
+	export := DataExport{Id: 7, Status: DATA_EXPORT_PENDING, CreatedAt: time.Now()}
+	mockRepo.EXPECT().createDataExport(&user).Return(&export, nil)
+	mockRepo.EXPECT().getExportData(gomock.Any()).Times(0)
+	res := testRequestUserDataExport(t, &user, http.StatusAccepted)
+	var accepted DataExport
+	require.Nil(t, json.Unmarshal(res.Body.Bytes(), &accepted))
+	assert.Equal(t, export.Id, accepted.Id)
+	assert.Equal(t, DATA_EXPORT_PENDING, accepted.Status)
+
+	mockRepo.EXPECT().createDataExport(&user).Return(nil, sql.ErrNoRows)
+	testRequestUserDataExport(t, &user, http.StatusTooManyRequests)
+
+	mockRepo.EXPECT().createDataExport(&user).Return(nil, sql.ErrConnDone)
+	testRequestUserDataExport(t, &user, http.StatusBadRequest)
+
+	testProfileHandlerEnd()
+}
+
+func testRequestUserDataExport(t *testing.T, user *auth.User, expectedStatusCode int) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(http.MethodPost, "/api/me/export", nil)
+	require.Nil(t, err)
+	req = setRequestUserContext(req, user)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(t, expectedStatusCode, res.Code)
+	return res
+}
+
+func TestDownloadDataExport(t *testing.T) {
+	testProfileHandlerInit(t)
+
+	archive := []byte("archive")
+	archiveKey := "exports/1/1/7.zip"
+	gomock.InOrder(
+		mockRepo.EXPECT().downloadDataExport(tenancy.DEFAULT_TENANT_ID, hashExportToken("exporttoken")).Return(archiveKey, nil),
+		mockStore.EXPECT().Get(archiveKey).Return(archive, nil),
+		mockStore.EXPECT().Delete(archiveKey).Return(nil),
+		mockRepo.EXPECT().downloadDataExport(tenancy.DEFAULT_TENANT_ID, hashExportToken("exporttoken")).Return("", sql.ErrNoRows),
+		mockRepo.EXPECT().downloadDataExport(tenancy.DEFAULT_TENANT_ID, hashExportToken("lostexport")).Return(archiveKey, nil),
+		mockStore.EXPECT().Get(archiveKey).Return(nil, storage.ErrBlobNotFound),
+	)
+
+	res := testDownloadUserDataExport(t, "exporttoken", http.StatusOK)
+	assert.Equal(t, "application/zip", res.Header().Get("Content-Type"))
+	assert.Equal(t, "no-store", res.Header().Get("Cache-Control"))
+	assert.Equal(t, archive, res.Body.Bytes())
+
+	testDownloadUserDataExport(t, "exporttoken", http.StatusBadRequest)
+	testDownloadUserDataExport(t, "lostexport", http.StatusBadRequest)
+	testDownloadUserDataExport(t, "", http.StatusBadRequest)
+
+	testProfileHandlerEnd()
+}
+
+func testDownloadUserDataExport(t *testing.T, token string, expectedStatusCode int) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(http.MethodGet, "/api/exports/download?token="+token, nil)
+	require.Nil(t, err)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(t, expectedStatusCode, res.Code)
+	return res
+}
+
 func TestUpdateProfilePicture(t *testing.T) {
 	testProfileHandlerInit(t)
-	initChangeProfPicRequest()
+	initChangeProfPicRequest(encodeTestPNG(t, newTestImage(6, 4), nil))
+
+	user := authenticatedUser
+	user.PictureKey = sql.NullString{String: "pictures/1/1/previous", Valid: true}
+	var key string
+	gomock.InOrder(
+		mockStore.EXPECT().Put(gomock.Any(), gomock.Any(), "image/png").Return(nil).Times(len(pictureVariantSizes)),
+		mockStore.EXPECT().Put(gomock.Any(), gomock.Any(), "image/png").DoAndReturn(func(storedKey string, content []byte, contentType string) error {
+			key = storedKey
+			assert.Equal(t, pictureKey(user.TenantId, user.Id, hashPicture(content)), storedKey)
+			return nil
+		}),
+		mockRepo.EXPECT().updateUserPicture(&user, gomock.Any(), "image/png", gomock.Any()).DoAndReturn(func(_ *auth.User, storedKey string, _ string, _ string) error {
+			assert.Equal(t, key, storedKey)
+			return nil
+		}),
+		mockStore.EXPECT().Delete("pictures/1/1/previous").Return(nil),
+		mockStore.EXPECT().Delete(gomock.Any()).Return(nil).Times(len(pictureVariantSizes)),
+	)
+
+	testUpdateUserProfilePicture(t, &user, changeProfPicReq, http.StatusOK)
+
+	testProfileHandlerEnd()
+}
+
+func TestUpdateProfilePictureStoreFailure(t *testing.T) {
+	testProfileHandlerInit(t)
+	initChangeProfPicRequest(encodeTestPNG(t, newTestImage(6, 4), nil))
+
+	mockStore.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("bucket is gone"))
+
+	testUpdateUserProfilePicture(t, &authenticatedUser, changeProfPicReq, http.StatusInternalServerError)
+
+	testProfileHandlerEnd()
+}
+
+func TestUpdateProfilePictureRejected(t *testing.T) {
+	testProfileHandlerInit(t)
+
+	initChangeProfPicRequest([]byte("just some text"))
+	res := testUpdateUserProfilePicture(t, &authenticatedUser, changeProfPicReq, http.StatusBadRequest)
+	assertUserlandError(t, res, ulanderrors.ErrPictureTypeNotAllowed)
+
+	initChangeProfPicRequest(pngPicture)
+	res = testUpdateUserProfilePicture(t, &authenticatedUser, changeProfPicReq, http.StatusBadRequest)
+	assertUserlandError(t, res, ulanderrors.ErrPictureCantBeDecoded)
 
-	mockRepo.EXPECT().updateUserPicture(&authenticatedUser, []byte(SAMPLE_IMAGE_BYTE)).Return(nil)
+	os.Setenv("PICTURE_MAX_DIMENSION", "5")
+	initChangeProfPicRequest(encodeTestPNG(t, newTestImage(6, 4), nil))
+	res = testUpdateUserProfilePicture(t, &authenticatedUser, changeProfPicReq, http.StatusBadRequest)
+	assertUserlandError(t, res, ulanderrors.ErrPictureDimensionsTooLarge)
+	os.Unsetenv("PICTURE_MAX_DIMENSION")
 
-	testUpdateUserProfilePicture(t, &authenticatedUser, changeProfPicReq, http.StatusOK)
+	os.Setenv("PICTURE_MAX_BYTES", "10")
+	initChangeProfPicRequest(encodeTestPNG(t, newTestImage(6, 4), nil))
+	res = testUpdateUserProfilePicture(t, &authenticatedUser, changeProfPicReq, http.StatusBadRequest)
+	assertUserlandError(t, res, ulanderrors.ErrPictureTooLarge)
+	os.Unsetenv("PICTURE_MAX_BYTES")
 
 	testProfileHandlerEnd()
 }
 
-func initChangeProfPicRequest() {
+func assertUserlandError(t *testing.T, res *httptest.ResponseRecorder, expected ulanderrors.UserlandError) {
+	var actual ulanderrors.UserlandError
+	require.Nil(t, json.Unmarshal(res.Body.Bytes(), &actual))
+	assert.Equal(t, expected, actual)
+}
+
+func initChangeProfPicRequest(picture []byte) {
+	profPic.Reset()
 	multipartWriter := multipart.NewWriter(&profPic)
 	fileWriter, err := multipartWriter.CreateFormFile("file", FILE_NAME)
 	if err != nil {
 		panic(err)
 	}
 
-	_, err = io.Copy(fileWriter, bytes.NewBuffer([]byte(SAMPLE_IMAGE_BYTE)))
+	_, err = io.Copy(fileWriter, bytes.NewBuffer(picture))
 	if err != nil {
 		panic(err)
 	}
@@ -309,11 +782,157 @@ func initChangeProfPicRequest() {
 	changeProfPicReq.Header.Set("Content-Type", multipartWriter.FormDataContentType())
 }
 
-func testUpdateUserProfilePicture(t *testing.T, user *auth.User, req *http.Request, expectedStatusCode int) {
+func testUpdateUserProfilePicture(t *testing.T, user *auth.User, req *http.Request, expectedStatusCode int) *httptest.ResponseRecorder {
 	req = setRequestUserContext(req, user)
 	res := httptest.NewRecorder()
 	router.ServeHTTP(res, req)
 	assert.Equal(t, expectedStatusCode, res.Code)
+	return res
+}
+
+func TestGetUserPicture(t *testing.T) {
+	testProfileHandlerInit(t)
+
+	hash := hashPicture(pngPicture)
+	picture := uploadedPicture(pictureKey(tenancy.DEFAULT_TENANT_ID, 2, hash), "image/png", hash)
+	mockRepo.EXPECT().getUserPicture(tenancy.DEFAULT_TENANT_ID, 2).Return(&picture, nil).Times(4)
+	mockStore.EXPECT().Get(picture.Key.String).Return(pngPicture, nil).Times(2)
+
+	res := testGetPicture(t, "/api/users/2/picture", nil, http.StatusOK)
+	assert.Equal(t, "image/png", res.Header().Get("Content-Type"))
+	assert.Equal(t, `"`+hash+`"`, res.Header().Get("ETag"))
+	assert.Equal(t, PICTURE_CACHE_REVALIDATED, res.Header().Get("Cache-Control"))
+	assert.Equal(t, pngPicture, res.Body.Bytes())
+
+	res = testGetPicture(t, "/api/users/2/picture?v="+pictureVersion(hash), map[string]string{"Range": "bytes=0-7"}, http.StatusPartialContent)
+	assert.Equal(t, PICTURE_CACHE_IMMUTABLE, res.Header().Get("Cache-Control"))
+	assert.Equal(t, fmt.Sprintf("bytes 0-7/%d", len(pngPicture)), res.Header().Get("Content-Range"))
+	assert.Equal(t, pngPicture[:8], res.Body.Bytes())
+
+	res = testGetPicture(t, "/api/users/2/picture", map[string]string{"If-None-Match": `"other", W/"` + hash + `"`}, http.StatusNotModified)
+	assert.Equal(t, `"`+hash+`"`, res.Header().Get("ETag"))
+	assert.Empty(t, res.Body.Bytes())
+
+	testGetPicture(t, "/api/users/2/picture", map[string]string{"If-None-Match": "*"}, http.StatusNotModified)
+
+	testProfileHandlerEnd()
+}
+
+func TestGetUserPictureVisibility(t *testing.T) {
+	testProfileHandlerInit(t)
+
+	picture := uploadedPicture("pictures/1/2/hash", "image/png", "hash")
+	picture.Visibility = VISIBILITY_AUTHENTICATED
+	mockRepo.EXPECT().getUserPicture(tenancy.DEFAULT_TENANT_ID, 2).Return(&picture, nil).Times(2)
+	mockStore.EXPECT().Get(picture.Key.String).Return([]byte("picture"), nil)
+
+	res := testGetPicture(t, "/api/users/2/picture?v="+pictureVersion("hash"), nil, http.StatusOK)
+	assert.Equal(t, "image/png", res.Header().Get("Content-Type"))
+	assert.Equal(t, pictureETag(fmt.Sprintf("%s-%d.png", avatarHash(2), AVATAR_DEFAULT_SIZE)), res.Header().Get("ETag"), "Anonymous viewers should get the avatar")
+	assert.Equal(t, PICTURE_CACHE_PRIVATE_REVALIDATED, res.Header().Get("Cache-Control"))
+	assert.Contains(t, res.Header().Get("Vary"), "Cookie")
+
+	req, err := http.NewRequest(http.MethodGet, "/api/users/2/picture?v="+pictureVersion("hash"), nil)
+	require.Nil(t, err)
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, setRequestUserContext(req, &authenticatedUser))
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "picture", res.Body.String())
+	assert.Equal(t, PICTURE_CACHE_PRIVATE_IMMUTABLE, res.Header().Get("Cache-Control"))
+
+	testProfileHandlerEnd()
+}
+
+func TestGetUserPictureVariant(t *testing.T) {
+	testProfileHandlerInit(t)
+
+	picture := uploadedPicture("pictures/1/2/hash", "image/png", "hash")
+	mockRepo.EXPECT().getUserPicture(tenancy.DEFAULT_TENANT_ID, 2).Return(&picture, nil).Times(2)
+	gomock.InOrder(
+		mockStore.EXPECT().Get("pictures/1/2/hash-64").Return([]byte("variant"), nil),
+		mockStore.EXPECT().Get("pictures/1/2/hash-256").Return(nil, storage.ErrBlobNotFound),
+		mockStore.EXPECT().Get("pictures/1/2/hash").Return([]byte("whole"), nil),
+	)
+
+	res := testGetPicture(t, "/api/users/2/picture?size=64", nil, http.StatusOK)
+	assert.Equal(t, `"hash-64"`, res.Header().Get("ETag"))
+	assert.Equal(t, "variant", res.Body.String())
+
+	res = testGetPicture(t, "/api/users/2/picture?size=256", nil, http.StatusOK)
+	assert.Equal(t, "whole", res.Body.String())
+
+	res = testGetPicture(t, "/api/users/2/picture?size=100", nil, http.StatusBadRequest)
+	assertUserlandError(t, res, ulanderrors.ErrPictureSizeInvalid)
+	testGetPicture(t, "/api/users/2/picture?size=big", nil, http.StatusBadRequest)
+
+	testProfileHandlerEnd()
+}
+
+func TestGetUserPictureFailures(t *testing.T) {
+	testProfileHandlerInit(t)
+
+	picture := uploadedPicture("pictures/1/2/hash", "image/png", "hash")
+	gomock.InOrder(
+		mockRepo.EXPECT().getUserPicture(tenancy.DEFAULT_TENANT_ID, 3).Return(nil, sql.ErrNoRows),
+		mockRepo.EXPECT().getUserPicture(tenancy.DEFAULT_TENANT_ID, 2).Return(&picture, nil),
+		mockStore.EXPECT().Get(picture.Key.String).Return(nil, storage.ErrBlobNotFound),
+	)
+
+	testGetPicture(t, "/api/users/3/picture", nil, http.StatusBadRequest)
+	testGetPicture(t, "/api/users/someone/picture", nil, http.StatusBadRequest)
+	res := testGetPicture(t, "/api/users/2/picture", nil, http.StatusInternalServerError)
+	assert.Empty(t, res.Header().Get("ETag"))
+
+	testProfileHandlerEnd()
+}
+
+func TestGetUserAvatar(t *testing.T) {
+	testProfileHandlerInit(t)
+
+	hash := avatarHash(2)
+	mockRepo.EXPECT().getUserPicture(tenancy.DEFAULT_TENANT_ID, 2).Return(&userPicture{}, nil).Times(4)
+
+	res := testGetPicture(t, "/api/users/2/picture?v="+pictureVersion(hash), nil, http.StatusOK)
+	assert.Equal(t, "image/png", res.Header().Get("Content-Type"))
+	assert.Equal(t, fmt.Sprintf(`"%s-%d.png"`, hash, AVATAR_DEFAULT_SIZE), res.Header().Get("ETag"))
+	assert.Equal(t, PICTURE_CACHE_IMMUTABLE, res.Header().Get("Cache-Control"))
+	assert.Equal(t, image.Rect(0, 0, AVATAR_DEFAULT_SIZE, AVATAR_DEFAULT_SIZE), decodeTestPicture(t, res.Body.Bytes()).Bounds())
+
+	res = testGetPicture(t, "/api/users/2/picture?size=64&format=svg", nil, http.StatusOK)
+	assert.Equal(t, "image/svg+xml", res.Header().Get("Content-Type"))
+	assert.Equal(t, PICTURE_CACHE_REVALIDATED, res.Header().Get("Cache-Control"))
+	assert.Contains(t, res.Body.String(), `width="64" height="64"`)
+
+	testGetPicture(t, "/api/users/2/picture?size=64&format=svg", map[string]string{"If-None-Match": res.Header().Get("ETag")}, http.StatusNotModified)
+
+	res = testGetPicture(t, "/api/users/2/picture?format=gif", nil, http.StatusBadRequest)
+	assertUserlandError(t, res, ulanderrors.ErrPictureFormatInvalid)
+
+	res = testGetPicture(t, "/api/users/2/picture?size=64", nil, http.StatusOK)
+	assert.Equal(t, image.Rect(0, 0, 64, 64), decodeTestPicture(t, res.Body.Bytes()).Bounds())
+
+	testProfileHandlerEnd()
+}
+
+func uploadedPicture(key string, contentType string, hash string) userPicture {
+	return userPicture{
+		Key:         sql.NullString{String: key, Valid: true},
+		ContentType: sql.NullString{String: contentType, Valid: true},
+		Hash:        sql.NullString{String: hash, Valid: true},
+		Visibility:  VISIBILITY_PUBLIC,
+	}
+}
+
+func testGetPicture(t *testing.T, url string, headers map[string]string, expectedStatusCode int) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.Nil(t, err)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(t, expectedStatusCode, res.Code)
+	return res
 }
 
 func TestDeleteProfilePicture(t *testing.T) {
@@ -322,6 +941,17 @@ func TestDeleteProfilePicture(t *testing.T) {
 
 	testDeleteUserProfilePicture(t, &authenticatedUser, http.StatusOK)
 
+	user := authenticatedUser
+	user.PictureKey = sql.NullString{String: "pictures/1/1/hash", Valid: true}
+	gomock.InOrder(
+		mockRepo.EXPECT().deleteUserPicture(&user).Return(nil),
+		mockStore.EXPECT().Delete("pictures/1/1/hash").Return(nil),
+		mockStore.EXPECT().Delete("pictures/1/1/hash-64").Return(nil),
+		mockStore.EXPECT().Delete("pictures/1/1/hash-256").Return(nil),
+		mockStore.EXPECT().Delete("pictures/1/1/hash-512").Return(nil),
+	)
+	testDeleteUserProfilePicture(t, &user, http.StatusOK)
+
 	testProfileHandlerEnd()
 }
 
@@ -333,3 +963,54 @@ func testDeleteUserProfilePicture(t *testing.T, user *auth.User, expectedStatusC
 	router.ServeHTTP(res, req)
 	assert.Equal(t, expectedStatusCode, res.Code)
 }
+
+func serveWithUser(t *testing.T, method string, url string, user *auth.User, body interface{}) *httptest.ResponseRecorder {
+	data, err := json.Marshal(body)
+	require.Nil(t, err)
+	req, err := http.NewRequest(method, url, bytes.NewReader(data))
+	require.Nil(t, err)
+	req = setRequestUserContext(req, user)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	return res
+}
+
+// serveProfileWrite serves a write to the profile of the user, as a client
+// that read its current version.
+func serveProfileWrite(t *testing.T, method string, user *auth.User, body interface{}) *httptest.ResponseRecorder {
+	data, err := json.Marshal(body)
+	require.Nil(t, err)
+	req, err := http.NewRequest(method, "/api/me", bytes.NewReader(data))
+	require.Nil(t, err)
+	req.Header.Set("If-Match", profileETag(user.ProfileVersion))
+	req = setRequestUserContext(req, user)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	return res
+}
+
+func findSessionCookie(t *testing.T, res *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range res.Result().Cookies() {
+		if cookie.Name == auth.SESSION_COOKIE {
+			return cookie
+		}
+	}
+	require.FailNow(t, "Response should set the session cookie")
+	return nil
+}
+
+func assertSessionRenewed(t *testing.T, res *httptest.ResponseRecorder, tokenVersion int) {
+	cookie := findSessionCookie(t, res)
+	claims := &auth.Claims{}
+	_, err := jwt.ParseWithClaims(cookie.Value, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(""), nil
+	})
+	require.Nil(t, err)
+	assert.Equal(t, tokenVersion, claims.TokenVersion, "Current session should carry the new token version")
+}
+
+func assertSessionEnded(t *testing.T, res *httptest.ResponseRecorder) {
+	cookie := findSessionCookie(t, res)
+	assert.Empty(t, cookie.Value)
+	assert.True(t, cookie.MaxAge < 0, "Current session cookie should be cleared")
+}
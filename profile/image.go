@@ -0,0 +1,267 @@
+package profile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	PICTURE_JPEG_QUALITY = 90
+
+	EXIF_ORIENTATION_TAG = 0x0112
+	EXIF_TYPE_SHORT      = 3
+)
+
+var (
+	// pictureVariantSizes are the sides of the square variants generated for
+	// every picture, in pixels.
+	pictureVariantSizes = []int{64, 256, 512}
+
+	allowedPictureTypes = map[string]bool{
+		"image/jpeg": true,
+		"image/png":  true,
+		"image/gif":  true,
+		"image/webp": true,
+	}
+
+	errPictureTypeNotAllowed     = errors.New("Picture is not a JPEG, PNG, WebP or GIF image")
+	errPictureDimensionsTooLarge = errors.New("Picture dimensions exceed the maximum")
+	errPictureCorrupt            = errors.New("Picture cannot be decoded")
+
+	exifHeader = []byte("Exif\x00\x00")
+)
+
+// processedPicture is an uploaded picture ready to be stored, along with its
+// variants by size.
+type processedPicture struct {
+	Content     []byte
+	ContentType string
+	Variants    map[int][]byte
+}
+
+// processPicture checks an uploaded picture and encodes it again from its
+// pixels, which leaves EXIF, GPS and any other metadata behind. The pixels
+// are turned upright first, as the orientation tag goes away with the rest.
+// JPEG pictures stay JPEG, the others become PNG, which keeps transparency.
+// Only the first frame of animated pictures is kept.
+func processPicture(content []byte, maxDimension int) (*processedPicture, error) {
+	contentType := http.DetectContentType(content)
+	if !allowedPictureTypes[contentType] {
+		return nil, errPictureTypeNotAllowed
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil || config.Width <= 0 || config.Height <= 0 {
+		return nil, errPictureCorrupt
+	}
+	if config.Width > maxDimension || config.Height > maxDimension {
+		return nil, errPictureDimensionsTooLarge
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, errPictureCorrupt
+	}
+	upright := orient(decoded, pictureOrientation(content, contentType))
+
+	if contentType != "image/jpeg" {
+		contentType = "image/png"
+	}
+	picture := processedPicture{ContentType: contentType, Variants: map[int][]byte{}}
+	picture.Content, err = encodePicture(upright, contentType)
+	if err != nil {
+		return nil, err
+	}
+	for _, size := range pictureVariantSizes {
+		picture.Variants[size], err = encodePicture(squareVariant(upright, size), contentType)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &picture, nil
+}
+
+func encodePicture(img image.Image, contentType string) ([]byte, error) {
+	var buffer bytes.Buffer
+	var err error
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(&buffer, img, &jpeg.Options{Quality: PICTURE_JPEG_QUALITY})
+	} else {
+		err = png.Encode(&buffer, img)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// squareVariant scales the largest centered square of the picture to the
+// size.
+func squareVariant(img *image.NRGBA, size int) *image.NRGBA {
+	bounds := img.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	crop := image.Rect(0, 0, side, side).Add(bounds.Min).Add(image.Pt((bounds.Dx()-side)/2, (bounds.Dy()-side)/2))
+
+	variant := image.NewNRGBA(image.Rect(0, 0, size, size))
+	draw.CatmullRom.Scale(variant, variant.Bounds(), img, crop, draw.Src, nil)
+	return variant
+}
+
+// orient applies an EXIF orientation to the pixels, 1 leaving them as they
+// are and 5 to 8 swapping width and height.
+func orient(img image.Image, orientation int) *image.NRGBA {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	src := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	if orientation >= 5 {
+		dst = image.NewNRGBA(image.Rect(0, 0, height, width))
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = width-1-x, y
+			case 3:
+				dx, dy = width-1-x, height-1-y
+			case 4:
+				dx, dy = x, height-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = height-1-y, x
+			case 7:
+				dx, dy = height-1-y, width-1-x
+			case 8:
+				dx, dy = y, width-1-x
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):], src.Pix[src.PixOffset(x, y):src.PixOffset(x, y)+4])
+		}
+	}
+	return dst
+}
+
+// pictureOrientation reads the EXIF orientation of the picture, from wherever
+// its format keeps EXIF data. Pictures without one are upright, i.e. 1.
+func pictureOrientation(content []byte, contentType string) int {
+	var exif []byte
+	switch contentType {
+	case "image/jpeg":
+		exif = jpegExif(content)
+	case "image/png":
+		exif = pngChunk(content, "eXIf")
+	case "image/webp":
+		exif = webpChunk(content, "EXIF")
+	}
+	return exifOrientation(bytes.TrimPrefix(exif, exifHeader))
+}
+
+// jpegExif returns the APP1 segment holding EXIF data, looking through the
+// segments preceding the image data.
+func jpegExif(content []byte) []byte {
+	offset := 2
+	for offset+4 <= len(content) && content[offset] == 0xFF {
+		marker := content[offset+1]
+		if marker == 0xDA || marker == 0xD9 {
+			return nil
+		}
+		length := int(binary.BigEndian.Uint16(content[offset+2:]))
+		end := offset + 2 + length
+		if length < 2 || end > len(content) {
+			return nil
+		}
+		if marker == 0xE1 && bytes.HasPrefix(content[offset+4:end], exifHeader) {
+			return content[offset+4 : end]
+		}
+		offset = end
+	}
+	return nil
+}
+
+func pngChunk(content []byte, chunkType string) []byte {
+	offset := 8
+	for offset+8 <= len(content) {
+		length := int64(binary.BigEndian.Uint32(content[offset:]))
+		end := int64(offset) + 8 + length
+		if end > int64(len(content)) {
+			return nil
+		}
+		if string(content[offset+4:offset+8]) == chunkType {
+			return content[offset+8 : end]
+		}
+		offset = int(end) + 4
+	}
+	return nil
+}
+
+func webpChunk(content []byte, fourCC string) []byte {
+	offset := 12
+	for offset+8 <= len(content) {
+		length := int64(binary.LittleEndian.Uint32(content[offset+4:]))
+		end := int64(offset) + 8 + length
+		if end > int64(len(content)) {
+			return nil
+		}
+		if string(content[offset:offset+4]) == fourCC {
+			return content[offset+8 : end]
+		}
+		offset = int(end + length%2)
+	}
+	return nil
+}
+
+// exifOrientation looks the orientation tag up in the first directory of
+// the EXIF data, which is a TIFF structure.
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:4]) {
+	case "II*\x00":
+		order = binary.LittleEndian
+	case "MM\x00*":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	directory := int64(order.Uint32(tiff[4:]))
+	if directory < 8 || directory+2 > int64(len(tiff)) {
+		return 1
+	}
+	entries := int64(order.Uint16(tiff[directory:]))
+	for i := int64(0); i < entries; i++ {
+		entry := directory + 2 + i*12
+		if entry+12 > int64(len(tiff)) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) != EXIF_ORIENTATION_TAG {
+			continue
+		}
+		orientation := int(order.Uint16(tiff[entry+8:]))
+		if order.Uint16(tiff[entry+2:]) != EXIF_TYPE_SHORT || orientation < 1 || orientation > 8 {
+			return 1
+		}
+		return orientation
+	}
+	return 1
+}
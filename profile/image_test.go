@@ -0,0 +1,175 @@
+package profile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestImage has a different color in every pixel, so that moving pixels
+// around shows.
+func newTestImage(width int, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 40), G: uint8(y * 40), B: 100, A: 255})
+		}
+	}
+	return img
+}
+
+// testExif is EXIF data with the orientation and a GPS directory pointer,
+// in the byte order given.
+func testExif(order binary.ByteOrder, orientation int) []byte {
+	tiff := make([]byte, 38)
+	if order == binary.LittleEndian {
+		copy(tiff, "II*\x00")
+	} else {
+		copy(tiff, "MM\x00*")
+	}
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 2)
+	order.PutUint16(tiff[10:], EXIF_ORIENTATION_TAG)
+	order.PutUint16(tiff[12:], EXIF_TYPE_SHORT)
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], uint16(orientation))
+	order.PutUint16(tiff[22:], 0x8825)
+	order.PutUint16(tiff[24:], 4)
+	order.PutUint32(tiff[26:], 1)
+	order.PutUint32(tiff[30:], 38)
+	return append(append([]byte{}, exifHeader...), tiff...)
+}
+
+func encodeTestPNG(t *testing.T, img image.Image, exif []byte) []byte {
+	var buffer bytes.Buffer
+	require.Nil(t, png.Encode(&buffer, img))
+	content := buffer.Bytes()
+	if exif == nil {
+		return content
+	}
+
+	// The chunk goes right after the header chunk, which is 25 bytes long.
+	chunk := make([]byte, 8, 12+len(exif))
+	binary.BigEndian.PutUint32(chunk, uint32(len(exif)))
+	copy(chunk[4:], "eXIf")
+	chunk = append(chunk, exif...)
+	chunk = append(chunk, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(chunk[len(chunk)-4:], crc32.ChecksumIEEE(chunk[4:len(chunk)-4]))
+	return append(append(append([]byte{}, content[:33]...), chunk...), content[33:]...)
+}
+
+func encodeTestJPEG(t *testing.T, img image.Image, exif []byte) []byte {
+	var buffer bytes.Buffer
+	require.Nil(t, jpeg.Encode(&buffer, img, nil))
+	content := buffer.Bytes()
+	if exif == nil {
+		return content
+	}
+
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(exif)+2))
+	segment = append(segment, exif...)
+	return append(append(append([]byte{}, content[:2]...), segment...), content[2:]...)
+}
+
+func decodeTestPicture(t *testing.T, content []byte) image.Image {
+	img, _, err := image.Decode(bytes.NewReader(content))
+	require.Nil(t, err)
+	return img
+}
+
+func TestProcessPicture(t *testing.T) {
+	picture, err := processPicture(encodeTestPNG(t, newTestImage(6, 4), testExif(binary.BigEndian, 1)), 100)
+	require.Nil(t, err)
+	assert.Equal(t, "image/png", picture.ContentType)
+	assert.NotContains(t, string(picture.Content), "Exif")
+	assert.Equal(t, image.Rect(0, 0, 6, 4), decodeTestPicture(t, picture.Content).Bounds())
+
+	require.Len(t, picture.Variants, len(pictureVariantSizes))
+	for _, size := range pictureVariantSizes {
+		assert.Equal(t, image.Rect(0, 0, size, size), decodeTestPicture(t, picture.Variants[size]).Bounds())
+	}
+}
+
+func TestProcessPictureJPEG(t *testing.T) {
+	original := encodeTestJPEG(t, newTestImage(6, 4), testExif(binary.LittleEndian, 6))
+	require.Equal(t, 6, pictureOrientation(original, "image/jpeg"))
+
+	picture, err := processPicture(original, 100)
+	require.Nil(t, err)
+	assert.Equal(t, "image/jpeg", picture.ContentType)
+	assert.False(t, bytes.Contains(picture.Content, exifHeader))
+	assert.Equal(t, image.Rect(0, 0, 4, 6), decodeTestPicture(t, picture.Content).Bounds())
+}
+
+func TestProcessPictureOrientation(t *testing.T) {
+	original := newTestImage(3, 2)
+	picture, err := processPicture(encodeTestPNG(t, original, testExif(binary.BigEndian, 6)), 100)
+	require.Nil(t, err)
+
+	// Rotated clockwise, the left column becomes the top row.
+	upright := decodeTestPicture(t, picture.Content)
+	require.Equal(t, image.Rect(0, 0, 2, 3), upright.Bounds())
+	assert.Equal(t, original.At(0, 1), color.NRGBAModel.Convert(upright.At(0, 0)))
+	assert.Equal(t, original.At(0, 0), color.NRGBAModel.Convert(upright.At(1, 0)))
+	assert.Equal(t, original.At(2, 1), color.NRGBAModel.Convert(upright.At(0, 2)))
+}
+
+func TestProcessPictureRejected(t *testing.T) {
+	_, err := processPicture([]byte("just some text"), 100)
+	assert.Equal(t, errPictureTypeNotAllowed, err)
+
+	_, err = processPicture([]byte("BM\x00\x00\x00\x00"), 100)
+	assert.Equal(t, errPictureTypeNotAllowed, err)
+
+	_, err = processPicture(pngPicture, 100)
+	assert.Equal(t, errPictureCorrupt, err)
+
+	_, err = processPicture(encodeTestPNG(t, newTestImage(6, 4), nil), 5)
+	assert.Equal(t, errPictureDimensionsTooLarge, err)
+}
+
+func TestOrient(t *testing.T) {
+	original := newTestImage(3, 2)
+	corners := map[int][4]image.Point{
+		// Where the top-left, top-right, bottom-left and bottom-right pixels end up.
+		1: {{0, 0}, {2, 0}, {0, 1}, {2, 1}},
+		2: {{2, 0}, {0, 0}, {2, 1}, {0, 1}},
+		3: {{2, 1}, {0, 1}, {2, 0}, {0, 0}},
+		4: {{0, 1}, {2, 1}, {0, 0}, {2, 0}},
+		5: {{0, 0}, {0, 2}, {1, 0}, {1, 2}},
+		6: {{1, 0}, {1, 2}, {0, 0}, {0, 2}},
+		7: {{1, 2}, {1, 0}, {0, 2}, {0, 0}},
+		8: {{0, 2}, {0, 0}, {1, 2}, {1, 0}},
+	}
+	for orientation, points := range corners {
+		oriented := orient(original, orientation)
+		for i, corner := range []image.Point{{0, 0}, {2, 0}, {0, 1}, {2, 1}} {
+			assert.Equal(t, original.At(corner.X, corner.Y), oriented.At(points[i].X, points[i].Y), "orientation %d", orientation)
+		}
+	}
+}
+
+func TestPictureOrientation(t *testing.T) {
+	assert.Equal(t, 1, pictureOrientation(encodeTestPNG(t, newTestImage(2, 2), nil), "image/png"))
+	assert.Equal(t, 8, pictureOrientation(encodeTestPNG(t, newTestImage(2, 2), testExif(binary.LittleEndian, 8)), "image/png"))
+	assert.Equal(t, 1, pictureOrientation(encodeTestJPEG(t, newTestImage(2, 2), testExif(binary.BigEndian, 9)), "image/jpeg"))
+
+	exif := testExif(binary.BigEndian, 3)
+	chunk := make([]byte, 8)
+	copy(chunk, "EXIF")
+	binary.LittleEndian.PutUint32(chunk[4:], uint32(len(exif)))
+	webp := append([]byte("RIFF\x00\x00\x00\x00WEBPVP8X\x0a\x00\x00\x00\x08\x00\x00\x00\x00\x00\x00\x00\x00\x00"), chunk...)
+	webp = append(webp, exif...)
+	assert.Equal(t, 3, pictureOrientation(webp, "image/webp"))
+
+	assert.Equal(t, 1, exifOrientation([]byte("II*\x00\xff\xff\xff\xff")))
+}
@@ -6,13 +6,14 @@ import (
 )
 
 type UserProfile struct {
-	Id             int       `json:"id"`
-	Fullname       string    `json:"fullname"`
-	Location       string    `json:"location"`
-	Bio            string    `json:"bio"`
-	Web            string    `json:"web"`
-	ProfilePicture []byte    `json:"picture" db:"picture"`
-	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	Id         int       `json:"id"`
+	Fullname   string    `json:"fullname"`
+	Username   *string   `json:"username,omitempty"`
+	Location   string    `json:"location"`
+	Bio        string    `json:"bio"`
+	Web        string    `json:"web"`
+	PictureURL string    `json:"picture_url,omitempty"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
 }
 
 func (user UserProfile) hasValidProfile() bool {
@@ -37,7 +38,8 @@ func (user UserProfile) hasValidWeb() bool {
 }
 
 type ChangeEmailRequest struct {
-	NewEmail string `json:"email"`
+	NewEmail             string `json:"email"`
+	RevokeCurrentSession bool   `json:"revoke_current_session"`
 }
 
 func (req ChangeEmailRequest) hasValidEmail() bool {
@@ -49,6 +51,8 @@ type ChangePasswordRequest struct {
 	PasswordCurrent string `json:"password_current"`
 	Password        string `json:"password"`
 	PasswordConfirm string `json:"password_confirm"`
+
+	RevokeCurrentSession bool `json:"revoke_current_session"`
 }
 
 func (req ChangePasswordRequest) hasMatchingNewPassword() bool {
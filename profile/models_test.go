@@ -21,13 +21,12 @@ const (
 
 func resetUserProfileModel() {
 	userProfile = UserProfile{
-		Id:             1,
-		Fullname:       "user",
-		Location:       "Jakarta, Indonesia",
-		Bio:            "hello",
-		Web:            "https://example.com",
-		ProfilePicture: nil,
-		CreatedAt:      time.Now(),
+		Id:        1,
+		Fullname:  "user",
+		Location:  "Jakarta, Indonesia",
+		Bio:       "hello",
+		Web:       "https://example.com",
+		CreatedAt: time.Now(),
 	}
 }
 
@@ -0,0 +1,118 @@
+package profile
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var jsonNull = []byte("null")
+
+// ProfilePatch is a JSON merge patch (RFC 7396) of the profile. Fields left
+// out of the patch are nil and stay as they are, while an explicit null
+// clears location, bio and web, and removes the username.
+type ProfilePatch struct {
+	Fullname *string
+	Username *string
+	Location *sql.NullString
+	Bio      *sql.NullString
+	Web      *sql.NullString
+}
+
+// UnmarshalJSON tells fields set to null apart from those left out. Members
+// that aren't profile fields, such as the read only id, are ignored as they
+// are with PUT.
+func (patch *ProfilePatch) UnmarshalJSON(data []byte) error {
+	var members map[string]json.RawMessage
+	err := json.Unmarshal(data, &members)
+	if err != nil {
+		return err
+	}
+	if members == nil {
+		return errors.New("Profile patch must be an object")
+	}
+
+	if value, ok := members["fullname"]; ok {
+		if bytes.Equal(value, jsonNull) {
+			return errors.New("Fullname can't be cleared")
+		}
+		patch.Fullname = new(string)
+		err = json.Unmarshal(value, patch.Fullname)
+		if err != nil {
+			return err
+		}
+	}
+
+	if value, ok := members["username"]; ok {
+		patch.Username = new(string)
+		if !bytes.Equal(value, jsonNull) {
+			err = json.Unmarshal(value, patch.Username)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for name, field := range map[string]**sql.NullString{"location": &patch.Location, "bio": &patch.Bio, "web": &patch.Web} {
+		value, ok := members[name]
+		if !ok {
+			continue
+		}
+		*field = &sql.NullString{}
+		if !bytes.Equal(value, jsonNull) {
+			err = json.Unmarshal(value, &(*field).String)
+			if err != nil {
+				return err
+			}
+			(*field).Valid = true
+		}
+	}
+	return nil
+}
+
+// hasValidProfile checks the fields being set the way a full update would,
+// cleared fields always being valid.
+func (patch ProfilePatch) hasValidProfile() bool {
+	if patch.Fullname != nil && !(UserProfile{Fullname: *patch.Fullname}).hasValidFullname() {
+		return false
+	}
+	if patch.Location != nil && !(UserProfile{Location: patch.Location.String}).hasValidLocation() {
+		return false
+	}
+	if patch.Bio != nil && !(UserProfile{Bio: patch.Bio.String}).hasValidBio() {
+		return false
+	}
+	if patch.Web != nil && patch.Web.Valid && !(UserProfile{Web: patch.Web.String}).hasValidWeb() {
+		return false
+	}
+	return true
+}
+
+// assignments returns the SET clause of the profile columns in the patch, and
+// their values numbered from $1. It's empty when the patch only has the
+// username, or nothing at all.
+func (patch ProfilePatch) assignments() (string, []interface{}) {
+	var columns []string
+	var args []interface{}
+	assign := func(column string, value interface{}) {
+		args = append(args, value)
+		columns = append(columns, fmt.Sprintf("%s=$%d", column, len(args)))
+	}
+
+	if patch.Fullname != nil {
+		assign("fullname", *patch.Fullname)
+	}
+	if patch.Location != nil {
+		assign("location", *patch.Location)
+	}
+	if patch.Bio != nil {
+		assign("bio", *patch.Bio)
+	}
+	if patch.Web != nil {
+		assign("web", *patch.Web)
+	}
+	return strings.Join(columns, ", "), args
+}
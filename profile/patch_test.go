@@ -0,0 +1,48 @@
+package profile
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfilePatchUnmarshal(t *testing.T) {
+	var patch ProfilePatch
+	err := json.Unmarshal([]byte(`{"id": 9, "bio": "hello", "web": null, "username": null}`), &patch)
+	require.Nil(t, err)
+	assert.Nil(t, patch.Fullname)
+	assert.Nil(t, patch.Location, "Fields left out shouldn't be changed")
+	assert.Equal(t, &sql.NullString{String: "hello", Valid: true}, patch.Bio)
+	assert.Equal(t, &sql.NullString{}, patch.Web, "Null should clear the field")
+	require.NotNil(t, patch.Username)
+	assert.Equal(t, "", *patch.Username, "Null should remove the username")
+
+	for _, data := range []string{`null`, `[]`, `"bio"`, `{"fullname": null}`, `{"bio": 3}`} {
+		assert.NotNil(t, json.Unmarshal([]byte(data), &ProfilePatch{}), data)
+	}
+}
+
+func TestProfilePatchValidity(t *testing.T) {
+	assert.True(t, ProfilePatch{}.hasValidProfile())
+	assert.True(t, ProfilePatch{Web: &sql.NullString{}}.hasValidProfile(), "Clearing the web address should be valid")
+
+	name := STR_LEN_LESS_THAN_3
+	assert.False(t, ProfilePatch{Fullname: &name}.hasValidProfile())
+	assert.False(t, ProfilePatch{Location: &sql.NullString{String: STR_LEN_MORE_THAN_128, Valid: true}}.hasValidProfile())
+	assert.False(t, ProfilePatch{Web: &sql.NullString{String: INVALID_WEB, Valid: true}}.hasValidProfile())
+}
+
+func TestProfilePatchAssignments(t *testing.T) {
+	columns, args := ProfilePatch{}.assignments()
+	assert.Equal(t, "", columns)
+	assert.Empty(t, args)
+
+	name := "updateduser"
+	bio := sql.NullString{}
+	columns, args = ProfilePatch{Fullname: &name, Bio: &bio}.assignments()
+	assert.Equal(t, "fullname=$1, bio=$2", columns)
+	assert.Equal(t, []interface{}{"updateduser", sql.NullString{}}, args)
+}
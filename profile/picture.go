@@ -0,0 +1,192 @@
+package profile
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"userland/config"
+	"userland/storage"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	PICTURE_MIGRATION_BATCH_SIZE = 50
+
+	// PICTURE_FORM_OVERHEAD is allowed on top of the picture for the rest of
+	// the multipart form.
+	PICTURE_FORM_OVERHEAD = 64 << 10
+
+	// PICTURE_VERSION_LENGTH is how much of the hash goes into picture URLs.
+	PICTURE_VERSION_LENGTH = 16
+
+	// Versioned URLs never change content, so they can be kept for good.
+	// Others must be revalidated against the ETag on every use.
+	PICTURE_CACHE_IMMUTABLE   = "public, max-age=31536000, immutable"
+	PICTURE_CACHE_REVALIDATED = "public, no-cache"
+
+	// Pictures that not everyone may see are kept out of shared caches.
+	PICTURE_CACHE_PRIVATE_IMMUTABLE   = "private, max-age=31536000, immutable"
+	PICTURE_CACHE_PRIVATE_REVALIDATED = "private, no-cache"
+)
+
+// pictureKey is where a picture of the user is kept. It includes the hash of
+// the picture so that uploading a new one never overwrites the one being
+// served.
+func pictureKey(tenantId int, userId int, hash string) string {
+	return fmt.Sprintf("pictures/%d/%d/%s", tenantId, userId, hash)
+}
+
+// pictureVariantKey is where the square variant of the size is kept, next to
+// the picture it was made from.
+func pictureVariantKey(key string, size int) string {
+	return fmt.Sprintf("%s-%d", key, size)
+}
+
+func isPictureVariantSize(size int) bool {
+	for _, variantSize := range pictureVariantSizes {
+		if size == variantSize {
+			return true
+		}
+	}
+	return false
+}
+
+// storePicture puts the variants before the picture itself, so that a stored
+// picture always comes with them.
+func storePicture(store storage.BlobStore, key string, picture *processedPicture) error {
+	for _, size := range pictureVariantSizes {
+		variant, ok := picture.Variants[size]
+		if !ok {
+			continue
+		}
+		err := store.Put(pictureVariantKey(key, size), variant, picture.ContentType)
+		if err != nil {
+			return err
+		}
+	}
+	return store.Put(key, picture.Content, picture.ContentType)
+}
+
+// DeletePicture removes a stored picture along with its variants. Every blob
+// is attempted, the first failure being returned.
+func DeletePicture(store storage.BlobStore, key string) error {
+	err := store.Delete(key)
+	for _, size := range pictureVariantSizes {
+		variantErr := store.Delete(pictureVariantKey(key, size))
+		if err == nil {
+			err = variantErr
+		}
+	}
+	return err
+}
+
+func hashPicture(picture []byte) string {
+	hash := sha256.Sum256(picture)
+	return hex.EncodeToString(hash[:])
+}
+
+// pictureURL is where the picture of the user is served. The version changes
+// with the picture, so that clients never show a stale one.
+func pictureURL(userId int, hash string) string {
+	return fmt.Sprintf("/api/users/%d/picture?v=%s", userId, pictureVersion(hash))
+}
+
+func pictureVersion(hash string) string {
+	if len(hash) > PICTURE_VERSION_LENGTH {
+		return hash[:PICTURE_VERSION_LENGTH]
+	}
+	return hash
+}
+
+// pictureETag is a strong validator, the content hash being known for every
+// stored picture.
+func pictureETag(hash string) string {
+	return `"` + hash + `"`
+}
+
+// etagMatches tells whether an If-None-Match header lists the ETag, using the
+// weak comparison the header calls for.
+func etagMatches(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// userPicture locates the picture of a user in the blob store, for users who
+// uploaded one, and tells who may see it.
+type userPicture struct {
+	Key         sql.NullString `db:"picture_key"`
+	ContentType sql.NullString `db:"picture_content_type"`
+	Hash        sql.NullString `db:"picture_hash"`
+	Visibility  string         `db:"picture_visibility"`
+}
+
+// pendingPicture is a picture taken out of the user table by the migration to
+// the blob store, not copied to the store yet.
+type pendingPicture struct {
+	UserId   int    `db:"user_id"`
+	TenantId int    `db:"tenant_id"`
+	Picture  []byte `db:"picture"`
+}
+
+// PictureMover copies the pictures parked by the migration to the blob store
+// into it and points their users at them.
+type PictureMover struct {
+	ProfileRepo profileRepositoryInterface
+	Store       storage.BlobStore
+}
+
+// Run moves every pending picture. It stops at the first failure, leaving the
+// rest to be moved on the next start.
+func (mover PictureMover) Run() {
+	moved := 0
+	for {
+		pictures, err := mover.ProfileRepo.getPendingPictures(PICTURE_MIGRATION_BATCH_SIZE)
+		if err != nil {
+			log.Warn(err)
+			return
+		}
+		if len(pictures) == 0 {
+			break
+		}
+
+		for _, picture := range pictures {
+			err = mover.move(picture)
+			if err != nil {
+				log.Warn(err)
+				return
+			}
+			moved++
+		}
+	}
+
+	if moved > 0 {
+		log.Info(fmt.Sprintf("Moved %d pictures to the blob store", moved))
+	}
+}
+
+// move processes the picture the way uploads are. Pictures that wouldn't be
+// accepted today are moved as they are, without variants, rather than lost.
+func (mover PictureMover) move(picture pendingPicture) error {
+	processed, err := processPicture(picture.Picture, config.GetPictureMaxDimension())
+	if err != nil {
+		log.Info(fmt.Sprintf("Picture of user %d is moved unprocessed: %v", picture.UserId, err))
+		processed = &processedPicture{Content: picture.Picture, ContentType: http.DetectContentType(picture.Picture)}
+	}
+	hash := hashPicture(processed.Content)
+	key := pictureKey(picture.TenantId, picture.UserId, hash)
+
+	err = storePicture(mover.Store, key, processed)
+	if err != nil {
+		return err
+	}
+	return mover.ProfileRepo.completePictureMove(picture.UserId, key, processed.ContentType, hash)
+}
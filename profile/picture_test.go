@@ -0,0 +1,103 @@
+package profile
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+	"userland/config"
+	"userland/storage"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPictureKey(t *testing.T) {
+	hash := hashPicture(pngPicture)
+	assert.Len(t, hash, 64)
+	assert.Equal(t, "pictures/1/2/"+hash, pictureKey(1, 2, hash))
+}
+
+func TestPictureURL(t *testing.T) {
+	hash := hashPicture(pngPicture)
+	assert.Equal(t, "/api/users/2/picture?v="+hash[:16], pictureURL(2, hash))
+	assert.Equal(t, `"`+hash+`"`, pictureETag(hash))
+}
+
+func TestEtagMatches(t *testing.T) {
+	assert.True(t, etagMatches(`"abc"`, `"abc"`))
+	assert.True(t, etagMatches(`"xyz", W/"abc"`, `"abc"`))
+	assert.True(t, etagMatches("*", `"abc"`))
+	assert.False(t, etagMatches("", `"abc"`))
+	assert.False(t, etagMatches(`"abcd"`, `"abc"`))
+}
+
+func TestPictureMover(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := NewMockprofileRepositoryInterface(ctrl)
+	mockStore := storage.NewMockBlobStore(ctrl)
+
+	mover := PictureMover{ProfileRepo: mockRepo, Store: mockStore}
+	pictures := []pendingPicture{
+		{UserId: 2, TenantId: 1, Picture: pngPicture},
+		{UserId: 3, TenantId: 1, Picture: []byte("GIF89a")},
+	}
+	pngKey := pictureKey(1, 2, hashPicture(pngPicture))
+	gifKey := pictureKey(1, 3, hashPicture([]byte("GIF89a")))
+
+	gomock.InOrder(
+		mockRepo.EXPECT().getPendingPictures(PICTURE_MIGRATION_BATCH_SIZE).Return(pictures, nil),
+		mockStore.EXPECT().Put(pngKey, pngPicture, "image/png").Return(nil),
+		mockRepo.EXPECT().completePictureMove(2, pngKey, "image/png", hashPicture(pngPicture)).Return(nil),
+		mockStore.EXPECT().Put(gifKey, []byte("GIF89a"), "image/gif").Return(nil),
+		mockRepo.EXPECT().completePictureMove(3, gifKey, "image/gif", hashPicture([]byte("GIF89a"))).Return(nil),
+		mockRepo.EXPECT().getPendingPictures(PICTURE_MIGRATION_BATCH_SIZE).Return([]pendingPicture{}, nil),
+	)
+	mover.Run()
+
+	gomock.InOrder(
+		mockRepo.EXPECT().getPendingPictures(PICTURE_MIGRATION_BATCH_SIZE).Return(pictures, nil),
+		mockStore.EXPECT().Put(pngKey, pngPicture, "image/png").Return(errors.New("bucket is gone")),
+	)
+	mover.Run()
+}
+
+func TestPictureMoverProcessesPictures(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := NewMockprofileRepositoryInterface(ctrl)
+	mockStore := storage.NewMockBlobStore(ctrl)
+
+	mover := PictureMover{ProfileRepo: mockRepo, Store: mockStore}
+	original := encodeTestJPEG(t, newTestImage(6, 4), testExif(binary.BigEndian, 6))
+	processed, err := processPicture(original, config.GetPictureMaxDimension())
+	require.Nil(t, err)
+	hash := hashPicture(processed.Content)
+	key := pictureKey(1, 2, hash)
+
+	gomock.InOrder(
+		mockRepo.EXPECT().getPendingPictures(PICTURE_MIGRATION_BATCH_SIZE).Return([]pendingPicture{{UserId: 2, TenantId: 1, Picture: original}}, nil),
+		mockStore.EXPECT().Put(key+"-64", processed.Variants[64], "image/jpeg").Return(nil),
+		mockStore.EXPECT().Put(key+"-256", processed.Variants[256], "image/jpeg").Return(nil),
+		mockStore.EXPECT().Put(key+"-512", processed.Variants[512], "image/jpeg").Return(nil),
+		mockStore.EXPECT().Put(key, processed.Content, "image/jpeg").Return(nil),
+		mockRepo.EXPECT().completePictureMove(2, key, "image/jpeg", hash).Return(nil),
+		mockRepo.EXPECT().getPendingPictures(PICTURE_MIGRATION_BATCH_SIZE).Return([]pendingPicture{}, nil),
+	)
+	mover.Run()
+}
+
+func TestDeletePicture(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockStore := storage.NewMockBlobStore(ctrl)
+
+	gomock.InOrder(
+		mockStore.EXPECT().Delete("pictures/1/2/hash").Return(errors.New("bucket is gone")),
+		mockStore.EXPECT().Delete("pictures/1/2/hash-64").Return(nil),
+		mockStore.EXPECT().Delete("pictures/1/2/hash-256").Return(nil),
+		mockStore.EXPECT().Delete("pictures/1/2/hash-512").Return(nil),
+	)
+	assert.EqualError(t, DeletePicture(mockStore, "pictures/1/2/hash"), "bucket is gone")
+}
@@ -0,0 +1,110 @@
+package profile
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+	"userland/auth"
+)
+
+const (
+	VISIBILITY_PUBLIC        = "public"
+	VISIBILITY_AUTHENTICATED = "authenticated"
+	VISIBILITY_PRIVATE       = "private"
+)
+
+// PrivacySettings tells who, besides the user, sees each optional field of
+// their profile.
+type PrivacySettings struct {
+	Location string `json:"location"`
+	Bio      string `json:"bio"`
+	Web      string `json:"web"`
+	Picture  string `json:"picture"`
+}
+
+func privacySettingsOf(user *auth.User) PrivacySettings {
+	return PrivacySettings{
+		Location: user.LocationVisibility,
+		Bio:      user.BioVisibility,
+		Web:      user.WebVisibility,
+		Picture:  user.PictureVisibility,
+	}
+}
+
+func (settings PrivacySettings) isValid() bool {
+	for _, visibility := range []string{settings.Location, settings.Bio, settings.Web, settings.Picture} {
+		if visibility != VISIBILITY_PUBLIC && visibility != VISIBILITY_AUTHENTICATED && visibility != VISIBILITY_PRIVATE {
+			return false
+		}
+	}
+	return true
+}
+
+// visibleTo tells whether a field of the owner's profile is shown to the
+// viewer, nil for anonymous requests. Owners always see their own profile.
+func visibleTo(visibility string, ownerId int, viewer *auth.User) bool {
+	if viewer != nil && viewer.Id == ownerId {
+		return true
+	}
+	switch visibility {
+	case VISIBILITY_PUBLIC:
+		return true
+	case VISIBILITY_AUTHENTICATED:
+		return viewer != nil
+	}
+	return false
+}
+
+// PublicProfile is what other users see of a profile. Fields hidden from the
+// viewer are left out, and a hidden picture gives way to the avatar.
+type PublicProfile struct {
+	Id         int       `json:"id"`
+	Username   *string   `json:"username,omitempty"`
+	Fullname   string    `json:"fullname"`
+	Location   string    `json:"location,omitempty"`
+	Bio        string    `json:"bio,omitempty"`
+	Web        string    `json:"web,omitempty"`
+	PictureURL string    `json:"picture_url"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func publicProfileOf(user *auth.User, viewer *auth.User) PublicProfile {
+	profile := PublicProfile{
+		Id:         user.Id,
+		Fullname:   user.Fullname,
+		PictureURL: pictureURL(user.Id, avatarHash(user.Id)),
+		CreatedAt:  user.CreatedAt,
+	}
+	if user.Username.Valid {
+		profile.Username = &user.Username.String
+	}
+	if visibleTo(user.LocationVisibility, user.Id, viewer) {
+		profile.Location = user.Location.String
+	}
+	if visibleTo(user.BioVisibility, user.Id, viewer) {
+		profile.Bio = user.Bio.String
+	}
+	if visibleTo(user.WebVisibility, user.Id, viewer) {
+		profile.Web = user.Web.String
+	}
+	if user.PictureKey.Valid && visibleTo(user.PictureVisibility, user.Id, viewer) {
+		profile.PictureURL = pictureURL(user.Id, user.PictureHash.String)
+	}
+	return profile
+}
+
+// publicProfilePath is where the user's public profile is, under their
+// current username when they have one.
+func publicProfilePath(user *auth.User) string {
+	if user.Username.Valid {
+		return "/api/users/" + url.PathEscape(user.Username.String)
+	}
+	return fmt.Sprintf("/api/users/%d", user.Id)
+}
+
+// viewerOf returns the user making the request, nil when it's anonymous.
+func viewerOf(r *http.Request) *auth.User {
+	viewer, _ := r.Context().Value("user").(*auth.User)
+	return viewer
+}
@@ -0,0 +1,41 @@
+package profile
+
+import (
+	"database/sql"
+	"testing"
+	"userland/auth"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVisibleTo(t *testing.T) {
+	owner := &auth.User{Id: 1}
+	other := &auth.User{Id: 2}
+
+	assert.True(t, visibleTo(VISIBILITY_PUBLIC, 1, nil))
+	assert.True(t, visibleTo(VISIBILITY_AUTHENTICATED, 1, other))
+	assert.False(t, visibleTo(VISIBILITY_AUTHENTICATED, 1, nil))
+	assert.False(t, visibleTo(VISIBILITY_PRIVATE, 1, other))
+	assert.True(t, visibleTo(VISIBILITY_PRIVATE, 1, owner))
+	assert.False(t, visibleTo("", 1, other), "Unknown visibilities should hide the field")
+}
+
+func TestPublicProfilePicture(t *testing.T) {
+	user := &auth.User{
+		Id:                2,
+		PictureKey:        sql.NullString{String: "pictures/1/2/hash", Valid: true},
+		PictureHash:       sql.NullString{String: "hash", Valid: true},
+		PictureVisibility: VISIBILITY_AUTHENTICATED,
+	}
+
+	assert.Equal(t, pictureURL(2, avatarHash(2)), publicProfileOf(user, nil).PictureURL)
+	assert.Equal(t, pictureURL(2, "hash"), publicProfileOf(user, &auth.User{Id: 1}).PictureURL)
+}
+
+func TestPrivacySettingsIsValid(t *testing.T) {
+	settings := PrivacySettings{Location: VISIBILITY_PUBLIC, Bio: VISIBILITY_AUTHENTICATED, Web: VISIBILITY_PRIVATE, Picture: VISIBILITY_PUBLIC}
+	assert.True(t, settings.isValid())
+
+	settings.Bio = ""
+	assert.False(t, settings.isValid())
+}
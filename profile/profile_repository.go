@@ -1,29 +1,111 @@
 package profile
 
 import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 	"userland/appcontext"
 	"userland/auth"
+	"userland/tenancy"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
 const (
-	UPDATE_PROFILE_BY_ID_QUERY         = "UPDATE \"user\" SET fullname=$1, location=$2, bio=$3, web=$4 WHERE id=$5"
-	CHANGE_EMAIL_BY_ID_QUERY           = "UPDATE \"user\" SET email=$1 WHERE id=$2"
-	CHANGE_PASSWORD_BY_ID_QUERY        = "UPDATE \"user\" SET password=$1 WHERE id=$2"
-	DELETE_USER_BY_ID_QUERY            = "DELETE FROM \"user\" WHERE id=$1"
-	UPDATE_PROFILE_PICTURE_BY_ID_QUERY = "UPDATE \"user\" SET picture=$1 WHERE id=$2"
-	DELETE_PROFILE_PICTURE_BY_ID_QUERY = "UPDATE \"user\" SET picture=NULL WHERE id=$1"
+	CLAIM_PROFILE_VERSION_QUERY        = "UPDATE \"user\" SET profile_version=profile_version+1 WHERE id=$1 AND tenant_id=$2 AND ($3::integer[] IS NULL OR profile_version=ANY($3)) RETURNING profile_version"
+	UPDATE_PROFILE_BY_ID_QUERY         = "UPDATE \"user\" SET fullname=$1, location=$2, bio=$3, web=$4 WHERE id=$5 AND tenant_id=$6"
+	PATCH_PROFILE_BY_ID_QUERY          = "UPDATE \"user\" SET %s WHERE id=$%d AND tenant_id=$%d"
+	SET_USERNAME_BY_ID_QUERY           = "UPDATE \"user\" SET username=$1 WHERE id=$2 AND tenant_id=$3 AND NOT EXISTS (SELECT 1 FROM username_redirect WHERE tenant_id=$3 AND lower(username)=lower($1) AND user_id<>$2 AND expires_at > now())"
+	DELETE_USERNAME_REDIRECT_QUERY     = "DELETE FROM username_redirect WHERE tenant_id=$1 AND lower(username)=lower($2)"
+	SAVE_USERNAME_REDIRECT_QUERY       = "INSERT INTO username_redirect (tenant_id, username, user_id, expires_at) VALUES ($1, $2, $3, now() + $4 * interval '1 second') ON CONFLICT (tenant_id, lower(username)) DO UPDATE SET user_id=EXCLUDED.user_id, created_at=now(), expires_at=EXCLUDED.expires_at"
+	CHANGE_EMAIL_BY_ID_QUERY           = "UPDATE \"user\" SET email=$1, token_version=token_version+1 WHERE id=$2 AND tenant_id=$3 RETURNING token_version"
+	CHANGE_PASSWORD_BY_ID_QUERY        = "UPDATE \"user\" SET password=$1, token_version=token_version+1 WHERE id=$2 AND tenant_id=$3 RETURNING token_version"
+	SCHEDULE_USER_DELETION_QUERY       = "UPDATE \"user\" SET deleted_at=now(), purge_at=now() + $1 * interval '1 second', restore_token=$2, token_version=token_version+1 WHERE id=$3 AND tenant_id=$4 RETURNING deleted_at, purge_at, restore_token, token_version"
+	PURGE_DELETED_USERS_QUERY          = "DELETE FROM \"user\" WHERE purge_at <= now() RETURNING COALESCE(picture_key, '')"
+	UPDATE_PROFILE_PICTURE_BY_ID_QUERY = "UPDATE \"user\" SET picture_key=$1, picture_content_type=$2, picture_hash=$3, profile_version=profile_version+1 WHERE id=$4 AND tenant_id=$5"
+	DELETE_PROFILE_PICTURE_BY_ID_QUERY = "UPDATE \"user\" SET picture_key=NULL, picture_content_type=NULL, picture_hash=NULL, profile_version=profile_version+1 WHERE id=$1 AND tenant_id=$2"
+	SELECT_USER_PICTURE_QUERY          = "SELECT picture_key, picture_content_type, picture_hash, picture_visibility FROM \"user\" WHERE id=$1 AND tenant_id=$2 AND deleted_at IS NULL"
+	UPDATE_PRIVACY_SETTINGS_QUERY      = "UPDATE \"user\" SET location_visibility=$1, bio_visibility=$2, web_visibility=$3, picture_visibility=$4 WHERE id=$5 AND tenant_id=$6"
+
+	SELECT_PUBLIC_USER_BY_ID_QUERY       = "SELECT * FROM \"user\" WHERE tenant_id=$1 AND id=$2 AND deleted_at IS NULL AND deactivated_at IS NULL"
+	SELECT_PUBLIC_USER_BY_USERNAME_QUERY = "SELECT * FROM \"user\" WHERE tenant_id=$1 AND lower(username)=lower($2) AND deleted_at IS NULL AND deactivated_at IS NULL"
+	SEARCH_USERS_QUERY                   = "SELECT * FROM (SELECT \"user\".*, %s AS search_key FROM \"user\" WHERE %s) AS found%s ORDER BY search_key %s, id %s LIMIT $%d"
+	SELECT_REDIRECTED_USER_QUERY         = "SELECT \"user\".* FROM username_redirect JOIN \"user\" ON \"user\".id=username_redirect.user_id WHERE username_redirect.tenant_id=$1 AND lower(username_redirect.username)=lower($2) AND username_redirect.expires_at > now() AND \"user\".deleted_at IS NULL AND \"user\".deactivated_at IS NULL"
+
+	CREATE_DATA_EXPORT_QUERY        = "INSERT INTO data_export (user_id) SELECT $1 WHERE NOT EXISTS (SELECT 1 FROM data_export WHERE user_id=$1 AND status='pending') RETURNING id, status, created_at"
+	CLAIM_DATA_EXPORTS_QUERY        = "UPDATE data_export SET started_at=now() FROM \"user\" WHERE \"user\".id=data_export.user_id AND data_export.id IN (SELECT id FROM data_export WHERE status='pending' AND (started_at IS NULL OR started_at <= now() - interval '1 hour') ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED) RETURNING data_export.id, data_export.user_id, \"user\".tenant_id"
+	SELECT_EXPORT_USER_QUERY        = "SELECT * FROM \"user\" WHERE id=$1 AND tenant_id=$2 AND deleted_at IS NULL"
+	SELECT_EXPORT_TENANT_QUERY      = "SELECT * FROM tenant WHERE id=$1"
+	SELECT_EXPORT_SESSIONS_QUERY    = "SELECT user_agent, ip_address, created_at, last_seen_at FROM user_device WHERE user_id=$1 ORDER BY id"
+	SELECT_EXPORT_EVENTS_QUERY      = "SELECT action, details, created_at FROM audit_event WHERE user_id=$1 ORDER BY id"
+	SELECT_EXPORT_CREDENTIALS_QUERY = "SELECT transports, created_at, last_used_at FROM webauthn_credential WHERE user_id=$1 ORDER BY id"
+	COMPLETE_DATA_EXPORT_QUERY      = "UPDATE data_export SET status='ready', archive_key=$1, token_hash=$2, completed_at=now(), expires_at=now() + $3 * interval '1 second' WHERE id=$4"
+	FAIL_DATA_EXPORT_QUERY          = "UPDATE data_export SET status='failed', completed_at=now() WHERE id=$1"
+	DOWNLOAD_DATA_EXPORT_QUERY      = "UPDATE data_export SET status='downloaded', downloaded_at=now() FROM data_export AS ready JOIN \"user\" ON \"user\".id=ready.user_id WHERE data_export.id=ready.id AND data_export.status='ready' AND \"user\".tenant_id=$1 AND ready.token_hash=$2 AND ready.expires_at > now() RETURNING ready.archive_key"
+	PURGE_DATA_EXPORTS_QUERY        = "DELETE FROM data_export WHERE status IN ('failed', 'downloaded') OR expires_at <= now() OR (status='pending' AND created_at <= now() - interval '1 day') OR user_id IN (SELECT id FROM \"user\" WHERE purge_at <= now()) RETURNING COALESCE(archive_key, '')"
+
+	SELECT_PENDING_PICTURES_QUERY = "SELECT user_picture_migration.user_id, \"user\".tenant_id, user_picture_migration.picture FROM user_picture_migration JOIN \"user\" ON \"user\".id=user_picture_migration.user_id ORDER BY user_picture_migration.user_id LIMIT $1"
+	COMPLETE_PICTURE_MOVE_QUERY   = "WITH moved AS (DELETE FROM user_picture_migration WHERE user_id=$1 RETURNING user_id) UPDATE \"user\" SET picture_key=$2, picture_content_type=$3, picture_hash=$4, profile_version=profile_version+1 WHERE id IN (SELECT user_id FROM moved) AND picture_key IS NULL"
+
+	RESTORE_TOKEN_BYTES = 16
+
+	UNIQUE_VIOLATION = "23505"
 )
 
+var (
+	errUsernameTaken         = errors.New("Username is taken or still redirects to another user")
+	errProfileVersionChanged = errors.New("Profile was changed since the client read it")
+)
+
+// searchSort is how users are ordered by a search sort, the ID breaking
+// ties in the same direction. Keys are passed around as text, and cast back
+// to their type for comparisons. An empty key stands for the search rank.
+type searchSort struct {
+	Key        string
+	Type       string
+	Descending bool
+}
+
+var searchSorts = map[string]searchSort{
+	SEARCH_SORT_RELEVANCE: {Type: "numeric", Descending: true},
+	SEARCH_SORT_FULLNAME:  {Key: "lower(fullname)", Type: "text"},
+	SEARCH_SORT_OLDEST:    {Key: "created_at", Type: "timestamptz"},
+	SEARCH_SORT_NEWEST:    {Key: "created_at", Type: "timestamptz", Descending: true},
+}
+
+// profileRepositoryInterface only ever changes the given user within their
+// own tenant.
 type profileRepositoryInterface interface {
-	updateUserProfile(user *auth.User, newUserProfile UserProfile) error
+	updateUserProfile(user *auth.User, newUserProfile UserProfile, versions []int64, redirectLifetime time.Duration) error
+	patchUserProfile(user *auth.User, patch ProfilePatch, versions []int64, redirectLifetime time.Duration) error
 	changeUserEmail(user *auth.User, newEmail string) error
 	changeUserPassword(user *auth.User, oldPassword string, newPassword string) error
-	deleteUser(user *auth.User, password string) error
-	updateUserPicture(user *auth.User, picture []byte) error
+	deleteUser(user *auth.User, password string, gracePeriod time.Duration) error
+	purgeDeletedUsers() ([]string, error)
+	createDataExport(user *auth.User) (*DataExport, error)
+	claimDataExports(limit int) ([]pendingDataExport, error)
+	getExportUser(tenantId int, userId int) (*auth.User, error)
+	getExportTenant(tenantId int) (*tenancy.Tenant, error)
+	getExportData(user *auth.User) (*exportData, error)
+	completeDataExport(exportId int, archiveKey string, tokenHash string, lifetime time.Duration) error
+	failDataExport(exportId int) error
+	downloadDataExport(tenantId int, tokenHash string) (string, error)
+	purgeDataExports() ([]string, error)
+	getPendingPictures(limit int) ([]pendingPicture, error)
+	completePictureMove(userId int, key string, contentType string, hash string) error
+	updateUserPicture(user *auth.User, key string, contentType string, hash string) error
 	deleteUserPicture(user *auth.User) error
+	getUserPicture(tenantId int, userId int) (*userPicture, error)
+	updatePrivacySettings(user *auth.User, settings PrivacySettings) error
+	getPublicUser(tenantId int, userId int) (*auth.User, error)
+	getPublicUserByUsername(tenantId int, username string) (*auth.User, bool, error)
+	searchUsers(tenantId int, viewer *auth.User, search userSearch) ([]searchedUser, error)
 }
 
 type profileRepository struct {
@@ -35,22 +117,121 @@ func GetProfileRepository() *profileRepository {
 	return &repo
 }
 
-func (repo *profileRepository) updateUserProfile(user *auth.User, newUserProfile UserProfile) error {
-	stmt, err := repo.db.Preparex(UPDATE_PROFILE_BY_ID_QUERY)
+// updateUserProfile changes the username too when the profile has one, an
+// empty one removing it. The previous username keeps pointing at the user
+// for the redirect lifetime, and nobody else can take it until then.
+func (repo *profileRepository) updateUserProfile(user *auth.User, newUserProfile UserProfile, versions []int64, redirectLifetime time.Duration) error {
+	tx, err := repo.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = claimProfileVersion(tx, user, versions)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(UPDATE_PROFILE_BY_ID_QUERY, newUserProfile.Fullname, newUserProfile.Location, newUserProfile.Bio, newUserProfile.Web, user.Id, user.TenantId)
+	if err != nil {
+		return err
+	}
+
+	if newUserProfile.Username != nil && *newUserProfile.Username != user.Username.String {
+		err = changeUsername(tx, user, *newUserProfile.Username, redirectLifetime)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// patchUserProfile only writes the columns present in the patch, leaving
+// the rest of the profile to whatever other clients have set.
+func (repo *profileRepository) patchUserProfile(user *auth.User, patch ProfilePatch, versions []int64, redirectLifetime time.Duration) error {
+	tx, err := repo.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = claimProfileVersion(tx, user, versions)
 	if err != nil {
 		return err
 	}
-	_, err = stmt.Queryx(newUserProfile.Fullname, newUserProfile.Location, newUserProfile.Bio, newUserProfile.Web, user.Id)
+
+	columns, args := patch.assignments()
+	if columns != "" {
+		query := fmt.Sprintf(PATCH_PROFILE_BY_ID_QUERY, columns, len(args)+1, len(args)+2)
+		_, err = tx.Exec(query, append(args, user.Id, user.TenantId)...)
+		if err != nil {
+			return err
+		}
+	}
+
+	if patch.Username != nil && *patch.Username != user.Username.String {
+		err = changeUsername(tx, user, *patch.Username, redirectLifetime)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// claimProfileVersion bumps the profile version when it's still one of the
+// versions the client read, nil standing for any version. The row stays
+// locked until the transaction ends, so that no other write gets in between.
+func claimProfileVersion(tx *sqlx.Tx, user *auth.User, versions []int64) error {
+	err := tx.QueryRowx(CLAIM_PROFILE_VERSION_QUERY, user.Id, user.TenantId, pq.Array(versions)).Scan(&user.ProfileVersion)
+	if err == sql.ErrNoRows {
+		return errProfileVersionChanged
+	}
 	return err
 }
 
+func changeUsername(tx *sqlx.Tx, user *auth.User, username string, redirectLifetime time.Duration) error {
+	newUsername := sql.NullString{String: username, Valid: username != ""}
+	result, err := tx.Exec(SET_USERNAME_BY_ID_QUERY, newUsername, user.Id, user.TenantId)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == UNIQUE_VIOLATION {
+		return errUsernameTaken
+	}
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errUsernameTaken
+	}
+
+	if newUsername.Valid {
+		// The user may be taking back a previous username of theirs, or one
+		// whose redirect has expired.
+		_, err = tx.Exec(DELETE_USERNAME_REDIRECT_QUERY, user.TenantId, username)
+		if err != nil {
+			return err
+		}
+	}
+
+	oldUsername := user.Username.String
+	if user.Username.Valid && !strings.EqualFold(oldUsername, username) && redirectLifetime > 0 {
+		_, err = tx.Exec(SAVE_USERNAME_REDIRECT_QUERY, user.TenantId, oldUsername, user.Id, redirectLifetime.Seconds())
+		if err != nil {
+			return err
+		}
+	}
+	user.Username = newUsername
+	return nil
+}
+
 func (repo *profileRepository) changeUserEmail(user *auth.User, newEmail string) error {
 	stmt, err := repo.db.Preparex(CHANGE_EMAIL_BY_ID_QUERY)
 	if err != nil {
 		return err
 	}
-	_, err = stmt.Queryx(newEmail, user.Id)
-	return err
+	return stmt.QueryRowx(newEmail, user.Id, user.TenantId).Scan(&user.TokenVersion)
 }
 
 func (repo *profileRepository) changeUserPassword(user *auth.User, oldPassword string, newPassword string) error {
@@ -69,32 +250,159 @@ func (repo *profileRepository) changeUserPassword(user *auth.User, oldPassword s
 	if err != nil {
 		return err
 	}
-	_, err = stmt.Queryx(passwordHash, user.Id)
-	return err
+	return stmt.QueryRowx(passwordHash, user.Id, user.TenantId).Scan(&user.TokenVersion)
 }
 
-func (repo *profileRepository) deleteUser(user *auth.User, password string) error {
+// deleteUser locks the account and revokes its sessions, leaving it to be
+// purged once the grace period is over. The user gets the restore token set
+// for the email that lets them take the deletion back until then.
+func (repo *profileRepository) deleteUser(user *auth.User, password string, gracePeriod time.Duration) error {
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
 
 	if err != nil {
 		return err
 	}
 
-	stmt, err := repo.db.Preparex(DELETE_USER_BY_ID_QUERY)
+	restoreToken := make([]byte, RESTORE_TOKEN_BYTES)
+	_, err = rand.Read(restoreToken)
 	if err != nil {
 		return err
 	}
-	_, err = stmt.Queryx(user.Id)
+
+	return repo.db.QueryRowx(SCHEDULE_USER_DELETION_QUERY, gracePeriod.Seconds(), hex.EncodeToString(restoreToken), user.Id, user.TenantId).
+		Scan(&user.DeletedAt, &user.PurgeAt, &user.RestoreToken, &user.TokenVersion)
+}
+
+// purgeDeletedUsers removes the accounts whose grace period is over, across
+// all tenants, returning the picture key of each one, empty when it had no
+// picture. Their devices, credentials, roles and memberships go with them
+// through the foreign keys; the audit log keeps its records of the account.
+func (repo *profileRepository) purgeDeletedUsers() ([]string, error) {
+	pictureKeys := []string{}
+	err := repo.db.Select(&pictureKeys, PURGE_DELETED_USERS_QUERY)
+	if err != nil {
+		return nil, err
+	}
+	return pictureKeys, nil
+}
+
+// createDataExport queues an export, unless one is already waiting to be
+// built for the user, in which case sql.ErrNoRows is returned.
+func (repo *profileRepository) createDataExport(user *auth.User) (*DataExport, error) {
+	var export DataExport
+	err := repo.db.QueryRowx(CREATE_DATA_EXPORT_QUERY, user.Id).StructScan(&export)
+	if err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// claimDataExports takes pending exports for the caller to build. Exports
+// another instance is building are skipped, unless it started them over an
+// hour ago and so most likely went away.
+func (repo *profileRepository) claimDataExports(limit int) ([]pendingDataExport, error) {
+	exports := []pendingDataExport{}
+	err := repo.db.Select(&exports, CLAIM_DATA_EXPORTS_QUERY, limit)
+	if err != nil {
+		return nil, err
+	}
+	return exports, nil
+}
+
+// getExportUser returns sql.ErrNoRows for users who were deleted since they
+// asked for the export.
+func (repo *profileRepository) getExportUser(tenantId int, userId int) (*auth.User, error) {
+	var user auth.User
+	err := repo.db.Get(&user, SELECT_EXPORT_USER_QUERY, userId, tenantId)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (repo *profileRepository) getExportTenant(tenantId int) (*tenancy.Tenant, error) {
+	var tenant tenancy.Tenant
+	err := repo.db.Get(&tenant, SELECT_EXPORT_TENANT_QUERY, tenantId)
+	if err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+func (repo *profileRepository) getExportData(user *auth.User) (*exportData, error) {
+	data := exportData{
+		Profile:     newExportProfile(user),
+		Sessions:    []exportSession{},
+		AuditEvents: []exportAuditEvent{},
+		Identities: exportIdentities{
+			ExternalId:          user.ExternalId.String,
+			WebAuthnCredentials: []exportCredential{},
+		},
+		ExportedAt: time.Now(),
+	}
+
+	err := repo.db.Select(&data.Sessions, SELECT_EXPORT_SESSIONS_QUERY, user.Id)
+	if err != nil {
+		return nil, err
+	}
+	err = repo.db.Select(&data.AuditEvents, SELECT_EXPORT_EVENTS_QUERY, user.Id)
+	if err != nil {
+		return nil, err
+	}
+	err = repo.db.Select(&data.Identities.WebAuthnCredentials, SELECT_EXPORT_CREDENTIALS_QUERY, user.Id)
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func (repo *profileRepository) completeDataExport(exportId int, archiveKey string, tokenHash string, lifetime time.Duration) error {
+	_, err := repo.db.Exec(COMPLETE_DATA_EXPORT_QUERY, archiveKey, tokenHash, lifetime.Seconds(), exportId)
 	return err
 }
 
-func (repo *profileRepository) updateUserPicture(user *auth.User, picture []byte) error {
+func (repo *profileRepository) failDataExport(exportId int) error {
+	_, err := repo.db.Exec(FAIL_DATA_EXPORT_QUERY, exportId)
+	return err
+}
+
+// downloadDataExport returns the key of the archive the token was sent for
+// and marks it downloaded in the same statement, so that each link works only
+// once.
+func (repo *profileRepository) downloadDataExport(tenantId int, tokenHash string) (string, error) {
+	var archiveKey string
+	err := repo.db.QueryRowx(DOWNLOAD_DATA_EXPORT_QUERY, tenantId, tokenHash).Scan(&archiveKey)
+	if err != nil {
+		return "", err
+	}
+	return archiveKey, nil
+}
+
+// purgeDataExports removes the exports that were downloaded, expired or
+// failed, as well as those of the accounts about to be purged, returning the
+// archive key of each one, empty when it had no archive.
+func (repo *profileRepository) purgeDataExports() ([]string, error) {
+	archiveKeys := []string{}
+	err := repo.db.Select(&archiveKeys, PURGE_DATA_EXPORTS_QUERY)
+	if err != nil {
+		return nil, err
+	}
+	return archiveKeys, nil
+}
+
+func (repo *profileRepository) updateUserPicture(user *auth.User, key string, contentType string, hash string) error {
 	stmt, err := repo.db.Preparex(UPDATE_PROFILE_PICTURE_BY_ID_QUERY)
 	if err != nil {
 		return err
 	}
-	_, err = stmt.Queryx(picture, user.Id)
-	return err
+	_, err = stmt.Exec(key, contentType, hash, user.Id, user.TenantId)
+	if err != nil {
+		return err
+	}
+	user.PictureKey = sql.NullString{String: key, Valid: true}
+	user.PictureContentType = sql.NullString{String: contentType, Valid: true}
+	user.PictureHash = sql.NullString{String: hash, Valid: true}
+	return nil
 }
 
 func (repo *profileRepository) deleteUserPicture(user *auth.User) error {
@@ -102,6 +410,147 @@ func (repo *profileRepository) deleteUserPicture(user *auth.User) error {
 	if err != nil {
 		return err
 	}
-	_, err = stmt.Queryx(user.Id)
+	_, err = stmt.Exec(user.Id, user.TenantId)
+	if err != nil {
+		return err
+	}
+	user.PictureKey = sql.NullString{}
+	user.PictureContentType = sql.NullString{}
+	user.PictureHash = sql.NullString{}
+	return nil
+}
+
+// getUserPicture returns sql.ErrNoRows when the user isn't in the tenant.
+func (repo *profileRepository) getUserPicture(tenantId int, userId int) (*userPicture, error) {
+	var picture userPicture
+	err := repo.db.Get(&picture, SELECT_USER_PICTURE_QUERY, userId, tenantId)
+	if err != nil {
+		return nil, err
+	}
+	return &picture, nil
+}
+
+func (repo *profileRepository) getPendingPictures(limit int) ([]pendingPicture, error) {
+	pictures := []pendingPicture{}
+	err := repo.db.Select(&pictures, SELECT_PENDING_PICTURES_QUERY, limit)
+	if err != nil {
+		return nil, err
+	}
+	return pictures, nil
+}
+
+// completePictureMove points the user at the moved picture and drops the
+// parked copy in the same statement. Users who uploaded a new picture in the
+// meantime keep it.
+func (repo *profileRepository) completePictureMove(userId int, key string, contentType string, hash string) error {
+	_, err := repo.db.Exec(COMPLETE_PICTURE_MOVE_QUERY, userId, key, contentType, hash)
+	return err
+}
+
+func (repo *profileRepository) updatePrivacySettings(user *auth.User, settings PrivacySettings) error {
+	_, err := repo.db.Exec(UPDATE_PRIVACY_SETTINGS_QUERY, settings.Location, settings.Bio, settings.Web, settings.Picture, user.Id, user.TenantId)
 	return err
 }
+
+// getPublicUser returns sql.ErrNoRows for users who are deleted or
+// deactivated, as they aren't to be looked up by others.
+func (repo *profileRepository) getPublicUser(tenantId int, userId int) (*auth.User, error) {
+	var user auth.User
+	err := repo.db.QueryRowx(SELECT_PUBLIC_USER_BY_ID_QUERY, tenantId, userId).StructScan(&user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// getPublicUserByUsername also finds users by a username they had before,
+// until its redirect expires, telling whether it went through one.
+func (repo *profileRepository) getPublicUserByUsername(tenantId int, username string) (*auth.User, bool, error) {
+	var user auth.User
+	err := repo.db.QueryRowx(SELECT_PUBLIC_USER_BY_USERNAME_QUERY, tenantId, username).StructScan(&user)
+	if err != sql.ErrNoRows {
+		if err != nil {
+			return nil, false, err
+		}
+		return &user, false, nil
+	}
+
+	err = repo.db.QueryRowx(SELECT_REDIRECTED_USER_QUERY, tenantId, username).StructScan(&user)
+	if err != nil {
+		return nil, false, err
+	}
+	return &user, true, nil
+}
+
+// searchUsers returns one more user than the search limit when there are
+// more to come, for the caller to tell there's another page.
+func (repo *profileRepository) searchUsers(tenantId int, viewer *auth.User, search userSearch) ([]searchedUser, error) {
+	query, args := search.query(tenantId, viewer.Id)
+	users := []searchedUser{}
+	err := repo.db.Select(&users, query, args...)
+	return users, err
+}
+
+// query matches the search terms against the start of each field, or any
+// word of it closely enough by trigrams, ranking prefix matches first. The
+// location only counts where the viewer may see it, so that searching
+// doesn't give hidden locations away.
+func (search userSearch) query(tenantId int, viewerId int) (string, []interface{}) {
+	args := []interface{}{tenantId}
+	conditions := []string{"tenant_id=$1", "deleted_at IS NULL", "deactivated_at IS NULL"}
+	rank := "0"
+
+	if search.Query != "" {
+		args = append(args, viewerId, strings.ToLower(search.Query), appcontext.EscapeLikePattern(strings.ToLower(search.Query))+"%")
+		viewer, term, prefix := len(args)-2, len(args)-1, len(args)
+		locationVisible := fmt.Sprintf("(location_visibility<>'%s' OR id=$%d)", VISIBILITY_PRIVATE, viewer)
+
+		fields := []string{"lower(fullname)", "lower(username)"}
+		if search.IncludeEmail {
+			fields = append(fields, "lower(email)")
+		}
+		matches, prefixMatches, similarities := []string{}, []string{}, []string{}
+		for _, field := range fields {
+			matches = append(matches, fmt.Sprintf("%s LIKE $%d OR $%d <%% %s", field, prefix, term, field))
+			prefixMatches = append(prefixMatches, fmt.Sprintf("%s LIKE $%d", field, prefix))
+			similarities = append(similarities, fmt.Sprintf("word_similarity($%d, %s)", term, field))
+		}
+		matches = append(matches, fmt.Sprintf("(%s AND (lower(location) LIKE $%d OR $%d <%% lower(location)))", locationVisible, prefix, term))
+		similarities = append(similarities, fmt.Sprintf("CASE WHEN %s THEN word_similarity($%d, lower(location)) END", locationVisible, term))
+
+		conditions = append(conditions, "("+strings.Join(matches, " OR ")+")")
+		rank = fmt.Sprintf("round((GREATEST(%s) + CASE WHEN %s THEN 1 ELSE 0 END)::numeric, 6)",
+			strings.Join(similarities, ", "), strings.Join(prefixMatches, " OR "))
+	}
+
+	if search.Verified != nil {
+		args = append(args, *search.Verified)
+		conditions = append(conditions, fmt.Sprintf("COALESCE(verified, false)=$%d", len(args)))
+	}
+	if search.CreatedAfter != nil {
+		args = append(args, *search.CreatedAfter)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if search.CreatedBefore != nil {
+		args = append(args, *search.CreatedBefore)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+
+	sort := searchSorts[search.Sort]
+	key := sort.Key
+	if key == "" {
+		key = rank
+	}
+	direction, comparison := "ASC", ">"
+	if sort.Descending {
+		direction, comparison = "DESC", "<"
+	}
+	after := ""
+	if search.After != nil {
+		args = append(args, search.After.Key, search.After.Id)
+		after = fmt.Sprintf(" WHERE (search_key, id) %s ($%d::%s, $%d)", comparison, len(args)-1, sort.Type, len(args))
+	}
+
+	args = append(args, search.Limit+1)
+	return fmt.Sprintf(SEARCH_USERS_QUERY, key, strings.Join(conditions, " AND "), after, direction, direction, len(args)), args
+}
@@ -5,9 +5,12 @@
 package profile
 
 import (
-	gomock "github.com/golang/mock/gomock"
 	reflect "reflect"
+	time "time"
 	auth "userland/auth"
+	tenancy "userland/tenancy"
+
+	gomock "github.com/golang/mock/gomock"
 )
 
 // MockprofileRepositoryInterface is a mock of profileRepositoryInterface interface
@@ -34,17 +37,31 @@ func (m *MockprofileRepositoryInterface) EXPECT() *MockprofileRepositoryInterfac
 }
 
 // updateUserProfile mocks base method
-func (m *MockprofileRepositoryInterface) updateUserProfile(user *auth.User, newUserProfile UserProfile) error {
+func (m *MockprofileRepositoryInterface) updateUserProfile(user *auth.User, newUserProfile UserProfile, versions []int64, redirectLifetime time.Duration) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "updateUserProfile", user, newUserProfile)
+	ret := m.ctrl.Call(m, "updateUserProfile", user, newUserProfile, versions, redirectLifetime)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // updateUserProfile indicates an expected call of updateUserProfile
-func (mr *MockprofileRepositoryInterfaceMockRecorder) updateUserProfile(user, newUserProfile interface{}) *gomock.Call {
+func (mr *MockprofileRepositoryInterfaceMockRecorder) updateUserProfile(user, newUserProfile, versions, redirectLifetime interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "updateUserProfile", reflect.TypeOf((*MockprofileRepositoryInterface)(nil).updateUserProfile), user, newUserProfile, versions, redirectLifetime)
+}
+
+// patchUserProfile mocks base method
+func (m *MockprofileRepositoryInterface) patchUserProfile(user *auth.User, patch ProfilePatch, versions []int64, redirectLifetime time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "patchUserProfile", user, patch, versions, redirectLifetime)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// patchUserProfile indicates an expected call of patchUserProfile
+func (mr *MockprofileRepositoryInterfaceMockRecorder) patchUserProfile(user, patch, versions, redirectLifetime interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "updateUserProfile", reflect.TypeOf((*MockprofileRepositoryInterface)(nil).updateUserProfile), user, newUserProfile)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "patchUserProfile", reflect.TypeOf((*MockprofileRepositoryInterface)(nil).patchUserProfile), user, patch, versions, redirectLifetime)
 }
 
 // changeUserEmail mocks base method
@@ -76,31 +93,208 @@ func (mr *MockprofileRepositoryInterfaceMockRecorder) changeUserPassword(user, o
 }
 
 // deleteUser mocks base method
-func (m *MockprofileRepositoryInterface) deleteUser(user *auth.User, password string) error {
+func (m *MockprofileRepositoryInterface) deleteUser(user *auth.User, password string, gracePeriod time.Duration) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "deleteUser", user, password)
+	ret := m.ctrl.Call(m, "deleteUser", user, password, gracePeriod)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // deleteUser indicates an expected call of deleteUser
-func (mr *MockprofileRepositoryInterfaceMockRecorder) deleteUser(user, password interface{}) *gomock.Call {
+func (mr *MockprofileRepositoryInterfaceMockRecorder) deleteUser(user, password, gracePeriod interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "deleteUser", reflect.TypeOf((*MockprofileRepositoryInterface)(nil).deleteUser), user, password)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "deleteUser", reflect.TypeOf((*MockprofileRepositoryInterface)(nil).deleteUser), user, password, gracePeriod)
+}
+
+// purgeDeletedUsers mocks base method
+func (m *MockprofileRepositoryInterface) purgeDeletedUsers() ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "purgeDeletedUsers")
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// purgeDeletedUsers indicates an expected call of purgeDeletedUsers
+func (mr *MockprofileRepositoryInterfaceMockRecorder) purgeDeletedUsers() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "purgeDeletedUsers", reflect.TypeOf((*MockprofileRepositoryInterface)(nil).purgeDeletedUsers))
+}
+
+// createDataExport mocks base method
+func (m *MockprofileRepositoryInterface) createDataExport(user *auth.User) (*DataExport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "createDataExport", user)
+	ret0, _ := ret[0].(*DataExport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// createDataExport indicates an expected call of createDataExport
+func (mr *MockprofileRepositoryInterfaceMockRecorder) createDataExport(user interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "createDataExport", reflect.TypeOf((*MockprofileRepositoryInterface)(nil).createDataExport), user)
+}
+
+// claimDataExports mocks base method
+func (m *MockprofileRepositoryInterface) claimDataExports(limit int) ([]pendingDataExport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "claimDataExports", limit)
+	ret0, _ := ret[0].([]pendingDataExport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// claimDataExports indicates an expected call of claimDataExports
+func (mr *MockprofileRepositoryInterfaceMockRecorder) claimDataExports(limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "claimDataExports", reflect.TypeOf((*MockprofileRepositoryInterface)(nil).claimDataExports), limit)
+}
+
+// getExportUser mocks base method
+func (m *MockprofileRepositoryInterface) getExportUser(tenantId, userId int) (*auth.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "getExportUser", tenantId, userId)
+	ret0, _ := ret[0].(*auth.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// getExportUser indicates an expected call of getExportUser
+func (mr *MockprofileRepositoryInterfaceMockRecorder) getExportUser(tenantId, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "getExportUser", reflect.TypeOf((*MockprofileRepositoryInterface)(nil).getExportUser), tenantId, userId)
+}
+
+// getExportTenant mocks base method
+func (m *MockprofileRepositoryInterface) getExportTenant(tenantId int) (*tenancy.Tenant, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "getExportTenant", tenantId)
+	ret0, _ := ret[0].(*tenancy.Tenant)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// getExportTenant indicates an expected call of getExportTenant
+func (mr *MockprofileRepositoryInterfaceMockRecorder) getExportTenant(tenantId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "getExportTenant", reflect.TypeOf((*MockprofileRepositoryInterface)(nil).getExportTenant), tenantId)
+}
+
+// getExportData mocks base method
+func (m *MockprofileRepositoryInterface) getExportData(user *auth.User) (*exportData, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "getExportData", user)
+	ret0, _ := ret[0].(*exportData)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// getExportData indicates an expected call of getExportData
+func (mr *MockprofileRepositoryInterfaceMockRecorder) getExportData(user interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "getExportData", reflect.TypeOf((*MockprofileRepositoryInterface)(nil).getExportData), user)
+}
+
+// completeDataExport mocks base method
+func (m *MockprofileRepositoryInterface) completeDataExport(exportId int, archiveKey, tokenHash string, lifetime time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "completeDataExport", exportId, archiveKey, tokenHash, lifetime)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// completeDataExport indicates an expected call of completeDataExport
+func (mr *MockprofileRepositoryInterfaceMockRecorder) completeDataExport(exportId, archiveKey, tokenHash, lifetime interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "completeDataExport", reflect.TypeOf((*MockprofileRepositoryInterface)(nil).completeDataExport), exportId, archiveKey, tokenHash, lifetime)
+}
+
+// failDataExport mocks base method
+func (m *MockprofileRepositoryInterface) failDataExport(exportId int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "failDataExport", exportId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// failDataExport indicates an expected call of failDataExport
+func (mr *MockprofileRepositoryInterfaceMockRecorder) failDataExport(exportId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "failDataExport", reflect.TypeOf((*MockprofileRepositoryInterface)(nil).failDataExport), exportId)
+}
+
+// downloadDataExport mocks base method
+func (m *MockprofileRepositoryInterface) downloadDataExport(tenantId int, tokenHash string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "downloadDataExport", tenantId, tokenHash)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// downloadDataExport indicates an expected call of downloadDataExport
+func (mr *MockprofileRepositoryInterfaceMockRecorder) downloadDataExport(tenantId, tokenHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "downloadDataExport", reflect.TypeOf((*MockprofileRepositoryInterface)(nil).downloadDataExport), tenantId, tokenHash)
+}
+
+// purgeDataExports mocks base method
+func (m *MockprofileRepositoryInterface) purgeDataExports() ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "purgeDataExports")
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// purgeDataExports indicates an expected call of purgeDataExports
+func (mr *MockprofileRepositoryInterfaceMockRecorder) purgeDataExports() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "purgeDataExports", reflect.TypeOf((*MockprofileRepositoryInterface)(nil).purgeDataExports))
+}
+
+// getPendingPictures mocks base method
+func (m *MockprofileRepositoryInterface) getPendingPictures(limit int) ([]pendingPicture, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "getPendingPictures", limit)
+	ret0, _ := ret[0].([]pendingPicture)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// getPendingPictures indicates an expected call of getPendingPictures
+func (mr *MockprofileRepositoryInterfaceMockRecorder) getPendingPictures(limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "getPendingPictures", reflect.TypeOf((*MockprofileRepositoryInterface)(nil).getPendingPictures), limit)
+}
+
+// completePictureMove mocks base method
+func (m *MockprofileRepositoryInterface) completePictureMove(userId int, key, contentType, hash string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "completePictureMove", userId, key, contentType, hash)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// completePictureMove indicates an expected call of completePictureMove
+func (mr *MockprofileRepositoryInterfaceMockRecorder) completePictureMove(userId, key, contentType, hash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "completePictureMove", reflect.TypeOf((*MockprofileRepositoryInterface)(nil).completePictureMove), userId, key, contentType, hash)
 }
 
 // updateUserPicture mocks base method
-func (m *MockprofileRepositoryInterface) updateUserPicture(user *auth.User, picture []byte) error {
+func (m *MockprofileRepositoryInterface) updateUserPicture(user *auth.User, key, contentType, hash string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "updateUserPicture", user, picture)
+	ret := m.ctrl.Call(m, "updateUserPicture", user, key, contentType, hash)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // updateUserPicture indicates an expected call of updateUserPicture
-func (mr *MockprofileRepositoryInterfaceMockRecorder) updateUserPicture(user, picture interface{}) *gomock.Call {
+func (mr *MockprofileRepositoryInterfaceMockRecorder) updateUserPicture(user, key, contentType, hash interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "updateUserPicture", reflect.TypeOf((*MockprofileRepositoryInterface)(nil).updateUserPicture), user, picture)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "updateUserPicture", reflect.TypeOf((*MockprofileRepositoryInterface)(nil).updateUserPicture), user, key, contentType, hash)
 }
 
 // deleteUserPicture mocks base method
@@ -116,3 +310,78 @@ func (mr *MockprofileRepositoryInterfaceMockRecorder) deleteUserPicture(user int
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "deleteUserPicture", reflect.TypeOf((*MockprofileRepositoryInterface)(nil).deleteUserPicture), user)
 }
+
+// getUserPicture mocks base method
+func (m *MockprofileRepositoryInterface) getUserPicture(tenantId, userId int) (*userPicture, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "getUserPicture", tenantId, userId)
+	ret0, _ := ret[0].(*userPicture)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// getUserPicture indicates an expected call of getUserPicture
+func (mr *MockprofileRepositoryInterfaceMockRecorder) getUserPicture(tenantId, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "getUserPicture", reflect.TypeOf((*MockprofileRepositoryInterface)(nil).getUserPicture), tenantId, userId)
+}
+
+// updatePrivacySettings mocks base method
+func (m *MockprofileRepositoryInterface) updatePrivacySettings(user *auth.User, settings PrivacySettings) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "updatePrivacySettings", user, settings)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// updatePrivacySettings indicates an expected call of updatePrivacySettings
+func (mr *MockprofileRepositoryInterfaceMockRecorder) updatePrivacySettings(user, settings interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "updatePrivacySettings", reflect.TypeOf((*MockprofileRepositoryInterface)(nil).updatePrivacySettings), user, settings)
+}
+
+// getPublicUser mocks base method
+func (m *MockprofileRepositoryInterface) getPublicUser(tenantId, userId int) (*auth.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "getPublicUser", tenantId, userId)
+	ret0, _ := ret[0].(*auth.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// getPublicUser indicates an expected call of getPublicUser
+func (mr *MockprofileRepositoryInterfaceMockRecorder) getPublicUser(tenantId, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "getPublicUser", reflect.TypeOf((*MockprofileRepositoryInterface)(nil).getPublicUser), tenantId, userId)
+}
+
+// getPublicUserByUsername mocks base method
+func (m *MockprofileRepositoryInterface) getPublicUserByUsername(tenantId int, username string) (*auth.User, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "getPublicUserByUsername", tenantId, username)
+	ret0, _ := ret[0].(*auth.User)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// getPublicUserByUsername indicates an expected call of getPublicUserByUsername
+func (mr *MockprofileRepositoryInterfaceMockRecorder) getPublicUserByUsername(tenantId, username interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "getPublicUserByUsername", reflect.TypeOf((*MockprofileRepositoryInterface)(nil).getPublicUserByUsername), tenantId, username)
+}
+
+// searchUsers mocks base method
+func (m *MockprofileRepositoryInterface) searchUsers(tenantId int, viewer *auth.User, search userSearch) ([]searchedUser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "searchUsers", tenantId, viewer, search)
+	ret0, _ := ret[0].([]searchedUser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// searchUsers indicates an expected call of searchUsers
+func (mr *MockprofileRepositoryInterfaceMockRecorder) searchUsers(tenantId, viewer, search interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "searchUsers", reflect.TypeOf((*MockprofileRepositoryInterface)(nil).searchUsers), tenantId, viewer, search)
+}
@@ -0,0 +1,74 @@
+package profile
+
+import (
+	"fmt"
+	"time"
+	"userland/storage"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// AccountPurger periodically deletes for good the accounts whose deletion
+// grace period is over, along with their pictures, as well as the data
+// exports that are no longer downloadable.
+type AccountPurger struct {
+	ProfileRepo profileRepositoryInterface
+	Store       storage.BlobStore
+	Interval    time.Duration
+}
+
+// Run purges once right away and then on every interval, until the stop
+// channel is closed.
+func (purger AccountPurger) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(purger.Interval)
+	defer ticker.Stop()
+
+	for {
+		purger.purge()
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// purge removes the data exports first, so that the archives of the accounts
+// being purged are deleted before the accounts take their rows with them.
+func (purger AccountPurger) purge() {
+	archiveKeys, err := purger.ProfileRepo.purgeDataExports()
+	if err != nil {
+		log.Warn(err)
+		return
+	}
+	for _, key := range archiveKeys {
+		if key == "" {
+			continue
+		}
+		err = purger.Store.Delete(key)
+		if err != nil {
+			log.Warn(err)
+		}
+	}
+	if len(archiveKeys) > 0 {
+		log.Info(fmt.Sprintf("Purged %d data exports", len(archiveKeys)))
+	}
+
+	pictureKeys, err := purger.ProfileRepo.purgeDeletedUsers()
+	if err != nil {
+		log.Warn(err)
+		return
+	}
+	for _, key := range pictureKeys {
+		if key == "" {
+			continue
+		}
+		err = DeletePicture(purger.Store, key)
+		if err != nil {
+			log.Warn(err)
+		}
+	}
+	if len(pictureKeys) > 0 {
+		log.Info(fmt.Sprintf("Purged %d deleted accounts", len(pictureKeys)))
+	}
+}
@@ -0,0 +1,57 @@
+package profile
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"userland/storage"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+func TestAccountPurger(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := NewMockprofileRepositoryInterface(ctrl)
+	mockStore := storage.NewMockBlobStore(ctrl)
+
+	purger := AccountPurger{ProfileRepo: mockRepo, Store: mockStore, Interval: time.Hour}
+
+	gomock.InOrder(
+		mockRepo.EXPECT().purgeDataExports().Return([]string{"exports/1/2/7.zip", ""}, nil),
+		mockStore.EXPECT().Delete("exports/1/2/7.zip").Return(nil),
+		mockRepo.EXPECT().purgeDeletedUsers().Return([]string{"pictures/1/2/hash", ""}, nil),
+		mockStore.EXPECT().Delete("pictures/1/2/hash").Return(nil),
+		mockStore.EXPECT().Delete("pictures/1/2/hash-64").Return(nil),
+		mockStore.EXPECT().Delete("pictures/1/2/hash-256").Return(nil),
+		mockStore.EXPECT().Delete("pictures/1/2/hash-512").Return(nil),
+	)
+	purger.purge()
+
+	gomock.InOrder(
+		mockRepo.EXPECT().purgeDataExports().Return([]string{}, nil),
+		mockRepo.EXPECT().purgeDeletedUsers().Return(nil, errors.New("connection refused")),
+	)
+	purger.purge()
+
+	mockRepo.EXPECT().purgeDataExports().Return(nil, errors.New("connection refused"))
+	purger.purge()
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	mockRepo.EXPECT().purgeDataExports().DoAndReturn(func() ([]string, error) {
+		close(stop)
+		return []string{}, nil
+	})
+	mockRepo.EXPECT().purgeDeletedUsers().Return([]string{}, nil)
+	go func() {
+		purger.Run(stop)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Purger didn't stop")
+	}
+}
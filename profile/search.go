@@ -0,0 +1,171 @@
+package profile
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"userland/auth"
+)
+
+const (
+	SEARCH_SORT_RELEVANCE = "relevance"
+	SEARCH_SORT_FULLNAME  = "fullname"
+	SEARCH_SORT_OLDEST    = "created_at"
+	SEARCH_SORT_NEWEST    = "-created_at"
+
+	SEARCH_DEFAULT_LIMIT    = 20
+	SEARCH_MAX_LIMIT        = 50
+	SEARCH_MAX_QUERY_LENGTH = 128
+)
+
+// userSearch looks users up by fullname, username, location and, for
+// admins, email. Results are sorted by the sort key then by ID, so that the
+// order is stable and pages can pick up after the last user of the previous
+// one.
+type userSearch struct {
+	Query         string
+	Verified      *bool
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Sort          string
+	Limit         int
+	After         *searchCursor
+	IncludeEmail  bool
+}
+
+// searchCursor is the sort key and ID of the last user of a page. Clients
+// get it encoded, and are only meant to hand it back as it is.
+type searchCursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k"`
+	Id   int    `json:"i"`
+}
+
+// searchedUser is a user found by a search, along with their sort key in a
+// form Postgres can read back.
+type searchedUser struct {
+	auth.User
+	Key string `db:"search_key"`
+}
+
+type UserSearchResult struct {
+	PublicProfile
+	Email string `json:"email,omitempty"`
+}
+
+type userSearchResponse struct {
+	Users      []UserSearchResult `json:"users"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
+func parseUserSearch(values url.Values) (userSearch, error) {
+	search := userSearch{
+		Query: strings.TrimSpace(values.Get("q")),
+		Sort:  values.Get("sort"),
+		Limit: SEARCH_DEFAULT_LIMIT,
+	}
+	if len(search.Query) > SEARCH_MAX_QUERY_LENGTH {
+		return search, errors.New("Search query is too long")
+	}
+
+	if search.Sort == "" {
+		search.Sort = SEARCH_SORT_FULLNAME
+		if search.Query != "" {
+			search.Sort = SEARCH_SORT_RELEVANCE
+		}
+	}
+	if _, ok := searchSorts[search.Sort]; !ok {
+		return search, errors.New("Search sort is unknown")
+	}
+
+	if verified := values.Get("verified"); verified != "" {
+		value, err := strconv.ParseBool(verified)
+		if err != nil {
+			return search, err
+		}
+		search.Verified = &value
+	}
+
+	var err error
+	search.CreatedAfter, err = parseSearchTime(values, "created_after")
+	if err != nil {
+		return search, err
+	}
+	search.CreatedBefore, err = parseSearchTime(values, "created_before")
+	if err != nil {
+		return search, err
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value < 1 || value > SEARCH_MAX_LIMIT {
+			return search, errors.New("Search limit is out of range")
+		}
+		search.Limit = value
+	}
+
+	if cursor := values.Get("cursor"); cursor != "" {
+		after, err := decodeSearchCursor(cursor)
+		if err != nil {
+			return search, err
+		}
+		if after.Sort != search.Sort {
+			return search, errors.New("Search cursor belongs to another sort")
+		}
+		search.After = &after
+	}
+
+	return search, nil
+}
+
+func parseSearchTime(values url.Values, name string) (*time.Time, error) {
+	value := values.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
+func (cursor searchCursor) encode() string {
+	content, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(content)
+}
+
+func decodeSearchCursor(encoded string) (searchCursor, error) {
+	var cursor searchCursor
+	content, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return cursor, err
+	}
+	err = json.Unmarshal(content, &cursor)
+	return cursor, err
+}
+
+// searchResults projects the users found as the viewer may see them, and
+// gives the cursor of the next page when the repository found one more user
+// than the limit.
+func searchResults(users []searchedUser, search userSearch, viewer *auth.User) userSearchResponse {
+	res := userSearchResponse{Users: []UserSearchResult{}}
+	if len(users) > search.Limit {
+		users = users[:search.Limit]
+		last := users[len(users)-1]
+		res.NextCursor = searchCursor{Sort: search.Sort, Key: last.Key, Id: last.Id}.encode()
+	}
+
+	for i := range users {
+		result := UserSearchResult{PublicProfile: publicProfileOf(&users[i].User, viewer)}
+		if search.IncludeEmail {
+			result.Email = users[i].Email
+		}
+		res.Users = append(res.Users, result)
+	}
+	return res
+}
@@ -0,0 +1,97 @@
+package profile
+
+import (
+	"database/sql"
+	"net/url"
+	"testing"
+	"time"
+	"userland/auth"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUserSearch(t *testing.T) {
+	search, err := parseUserSearch(url.Values{})
+	require.Nil(t, err)
+	assert.Equal(t, userSearch{Sort: SEARCH_SORT_FULLNAME, Limit: SEARCH_DEFAULT_LIMIT}, search)
+
+	search, err = parseUserSearch(url.Values{"q": {" jo "}, "verified": {"true"}, "created_after": {"2026-01-01T00:00:00Z"}, "limit": {"5"}})
+	require.Nil(t, err)
+	assert.Equal(t, "jo", search.Query)
+	assert.Equal(t, SEARCH_SORT_RELEVANCE, search.Sort, "Searches with terms should be sorted by relevance by default")
+	require.NotNil(t, search.Verified)
+	assert.True(t, *search.Verified)
+	require.NotNil(t, search.CreatedAfter)
+	assert.Equal(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), search.CreatedAfter.UTC())
+	assert.Nil(t, search.CreatedBefore)
+	assert.Equal(t, 5, search.Limit)
+
+	cursor := searchCursor{Sort: SEARCH_SORT_NEWEST, Key: "2026-01-01T00:00:00Z", Id: 7}
+	search, err = parseUserSearch(url.Values{"sort": {SEARCH_SORT_NEWEST}, "cursor": {cursor.encode()}})
+	require.Nil(t, err)
+	assert.Equal(t, &cursor, search.After)
+
+	for _, values := range []url.Values{
+		{"sort": {"email"}},
+		{"verified": {"maybe"}},
+		{"created_before": {"yesterday"}},
+		{"limit": {"0"}},
+		{"limit": {"51"}},
+		{"cursor": {"not a cursor"}},
+		{"cursor": {cursor.encode()}},
+	} {
+		_, err = parseUserSearch(values)
+		assert.NotNil(t, err, values.Encode())
+	}
+}
+
+func TestUserSearchQuery(t *testing.T) {
+	query, args := userSearch{Sort: SEARCH_SORT_FULLNAME, Limit: 20}.query(1, 2)
+	assert.Equal(t, `SELECT * FROM (SELECT "user".*, lower(fullname) AS search_key FROM "user" WHERE tenant_id=$1 AND deleted_at IS NULL AND deactivated_at IS NULL) AS found ORDER BY search_key ASC, id ASC LIMIT $2`, query)
+	assert.Equal(t, []interface{}{1, 21}, args)
+
+	verified := false
+	query, args = userSearch{
+		Query:    "50%_Off",
+		Verified: &verified,
+		Sort:     SEARCH_SORT_RELEVANCE,
+		Limit:    20,
+		After:    &searchCursor{Sort: SEARCH_SORT_RELEVANCE, Key: "1.5", Id: 9},
+	}.query(1, 2)
+	assert.Equal(t, []interface{}{1, 2, "50%_off", `50\%\_off%`, false, "1.5", 9, 21}, args)
+	assert.Contains(t, query, "lower(fullname) LIKE $4 OR $3 <% lower(fullname)")
+	assert.Contains(t, query, "((location_visibility<>'private' OR id=$2) AND (lower(location) LIKE $4 OR $3 <% lower(location)))")
+
+	_, args = userSearch{Query: `C:\Users`, Sort: SEARCH_SORT_RELEVANCE, Limit: 20}.query(1, 2)
+	assert.Equal(t, []interface{}{1, 2, `c:\users`, `c:\\users%`, 21}, args, "Backslash should be escaped once")
+	assert.NotContains(t, query, "email", "Only admins should search by email")
+	assert.Contains(t, query, "COALESCE(verified, false)=$5")
+	assert.Contains(t, query, "AS found WHERE (search_key, id) < ($6::numeric, $7) ORDER BY search_key DESC, id DESC LIMIT $8")
+
+	query, _ = userSearch{Query: "jo", Sort: SEARCH_SORT_RELEVANCE, Limit: 20, IncludeEmail: true}.query(1, 2)
+	assert.Contains(t, query, "lower(email) LIKE $4 OR $3 <% lower(email)")
+}
+
+func TestSearchResults(t *testing.T) {
+	users := []searchedUser{
+		{User: auth.User{Id: 3, Fullname: "Ann", Email: "ann@example.com", Location: sql.NullString{String: "Jakarta", Valid: true}, LocationVisibility: VISIBILITY_PRIVATE}, Key: "ann"},
+		{User: auth.User{Id: 5, Fullname: "Bob", Email: "bob@example.com"}, Key: "bob"},
+		{User: auth.User{Id: 4, Fullname: "Cid", Email: "cid@example.com"}, Key: "cid"},
+	}
+	viewer := &auth.User{Id: 1}
+
+	res := searchResults(users, userSearch{Sort: SEARCH_SORT_FULLNAME, Limit: 2}, viewer)
+	require.Len(t, res.Users, 2)
+	assert.Equal(t, 3, res.Users[0].Id)
+	assert.Empty(t, res.Users[0].Location)
+	assert.Empty(t, res.Users[0].Email)
+	cursor, err := decodeSearchCursor(res.NextCursor)
+	require.Nil(t, err)
+	assert.Equal(t, searchCursor{Sort: SEARCH_SORT_FULLNAME, Key: "bob", Id: 5}, cursor)
+
+	res = searchResults(users, userSearch{Sort: SEARCH_SORT_FULLNAME, Limit: 3, IncludeEmail: true}, viewer)
+	require.Len(t, res.Users, 3)
+	assert.Empty(t, res.NextCursor, "The last page should have no cursor")
+	assert.Equal(t, "ann@example.com", res.Users[0].Email)
+}
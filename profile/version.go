@@ -0,0 +1,42 @@
+package profile
+
+import (
+	"strconv"
+	"strings"
+)
+
+// PROFILE_CACHE keeps the profile out of shared caches, and has browsers
+// revalidate it against the ETag before every use.
+const PROFILE_CACHE = "private, no-cache"
+
+// profileETag is a strong validator, the profile the user sees only changing
+// along with its version.
+func profileETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// profileVersionsOf returns the versions listed by an If-Match header, nil
+// when it matches any version, and false when there's no header at all.
+// If-Match calls for the strong comparison, so weak ETags match nothing.
+func profileVersionsOf(header string) ([]int64, bool) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return nil, false
+	}
+	if header == "*" {
+		return nil, true
+	}
+
+	versions := []int64{}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if len(candidate) < 2 || candidate[0] != '"' || candidate[len(candidate)-1] != '"' {
+			continue
+		}
+		version, err := strconv.ParseInt(candidate[1:len(candidate)-1], 10, 64)
+		if err == nil {
+			versions = append(versions, version)
+		}
+	}
+	return versions, true
+}
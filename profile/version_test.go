@@ -0,0 +1,26 @@
+package profile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfileVersionsOf(t *testing.T) {
+	versions, ok := profileVersionsOf("")
+	assert.False(t, ok)
+	assert.Nil(t, versions)
+
+	versions, ok = profileVersionsOf("*")
+	assert.True(t, ok)
+	assert.Nil(t, versions, "Any version should match *")
+
+	versions, ok = profileVersionsOf(profileETag(3) + `, "5", W/"7", "x"`)
+	assert.True(t, ok)
+	assert.Equal(t, []int64{3, 5}, versions, "Weak and malformed ETags should be left out")
+
+	versions, ok = profileVersionsOf(`W/"7"`)
+	assert.True(t, ok)
+	assert.NotNil(t, versions)
+	assert.Empty(t, versions, "A header listing no usable ETag should match no version")
+}
@@ -0,0 +1,31 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	currentTime := time.Now()
+	limiter := NewLimiter(2, time.Minute)
+	limiter.now = func() time.Time { return currentTime }
+
+	assert.True(t, limiter.Allow("user@example.com"))
+	assert.True(t, limiter.Allow("user@example.com"))
+	assert.False(t, limiter.Allow("user@example.com"), "Third hit within the window should be limited")
+	assert.True(t, limiter.Allow("another@example.com"), "Limits should be tracked per key")
+
+	currentTime = currentTime.Add(time.Minute)
+	assert.True(t, limiter.Allow("user@example.com"), "Limit should be lifted once the window passes")
+}
+
+func TestLimiterReset(t *testing.T) {
+	limiter := NewLimiter(1, time.Minute)
+
+	assert.True(t, limiter.Allow("user@example.com"))
+	assert.False(t, limiter.Allow("user@example.com"))
+	limiter.Reset("user@example.com")
+	assert.True(t, limiter.Allow("user@example.com"))
+}
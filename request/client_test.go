@@ -0,0 +1,17 @@
+package request
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	assert.Equal(t, "192.0.2.1", ClientIP(req))
+
+	req.RemoteAddr = "198.51.100.1"
+	assert.Equal(t, "198.51.100.1", ClientIP(req), "Address without a port should be returned as is")
+}
@@ -23,6 +23,10 @@ func RespondSuccessWithBody(w http.ResponseWriter, payload interface{}) {
 	respondWithJSON(w, http.StatusOK, payload)
 }
 
+func RespondAccepted(w http.ResponseWriter, payload interface{}) {
+	respondWithJSON(w, http.StatusAccepted, payload)
+}
+
 func RespondBadRequest(w http.ResponseWriter, err ulanderrors.UserlandError) {
 	respondWithJSON(w, http.StatusBadRequest, err)
 }
@@ -31,6 +35,26 @@ func RespondUnauthorized(w http.ResponseWriter, err ulanderrors.UserlandError) {
 	respondWithJSON(w, http.StatusUnauthorized, err)
 }
 
+func RespondForbidden(w http.ResponseWriter, err ulanderrors.UserlandError) {
+	respondWithJSON(w, http.StatusForbidden, err)
+}
+
+func RespondNotFound(w http.ResponseWriter, err ulanderrors.UserlandError) {
+	respondWithJSON(w, http.StatusNotFound, err)
+}
+
 func RespondInternalError(w http.ResponseWriter, err ulanderrors.UserlandError) {
 	respondWithJSON(w, http.StatusInternalServerError, err)
 }
+
+func RespondTooManyRequests(w http.ResponseWriter, err ulanderrors.UserlandError) {
+	respondWithJSON(w, http.StatusTooManyRequests, err)
+}
+
+func RespondPreconditionFailed(w http.ResponseWriter, err ulanderrors.UserlandError) {
+	respondWithJSON(w, http.StatusPreconditionFailed, err)
+}
+
+func RespondPreconditionRequired(w http.ResponseWriter, err ulanderrors.UserlandError) {
+	respondWithJSON(w, http.StatusPreconditionRequired, err)
+}
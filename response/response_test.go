@@ -49,6 +49,18 @@ func TestRespondSuccessWithBody(t *testing.T) {
 	assert.Equal(t, string(expectedBody), res.Body.String())
 }
 
+func TestRespondAccepted(t *testing.T) {
+	sampleObject := map[string]interface{}{"id": 1, "status": "pending"}
+	expectedBody, err := json.Marshal(sampleObject)
+	require.Nil(t, err)
+
+	res := httptest.NewRecorder()
+	RespondAccepted(res, sampleObject)
+	assert.Equal(t, http.StatusAccepted, res.Code)
+	assert.Equal(t, APPLICATION_JSON_CONTENT_TYPE, res.Header().Get("Content-Type"))
+	assert.Equal(t, string(expectedBody), res.Body.String())
+}
+
 func TestRespondBadRequest(t *testing.T) {
 	expectedBody, err := json.Marshal(sampleError)
 	require.Nil(t, err)
@@ -71,6 +83,28 @@ func TestRespondUnauthorized(t *testing.T) {
 	assert.Equal(t, string(expectedBody), res.Body.String())
 }
 
+func TestRespondForbidden(t *testing.T) {
+	expectedBody, err := json.Marshal(sampleError)
+	require.Nil(t, err)
+
+	res := httptest.NewRecorder()
+	RespondForbidden(res, sampleError)
+	assert.Equal(t, http.StatusForbidden, res.Code)
+	assert.Equal(t, APPLICATION_JSON_CONTENT_TYPE, res.Header().Get("Content-Type"))
+	assert.Equal(t, string(expectedBody), res.Body.String())
+}
+
+func TestRespondNotFound(t *testing.T) {
+	expectedBody, err := json.Marshal(sampleError)
+	require.Nil(t, err)
+
+	res := httptest.NewRecorder()
+	RespondNotFound(res, sampleError)
+	assert.Equal(t, http.StatusNotFound, res.Code)
+	assert.Equal(t, APPLICATION_JSON_CONTENT_TYPE, res.Header().Get("Content-Type"))
+	assert.Equal(t, string(expectedBody), res.Body.String())
+}
+
 func TestRespondInternalError(t *testing.T) {
 	expectedBody, err := json.Marshal(sampleError)
 	require.Nil(t, err)
@@ -81,3 +115,36 @@ func TestRespondInternalError(t *testing.T) {
 	assert.Equal(t, APPLICATION_JSON_CONTENT_TYPE, res.Header().Get("Content-Type"))
 	assert.Equal(t, string(expectedBody), res.Body.String())
 }
+
+func TestRespondTooManyRequests(t *testing.T) {
+	expectedBody, err := json.Marshal(sampleError)
+	require.Nil(t, err)
+
+	res := httptest.NewRecorder()
+	RespondTooManyRequests(res, sampleError)
+	assert.Equal(t, http.StatusTooManyRequests, res.Code)
+	assert.Equal(t, APPLICATION_JSON_CONTENT_TYPE, res.Header().Get("Content-Type"))
+	assert.Equal(t, string(expectedBody), res.Body.String())
+}
+
+func TestRespondPreconditionFailed(t *testing.T) {
+	expectedBody, err := json.Marshal(sampleError)
+	require.Nil(t, err)
+
+	res := httptest.NewRecorder()
+	RespondPreconditionFailed(res, sampleError)
+	assert.Equal(t, http.StatusPreconditionFailed, res.Code)
+	assert.Equal(t, APPLICATION_JSON_CONTENT_TYPE, res.Header().Get("Content-Type"))
+	assert.Equal(t, string(expectedBody), res.Body.String())
+}
+
+func TestRespondPreconditionRequired(t *testing.T) {
+	expectedBody, err := json.Marshal(sampleError)
+	require.Nil(t, err)
+
+	res := httptest.NewRecorder()
+	RespondPreconditionRequired(res, sampleError)
+	assert.Equal(t, http.StatusPreconditionRequired, res.Code)
+	assert.Equal(t, APPLICATION_JSON_CONTENT_TYPE, res.Header().Get("Content-Type"))
+	assert.Equal(t, string(expectedBody), res.Body.String())
+}
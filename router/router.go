@@ -2,32 +2,93 @@ package router
 
 import (
 	"net/http"
+	"userland/admin"
+	"userland/audit"
 	"userland/auth"
+	"userland/config"
+	"userland/mailer"
+	"userland/org"
 	"userland/ping"
 	"userland/profile"
+	"userland/ratelimit"
+	"userland/scim"
+	"userland/storage"
+	"userland/tenancy"
+	"userland/webauthn"
 
 	"github.com/gorilla/mux"
 )
 
 var (
-	authHandler    auth.AuthHandler
-	authMiddleware auth.AuthMiddleware
-	profileHandler profile.ProfileHandler
+	authHandler      auth.AuthHandler
+	authMiddleware   auth.AuthMiddleware
+	tenantMiddleware tenancy.TenantMiddleware
+	profileHandler   profile.ProfileHandler
+	adminHandler     admin.AdminHandler
+	orgHandler       org.OrgHandler
+	scimHandler      scim.ScimHandler
 )
 
 func GetRouter() *mux.Router {
 	router := mux.NewRouter()
 
 	initHandlersAndMiddlewares()
+	router.Use(tenantMiddleware.WithTenant)
 	setupRouteHandler(router)
 
 	return router
 }
 
 func initHandlersAndMiddlewares() {
-	authHandler = auth.AuthHandler{UserRepo: auth.GetUserRepository()}
-	profileHandler = profile.ProfileHandler{ProfileRepo: profile.GetProfileRepository()}
-	authMiddleware = auth.AuthMiddleware{UserRepo: auth.GetUserRepository()}
+	authHandler = auth.AuthHandler{
+		UserRepo:                 auth.GetUserRepository(),
+		WebAuthnRepo:             auth.GetWebAuthnRepository(),
+		DeviceRepo:               auth.GetDeviceRepository(),
+		RoleRepo:                 auth.GetRoleRepository(),
+		RelyingParty:             getRelyingParty(),
+		Mailer:                   mailer.GetMailer(),
+		AuditLogger:              audit.GetLogger(),
+		EmailLoginRequestLimiter: ratelimit.NewLimiter(auth.EMAIL_LOGIN_REQUEST_LIMIT, auth.EMAIL_LOGIN_LIMIT_PERIOD),
+		EmailLoginAttemptLimiter: ratelimit.NewLimiter(auth.EMAIL_LOGIN_ATTEMPT_LIMIT, auth.EMAIL_LOGIN_LIMIT_PERIOD),
+		ReauthAttemptLimiter:     ratelimit.NewLimiter(auth.REAUTH_ATTEMPT_LIMIT, auth.REAUTH_LIMIT_PERIOD),
+		UsernameCheckLimiter:     ratelimit.NewLimiter(auth.USERNAME_AVAILABILITY_LIMIT, auth.USERNAME_AVAILABILITY_LIMIT_PERIOD),
+		RegistrationPolicy:       auth.GetRegistrationPolicy(),
+		Authenticator:            auth.GetAuthenticator(auth.GetUserRepository()),
+	}
+	profileHandler = profile.ProfileHandler{
+		ProfileRepo: profile.GetProfileRepository(),
+		Mailer:      mailer.GetMailer(),
+		Store:       storage.GetBlobStore(),
+	}
+	adminHandler = admin.AdminHandler{
+		AdminRepo:   admin.GetAdminRepository(),
+		AuditLogger: audit.GetLogger(),
+		Mailer:      mailer.GetMailer(),
+		Store:       storage.GetBlobStore(),
+	}
+	orgHandler = org.OrgHandler{
+		OrgRepo: org.GetOrgRepository(),
+		Mailer:  mailer.GetMailer(),
+	}
+	scimHandler = scim.ScimHandler{ScimRepo: scim.GetScimRepository()}
+	authMiddleware = auth.AuthMiddleware{
+		UserRepo:    auth.GetUserRepository(),
+		RoleRepo:    auth.GetRoleRepository(),
+		AuditLogger: audit.GetLogger(),
+	}
+	tenantMiddleware = tenancy.TenantMiddleware{
+		TenantRepo:    tenancy.GetTenantRepository(),
+		Header:        config.GetTenantHeader(),
+		DefaultTenant: config.GetDefaultTenant(),
+	}
+}
+
+func getRelyingParty() webauthn.RelyingParty {
+	return webauthn.RelyingParty{
+		ID:     config.GetWebAuthnRPId(),
+		Name:   config.GetWebAuthnRPName(),
+		Origin: config.GetWebAuthnRPOrigin(),
+	}
 }
 
 func setupRouteHandler(router *mux.Router) {
@@ -35,15 +96,97 @@ func setupRouteHandler(router *mux.Router) {
 	router.HandleFunc("/api/auth/register", authHandler.Register).Methods(http.MethodPost)
 	router.HandleFunc("/api/auth/verification", authHandler.Verify).Methods(http.MethodPost)
 	router.HandleFunc("/api/auth/login", authHandler.Login).Methods(http.MethodPost)
+	router.HandleFunc("/api/auth/login/email", authHandler.LoginWithEmail).Methods(http.MethodPost)
+	router.HandleFunc("/api/auth/login/email/verify", authHandler.ExchangeEmailLogin).Methods(http.MethodPost)
 	router.HandleFunc("/api/auth/password/forgot", authHandler.ForgetPassword).Methods(http.MethodPost)
 	router.HandleFunc("/api/auth/password/reset", authHandler.ResetPassword).Methods(http.MethodPost)
+	router.HandleFunc("/api/auth/account/restore", authHandler.RestoreAccount).Methods(http.MethodPost)
+	router.HandleFunc("/api/auth/username/available", authHandler.CheckUsernameAvailability).Methods(http.MethodGet)
+	router.HandleFunc("/api/exports/download", profileHandler.DownloadDataExport).Methods(http.MethodGet)
+	router.HandleFunc("/api/users/{id}/picture", authMiddleware.WithOptionalJWT(profileHandler.GetUserPicture)).Methods(http.MethodGet)
+	router.HandleFunc("/api/users", authMiddleware.WithVerifyJWT(profileHandler.SearchUsers)).Methods(http.MethodGet)
+	router.HandleFunc("/api/users/{handle}", authMiddleware.WithOptionalJWT(profileHandler.GetPublicProfile)).Methods(http.MethodGet)
+	router.HandleFunc("/api/auth/device/report", authHandler.ReportDevice).Methods(http.MethodPost)
+	router.HandleFunc("/api/auth/webauthn/login/begin", authHandler.BeginWebAuthnLogin).Methods(http.MethodPost)
+	router.HandleFunc("/api/auth/webauthn/login/finish", authHandler.FinishWebAuthnLogin).Methods(http.MethodPost)
 
 	router.HandleFunc("/api/me", authMiddleware.WithVerifyJWT(profileHandler.GetProfile)).Methods(http.MethodGet)
 	router.HandleFunc("/api/me", authMiddleware.WithVerifyJWT(profileHandler.UpdateProfile)).Methods(http.MethodPut)
+	router.HandleFunc("/api/me", authMiddleware.WithVerifyJWT(profileHandler.PatchProfile)).Methods(http.MethodPatch)
+	router.HandleFunc("/api/me/privacy", authMiddleware.WithVerifyJWT(profileHandler.GetPrivacySettings)).Methods(http.MethodGet)
+	router.HandleFunc("/api/me/privacy", authMiddleware.WithVerifyJWT(profileHandler.UpdatePrivacySettings)).Methods(http.MethodPut)
 	router.HandleFunc("/api/me/email", authMiddleware.WithVerifyJWT(profileHandler.GetEmail)).Methods(http.MethodGet)
-	router.HandleFunc("/api/me/email", authMiddleware.WithVerifyJWT(profileHandler.ChangeEmailAddress)).Methods(http.MethodPut)
-	router.HandleFunc("/api/me/password", authMiddleware.WithVerifyJWT(profileHandler.ChangePassword)).Methods(http.MethodPost)
-	router.HandleFunc("/api/me/delete", authMiddleware.WithVerifyJWT(profileHandler.DeleteAccount)).Methods(http.MethodPost)
+	router.HandleFunc("/api/me/email", authMiddleware.WithRecentAuth(profileHandler.ChangeEmailAddress)).Methods(http.MethodPut)
+	router.HandleFunc("/api/me/reauth", authMiddleware.WithoutImpersonation(authHandler.Reauthenticate)).Methods(http.MethodPost)
+	router.HandleFunc("/api/me/reauth/webauthn", authMiddleware.WithoutImpersonation(authHandler.BeginReauthentication)).Methods(http.MethodPost)
+	router.HandleFunc("/api/me/password", authMiddleware.WithoutImpersonation(profileHandler.ChangePassword)).Methods(http.MethodPost)
+	router.HandleFunc("/api/me/delete", authMiddleware.WithoutImpersonation(profileHandler.DeleteAccount)).Methods(http.MethodPost)
+	router.HandleFunc("/api/me/export", authMiddleware.WithoutImpersonation(profileHandler.RequestDataExport)).Methods(http.MethodPost)
 	router.HandleFunc("/api/me/picture", authMiddleware.WithVerifyJWT(profileHandler.UpdateProfilePicture)).Methods(http.MethodPut)
 	router.HandleFunc("/api/me/picture", authMiddleware.WithVerifyJWT(profileHandler.DeleteProfilePicture)).Methods(http.MethodDelete)
+	router.HandleFunc("/api/me/webauthn", authMiddleware.WithVerifyJWT(authHandler.GetWebAuthnCredentials)).Methods(http.MethodGet)
+	router.HandleFunc("/api/me/webauthn/register/begin", authMiddleware.WithRecentAuth(authHandler.BeginWebAuthnRegistration)).Methods(http.MethodPost)
+	router.HandleFunc("/api/me/webauthn/register/finish", authMiddleware.WithRecentAuth(authHandler.FinishWebAuthnRegistration)).Methods(http.MethodPost)
+	router.HandleFunc("/api/me/webauthn/{id}", authMiddleware.WithRecentAuth(authHandler.DeleteWebAuthnCredential)).Methods(http.MethodDelete)
+
+	router.HandleFunc("/api/me/orgs", authMiddleware.WithVerifyJWT(orgHandler.GetMyOrganizations)).Methods(http.MethodGet)
+	router.HandleFunc("/api/me/orgs/active", authMiddleware.WithVerifyJWT(orgHandler.SwitchOrganization)).Methods(http.MethodPut)
+	router.HandleFunc("/api/me/orgs/invitations", authMiddleware.WithVerifyJWT(orgHandler.GetMyInvitations)).Methods(http.MethodGet)
+	router.HandleFunc("/api/me/orgs/invitations/{invitationId}/accept", authMiddleware.WithVerifyJWT(orgHandler.AcceptInvitation)).Methods(http.MethodPost)
+	router.HandleFunc("/api/me/orgs/invitations/{invitationId}/decline", authMiddleware.WithVerifyJWT(orgHandler.DeclineInvitation)).Methods(http.MethodPost)
+
+	router.HandleFunc("/api/orgs", authMiddleware.WithVerifyJWT(orgHandler.CreateOrganization)).Methods(http.MethodPost)
+	router.HandleFunc("/api/orgs/{id}", authMiddleware.WithVerifyJWT(orgHandler.GetOrganization)).Methods(http.MethodGet)
+	router.HandleFunc("/api/orgs/{id}", authMiddleware.WithVerifyJWT(orgHandler.UpdateOrganization)).Methods(http.MethodPut)
+	router.HandleFunc("/api/orgs/{id}", authMiddleware.WithVerifyJWT(orgHandler.DeleteOrganization)).Methods(http.MethodDelete)
+	router.HandleFunc("/api/orgs/{id}/members", authMiddleware.WithVerifyJWT(orgHandler.GetMembers)).Methods(http.MethodGet)
+	router.HandleFunc("/api/orgs/{id}/members/{userId}", authMiddleware.WithVerifyJWT(orgHandler.UpdateMemberRole)).Methods(http.MethodPut)
+	router.HandleFunc("/api/orgs/{id}/members/{userId}", authMiddleware.WithVerifyJWT(orgHandler.RemoveMember)).Methods(http.MethodDelete)
+	router.HandleFunc("/api/orgs/{id}/invitations", authMiddleware.WithVerifyJWT(orgHandler.GetInvitations)).Methods(http.MethodGet)
+	router.HandleFunc("/api/orgs/{id}/invitations", authMiddleware.WithVerifyJWT(orgHandler.InviteMember)).Methods(http.MethodPost)
+	router.HandleFunc("/api/orgs/{id}/invitations/{invitationId}", authMiddleware.WithVerifyJWT(orgHandler.RevokeInvitation)).Methods(http.MethodDelete)
+
+	requireRolesManage := authMiddleware.RequirePermission(auth.PERMISSION_ROLES_MANAGE)
+	router.HandleFunc("/api/admin/roles", authMiddleware.WithVerifyJWT(requireRolesManage(authHandler.GetRoles))).Methods(http.MethodGet)
+	router.HandleFunc("/api/admin/users/{id}/roles", authMiddleware.WithVerifyJWT(requireRolesManage(authHandler.GetUserRoles))).Methods(http.MethodGet)
+	router.HandleFunc("/api/admin/users/{id}/roles/{role}", authMiddleware.WithVerifyJWT(requireRolesManage(authHandler.AssignUserRole))).Methods(http.MethodPut)
+	router.HandleFunc("/api/admin/users/{id}/roles/{role}", authMiddleware.WithVerifyJWT(requireRolesManage(authHandler.RevokeUserRole))).Methods(http.MethodDelete)
+
+	requireUsersRead := authMiddleware.RequirePermission(auth.PERMISSION_USERS_READ)
+	requireUsersWrite := authMiddleware.RequirePermission(auth.PERMISSION_USERS_WRITE)
+	requireUsersDelete := authMiddleware.RequirePermission(auth.PERMISSION_USERS_DELETE)
+	router.HandleFunc("/api/admin/users", authMiddleware.WithVerifyJWT(requireUsersRead(adminHandler.GetUsers))).Methods(http.MethodGet)
+	router.HandleFunc("/api/admin/users", authMiddleware.WithVerifyJWT(requireUsersWrite(adminHandler.CreateUser))).Methods(http.MethodPost)
+	router.HandleFunc("/api/admin/users/search", authMiddleware.WithVerifyJWT(requireUsersRead(profileHandler.AdminSearchUsers))).Methods(http.MethodGet)
+	router.HandleFunc("/api/admin/users/{id}", authMiddleware.WithVerifyJWT(requireUsersRead(adminHandler.GetUser))).Methods(http.MethodGet)
+	router.HandleFunc("/api/admin/users/{id}", authMiddleware.WithVerifyJWT(requireUsersWrite(adminHandler.UpdateUser))).Methods(http.MethodPut)
+	router.HandleFunc("/api/admin/users/{id}", authMiddleware.WithVerifyJWT(requireUsersDelete(adminHandler.DeleteUser))).Methods(http.MethodDelete)
+	router.HandleFunc("/api/admin/users/{id}/verify", authMiddleware.WithVerifyJWT(requireUsersWrite(adminHandler.VerifyUser))).Methods(http.MethodPost)
+	router.HandleFunc("/api/admin/users/{id}/password/reset", authMiddleware.WithVerifyJWT(requireUsersWrite(adminHandler.ResetUserPassword))).Methods(http.MethodPost)
+	router.HandleFunc("/api/admin/users/{id}/sessions/revoke", authMiddleware.WithVerifyJWT(requireUsersWrite(adminHandler.RevokeUserSessions))).Methods(http.MethodPost)
+	router.HandleFunc("/api/admin/users/{id}/tfa/disable", authMiddleware.WithVerifyJWT(requireUsersWrite(adminHandler.DisableUserTFA))).Methods(http.MethodPost)
+
+	router.HandleFunc("/api/admin/registration/invitations", authMiddleware.WithVerifyJWT(requireUsersWrite(adminHandler.CreateRegistrationInvitation))).Methods(http.MethodPost)
+	router.HandleFunc("/api/admin/scim/tokens", authMiddleware.WithVerifyJWT(requireUsersWrite(adminHandler.CreateScimToken))).Methods(http.MethodPost)
+	router.HandleFunc("/api/admin/scim/tokens/{id}", authMiddleware.WithVerifyJWT(requireUsersWrite(adminHandler.RevokeScimToken))).Methods(http.MethodDelete)
+
+	requireUsersSuspend := authMiddleware.RequirePermission(auth.PERMISSION_USERS_SUSPEND)
+	router.HandleFunc("/api/admin/users/{id}/suspend", authMiddleware.WithVerifyJWT(requireUsersSuspend(adminHandler.SuspendUser))).Methods(http.MethodPost)
+	router.HandleFunc("/api/admin/users/{id}/unsuspend", authMiddleware.WithVerifyJWT(requireUsersSuspend(adminHandler.UnsuspendUser))).Methods(http.MethodPost)
+
+	requireUsersImpersonate := authMiddleware.RequirePermission(auth.PERMISSION_USERS_IMPERSONATE)
+	router.HandleFunc("/api/admin/users/{id}/impersonate", authMiddleware.WithVerifyJWT(requireUsersImpersonate(authHandler.ImpersonateUser))).Methods(http.MethodPost)
+
+	router.HandleFunc("/scim/v2/Users", scimHandler.WithBearerToken(scimHandler.GetUsers)).Methods(http.MethodGet)
+	router.HandleFunc("/scim/v2/Users", scimHandler.WithBearerToken(scimHandler.CreateUser)).Methods(http.MethodPost)
+	router.HandleFunc("/scim/v2/Users/{id}", scimHandler.WithBearerToken(scimHandler.GetUser)).Methods(http.MethodGet)
+	router.HandleFunc("/scim/v2/Users/{id}", scimHandler.WithBearerToken(scimHandler.ReplaceUser)).Methods(http.MethodPut)
+	router.HandleFunc("/scim/v2/Users/{id}", scimHandler.WithBearerToken(scimHandler.PatchUser)).Methods(http.MethodPatch)
+	router.HandleFunc("/scim/v2/Users/{id}", scimHandler.WithBearerToken(scimHandler.DeleteUser)).Methods(http.MethodDelete)
+	router.HandleFunc("/scim/v2/Groups", scimHandler.WithBearerToken(scimHandler.GetGroups)).Methods(http.MethodGet)
+	router.HandleFunc("/scim/v2/Groups", scimHandler.WithBearerToken(scimHandler.CreateGroup)).Methods(http.MethodPost)
+	router.HandleFunc("/scim/v2/Groups/{id}", scimHandler.WithBearerToken(scimHandler.GetGroup)).Methods(http.MethodGet)
+	router.HandleFunc("/scim/v2/Groups/{id}", scimHandler.WithBearerToken(scimHandler.ReplaceGroup)).Methods(http.MethodPut)
+	router.HandleFunc("/scim/v2/Groups/{id}", scimHandler.WithBearerToken(scimHandler.PatchGroup)).Methods(http.MethodPatch)
+	router.HandleFunc("/scim/v2/Groups/{id}", scimHandler.WithBearerToken(scimHandler.DeleteGroup)).Methods(http.MethodDelete)
 }
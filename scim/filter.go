@@ -0,0 +1,133 @@
+package scim
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"userland/appcontext"
+)
+
+const (
+	FILTER_EQUAL       = "eq"
+	FILTER_NOT_EQUAL   = "ne"
+	FILTER_CONTAINS    = "co"
+	FILTER_STARTS_WITH = "sw"
+	FILTER_ENDS_WITH   = "ew"
+)
+
+var (
+	errFilterInvalid = errors.New("Filter is invalid or not supported")
+
+	// userFilterColumns and groupFilterColumns map the filterable attributes,
+	// in lower case, onto their columns.
+	userFilterColumns = map[string]string{
+		"id":           "id",
+		"username":     "email",
+		"emails.value": "email",
+		"emails":       "email",
+		"externalid":   "external_id",
+		"displayname":  "fullname",
+		"active":       "active",
+	}
+	groupFilterColumns = map[string]string{
+		"id":          "organization.id",
+		"displayname": "organization.name",
+	}
+	idColumns = map[string]bool{
+		"id":              true,
+		"organization.id": true,
+	}
+)
+
+// filter is a single comparison, which covers what identity providers send
+// to look up a resource before provisioning it, e.g. `userName eq "a@b.com"`.
+// Logical operators and grouping aren't supported.
+type filter struct {
+	Column   string
+	Operator string
+	Value    string
+}
+
+// parseFilter reads the filter against the given attribute columns. An empty
+// expression yields no filter.
+func parseFilter(expression string, columns map[string]string) (*filter, error) {
+	expression = strings.TrimSpace(expression)
+	if expression == "" {
+		return nil, nil
+	}
+
+	parts := strings.SplitN(expression, " ", 3)
+	if len(parts) != 3 {
+		return nil, errFilterInvalid
+	}
+
+	column, ok := columns[strings.ToLower(parts[0])]
+	if !ok {
+		return nil, errFilterInvalid
+	}
+
+	operator := strings.ToLower(parts[1])
+	switch operator {
+	case FILTER_EQUAL, FILTER_NOT_EQUAL, FILTER_CONTAINS, FILTER_STARTS_WITH, FILTER_ENDS_WITH:
+	default:
+		return nil, errFilterInvalid
+	}
+
+	value := strings.TrimSpace(parts[2])
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		value = unquoted
+	} else if value != "true" && value != "false" && !isNumber(value) {
+		return nil, errFilterInvalid
+	}
+
+	// Ids and the active flag can only be compared as a whole.
+	exact := operator == FILTER_EQUAL || operator == FILTER_NOT_EQUAL
+	if column == "active" && (!exact || (value != "true" && value != "false")) {
+		return nil, errFilterInvalid
+	}
+	if idColumns[column] && (!exact || !isNumber(value)) {
+		return nil, errFilterInvalid
+	}
+	return &filter{Column: column, Operator: operator, Value: value}, nil
+}
+
+// condition returns the SQL condition of the filter with its argument as the
+// given placeholder, and the argument itself. String comparisons ignore case,
+// as SCIM attributes of userland aren't case-exact.
+func (f filter) condition(placeholder int) (string, interface{}) {
+	if f.Column == "active" {
+		active := f.Value == "true"
+		if f.Operator == FILTER_NOT_EQUAL {
+			active = !active
+		}
+		return fmt.Sprintf("(deactivated_at IS NULL) = $%d", placeholder), active
+	}
+
+	if idColumns[f.Column] {
+		id, _ := strconv.Atoi(f.Value)
+		operator := "="
+		if f.Operator == FILTER_NOT_EQUAL {
+			operator = "<>"
+		}
+		return fmt.Sprintf("%s %s $%d", f.Column, operator, placeholder), id
+	}
+
+	escaped := appcontext.EscapeLikePattern(f.Value)
+	switch f.Operator {
+	case FILTER_NOT_EQUAL:
+		return fmt.Sprintf("lower(%s) <> lower($%d)", f.Column, placeholder), f.Value
+	case FILTER_CONTAINS:
+		return fmt.Sprintf("lower(%s) LIKE lower($%d)", f.Column, placeholder), "%" + escaped + "%"
+	case FILTER_STARTS_WITH:
+		return fmt.Sprintf("lower(%s) LIKE lower($%d)", f.Column, placeholder), escaped + "%"
+	case FILTER_ENDS_WITH:
+		return fmt.Sprintf("lower(%s) LIKE lower($%d)", f.Column, placeholder), "%" + escaped
+	}
+	return fmt.Sprintf("lower(%s) = lower($%d)", f.Column, placeholder), f.Value
+}
+
+func isNumber(value string) bool {
+	_, err := strconv.Atoi(value)
+	return err == nil
+}
@@ -0,0 +1,57 @@
+package scim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	f, err := parseFilter("", userFilterColumns)
+	assert.Nil(t, err)
+	assert.Nil(t, f, "Empty expression should yield no filter")
+
+	f, err = parseFilter(`UserName Eq "jane doe@example.com"`, userFilterColumns)
+	require.Nil(t, err)
+	assert.Equal(t, filter{Column: "email", Operator: FILTER_EQUAL, Value: "jane doe@example.com"}, *f)
+
+	f, err = parseFilter(`active eq false`, userFilterColumns)
+	require.Nil(t, err)
+	assert.Equal(t, "false", f.Value)
+
+	invalid := []string{
+		`userName pr`,
+		`userName gt "a"`,
+		`nickName eq "jd"`,
+		`userName eq jane`,
+		`id co "1"`,
+		`id eq "abc"`,
+		`active sw "t"`,
+		`userName eq "a" and active eq true`,
+	}
+	for _, expression := range invalid {
+		_, err = parseFilter(expression, userFilterColumns)
+		assert.Equal(t, errFilterInvalid, err, expression)
+	}
+
+	_, err = parseFilter(`displayName eq "Engineering"`, groupFilterColumns)
+	assert.Nil(t, err)
+}
+
+func TestFilterCondition(t *testing.T) {
+	condition, arg := filter{Column: "email", Operator: FILTER_STARTS_WITH, Value: "jane_"}.condition(2)
+	assert.Equal(t, "lower(email) LIKE lower($2)", condition)
+	assert.Equal(t, `jane\_%`, arg, "LIKE wildcards should be escaped")
+
+	_, arg = filter{Column: "email", Operator: FILTER_CONTAINS, Value: `jane\doe`}.condition(2)
+	assert.Equal(t, `%jane\\doe%`, arg)
+
+	condition, arg = filter{Column: "active", Operator: FILTER_NOT_EQUAL, Value: "true"}.condition(2)
+	assert.Equal(t, "(deactivated_at IS NULL) = $2", condition)
+	assert.Equal(t, false, arg)
+
+	condition, arg = filter{Column: "organization.id", Operator: FILTER_EQUAL, Value: "3"}.condition(2)
+	assert.Equal(t, "organization.id = $2", condition)
+	assert.Equal(t, 3, arg)
+}
@@ -0,0 +1,475 @@
+package scim
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"userland/request"
+	"userland/tenancy"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	SCIM_TYPE_INVALID_FILTER = "invalidFilter"
+	SCIM_TYPE_INVALID_VALUE  = "invalidValue"
+	SCIM_TYPE_INVALID_PATH   = "invalidPath"
+	SCIM_TYPE_INVALID_SYNTAX = "invalidSyntax"
+	SCIM_TYPE_UNIQUENESS     = "uniqueness"
+)
+
+type ScimHandler struct {
+	ScimRepo scimRepositoryInterface
+}
+
+// HashToken returns the hash under which a SCIM bearer token is stored.
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// WithBearerToken lets through requests bearing one of the tenant's SCIM
+// tokens, which the identity provider is configured with.
+func (handler ScimHandler) WithBearerToken(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if len(header) <= len("Bearer ") || !strings.EqualFold(header[:len("Bearer ")], "Bearer ") {
+			log.Info("SCIM token not provided")
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			respondError(w, http.StatusUnauthorized, "", "Bearer token not provided")
+			return
+		}
+
+		tokenHash := HashToken(strings.TrimSpace(header[len("Bearer "):]))
+		err := handler.ScimRepo.useToken(tenancy.FromRequest(r).Id, tokenHash)
+		if err == sql.ErrNoRows {
+			log.Info("SCIM token is invalid")
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			respondError(w, http.StatusUnauthorized, "", "Bearer token is invalid")
+			return
+		}
+		if err != nil {
+			log.Warn(err)
+			respondError(w, http.StatusInternalServerError, "", "Token could not be checked")
+			return
+		}
+		next(w, r)
+	})
+}
+
+func (handler ScimHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseFilter(r.URL.Query().Get("filter"), userFilterColumns)
+	if err != nil {
+		log.Info(err)
+		respondError(w, http.StatusBadRequest, SCIM_TYPE_INVALID_FILTER, err.Error())
+		return
+	}
+
+	startIndex, count, ok := pageFromQuery(w, r)
+	if !ok {
+		return
+	}
+
+	users, total, err := handler.ScimRepo.getUsers(tenancy.FromRequest(r).Id, filter, startIndex, count)
+	if err != nil {
+		log.Warn(err)
+		respondError(w, http.StatusInternalServerError, "", "Users could not be listed")
+		return
+	}
+
+	resources := make([]User, 0, len(users))
+	for i := range users {
+		resources = append(resources, userResource(&users[i], baseURL(r)))
+	}
+
+	log.Info("SCIM get users successful")
+	respond(w, http.StatusOK, listResponse(resources, len(resources), total, startIndex))
+}
+
+func (handler ScimHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := idFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := handler.ScimRepo.getUser(tenancy.FromRequest(r).Id, id)
+	if err != nil {
+		respondRepositoryError(w, err)
+		return
+	}
+
+	log.Info("SCIM get user successful")
+	respond(w, http.StatusOK, userResource(user, baseURL(r)))
+}
+
+func (handler ScimHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	var resource User
+	err := request.ParseJSON(r.Body, &resource)
+	if err != nil {
+		log.Info(err)
+		respondError(w, http.StatusBadRequest, SCIM_TYPE_INVALID_SYNTAX, "Body could not be parsed")
+		return
+	}
+
+	attributes := resource.attributes()
+	if !attributes.isValid() {
+		log.Info("SCIM user data is invalid")
+		respondError(w, http.StatusBadRequest, SCIM_TYPE_INVALID_VALUE, "User needs a valid email address and a name of at most 128 characters")
+		return
+	}
+
+	user, err := handler.ScimRepo.createUser(tenancy.FromRequest(r).Id, attributes)
+	if err != nil {
+		respondRepositoryError(w, err)
+		return
+	}
+
+	created := userResource(user, baseURL(r))
+	w.Header().Set("Location", created.Meta.Location)
+
+	log.Info("SCIM create user successful")
+	respond(w, http.StatusCreated, created)
+}
+
+func (handler ScimHandler) ReplaceUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := idFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var resource User
+	err := request.ParseJSON(r.Body, &resource)
+	if err != nil {
+		log.Info(err)
+		respondError(w, http.StatusBadRequest, SCIM_TYPE_INVALID_SYNTAX, "Body could not be parsed")
+		return
+	}
+
+	handler.replaceUser(w, r, id, resource)
+}
+
+// PatchUser applies the operations to the user as it currently is, then
+// stores the result like a replacement.
+func (handler ScimHandler) PatchUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := idFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var patchReq PatchRequest
+	err := request.ParseJSON(r.Body, &patchReq)
+	if err != nil {
+		log.Info(err)
+		respondError(w, http.StatusBadRequest, SCIM_TYPE_INVALID_SYNTAX, "Body could not be parsed")
+		return
+	}
+
+	user, err := handler.ScimRepo.getUser(tenancy.FromRequest(r).Id, id)
+	if err != nil {
+		respondRepositoryError(w, err)
+		return
+	}
+
+	resource := userResource(user, baseURL(r))
+	err = resource.applyPatch(patchReq.Operations)
+	if err != nil {
+		log.Info(err)
+		respondError(w, http.StatusBadRequest, SCIM_TYPE_INVALID_PATH, err.Error())
+		return
+	}
+
+	handler.replaceUser(w, r, id, resource)
+}
+
+// DeleteUser deactivates the user rather than deleting the account, so that
+// the identity provider can bring it back with all of its data.
+func (handler ScimHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := idFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	err := handler.ScimRepo.deactivateUser(tenancy.FromRequest(r).Id, id)
+	if err != nil {
+		respondRepositoryError(w, err)
+		return
+	}
+
+	log.Info("SCIM deactivate user successful")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (handler ScimHandler) GetGroups(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseFilter(r.URL.Query().Get("filter"), groupFilterColumns)
+	if err != nil {
+		log.Info(err)
+		respondError(w, http.StatusBadRequest, SCIM_TYPE_INVALID_FILTER, err.Error())
+		return
+	}
+
+	startIndex, count, ok := pageFromQuery(w, r)
+	if !ok {
+		return
+	}
+
+	groups, total, err := handler.ScimRepo.getGroups(tenancy.FromRequest(r).Id, filter, startIndex, count)
+	if err != nil {
+		log.Warn(err)
+		respondError(w, http.StatusInternalServerError, "", "Groups could not be listed")
+		return
+	}
+
+	resources := make([]Group, 0, len(groups))
+	for i := range groups {
+		resources = append(resources, groupResource(&groups[i], baseURL(r)))
+	}
+
+	log.Info("SCIM get groups successful")
+	respond(w, http.StatusOK, listResponse(resources, len(resources), total, startIndex))
+}
+
+func (handler ScimHandler) GetGroup(w http.ResponseWriter, r *http.Request) {
+	id, ok := idFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	group, err := handler.ScimRepo.getGroup(tenancy.FromRequest(r).Id, id)
+	if err != nil {
+		respondRepositoryError(w, err)
+		return
+	}
+
+	log.Info("SCIM get group successful")
+	respond(w, http.StatusOK, groupResource(group, baseURL(r)))
+}
+
+func (handler ScimHandler) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	var resource Group
+	err := request.ParseJSON(r.Body, &resource)
+	if err != nil {
+		log.Info(err)
+		respondError(w, http.StatusBadRequest, SCIM_TYPE_INVALID_SYNTAX, "Body could not be parsed")
+		return
+	}
+
+	memberIds, ok := validGroup(w, resource)
+	if !ok {
+		return
+	}
+
+	group, err := handler.ScimRepo.createGroup(tenancy.FromRequest(r).Id, strings.TrimSpace(resource.DisplayName), memberIds)
+	if err != nil {
+		respondRepositoryError(w, err)
+		return
+	}
+
+	created := groupResource(group, baseURL(r))
+	w.Header().Set("Location", created.Meta.Location)
+
+	log.Info("SCIM create group successful")
+	respond(w, http.StatusCreated, created)
+}
+
+func (handler ScimHandler) ReplaceGroup(w http.ResponseWriter, r *http.Request) {
+	id, ok := idFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var resource Group
+	err := request.ParseJSON(r.Body, &resource)
+	if err != nil {
+		log.Info(err)
+		respondError(w, http.StatusBadRequest, SCIM_TYPE_INVALID_SYNTAX, "Body could not be parsed")
+		return
+	}
+
+	handler.replaceGroup(w, r, id, resource)
+}
+
+// PatchGroup applies the operations to the group as it currently is, then
+// stores the result like a replacement.
+func (handler ScimHandler) PatchGroup(w http.ResponseWriter, r *http.Request) {
+	id, ok := idFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var patchReq PatchRequest
+	err := request.ParseJSON(r.Body, &patchReq)
+	if err != nil {
+		log.Info(err)
+		respondError(w, http.StatusBadRequest, SCIM_TYPE_INVALID_SYNTAX, "Body could not be parsed")
+		return
+	}
+
+	group, err := handler.ScimRepo.getGroup(tenancy.FromRequest(r).Id, id)
+	if err != nil {
+		respondRepositoryError(w, err)
+		return
+	}
+
+	resource := groupResource(group, baseURL(r))
+	err = resource.applyPatch(patchReq.Operations)
+	if err != nil {
+		log.Info(err)
+		respondError(w, http.StatusBadRequest, SCIM_TYPE_INVALID_PATH, err.Error())
+		return
+	}
+
+	handler.replaceGroup(w, r, id, resource)
+}
+
+func (handler ScimHandler) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	id, ok := idFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	err := handler.ScimRepo.deleteGroup(tenancy.FromRequest(r).Id, id)
+	if err != nil {
+		respondRepositoryError(w, err)
+		return
+	}
+
+	log.Info("SCIM delete group successful")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (handler ScimHandler) replaceUser(w http.ResponseWriter, r *http.Request, id int, resource User) {
+	attributes := resource.attributes()
+	if !attributes.isValid() {
+		log.Info("SCIM user data is invalid")
+		respondError(w, http.StatusBadRequest, SCIM_TYPE_INVALID_VALUE, "User needs a valid email address and a name of at most 128 characters")
+		return
+	}
+
+	user, err := handler.ScimRepo.replaceUser(tenancy.FromRequest(r).Id, id, attributes)
+	if err != nil {
+		respondRepositoryError(w, err)
+		return
+	}
+
+	log.Info("SCIM replace user successful")
+	respond(w, http.StatusOK, userResource(user, baseURL(r)))
+}
+
+func (handler ScimHandler) replaceGroup(w http.ResponseWriter, r *http.Request, id int, resource Group) {
+	memberIds, ok := validGroup(w, resource)
+	if !ok {
+		return
+	}
+
+	group, err := handler.ScimRepo.replaceGroup(tenancy.FromRequest(r).Id, id, strings.TrimSpace(resource.DisplayName), memberIds)
+	if err != nil {
+		respondRepositoryError(w, err)
+		return
+	}
+
+	log.Info("SCIM replace group successful")
+	respond(w, http.StatusOK, groupResource(group, baseURL(r)))
+}
+
+func validGroup(w http.ResponseWriter, resource Group) ([]int, bool) {
+	if !resource.isValid() {
+		log.Info("SCIM group data is invalid")
+		respondError(w, http.StatusBadRequest, SCIM_TYPE_INVALID_VALUE, "Group needs a display name of at most 128 characters")
+		return nil, false
+	}
+
+	memberIds, ok := resource.memberIds()
+	if !ok {
+		log.Info("SCIM group members are invalid")
+		respondError(w, http.StatusBadRequest, SCIM_TYPE_INVALID_VALUE, errUnknownMember.Error())
+		return nil, false
+	}
+	return memberIds, true
+}
+
+// pageFromQuery reads the 1-based startIndex and the count of the request,
+// capping the count at MAX_PAGE_SIZE.
+func pageFromQuery(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	startIndex, count := 1, DEFAULT_PAGE_SIZE
+	query := r.URL.Query()
+
+	var err error
+	if value := query.Get("startIndex"); value != "" {
+		startIndex, err = strconv.Atoi(value)
+	}
+	if value := query.Get("count"); value != "" && err == nil {
+		count, err = strconv.Atoi(value)
+	}
+	if err != nil || count < 0 {
+		log.Info("SCIM pagination is invalid")
+		respondError(w, http.StatusBadRequest, SCIM_TYPE_INVALID_VALUE, "startIndex and count have to be numbers")
+		return 0, 0, false
+	}
+
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	if count > MAX_PAGE_SIZE {
+		count = MAX_PAGE_SIZE
+	}
+	return startIndex, count, true
+}
+
+func idFromPath(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		log.Info(err)
+		respondError(w, http.StatusNotFound, "", "Resource not found")
+		return 0, false
+	}
+	return id, true
+}
+
+func listResponse(resources interface{}, itemsPerPage int, total int, startIndex int) ListResponse {
+	return ListResponse{
+		Schemas:      []string{SCHEMA_LIST_RESPONSE},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: itemsPerPage,
+		Resources:    resources,
+	}
+}
+
+func respondRepositoryError(w http.ResponseWriter, err error) {
+	switch err {
+	case sql.ErrNoRows:
+		log.Info(err)
+		respondError(w, http.StatusNotFound, "", "Resource not found")
+	case errConflict:
+		log.Info(err)
+		respondError(w, http.StatusConflict, SCIM_TYPE_UNIQUENESS, err.Error())
+	case errUnknownMember:
+		log.Info(err)
+		respondError(w, http.StatusBadRequest, SCIM_TYPE_INVALID_VALUE, err.Error())
+	default:
+		log.Warn(err)
+		respondError(w, http.StatusInternalServerError, "", "Request could not be processed")
+	}
+}
+
+func respondError(w http.ResponseWriter, status int, scimType string, detail string) {
+	respond(w, status, Error{
+		Schemas:  []string{SCHEMA_ERROR},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	})
+}
+
+func respond(w http.ResponseWriter, status int, payload interface{}) {
+	body, _ := json.Marshal(payload)
+
+	w.Header().Set("Content-Type", CONTENT_TYPE)
+	w.WriteHeader(status)
+	w.Write(body)
+}
@@ -0,0 +1,260 @@
+package scim
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"userland/auth"
+	"userland/tenancy"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	SCIM_TOKEN = "scim-token"
+)
+
+var (
+	handler ScimHandler
+	router  *mux.Router
+
+	ctrl     *gomock.Controller
+	mockRepo *MockscimRepositoryInterface
+
+	provisionedUser = auth.User{
+		Id:         7,
+		Fullname:   "Jane Doe",
+		Email:      "jane@example.com",
+		ExternalId: sql.NullString{String: "00u1", Valid: true},
+		TenantId:   tenancy.DEFAULT_TENANT_ID,
+		CreatedAt:  time.Now(),
+	}
+	provisionedGroup = organization{
+		Id:      3,
+		Name:    "Engineering",
+		Members: []member{{OrganizationId: 3, UserId: provisionedUser.Id, Fullname: provisionedUser.Fullname}},
+	}
+)
+
+func testScimHandlerInit(t *testing.T) {
+	ctrl = gomock.NewController(t)
+	mockRepo = NewMockscimRepositoryInterface(ctrl)
+
+	handler = ScimHandler{ScimRepo: mockRepo}
+	mockRepo.EXPECT().useToken(tenancy.DEFAULT_TENANT_ID, HashToken(SCIM_TOKEN)).Return(nil).AnyTimes()
+
+	router = mux.NewRouter()
+	router.HandleFunc("/Users", handler.WithBearerToken(handler.GetUsers)).Methods(http.MethodGet)
+	router.HandleFunc("/Users", handler.WithBearerToken(handler.CreateUser)).Methods(http.MethodPost)
+	router.HandleFunc("/Users/{id}", handler.WithBearerToken(handler.GetUser)).Methods(http.MethodGet)
+	router.HandleFunc("/Users/{id}", handler.WithBearerToken(handler.ReplaceUser)).Methods(http.MethodPut)
+	router.HandleFunc("/Users/{id}", handler.WithBearerToken(handler.PatchUser)).Methods(http.MethodPatch)
+	router.HandleFunc("/Users/{id}", handler.WithBearerToken(handler.DeleteUser)).Methods(http.MethodDelete)
+	router.HandleFunc("/Groups", handler.WithBearerToken(handler.GetGroups)).Methods(http.MethodGet)
+	router.HandleFunc("/Groups", handler.WithBearerToken(handler.CreateGroup)).Methods(http.MethodPost)
+	router.HandleFunc("/Groups/{id}", handler.WithBearerToken(handler.PatchGroup)).Methods(http.MethodPatch)
+	router.HandleFunc("/Groups/{id}", handler.WithBearerToken(handler.DeleteGroup)).Methods(http.MethodDelete)
+}
+
+func testScimHandlerEnd() {
+	ctrl.Finish()
+}
+
+func serve(t *testing.T, method string, url string, body interface{}) *httptest.ResponseRecorder {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		reqBody, err = json.Marshal(body)
+		require.Nil(t, err)
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(reqBody))
+	require.Nil(t, err)
+	req.Host = "example.com"
+	req.Header.Set("Authorization", "Bearer "+SCIM_TOKEN)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	return res
+}
+
+func decodeError(t *testing.T, res *httptest.ResponseRecorder) Error {
+	var scimErr Error
+	require.Nil(t, json.Unmarshal(res.Body.Bytes(), &scimErr))
+	return scimErr
+}
+
+func TestWithBearerToken(t *testing.T) {
+	testScimHandlerInit(t)
+	mockRepo.EXPECT().useToken(tenancy.DEFAULT_TENANT_ID, HashToken("revoked")).Return(sql.ErrNoRows)
+
+	req := httptest.NewRequest(http.MethodGet, "/Users", nil)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusUnauthorized, res.Code, "Request without a token should be refused")
+	assert.Equal(t, "Bearer", res.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, CONTENT_TYPE, res.Header().Get("Content-Type"))
+
+	req = httptest.NewRequest(http.MethodGet, "/Users", nil)
+	req.Header.Set("Authorization", "Bearer revoked")
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusUnauthorized, res.Code, "Request with an unknown token should be refused")
+	assert.Equal(t, "401", decodeError(t, res).Status)
+
+	testScimHandlerEnd()
+}
+
+func TestGetUsers(t *testing.T) {
+	testScimHandlerInit(t)
+	expectedFilter := &filter{Column: "email", Operator: FILTER_EQUAL, Value: provisionedUser.Email}
+	mockRepo.EXPECT().getUsers(tenancy.DEFAULT_TENANT_ID, expectedFilter, 1, DEFAULT_PAGE_SIZE).Return([]auth.User{provisionedUser}, 1, nil)
+	mockRepo.EXPECT().getUsers(tenancy.DEFAULT_TENANT_ID, nil, 11, MAX_PAGE_SIZE).Return([]auth.User{}, 1, nil)
+
+	res := serve(t, http.MethodGet, `/Users?filter=userName+eq+"jane@example.com"`, nil)
+	assert.Equal(t, http.StatusOK, res.Code)
+	var list struct {
+		TotalResults int
+		StartIndex   int
+		Resources    []User
+	}
+	require.Nil(t, json.Unmarshal(res.Body.Bytes(), &list))
+	assert.Equal(t, 1, list.TotalResults)
+	require.Len(t, list.Resources, 1)
+	assert.Equal(t, "7", list.Resources[0].Id)
+	assert.Equal(t, "00u1", list.Resources[0].ExternalId)
+	assert.True(t, *list.Resources[0].Active)
+
+	res = serve(t, http.MethodGet, "/Users?startIndex=11&count=1000", nil)
+	assert.Equal(t, http.StatusOK, res.Code, "Count should be capped rather than refused")
+
+	res = serve(t, http.MethodGet, `/Users?filter=userName+pr`, nil)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assert.Equal(t, SCIM_TYPE_INVALID_FILTER, decodeError(t, res).ScimType)
+
+	testScimHandlerEnd()
+}
+
+func TestCreateUser(t *testing.T) {
+	testScimHandlerInit(t)
+	resource := User{
+		Schemas:    []string{SCHEMA_USER},
+		ExternalId: "00u1",
+		UserName:   "Jane@Example.com",
+		Name:       &Name{GivenName: "Jane", FamilyName: "Doe"},
+	}
+	expectedAttributes := userAttributes{ExternalId: "00u1", Fullname: "Jane Doe", Email: "jane@example.com", Active: true}
+
+	gomock.InOrder(
+		mockRepo.EXPECT().createUser(tenancy.DEFAULT_TENANT_ID, expectedAttributes).Return(&provisionedUser, nil),
+		mockRepo.EXPECT().createUser(tenancy.DEFAULT_TENANT_ID, expectedAttributes).Return(nil, errConflict),
+	)
+
+	res := serve(t, http.MethodPost, "/Users", resource)
+	assert.Equal(t, http.StatusCreated, res.Code)
+	assert.Equal(t, "http://example.com/scim/v2/Users/7", res.Header().Get("Location"))
+
+	res = serve(t, http.MethodPost, "/Users", resource)
+	assert.Equal(t, http.StatusConflict, res.Code, "Existing user should conflict")
+	assert.Equal(t, SCIM_TYPE_UNIQUENESS, decodeError(t, res).ScimType)
+
+	res = serve(t, http.MethodPost, "/Users", User{UserName: "jane"})
+	assert.Equal(t, http.StatusBadRequest, res.Code, "User without an email address should be refused")
+
+	testScimHandlerEnd()
+}
+
+func TestPatchUser(t *testing.T) {
+	testScimHandlerInit(t)
+	patch := PatchRequest{
+		Schemas: []string{SCHEMA_PATCH_OP},
+		Operations: []PatchOperation{
+			{Op: "Replace", Path: "active", Value: "False"},
+			{Op: "replace", Path: "name.formatted", Value: "Jane Smith"},
+		},
+	}
+	expectedAttributes := userAttributes{ExternalId: "00u1", Fullname: "Jane Smith", Email: provisionedUser.Email}
+
+	mockRepo.EXPECT().getUser(tenancy.DEFAULT_TENANT_ID, provisionedUser.Id).Return(&provisionedUser, nil).Times(2)
+	mockRepo.EXPECT().replaceUser(tenancy.DEFAULT_TENANT_ID, provisionedUser.Id, expectedAttributes).Return(&provisionedUser, nil)
+
+	res := serve(t, http.MethodPatch, "/Users/7", patch)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	patch.Operations = []PatchOperation{{Op: "replace", Path: "nickName", Value: "JD"}}
+	res = serve(t, http.MethodPatch, "/Users/7", patch)
+	assert.Equal(t, http.StatusBadRequest, res.Code, "Unsupported attribute should be refused")
+	assert.Equal(t, SCIM_TYPE_INVALID_PATH, decodeError(t, res).ScimType)
+
+	testScimHandlerEnd()
+}
+
+func TestDeleteUser(t *testing.T) {
+	testScimHandlerInit(t)
+	mockRepo.EXPECT().deactivateUser(tenancy.DEFAULT_TENANT_ID, provisionedUser.Id).Return(nil)
+	mockRepo.EXPECT().deactivateUser(tenancy.DEFAULT_TENANT_ID, 8).Return(sql.ErrNoRows)
+
+	res := serve(t, http.MethodDelete, "/Users/7", nil)
+	assert.Equal(t, http.StatusNoContent, res.Code, "User should be deactivated")
+
+	res = serve(t, http.MethodDelete, "/Users/8", nil)
+	assert.Equal(t, http.StatusNotFound, res.Code)
+
+	res = serve(t, http.MethodGet, "/Users/abc", nil)
+	assert.Equal(t, http.StatusNotFound, res.Code)
+
+	testScimHandlerEnd()
+}
+
+func TestCreateGroup(t *testing.T) {
+	testScimHandlerInit(t)
+	resource := Group{
+		Schemas:     []string{SCHEMA_GROUP},
+		DisplayName: "Engineering",
+		Members:     []GroupMember{{Value: "7"}},
+	}
+
+	mockRepo.EXPECT().createGroup(tenancy.DEFAULT_TENANT_ID, "Engineering", []int{7}).Return(&provisionedGroup, nil)
+	mockRepo.EXPECT().createGroup(tenancy.DEFAULT_TENANT_ID, "Engineering", []int{8}).Return(nil, errUnknownMember)
+
+	res := serve(t, http.MethodPost, "/Groups", resource)
+	assert.Equal(t, http.StatusCreated, res.Code)
+	var created Group
+	require.Nil(t, json.Unmarshal(res.Body.Bytes(), &created))
+	assert.Equal(t, "3", created.Id)
+	assert.Equal(t, "Jane Doe", created.Members[0].Display)
+
+	resource.Members = []GroupMember{{Value: "8"}}
+	res = serve(t, http.MethodPost, "/Groups", resource)
+	assert.Equal(t, http.StatusBadRequest, res.Code, "Member outside of the tenant should be refused")
+
+	resource.Members = []GroupMember{{Value: "jane"}}
+	res = serve(t, http.MethodPost, "/Groups", resource)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+
+	testScimHandlerEnd()
+}
+
+func TestPatchGroup(t *testing.T) {
+	testScimHandlerInit(t)
+	patch := PatchRequest{
+		Schemas: []string{SCHEMA_PATCH_OP},
+		Operations: []PatchOperation{
+			{Op: "add", Path: "members", Value: []interface{}{map[string]interface{}{"value": "9"}}},
+			{Op: "remove", Path: `members[value eq "7"]`},
+		},
+	}
+
+	mockRepo.EXPECT().getGroup(tenancy.DEFAULT_TENANT_ID, provisionedGroup.Id).Return(&provisionedGroup, nil)
+	mockRepo.EXPECT().replaceGroup(tenancy.DEFAULT_TENANT_ID, provisionedGroup.Id, "Engineering", []int{9}).Return(&provisionedGroup, nil)
+
+	res := serve(t, http.MethodPatch, "/Groups/3", patch)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	testScimHandlerEnd()
+}
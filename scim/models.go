@@ -0,0 +1,279 @@
+package scim
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"userland/auth"
+)
+
+const (
+	SCHEMA_USER          = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SCHEMA_GROUP         = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SCHEMA_LIST_RESPONSE = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SCHEMA_PATCH_OP      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SCHEMA_ERROR         = "urn:ietf:params:scim:api:messages:2.0:Error"
+
+	CONTENT_TYPE = "application/scim+json"
+
+	RESOURCE_TYPE_USER  = "User"
+	RESOURCE_TYPE_GROUP = "Group"
+
+	DEFAULT_PAGE_SIZE = 100
+	MAX_PAGE_SIZE     = 200
+
+	NAME_MAX_LENGTH = 128
+)
+
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type Email struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type Address struct {
+	Formatted string `json:"formatted"`
+	Type      string `json:"type,omitempty"`
+	Primary   bool   `json:"primary,omitempty"`
+}
+
+type Meta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location"`
+}
+
+// User is the SCIM representation of a userland user. The userName is the
+// user's email address; the password is only ever read, never returned.
+type User struct {
+	Schemas     []string  `json:"schemas"`
+	Id          string    `json:"id,omitempty"`
+	ExternalId  string    `json:"externalId,omitempty"`
+	UserName    string    `json:"userName"`
+	Name        *Name     `json:"name,omitempty"`
+	DisplayName string    `json:"displayName,omitempty"`
+	ProfileUrl  string    `json:"profileUrl,omitempty"`
+	Emails      []Email   `json:"emails,omitempty"`
+	Addresses   []Address `json:"addresses,omitempty"`
+	Active      *bool     `json:"active,omitempty"`
+	Password    string    `json:"password,omitempty"`
+	Meta        *Meta     `json:"meta,omitempty"`
+}
+
+type GroupMember struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+// Group is the SCIM representation of an organization.
+type Group struct {
+	Schemas     []string      `json:"schemas"`
+	Id          string        `json:"id,omitempty"`
+	DisplayName string        `json:"displayName"`
+	Members     []GroupMember `json:"members"`
+	Meta        *Meta         `json:"meta,omitempty"`
+}
+
+type ListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int         `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+// Error is the body of every failed SCIM request. The scimType narrows down
+// bad requests and conflicts for the identity provider.
+type Error struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail"`
+}
+
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// userAttributes are the parts of a user which the identity provider manages.
+type userAttributes struct {
+	ExternalId string
+	Fullname   string
+	Email      string
+	Web        string
+	Location   string
+	Active     bool
+	Password   string
+}
+
+// organization is an organization along with its members in the tenant.
+type organization struct {
+	Id        int       `db:"id"`
+	Name      string    `db:"name"`
+	CreatedAt time.Time `db:"created_at"`
+	Members   []member
+}
+
+type member struct {
+	OrganizationId int    `db:"organization_id"`
+	UserId         int    `db:"user_id"`
+	Fullname       string `db:"fullname"`
+}
+
+// attributes reads the user as sent by the identity provider. The email is
+// the userName when it's an address, and the primary email otherwise. The
+// name falls back from the formatted one to the given and family names, then
+// to the display name and finally to the email.
+func (user User) attributes() userAttributes {
+	email := strings.ToLower(strings.TrimSpace(user.UserName))
+	if !isValidEmail(email) {
+		email = strings.ToLower(strings.TrimSpace(user.primaryEmail()))
+	}
+
+	fullname := ""
+	if user.Name != nil {
+		fullname = strings.TrimSpace(user.Name.Formatted)
+		if fullname == "" {
+			fullname = strings.TrimSpace(user.Name.GivenName + " " + user.Name.FamilyName)
+		}
+	}
+	if fullname == "" {
+		fullname = strings.TrimSpace(user.DisplayName)
+	}
+	if fullname == "" {
+		fullname = email
+	}
+
+	location := ""
+	if len(user.Addresses) > 0 {
+		location = user.Addresses[0].Formatted
+		for _, address := range user.Addresses {
+			if address.Primary {
+				location = address.Formatted
+			}
+		}
+	}
+
+	return userAttributes{
+		ExternalId: user.ExternalId,
+		Fullname:   fullname,
+		Email:      email,
+		Web:        user.ProfileUrl,
+		Location:   location,
+		Active:     user.Active == nil || *user.Active,
+		Password:   user.Password,
+	}
+}
+
+func (user User) primaryEmail() string {
+	if len(user.Emails) == 0 {
+		return ""
+	}
+	for _, email := range user.Emails {
+		if email.Primary {
+			return email.Value
+		}
+	}
+	return user.Emails[0].Value
+}
+
+func (attributes userAttributes) isValid() bool {
+	return isValidEmail(attributes.Email) && len(attributes.Fullname) <= NAME_MAX_LENGTH
+}
+
+func isValidEmail(email string) bool {
+	return regexp.MustCompile(`^[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,4}$`).MatchString(email)
+}
+
+func userResource(user *auth.User, baseURL string) User {
+	active := !user.DeactivatedAt.Valid
+	resource := User{
+		Schemas:     []string{SCHEMA_USER},
+		Id:          strconv.Itoa(user.Id),
+		ExternalId:  user.ExternalId.String,
+		UserName:    user.Email,
+		Name:        &Name{Formatted: user.Fullname},
+		DisplayName: user.Fullname,
+		ProfileUrl:  user.Web.String,
+		Emails:      []Email{{Value: user.Email, Type: "work", Primary: true}},
+		Active:      &active,
+		Meta: &Meta{
+			ResourceType: RESOURCE_TYPE_USER,
+			Created:      user.CreatedAt,
+			LastModified: user.CreatedAt,
+			Location:     fmt.Sprintf("%s/Users/%d", baseURL, user.Id),
+		},
+	}
+	if user.Location.String != "" {
+		resource.Addresses = []Address{{Formatted: user.Location.String, Type: "work", Primary: true}}
+	}
+	return resource
+}
+
+func groupResource(group *organization, baseURL string) Group {
+	members := make([]GroupMember, 0, len(group.Members))
+	for _, member := range group.Members {
+		members = append(members, GroupMember{
+			Value:   strconv.Itoa(member.UserId),
+			Display: member.Fullname,
+			Ref:     fmt.Sprintf("%s/Users/%d", baseURL, member.UserId),
+		})
+	}
+	return Group{
+		Schemas:     []string{SCHEMA_GROUP},
+		Id:          strconv.Itoa(group.Id),
+		DisplayName: group.Name,
+		Members:     members,
+		Meta: &Meta{
+			ResourceType: RESOURCE_TYPE_GROUP,
+			Created:      group.CreatedAt,
+			LastModified: group.CreatedAt,
+			Location:     fmt.Sprintf("%s/Groups/%d", baseURL, group.Id),
+		},
+	}
+}
+
+// memberIds reads the ids of the group's members, failing on any which isn't
+// a userland user id.
+func (group Group) memberIds() ([]int, bool) {
+	ids := make([]int, 0, len(group.Members))
+	for _, member := range group.Members {
+		id, err := strconv.Atoi(member.Value)
+		if err != nil {
+			return nil, false
+		}
+		ids = append(ids, id)
+	}
+	return ids, true
+}
+
+func (group Group) isValid() bool {
+	name := strings.TrimSpace(group.DisplayName)
+	return name != "" && len(name) <= NAME_MAX_LENGTH
+}
+
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/scim/v2", scheme, r.Host)
+}
@@ -0,0 +1,80 @@
+package scim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserAttributes(t *testing.T) {
+	inactive := false
+	user := User{
+		UserName:    "00u1",
+		DisplayName: "Jane",
+		Emails:      []Email{{Value: "other@example.com"}, {Value: "Jane@Example.com", Primary: true}},
+		Addresses:   []Address{{Formatted: "Jakarta"}},
+		Active:      &inactive,
+	}
+
+	attributes := user.attributes()
+	assert.Equal(t, "jane@example.com", attributes.Email, "Primary email should be used when the userName isn't one")
+	assert.Equal(t, "Jane", attributes.Fullname)
+	assert.Equal(t, "Jakarta", attributes.Location)
+	assert.False(t, attributes.Active)
+	assert.True(t, attributes.isValid())
+
+	attributes = User{UserName: "jane@example.com"}.attributes()
+	assert.Equal(t, "jane@example.com", attributes.Fullname, "Email should stand in for a missing name")
+	assert.True(t, attributes.Active, "Users should be active unless told otherwise")
+
+	assert.False(t, User{UserName: "jane"}.attributes().isValid())
+}
+
+func TestUserApplyPatch(t *testing.T) {
+	user := User{
+		UserName: "jane@example.com",
+		Name:     &Name{Formatted: "Jane Doe"},
+		Emails:   []Email{{Value: "jane@example.com", Primary: true}},
+	}
+
+	err := user.applyPatch([]PatchOperation{
+		{Op: "replace", Value: map[string]interface{}{"active": false, "name.givenName": "Janet"}},
+		{Op: "replace", Path: "name.familyName", Value: "Smith"},
+		{Op: "replace", Path: `emails[type eq "work"].value`, Value: "janet@example.com"},
+		{Op: "add", Path: "addresses", Value: []interface{}{map[string]interface{}{"formatted": "Bandung", "primary": true}}},
+	})
+	require.Nil(t, err)
+
+	attributes := user.attributes()
+	assert.False(t, attributes.Active)
+	assert.Equal(t, "Janet Smith", attributes.Fullname)
+	assert.Equal(t, "janet@example.com", attributes.Email, "userName should follow the email it stood for")
+	assert.Equal(t, "Bandung", attributes.Location)
+
+	require.Nil(t, user.applyPatch([]PatchOperation{{Op: "remove", Path: "addresses"}}))
+	assert.Empty(t, user.attributes().Location)
+
+	assert.Equal(t, errPatchInvalid, user.applyPatch([]PatchOperation{{Op: "move", Path: "active", Value: true}}))
+	assert.Equal(t, errPatchInvalid, user.applyPatch([]PatchOperation{{Op: "remove", Path: "userName"}}))
+}
+
+func TestGroupApplyPatch(t *testing.T) {
+	group := Group{DisplayName: "Engineering", Members: []GroupMember{{Value: "1"}, {Value: "2"}}}
+
+	err := group.applyPatch([]PatchOperation{
+		{Op: "add", Path: "members", Value: []interface{}{map[string]interface{}{"value": "2"}, map[string]interface{}{"value": "3"}}},
+		{Op: "remove", Path: "members", Value: []interface{}{map[string]interface{}{"value": "1"}}},
+		{Op: "replace", Path: "displayName", Value: "Platform"},
+	})
+	require.Nil(t, err)
+	assert.Equal(t, "Platform", group.DisplayName)
+	ids, ok := group.memberIds()
+	assert.True(t, ok)
+	assert.Equal(t, []int{2, 3}, ids)
+
+	require.Nil(t, group.applyPatch([]PatchOperation{{Op: "remove", Path: "members"}}))
+	assert.Empty(t, group.Members, "Removing without a value should remove every member")
+
+	assert.Equal(t, errPatchInvalid, group.applyPatch([]PatchOperation{{Op: "remove", Path: "displayName"}}))
+}
@@ -0,0 +1,293 @@
+package scim
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	PATCH_ADD     = "add"
+	PATCH_REPLACE = "replace"
+	PATCH_REMOVE  = "remove"
+)
+
+var (
+	errPatchInvalid = errors.New("Patch operation is invalid or not supported")
+
+	// valuePathFilter matches the filter of a multi-valued attribute path,
+	// e.g. [type eq "work"] in emails[type eq "work"].value.
+	valuePathFilter = regexp.MustCompile(`\[[^\]]*\]`)
+	memberPath      = regexp.MustCompile(`(?i)^members\[value eq "?([0-9]+)"?\]$`)
+)
+
+// applyPatch applies the operations to the user in order. Operations without
+// a path carry an object of attributes to set, as some identity providers
+// send them; every other operation names a single attribute.
+func (user *User) applyPatch(operations []PatchOperation) error {
+	for _, operation := range operations {
+		op := strings.ToLower(operation.Op)
+		switch {
+		case op != PATCH_ADD && op != PATCH_REPLACE && op != PATCH_REMOVE:
+			return errPatchInvalid
+		case operation.Path == "" && op == PATCH_REMOVE:
+			return errPatchInvalid
+		case operation.Path == "":
+			values, ok := operation.Value.(map[string]interface{})
+			if !ok {
+				return errPatchInvalid
+			}
+			for path, value := range values {
+				err := user.setAttribute(path, value)
+				if err != nil {
+					return err
+				}
+			}
+		case op == PATCH_REMOVE:
+			err := user.setAttribute(operation.Path, nil)
+			if err != nil {
+				return err
+			}
+		default:
+			err := user.setAttribute(operation.Path, operation.Value)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setAttribute sets the attribute at the path, or clears it when the value is
+// nil. Filters of multi-valued attributes are ignored since userland only
+// keeps one email address and one address per user.
+func (user *User) setAttribute(path string, value interface{}) error {
+	path = strings.ToLower(strings.TrimPrefix(path, SCHEMA_USER+":"))
+	path = valuePathFilter.ReplaceAllString(path, "")
+
+	if user.Name == nil {
+		user.Name = &Name{}
+	}
+
+	switch path {
+	case "active":
+		active, ok := boolValue(value)
+		if !ok {
+			return errPatchInvalid
+		}
+		user.Active = &active
+	case "username":
+		userName, ok := value.(string)
+		if !ok {
+			return errPatchInvalid
+		}
+		user.UserName = userName
+	case "externalid":
+		user.ExternalId, _ = value.(string)
+	case "displayname", "name.formatted":
+		user.Name.Formatted, _ = value.(string)
+		user.DisplayName = user.Name.Formatted
+	case "name.givenname":
+		user.Name.GivenName, _ = value.(string)
+		user.Name.Formatted, user.DisplayName = "", ""
+	case "name.familyname":
+		user.Name.FamilyName, _ = value.(string)
+		user.Name.Formatted, user.DisplayName = "", ""
+	case "name":
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return errPatchInvalid
+		}
+		for attribute, attributeValue := range values {
+			err := user.setAttribute("name."+attribute, attributeValue)
+			if err != nil {
+				return err
+			}
+		}
+	case "profileurl":
+		user.ProfileUrl, _ = value.(string)
+	case "emails", "emails.value":
+		email, ok := multiValuedString(value, "value")
+		if !ok || email == "" {
+			return errPatchInvalid
+		}
+		if strings.EqualFold(user.UserName, user.primaryEmail()) {
+			user.UserName = email
+		}
+		user.Emails = []Email{{Value: email, Type: "work", Primary: true}}
+	case "addresses", "addresses.formatted":
+		location, ok := multiValuedString(value, "formatted")
+		if !ok {
+			return errPatchInvalid
+		}
+		user.Addresses = nil
+		if location != "" {
+			user.Addresses = []Address{{Formatted: location, Type: "work", Primary: true}}
+		}
+	case "password":
+		password, ok := value.(string)
+		if !ok || password == "" {
+			return errPatchInvalid
+		}
+		user.Password = password
+	default:
+		return errPatchInvalid
+	}
+	return nil
+}
+
+// applyPatch applies the operations to the group in order, covering renames
+// and adding, replacing and removing members.
+func (group *Group) applyPatch(operations []PatchOperation) error {
+	for _, operation := range operations {
+		op := strings.ToLower(operation.Op)
+		path := strings.TrimPrefix(operation.Path, SCHEMA_GROUP+":")
+
+		if match := memberPath.FindStringSubmatch(path); match != nil && op == PATCH_REMOVE {
+			group.removeMembers([]GroupMember{{Value: match[1]}})
+			continue
+		}
+
+		switch strings.ToLower(path) {
+		case "":
+			values, ok := operation.Value.(map[string]interface{})
+			if !ok || op == PATCH_REMOVE {
+				return errPatchInvalid
+			}
+			for attribute, value := range values {
+				err := group.applyPatch([]PatchOperation{{Op: op, Path: attribute, Value: value}})
+				if err != nil {
+					return err
+				}
+			}
+		case "displayname":
+			name, ok := operation.Value.(string)
+			if !ok || op == PATCH_REMOVE {
+				return errPatchInvalid
+			}
+			group.DisplayName = name
+		case "members":
+			members, ok := memberValues(operation.Value)
+			if !ok {
+				return errPatchInvalid
+			}
+			switch op {
+			case PATCH_ADD:
+				group.addMembers(members)
+			case PATCH_REPLACE:
+				group.Members = nil
+				group.addMembers(members)
+			case PATCH_REMOVE:
+				if operation.Value == nil {
+					group.Members = nil
+				} else {
+					group.removeMembers(members)
+				}
+			default:
+				return errPatchInvalid
+			}
+		default:
+			return errPatchInvalid
+		}
+	}
+	return nil
+}
+
+func (group *Group) addMembers(members []GroupMember) {
+	for _, member := range members {
+		if !group.hasMember(member.Value) {
+			group.Members = append(group.Members, member)
+		}
+	}
+}
+
+func (group *Group) removeMembers(members []GroupMember) {
+	removed := map[string]bool{}
+	for _, member := range members {
+		removed[member.Value] = true
+	}
+
+	kept := []GroupMember{}
+	for _, member := range group.Members {
+		if !removed[member.Value] {
+			kept = append(kept, member)
+		}
+	}
+	group.Members = kept
+}
+
+func (group *Group) hasMember(value string) bool {
+	for _, member := range group.Members {
+		if member.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+// boolValue reads a boolean, which some identity providers send as a string.
+func boolValue(value interface{}) (bool, bool) {
+	switch value := value.(type) {
+	case bool:
+		return value, true
+	case string:
+		parsed, err := strconv.ParseBool(value)
+		return parsed, err == nil
+	}
+	return false, false
+}
+
+// multiValuedString reads the primary, or else the first, sub-attribute of a
+// multi-valued attribute, which may also be given directly as a string. A nil
+// value reads as an empty string.
+func multiValuedString(value interface{}, subAttribute string) (string, bool) {
+	switch value := value.(type) {
+	case nil:
+		return "", true
+	case string:
+		return value, true
+	case []interface{}:
+		result := ""
+		for i, item := range value {
+			entry, ok := item.(map[string]interface{})
+			if !ok {
+				return "", false
+			}
+			if primary, _ := entry["primary"].(bool); i == 0 || primary {
+				result, _ = entry[subAttribute].(string)
+			}
+		}
+		return result, true
+	}
+	return "", false
+}
+
+// memberValues reads a list of members, each an object with a value.
+func memberValues(value interface{}) ([]GroupMember, bool) {
+	if value == nil {
+		return nil, true
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	members := make([]GroupMember, 0, len(items))
+	for _, item := range items {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		switch memberValue := entry["value"].(type) {
+		case string:
+			members = append(members, GroupMember{Value: memberValue})
+		case float64:
+			members = append(members, GroupMember{Value: fmt.Sprintf("%.0f", memberValue)})
+		default:
+			return nil, false
+		}
+	}
+	return members, true
+}
@@ -0,0 +1,346 @@
+package scim
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"userland/appcontext"
+	"userland/auth"
+	"userland/org"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	USE_TOKEN_QUERY = "UPDATE scim_token SET last_used_at=now() WHERE tenant_id=$1 AND token_hash=$2"
+
+	SELECT_USERS_QUERY = "SELECT * FROM \"user\" WHERE tenant_id=$1%s ORDER BY id LIMIT $%d OFFSET $%d"
+	COUNT_USERS_QUERY  = "SELECT count(*) FROM \"user\" WHERE tenant_id=$1%s"
+	SELECT_USER_QUERY  = "SELECT * FROM \"user\" WHERE tenant_id=$1 AND id=$2"
+	CREATE_USER_QUERY  = "INSERT INTO \"user\" (tenant_id, external_id, fullname, email, web, location, password, verified, deactivated_at) " +
+		"VALUES ($1, NULLIF($2, ''), $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7, true, CASE WHEN $8 THEN NULL ELSE now() END) RETURNING id"
+	// Sessions are revoked when the user is deactivated or the email address
+	// or password changes, as when the user changes them.
+	REPLACE_USER_QUERY = "UPDATE \"user\" SET external_id=NULLIF($1, ''), fullname=$2, email=$3, web=NULLIF($4, ''), location=NULLIF($5, ''), " +
+		"deactivated_at=CASE WHEN $6 THEN NULL ELSE COALESCE(deactivated_at, now()) END, password=COALESCE($7, password), " +
+		"profile_version=profile_version+1, token_version=token_version + CASE WHEN email<>$3 OR (NOT $6 AND deactivated_at IS NULL) OR $7 IS NOT NULL THEN 1 ELSE 0 END " +
+		"WHERE tenant_id=$8 AND id=$9"
+	DEACTIVATE_USER_QUERY = "UPDATE \"user\" SET deactivated_at=COALESCE(deactivated_at, now()), token_version=token_version + CASE WHEN deactivated_at IS NULL THEN 1 ELSE 0 END WHERE tenant_id=$1 AND id=$2"
+
+	SELECT_GROUPS_QUERY       = "SELECT id, name, created_at FROM organization WHERE tenant_id=$1%s ORDER BY id LIMIT $%d OFFSET $%d"
+	COUNT_GROUPS_QUERY        = "SELECT count(*) FROM organization WHERE tenant_id=$1%s"
+	SELECT_GROUP_QUERY        = "SELECT id, name, created_at FROM organization WHERE tenant_id=$1 AND id=$2"
+	SELECT_GROUP_MEMBERS      = "SELECT organization_member.organization_id, \"user\".id AS user_id, \"user\".fullname FROM organization_member JOIN \"user\" ON \"user\".id=organization_member.user_id WHERE organization_member.organization_id = ANY($1) ORDER BY \"user\".id"
+	CREATE_GROUP_QUERY        = "INSERT INTO organization (tenant_id, name) VALUES ($1, $2) RETURNING id"
+	UPDATE_GROUP_QUERY        = "UPDATE organization SET name=$1 WHERE tenant_id=$2 AND id=$3"
+	DELETE_GROUP_QUERY        = "DELETE FROM organization WHERE tenant_id=$1 AND id=$2"
+	COUNT_TENANT_USERS_QUERY  = "SELECT count(*) FROM \"user\" WHERE tenant_id=$1 AND id = ANY($2)"
+	INSERT_GROUP_MEMBER_QUERY = "INSERT INTO organization_member (organization_id, user_id, role) SELECT $1, id, '" + org.ROLE_MEMBER + "' FROM \"user\" WHERE tenant_id=$2 AND id = ANY($3) ON CONFLICT DO NOTHING"
+	DELETE_GROUP_MEMBER_QUERY = "DELETE FROM organization_member WHERE organization_id=$1 AND NOT (user_id = ANY($2))"
+
+	UNIQUE_VIOLATION      = "23505"
+	RANDOM_PASSWORD_BYTES = 32
+)
+
+var (
+	errConflict      = errors.New("Resource conflicts with an existing one")
+	errUnknownMember = errors.New("Group member isn't a user of the tenant")
+)
+
+type scimRepositoryInterface interface {
+	useToken(tenantId int, tokenHash string) error
+	getUsers(tenantId int, filter *filter, startIndex int, count int) ([]auth.User, int, error)
+	getUser(tenantId int, id int) (*auth.User, error)
+	createUser(tenantId int, attributes userAttributes) (*auth.User, error)
+	replaceUser(tenantId int, id int, attributes userAttributes) (*auth.User, error)
+	deactivateUser(tenantId int, id int) error
+	getGroups(tenantId int, filter *filter, startIndex int, count int) ([]organization, int, error)
+	getGroup(tenantId int, id int) (*organization, error)
+	createGroup(tenantId int, name string, memberIds []int) (*organization, error)
+	replaceGroup(tenantId int, id int, name string, memberIds []int) (*organization, error)
+	deleteGroup(tenantId int, id int) error
+}
+
+type scimRepository struct {
+	db *sqlx.DB
+}
+
+func GetScimRepository() *scimRepository {
+	repo := scimRepository{appcontext.GetDB()}
+	return &repo
+}
+
+// useToken records the use of the tenant's token, returning sql.ErrNoRows
+// when the tenant has no such token.
+func (repo *scimRepository) useToken(tenantId int, tokenHash string) error {
+	return execForRow(repo.db, USE_TOKEN_QUERY, tenantId, tokenHash)
+}
+
+func (repo *scimRepository) getUsers(tenantId int, filter *filter, startIndex int, count int) ([]auth.User, int, error) {
+	where, args := filterCondition(tenantId, filter)
+
+	var total int
+	err := repo.db.Get(&total, fmt.Sprintf(COUNT_USERS_QUERY, where), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	users := []auth.User{}
+	query := fmt.Sprintf(SELECT_USERS_QUERY, where, len(args)+1, len(args)+2)
+	err = repo.db.Select(&users, query, append(args, count, startIndex-1)...)
+	return users, total, err
+}
+
+func (repo *scimRepository) getUser(tenantId int, id int) (*auth.User, error) {
+	var user auth.User
+	err := repo.db.Get(&user, SELECT_USER_QUERY, tenantId, id)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// createUser provisions a verified user. Without a password from the identity
+// provider the user gets an unguessable one, to sign in through other means
+// or after resetting it.
+func (repo *scimRepository) createUser(tenantId int, attributes userAttributes) (*auth.User, error) {
+	password := attributes.Password
+	if password == "" {
+		random := make([]byte, RANDOM_PASSWORD_BYTES)
+		_, err := rand.Read(random)
+		if err != nil {
+			return nil, err
+		}
+		password = hex.EncodeToString(random)
+	}
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		return nil, err
+	}
+
+	var id int
+	err = repo.db.Get(&id, CREATE_USER_QUERY, tenantId, attributes.ExternalId, attributes.Fullname, attributes.Email,
+		attributes.Web, attributes.Location, string(passwordHash), attributes.Active)
+	if err != nil {
+		return nil, conflictOrError(err)
+	}
+	return repo.getUser(tenantId, id)
+}
+
+// replaceUser overwrites the attributes managed by the identity provider. The
+// password is only changed when one is given.
+func (repo *scimRepository) replaceUser(tenantId int, id int, attributes userAttributes) (*auth.User, error) {
+	passwordHash := sql.NullString{}
+	if attributes.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(attributes.Password), bcrypt.MinCost)
+		if err != nil {
+			return nil, err
+		}
+		passwordHash = sql.NullString{String: string(hash), Valid: true}
+	}
+
+	err := execForRow(repo.db, REPLACE_USER_QUERY, attributes.ExternalId, attributes.Fullname, attributes.Email,
+		attributes.Web, attributes.Location, attributes.Active, passwordHash, tenantId, id)
+	if err != nil {
+		return nil, conflictOrError(err)
+	}
+	return repo.getUser(tenantId, id)
+}
+
+// deactivateUser locks the user out and revokes the sessions, keeping the
+// account so that it can be activated again.
+func (repo *scimRepository) deactivateUser(tenantId int, id int) error {
+	return execForRow(repo.db, DEACTIVATE_USER_QUERY, tenantId, id)
+}
+
+func (repo *scimRepository) getGroups(tenantId int, filter *filter, startIndex int, count int) ([]organization, int, error) {
+	where, args := filterCondition(tenantId, filter)
+
+	var total int
+	err := repo.db.Get(&total, fmt.Sprintf(COUNT_GROUPS_QUERY, where), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	groups := []organization{}
+	query := fmt.Sprintf(SELECT_GROUPS_QUERY, where, len(args)+1, len(args)+2)
+	err = repo.db.Select(&groups, query, append(args, count, startIndex-1)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return groups, total, repo.loadMembers(groups)
+}
+
+func (repo *scimRepository) getGroup(tenantId int, id int) (*organization, error) {
+	var group organization
+	err := repo.db.Get(&group, SELECT_GROUP_QUERY, tenantId, id)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := []organization{group}
+	err = repo.loadMembers(groups)
+	if err != nil {
+		return nil, err
+	}
+	return &groups[0], nil
+}
+
+// createGroup creates the organization with the members given, who join with
+// the member role.
+func (repo *scimRepository) createGroup(tenantId int, name string, memberIds []int) (*organization, error) {
+	tx, err := repo.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.Get(&id, CREATE_GROUP_QUERY, tenantId, name)
+	if err != nil {
+		return nil, err
+	}
+
+	err = setMembers(tx, tenantId, id, memberIds)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return repo.getGroup(tenantId, id)
+}
+
+// replaceGroup renames the organization and makes the given users its only
+// members. Members who stay keep their role.
+func (repo *scimRepository) replaceGroup(tenantId int, id int, name string, memberIds []int) (*organization, error) {
+	tx, err := repo.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(UPDATE_GROUP_QUERY, name, tenantId, id)
+	if err != nil {
+		return nil, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if affected == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	err = setMembers(tx, tenantId, id, memberIds)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return repo.getGroup(tenantId, id)
+}
+
+func (repo *scimRepository) deleteGroup(tenantId int, id int) error {
+	return execForRow(repo.db, DELETE_GROUP_QUERY, tenantId, id)
+}
+
+// loadMembers fills in the members of the groups.
+func (repo *scimRepository) loadMembers(groups []organization) error {
+	if len(groups) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(groups))
+	for _, group := range groups {
+		ids = append(ids, int64(group.Id))
+	}
+
+	members := []member{}
+	err := repo.db.Select(&members, SELECT_GROUP_MEMBERS, pq.Int64Array(ids))
+	if err != nil {
+		return err
+	}
+
+	for i := range groups {
+		groups[i].Members = []member{}
+		for _, member := range members {
+			if member.OrganizationId == groups[i].Id {
+				groups[i].Members = append(groups[i].Members, member)
+			}
+		}
+	}
+	return nil
+}
+
+// setMembers makes the users the only members of the organization, refusing
+// users outside of the tenant.
+func setMembers(tx *sqlx.Tx, tenantId int, organizationId int, memberIds []int) error {
+	unique := map[int]bool{}
+	ids := make([]int64, 0, len(memberIds))
+	for _, id := range memberIds {
+		if !unique[id] {
+			unique[id] = true
+			ids = append(ids, int64(id))
+		}
+	}
+
+	var found int
+	err := tx.Get(&found, COUNT_TENANT_USERS_QUERY, tenantId, pq.Int64Array(ids))
+	if err != nil {
+		return err
+	}
+	if found != len(ids) {
+		return errUnknownMember
+	}
+
+	_, err = tx.Exec(DELETE_GROUP_MEMBER_QUERY, organizationId, pq.Int64Array(ids))
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(INSERT_GROUP_MEMBER_QUERY, organizationId, tenantId, pq.Int64Array(ids))
+	return err
+}
+
+// filterCondition builds the part of the WHERE clause after the tenant and
+// its arguments, the tenant being the first.
+func filterCondition(tenantId int, filter *filter) (string, []interface{}) {
+	args := []interface{}{tenantId}
+	if filter == nil {
+		return "", args
+	}
+	condition, arg := filter.condition(len(args) + 1)
+	return " AND " + condition, append(args, arg)
+}
+
+// conflictOrError turns unique violations, of the email address or external
+// id within the tenant, into errConflict.
+func conflictOrError(err error) error {
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == UNIQUE_VIOLATION {
+		return errConflict
+	}
+	return err
+}
+
+// execForRow runs a statement on a single row, returning sql.ErrNoRows when
+// the row doesn't exist.
+func execForRow(db *sqlx.DB, query string, args ...interface{}) error {
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err == nil && affected == 0 {
+		return sql.ErrNoRows
+	}
+	return err
+}
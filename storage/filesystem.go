@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FilesystemStore keeps blobs as files under the root directory, the key
+// being their relative path.
+type FilesystemStore struct {
+	Root string
+}
+
+func (store *FilesystemStore) Put(key string, content []byte, contentType string) error {
+	filename, err := store.filename(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(filename), 0755)
+	if err != nil {
+		return err
+	}
+
+	// Writing to a temporary file first keeps readers from ever seeing a
+	// partially written blob.
+	file, err := ioutil.TempFile(filepath.Dir(filename), ".blob-")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
+
+func (store *FilesystemStore) Get(key string) ([]byte, error) {
+	filename, err := store.filename(key)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, ErrBlobNotFound
+	}
+	return content, err
+}
+
+func (store *FilesystemStore) Delete(key string) error {
+	filename, err := store.filename(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// filename maps the key to a path under the root, refusing keys that would
+// leave it.
+func (store *FilesystemStore) filename(key string) (string, error) {
+	if !validKey(key) {
+		return "", errBlobKeyInvalid
+	}
+	return filepath.Join(store.Root, filepath.FromSlash(key)), nil
+}
+
+func validKey(key string) bool {
+	return key != "" && path.Clean("/"+key) == "/"+key && !strings.Contains(key, `\`)
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilesystemStore(t *testing.T) {
+	root, err := ioutil.TempDir("", "blobs")
+	require.Nil(t, err)
+	defer os.RemoveAll(root)
+
+	store := &FilesystemStore{Root: root}
+
+	_, err = store.Get("pictures/1/2/hash")
+	assert.Equal(t, ErrBlobNotFound, err)
+
+	require.Nil(t, store.Put("pictures/1/2/hash", []byte("picture"), "image/png"))
+	content, err := store.Get("pictures/1/2/hash")
+	require.Nil(t, err)
+	assert.Equal(t, []byte("picture"), content)
+
+	content, err = ioutil.ReadFile(filepath.Join(root, "pictures", "1", "2", "hash"))
+	require.Nil(t, err)
+	assert.Equal(t, []byte("picture"), content)
+
+	require.Nil(t, store.Put("pictures/1/2/hash", []byte("replaced"), "image/png"))
+	content, err = store.Get("pictures/1/2/hash")
+	require.Nil(t, err)
+	assert.Equal(t, []byte("replaced"), content)
+
+	entries, err := ioutil.ReadDir(filepath.Join(root, "pictures", "1", "2"))
+	require.Nil(t, err)
+	assert.Len(t, entries, 1, "Temporary files shouldn't be left behind")
+
+	require.Nil(t, store.Delete("pictures/1/2/hash"))
+	_, err = store.Get("pictures/1/2/hash")
+	assert.Equal(t, ErrBlobNotFound, err)
+	assert.Nil(t, store.Delete("pictures/1/2/hash"))
+}
+
+func TestFilesystemStoreRefusesInvalidKeys(t *testing.T) {
+	root, err := ioutil.TempDir("", "blobs")
+	require.Nil(t, err)
+	defer os.RemoveAll(root)
+
+	store := &FilesystemStore{Root: root}
+	for _, key := range []string{"", "/etc/passwd", "../outside", "pictures/../../outside", "pictures//hash", "pictures/", `pictures\hash`} {
+		assert.Equal(t, errBlobKeyInvalid, store.Put(key, []byte("picture"), "image/png"), key)
+		_, err = store.Get(key)
+		assert.Equal(t, errBlobKeyInvalid, err, key)
+		assert.Equal(t, errBlobKeyInvalid, store.Delete(key), key)
+	}
+}
@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	S3_SERVICE         = "s3"
+	S3_ALGORITHM       = "AWS4-HMAC-SHA256"
+	S3_TIME_FORMAT     = "20060102T150405Z"
+	S3_DATE_FORMAT     = "20060102"
+	S3_DEFAULT_TIMEOUT = 30 * time.Second
+
+	// errorBodyLimit bounds how much of an error response ends up in the
+	// returned error.
+	errorBodyLimit = 1024
+)
+
+// S3Store keeps blobs as objects of a bucket on an S3-compatible API, signing
+// requests with AWS Signature Version 4. Path-style addressing puts the
+// bucket in the path rather than in the host name, which most self-hosted
+// implementations expect.
+type S3Store struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyId     string
+	SecretAccessKey string
+	PathStyle       bool
+	Client          *http.Client
+}
+
+// S3Error is a response other than success from the API.
+type S3Error struct {
+	StatusCode int
+	Body       string
+}
+
+func (err *S3Error) Error() string {
+	return fmt.Sprintf("S3 responded with status %d: %s", err.StatusCode, err.Body)
+}
+
+func (store *S3Store) Put(key string, content []byte, contentType string) error {
+	res, err := store.do(http.MethodPut, key, content, map[string]string{"Content-Type": contentType})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	return checkResponse(res)
+}
+
+func (store *S3Store) Get(key string) ([]byte, error) {
+	res, err := store.do(http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, ErrBlobNotFound
+	}
+	err = checkResponse(res)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(res.Body)
+}
+
+func (store *S3Store) Delete(key string) error {
+	res, err := store.do(http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return checkResponse(res)
+}
+
+func (store *S3Store) do(method string, key string, content []byte, headers map[string]string) (*http.Response, error) {
+	if !validKey(key) {
+		return nil, errBlobKeyInvalid
+	}
+	objectURL, err := store.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(method, objectURL, bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	store.sign(req, content, time.Now())
+
+	client := store.Client
+	if client == nil {
+		client = &http.Client{Timeout: S3_DEFAULT_TIMEOUT}
+	}
+	return client.Do(req)
+}
+
+func (store *S3Store) objectURL(key string) (string, error) {
+	endpoint, err := url.Parse(strings.TrimRight(store.Endpoint, "/"))
+	if err != nil {
+		return "", err
+	}
+	if store.PathStyle {
+		return fmt.Sprintf("%s://%s%s/%s/%s", endpoint.Scheme, endpoint.Host, endpoint.Path, uriEncode(store.Bucket, true), uriEncode(key, false)), nil
+	}
+	return fmt.Sprintf("%s://%s.%s%s/%s", endpoint.Scheme, store.Bucket, endpoint.Host, endpoint.Path, uriEncode(key, false)), nil
+}
+
+// sign adds the Signature Version 4 authorization to the request, covering
+// the host, the payload and every header set so far.
+func (store *S3Store) sign(req *http.Request, payload []byte, now time.Time) {
+	payloadHash := sha256.Sum256(payload)
+	timestamp := now.UTC().Format(S3_TIME_FORMAT)
+	date := now.UTC().Format(S3_DATE_FORMAT)
+	req.Header.Set("X-Amz-Date", timestamp)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := []string{}
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := strings.Join([]string{date, store.Region, S3_SERVICE, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{S3_ALGORITHM, timestamp, scope, hex.EncodeToString(canonicalRequestHash[:])}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+store.SecretAccessKey), date)
+	signingKey = hmacSHA256(signingKey, store.Region)
+	signingKey = hmacSHA256(signingKey, S3_SERVICE)
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		S3_ALGORITHM, store.AccessKeyId, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func canonicalQuery(query url.Values) string {
+	pairs := []string{}
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes everything but the unreserved characters, and
+// slashes unless asked to, as the signature expects.
+func uriEncode(value string, encodeSlash bool) string {
+	var builder strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			builder.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			builder.WriteByte(c)
+		default:
+			fmt.Fprintf(&builder, "%%%02X", c)
+		}
+	}
+	return builder.String()
+}
+
+func checkResponse(res *http.Response) error {
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+	body, _ := ioutil.ReadAll(io.LimitReader(res.Body, errorBodyLimit))
+	return &S3Error{StatusCode: res.StatusCode, Body: string(body)}
+}
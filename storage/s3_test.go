@@ -0,0 +1,62 @@
+package storage_test
+
+import (
+	"net/http"
+	"testing"
+	"userland/storage"
+	"userland/storage/s3test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3Store(t *testing.T) {
+	server := s3test.NewServer("access")
+	defer server.Close()
+
+	store := &storage.S3Store{
+		Endpoint:        server.URL,
+		Region:          "us-east-1",
+		Bucket:          "userland",
+		AccessKeyId:     "access",
+		SecretAccessKey: "secret",
+		PathStyle:       true,
+	}
+
+	_, err := store.Get("pictures/1/2/hash")
+	assert.Equal(t, storage.ErrBlobNotFound, err)
+
+	require.Nil(t, store.Put("pictures/1/2/hash", []byte("picture"), "image/png"))
+	object, ok := server.Object("userland", "pictures/1/2/hash")
+	require.True(t, ok)
+	assert.Equal(t, []byte("picture"), object.Content)
+	assert.Equal(t, "image/png", object.ContentType)
+
+	content, err := store.Get("pictures/1/2/hash")
+	require.Nil(t, err)
+	assert.Equal(t, []byte("picture"), content)
+
+	require.Nil(t, store.Delete("pictures/1/2/hash"))
+	_, ok = server.Object("userland", "pictures/1/2/hash")
+	assert.False(t, ok)
+	_, err = store.Get("pictures/1/2/hash")
+	assert.Equal(t, storage.ErrBlobNotFound, err)
+}
+
+func TestS3StoreError(t *testing.T) {
+	server := s3test.NewServer("access")
+	defer server.Close()
+
+	store := &storage.S3Store{
+		Endpoint:        server.URL,
+		Region:          "us-east-1",
+		Bucket:          "userland",
+		AccessKeyId:     "someone-else",
+		SecretAccessKey: "secret",
+		PathStyle:       true,
+	}
+
+	err := store.Put("pictures/1/2/hash", []byte("picture"), "image/png")
+	require.IsType(t, &storage.S3Error{}, err)
+	assert.Equal(t, http.StatusForbidden, err.(*storage.S3Error).StatusCode)
+}
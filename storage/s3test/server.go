@@ -0,0 +1,92 @@
+// Package s3test provides an in-process stand-in for an S3-compatible API,
+// for exercising the S3 blob store in tests.
+package s3test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+type Object struct {
+	Content     []byte
+	ContentType string
+}
+
+// Server keeps objects in memory, addressed path-style as /bucket/key. It
+// refuses requests that aren't signed with its access key id or whose
+// payload doesn't match the signed hash; the signature itself isn't
+// checked.
+type Server struct {
+	URL         string
+	AccessKeyId string
+
+	server  *httptest.Server
+	mutex   sync.Mutex
+	objects map[string]Object
+}
+
+// NewServer starts a server on a local port, to be stopped with Close.
+func NewServer(accessKeyId string) *Server {
+	s := &Server{AccessKeyId: accessKeyId, objects: map[string]Object{}}
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	s.URL = s.server.URL
+	return s
+}
+
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Object returns the object stored under the bucket and key, if any.
+func (s *Server) Object(bucket string, key string) (Object, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	object, ok := s.objects[bucket+"/"+key]
+	return object, ok
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential="+s.AccessKeyId+"/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	payloadHash := sha256.Sum256(body)
+	if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(payloadHash[:]) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		s.objects[name] = Object{Content: body, ContentType: r.Header.Get("Content-Type")}
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		object, ok := s.objects[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", object.ContentType)
+		w.Write(object.Content)
+	case http.MethodDelete:
+		delete(s.objects, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,45 @@
+// Package storage keeps binary objects, such as profile pictures, outside of
+// the database.
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"userland/config"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrBlobNotFound   = errors.New("Blob doesn't exist")
+	errBlobKeyInvalid = errors.New("Blob key is invalid")
+)
+
+// BlobStore keeps blobs under slash-separated keys. Putting a blob under an
+// existing key replaces it, and deleting a missing one isn't an error.
+type BlobStore interface {
+	Put(key string, content []byte, contentType string) error
+	// Get returns ErrBlobNotFound when there is no blob under the key.
+	Get(key string) ([]byte, error)
+	Delete(key string) error
+}
+
+// GetBlobStore returns the store of the configured backend. An unknown
+// backend is logged and the filesystem is used instead.
+func GetBlobStore() BlobStore {
+	switch backend := config.GetStorageBackend(); backend {
+	case config.STORAGE_BACKEND_S3:
+		return &S3Store{
+			Endpoint:        config.GetS3Endpoint(),
+			Region:          config.GetS3Region(),
+			Bucket:          config.GetS3Bucket(),
+			AccessKeyId:     config.GetS3AccessKeyId(),
+			SecretAccessKey: config.GetS3SecretAccessKey(),
+			PathStyle:       config.GetS3PathStyle(),
+		}
+	case config.STORAGE_BACKEND_FILESYSTEM:
+	default:
+		log.Warn(fmt.Sprintf("Unknown storage backend %q, using the filesystem", backend))
+	}
+	return &FilesystemStore{Root: config.GetStoragePath()}
+}
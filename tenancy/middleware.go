@@ -0,0 +1,62 @@
+package tenancy
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"net/http"
+	"strings"
+	ulanderrors "userland/errors"
+	"userland/response"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type TenantMiddleware struct {
+	TenantRepo    tenantRepositoryInterface
+	Header        string
+	DefaultTenant string
+}
+
+// WithTenant resolves the tenant of every request, from the configured header
+// when the client sends it and from the host name otherwise. Hosts which don't
+// belong to any tenant are served by the default tenant, if there is one.
+func (middleware TenantMiddleware) WithTenant(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant, err := middleware.resolve(r)
+		if err == sql.ErrNoRows {
+			log.Info("Request doesn't belong to any tenant")
+			response.RespondBadRequest(w, ulanderrors.ErrTenantNotFound)
+			return
+		}
+		if err != nil {
+			log.Warn(err)
+			response.RespondInternalError(w, ulanderrors.ErrTenantQueryExec)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), "tenant", tenant)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (middleware TenantMiddleware) resolve(r *http.Request) (*Tenant, error) {
+	if middleware.Header != "" {
+		if slug := strings.TrimSpace(r.Header.Get(middleware.Header)); slug != "" {
+			return middleware.TenantRepo.getTenantBySlug(strings.ToLower(slug))
+		}
+	}
+
+	tenant, err := middleware.TenantRepo.getTenantByHostname(hostname(r.Host))
+	if err == sql.ErrNoRows && middleware.DefaultTenant != "" {
+		return middleware.TenantRepo.getTenantBySlug(middleware.DefaultTenant)
+	}
+	return tenant, err
+}
+
+func hostname(host string) string {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	return strings.ToLower(host)
+}
@@ -0,0 +1,76 @@
+package tenancy
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"userland/config"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	brandTenant   = Tenant{Id: 2, Slug: "brand", Hostname: sql.NullString{String: "brand.example.com", Valid: true}}
+	defaultTenant = Tenant{Id: DEFAULT_TENANT_ID, Slug: config.DEFAULT_TENANT_SLUG}
+)
+
+func serveWithTenant(middleware TenantMiddleware, host string, header string) (*httptest.ResponseRecorder, Tenant) {
+	var resolved Tenant
+	handler := middleware.WithTenant(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resolved = FromRequest(r)
+	}))
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/ping", nil)
+	req.Host = host
+	if header != "" {
+		req.Header.Set(config.DEFAULT_TENANT_HEADER, header)
+	}
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+	return res, resolved
+}
+
+func TestWithTenant(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := NewMocktenantRepositoryInterface(ctrl)
+	middleware := TenantMiddleware{TenantRepo: mockRepo, Header: config.DEFAULT_TENANT_HEADER, DefaultTenant: config.DEFAULT_TENANT_SLUG}
+
+	mockRepo.EXPECT().getTenantByHostname("brand.example.com").Return(&brandTenant, nil)
+	res, tenant := serveWithTenant(middleware, "Brand.example.com:8080", "")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, brandTenant.Id, tenant.Id)
+
+	mockRepo.EXPECT().getTenantBySlug("brand").Return(&brandTenant, nil)
+	res, tenant = serveWithTenant(middleware, "localhost", "Brand")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, brandTenant.Id, tenant.Id, "Header should take precedence over the host")
+
+	mockRepo.EXPECT().getTenantByHostname("localhost").Return(nil, sql.ErrNoRows)
+	mockRepo.EXPECT().getTenantBySlug(config.DEFAULT_TENANT_SLUG).Return(&defaultTenant, nil)
+	res, tenant = serveWithTenant(middleware, "localhost:8080", "")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, DEFAULT_TENANT_ID, tenant.Id)
+
+	mockRepo.EXPECT().getTenantBySlug("unknown").Return(nil, sql.ErrNoRows)
+	res, _ = serveWithTenant(middleware, "brand.example.com", "unknown")
+	assert.Equal(t, http.StatusBadRequest, res.Code, "Unknown tenant in the header should not fall back")
+
+	mockRepo.EXPECT().getTenantByHostname("brand.example.com").Return(nil, errors.New("connection refused"))
+	res, _ = serveWithTenant(middleware, "brand.example.com", "")
+	assert.Equal(t, http.StatusInternalServerError, res.Code)
+}
+
+func TestWithTenantWithoutDefault(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := NewMocktenantRepositoryInterface(ctrl)
+	middleware := TenantMiddleware{TenantRepo: mockRepo, Header: config.DEFAULT_TENANT_HEADER}
+
+	mockRepo.EXPECT().getTenantByHostname("unknown.example.com").Return(nil, sql.ErrNoRows)
+	res, _ := serveWithTenant(middleware, "unknown.example.com", "")
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+}
@@ -0,0 +1,66 @@
+package tenancy
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+	"userland/config"
+	"userland/mailer"
+
+	"github.com/jmoiron/sqlx/types"
+	"github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DEFAULT_TENANT_ID = 1
+)
+
+// Tenant is one of the brands served by the deployment. Users, sessions and
+// emails never cross from one tenant to another.
+type Tenant struct {
+	Id                         int            `json:"id"`
+	Slug                       string         `json:"slug"`
+	Name                       string         `json:"name"`
+	Hostname                   sql.NullString `json:"hostname"`
+	JWTKey                     sql.NullString `json:"-" db:"jwt_key"`
+	RegistrationMode           sql.NullString `json:"registration_mode" db:"registration_mode"`
+	RegistrationAllowedDomains pq.StringArray `json:"registration_allowed_domains" db:"registration_allowed_domains"`
+	EmailTemplates             types.JSONText `json:"email_templates" db:"email_templates"`
+	CreatedAt                  time.Time      `json:"created_at" db:"created_at"`
+}
+
+// FromRequest returns the tenant resolved by TenantMiddleware. Requests which
+// didn't go through it, e.g. in tests, belong to the default tenant.
+func FromRequest(r *http.Request) Tenant {
+	tenant, ok := r.Context().Value("tenant").(*Tenant)
+	if !ok {
+		return Tenant{Id: DEFAULT_TENANT_ID, Slug: config.DEFAULT_TENANT_SLUG}
+	}
+	return *tenant
+}
+
+// SigningKey returns the key the tenant's tokens are signed with, falling
+// back to JWT_KEY for tenants without their own.
+func (tenant Tenant) SigningKey() []byte {
+	if tenant.JWTKey.Valid && tenant.JWTKey.String != "" {
+		return []byte(tenant.JWTKey.String)
+	}
+	return []byte(config.GetJWTKey())
+}
+
+// Templates returns the tenant's overrides of the default email templates.
+// Overrides which can't be decoded are logged and ignored.
+func (tenant Tenant) Templates() mailer.TemplateSet {
+	set := mailer.TemplateSet{}
+	if len(tenant.EmailTemplates) == 0 {
+		return set
+	}
+	err := json.Unmarshal(tenant.EmailTemplates, &set)
+	if err != nil {
+		log.Warn(err)
+		return mailer.TemplateSet{}
+	}
+	return set
+}
@@ -0,0 +1,35 @@
+package tenancy
+
+import (
+	"database/sql"
+	"net/http"
+	"os"
+	"testing"
+	"userland/mailer"
+
+	"github.com/jmoiron/sqlx/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromRequest(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/api/ping", nil)
+	assert.Equal(t, DEFAULT_TENANT_ID, FromRequest(req).Id, "Request without a resolved tenant should belong to the default one")
+}
+
+func TestSigningKey(t *testing.T) {
+	os.Setenv("JWT_KEY", "default_key")
+	defer os.Unsetenv("JWT_KEY")
+
+	assert.Equal(t, []byte("default_key"), Tenant{}.SigningKey())
+	assert.Equal(t, []byte("brand_key"), Tenant{JWTKey: sql.NullString{String: "brand_key", Valid: true}}.SigningKey())
+}
+
+func TestTemplates(t *testing.T) {
+	assert.Empty(t, Tenant{}.Templates())
+
+	tenant := Tenant{EmailTemplates: types.JSONText(`{"email_login": {"subject": "Your Brand login link"}}`)}
+	assert.Equal(t, "Your Brand login link", tenant.Templates()[mailer.EMAIL_LOGIN_TEMPLATE].Subject)
+
+	tenant = Tenant{EmailTemplates: types.JSONText(`["not", "templates"]`)}
+	assert.Empty(t, tenant.Templates(), "Undecodable overrides should be ignored")
+}
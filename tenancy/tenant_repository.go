@@ -0,0 +1,44 @@
+package tenancy
+
+import (
+	"userland/appcontext"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	SELECT_TENANT_BY_SLUG_QUERY     = "SELECT * FROM tenant WHERE slug=$1"
+	SELECT_TENANT_BY_HOSTNAME_QUERY = "SELECT * FROM tenant WHERE hostname=$1"
+)
+
+type tenantRepositoryInterface interface {
+	getTenantBySlug(slug string) (*Tenant, error)
+	getTenantByHostname(hostname string) (*Tenant, error)
+}
+
+type tenantRepository struct {
+	db *sqlx.DB
+}
+
+func GetTenantRepository() *tenantRepository {
+	repo := tenantRepository{appcontext.GetDB()}
+	return &repo
+}
+
+func (repo *tenantRepository) getTenantBySlug(slug string) (*Tenant, error) {
+	var tenant Tenant
+	err := repo.db.Get(&tenant, SELECT_TENANT_BY_SLUG_QUERY, slug)
+	if err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+func (repo *tenantRepository) getTenantByHostname(hostname string) (*Tenant, error) {
+	var tenant Tenant
+	err := repo.db.Get(&tenant, SELECT_TENANT_BY_HOSTNAME_QUERY, hostname)
+	if err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
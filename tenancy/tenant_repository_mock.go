@@ -0,0 +1,64 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: tenancy/tenant_repository.go
+
+// Package tenancy is a generated GoMock package.
+package tenancy
+
+import (
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MocktenantRepositoryInterface is a mock of tenantRepositoryInterface interface
+type MocktenantRepositoryInterface struct {
+	ctrl     *gomock.Controller
+	recorder *MocktenantRepositoryInterfaceMockRecorder
+}
+
+// MocktenantRepositoryInterfaceMockRecorder is the mock recorder for MocktenantRepositoryInterface
+type MocktenantRepositoryInterfaceMockRecorder struct {
+	mock *MocktenantRepositoryInterface
+}
+
+// NewMocktenantRepositoryInterface creates a new mock instance
+func NewMocktenantRepositoryInterface(ctrl *gomock.Controller) *MocktenantRepositoryInterface {
+	mock := &MocktenantRepositoryInterface{ctrl: ctrl}
+	mock.recorder = &MocktenantRepositoryInterfaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MocktenantRepositoryInterface) EXPECT() *MocktenantRepositoryInterfaceMockRecorder {
+	return m.recorder
+}
+
+// getTenantBySlug mocks base method
+func (m *MocktenantRepositoryInterface) getTenantBySlug(slug string) (*Tenant, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "getTenantBySlug", slug)
+	ret0, _ := ret[0].(*Tenant)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// getTenantBySlug indicates an expected call of getTenantBySlug
+func (mr *MocktenantRepositoryInterfaceMockRecorder) getTenantBySlug(slug interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "getTenantBySlug", reflect.TypeOf((*MocktenantRepositoryInterface)(nil).getTenantBySlug), slug)
+}
+
+// getTenantByHostname mocks base method
+func (m *MocktenantRepositoryInterface) getTenantByHostname(hostname string) (*Tenant, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "getTenantByHostname", hostname)
+	ret0, _ := ret[0].(*Tenant)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// getTenantByHostname indicates an expected call of getTenantByHostname
+func (mr *MocktenantRepositoryInterfaceMockRecorder) getTenantByHostname(hostname interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "getTenantByHostname", reflect.TypeOf((*MocktenantRepositoryInterface)(nil).getTenantByHostname), hostname)
+}
@@ -0,0 +1,74 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	FLAG_USER_PRESENT           = 0x01
+	FLAG_USER_VERIFIED          = 0x04
+	FLAG_ATTESTED_CREDENTIAL    = 0x40
+	FLAG_EXTENSION_DATA_PRESENT = 0x80
+
+	rpIdHashLength      = 32
+	flagsLength         = 1
+	signCountLength     = 4
+	aaguidLength        = 16
+	credIdLengthLength  = 2
+	minAuthDataLength   = rpIdHashLength + flagsLength + signCountLength
+	attestedHeaderBytes = aaguidLength + credIdLengthLength
+)
+
+var errAuthenticatorDataMalformed = errors.New("Malformed authenticator data")
+
+type authenticatorData struct {
+	RPIdHash     []byte
+	Flags        byte
+	SignCount    uint32
+	CredentialId []byte
+	PublicKey    []byte
+}
+
+func (data authenticatorData) userPresent() bool {
+	return data.Flags&FLAG_USER_PRESENT != 0
+}
+
+func (data authenticatorData) userVerified() bool {
+	return data.Flags&FLAG_USER_VERIFIED != 0
+}
+
+func parseAuthenticatorData(raw []byte) (*authenticatorData, error) {
+	if len(raw) < minAuthDataLength {
+		return nil, errAuthenticatorDataMalformed
+	}
+
+	data := authenticatorData{
+		RPIdHash:  raw[:rpIdHashLength],
+		Flags:     raw[rpIdHashLength],
+		SignCount: binary.BigEndian.Uint32(raw[rpIdHashLength+flagsLength : minAuthDataLength]),
+	}
+
+	if data.Flags&FLAG_ATTESTED_CREDENTIAL == 0 {
+		return &data, nil
+	}
+
+	rest := raw[minAuthDataLength:]
+	if len(rest) < attestedHeaderBytes {
+		return nil, errAuthenticatorDataMalformed
+	}
+	credIdLength := int(binary.BigEndian.Uint16(rest[aaguidLength:attestedHeaderBytes]))
+	rest = rest[attestedHeaderBytes:]
+	if len(rest) < credIdLength {
+		return nil, errAuthenticatorDataMalformed
+	}
+	data.CredentialId = rest[:credIdLength]
+	rest = rest[credIdLength:]
+
+	_, keyLength, err := decodeCBOR(rest)
+	if err != nil {
+		return nil, err
+	}
+	data.PublicKey = rest[:keyLength]
+	return &data, nil
+}
@@ -0,0 +1,156 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	cborUnsignedInt = 0
+	cborNegativeInt = 1
+	cborByteString  = 2
+	cborTextString  = 3
+	cborArray       = 4
+	cborMap         = 5
+	cborTag         = 6
+	cborSimple      = 7
+
+	// COSE keys and attestation objects nest a few levels at most.
+	cborMaxDepth = 16
+)
+
+var errCBORMalformed = errors.New("Malformed CBOR data")
+
+// cborDecoder decodes the subset of CBOR used by WebAuthn attestation objects
+// and COSE keys: integers, byte and text strings, arrays, maps, tags and the
+// false/true/null simple values. Integers are decoded as int64. Lengths are
+// checked against the data left and nesting is bounded, so that hostile
+// input can't make it allocate or recurse without limit.
+type cborDecoder struct {
+	data   []byte
+	offset int
+	depth  int
+}
+
+func decodeCBOR(data []byte) (interface{}, int, error) {
+	decoder := cborDecoder{data: data}
+	value, err := decoder.decode()
+	return value, decoder.offset, err
+}
+
+func (d *cborDecoder) decode() (interface{}, error) {
+	if d.offset >= len(d.data) || d.depth >= cborMaxDepth {
+		return nil, errCBORMalformed
+	}
+	d.depth++
+	defer func() { d.depth-- }()
+
+	initial := d.data[d.offset]
+	d.offset++
+	majorType, additional := initial>>5, initial&0x1f
+
+	if majorType == cborSimple {
+		switch additional {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22, 23:
+			return nil, nil
+		}
+		return nil, errCBORMalformed
+	}
+
+	argument, err := d.readArgument(additional)
+	if err != nil {
+		return nil, err
+	}
+
+	switch majorType {
+	case cborUnsignedInt:
+		return int64(argument), nil
+	case cborNegativeInt:
+		return -1 - int64(argument), nil
+	case cborByteString:
+		return d.readBytes(argument)
+	case cborTextString:
+		text, err := d.readBytes(argument)
+		return string(text), err
+	case cborArray:
+		// every item takes at least a byte
+		if argument > uint64(len(d.data)-d.offset) {
+			return nil, errCBORMalformed
+		}
+		array := make([]interface{}, 0, argument)
+		for i := uint64(0); i < argument; i++ {
+			item, err := d.decode()
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, item)
+		}
+		return array, nil
+	case cborMap:
+		// every entry takes at least a byte for its key and one for its value
+		if argument > uint64(len(d.data)-d.offset)/2 {
+			return nil, errCBORMalformed
+		}
+		dict := make(map[interface{}]interface{}, argument)
+		for i := uint64(0); i < argument; i++ {
+			key, err := d.decode()
+			if err != nil {
+				return nil, err
+			}
+			if _, isHashable := key.([]interface{}); isHashable {
+				return nil, errCBORMalformed
+			}
+			value, err := d.decode()
+			if err != nil {
+				return nil, err
+			}
+			dict[key] = value
+		}
+		return dict, nil
+	case cborTag:
+		return d.decode()
+	}
+	return nil, errCBORMalformed
+}
+
+func (d *cborDecoder) readArgument(additional byte) (uint64, error) {
+	if additional < 24 {
+		return uint64(additional), nil
+	}
+
+	size := 0
+	switch additional {
+	case 24:
+		size = 1
+	case 25:
+		size = 2
+	case 26:
+		size = 4
+	case 27:
+		size = 8
+	default:
+		// indefinite lengths are never produced by authenticators
+		return 0, errCBORMalformed
+	}
+
+	raw, err := d.readBytes(uint64(size))
+	if err != nil {
+		return 0, err
+	}
+	padded := make([]byte, 8)
+	copy(padded[8-size:], raw)
+	return binary.BigEndian.Uint64(padded), nil
+}
+
+func (d *cborDecoder) readBytes(length uint64) ([]byte, error) {
+	if length > uint64(len(d.data)-d.offset) {
+		return nil, errCBORMalformed
+	}
+	value := d.data[d.offset : d.offset+int(length)]
+	d.offset += int(length)
+	return value, nil
+}
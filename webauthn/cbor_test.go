@@ -0,0 +1,60 @@
+package webauthn
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeCBOR(t *testing.T) {
+	// {"fmt": "none", 1: 2, -1: -7, "list": [1000, true, null], "bytes": h'010203'}
+	encoded := []byte{
+		0xa5,
+		0x63, 'f', 'm', 't', 0x64, 'n', 'o', 'n', 'e',
+		0x01, 0x02,
+		0x20, 0x26,
+		0x64, 'l', 'i', 's', 't', 0x83, 0x19, 0x03, 0xe8, 0xf5, 0xf6,
+		0x65, 'b', 'y', 't', 'e', 's', 0x43, 0x01, 0x02, 0x03,
+	}
+
+	value, length, err := decodeCBOR(append(encoded, 0xff))
+	require.Nil(t, err)
+	assert.Equal(t, len(encoded), length, "Decoder should stop at the end of the first item")
+
+	dict, ok := value.(map[interface{}]interface{})
+	require.True(t, ok)
+	assert.Equal(t, "none", dict["fmt"])
+	assert.Equal(t, int64(2), dict[int64(1)])
+	assert.Equal(t, int64(-7), dict[int64(-1)])
+	assert.Equal(t, []interface{}{int64(1000), true, nil}, dict["list"])
+	assert.Equal(t, []byte{1, 2, 3}, dict["bytes"])
+}
+
+func TestDecodeCBORMalformed(t *testing.T) {
+	_, _, err := decodeCBOR([]byte{})
+	assert.NotNil(t, err)
+
+	_, _, err = decodeCBOR([]byte{0x45, 0x01})
+	assert.NotNil(t, err, "Byte string shorter than its declared length should fail")
+
+	_, _, err = decodeCBOR([]byte{0x5f})
+	assert.NotNil(t, err, "Indefinite length items should fail")
+
+	_, _, err = decodeCBOR([]byte{0x9b, 0x00, 0x00, 0x10, 0x00, 0x00, 0x00, 0x00, 0x00})
+	assert.NotNil(t, err, "Array longer than the data left should fail before allocating")
+
+	_, _, err = decodeCBOR([]byte{0xbb, 0x00, 0x00, 0x10, 0x00, 0x00, 0x00, 0x00, 0x00})
+	assert.NotNil(t, err, "Map longer than the data left should fail before allocating")
+
+	_, _, err = decodeCBOR([]byte{0xa1, 0x01})
+	assert.NotNil(t, err, "Map without room for its value should fail")
+
+	nested := bytes.Repeat([]byte{0x81}, cborMaxDepth)
+	_, _, err = decodeCBOR(append(nested, 0x01))
+	assert.NotNil(t, err, "Nesting deeper than the limit should fail")
+
+	_, _, err = decodeCBOR(append(nested[1:], 0x01))
+	assert.Nil(t, err)
+}
@@ -0,0 +1,101 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+)
+
+const (
+	COSE_ALG_ES256 = -7
+	COSE_ALG_RS256 = -257
+
+	coseKeyType        = 1
+	coseKeyAlgorithm   = 3
+	coseEC2Curve       = -1
+	coseEC2X           = -2
+	coseEC2Y           = -3
+	coseRSAModulus     = -1
+	coseRSAExponent    = -2
+	coseKeyTypeEC2     = 2
+	coseKeyTypeRSA     = 3
+	coseCurveP256      = 1
+	ec2CoordinateBytes = 32
+)
+
+var (
+	errUnsupportedKey   = errors.New("Unsupported credential public key")
+	errInvalidSignature = errors.New("Invalid assertion signature")
+)
+
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// parsePublicKey reads a COSE_Key as stored for a credential.
+func parsePublicKey(coseKey []byte) (crypto.PublicKey, error) {
+	value, _, err := decodeCBOR(coseKey)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return nil, errUnsupportedKey
+	}
+
+	switch key[int64(coseKeyType)] {
+	case int64(coseKeyTypeEC2):
+		if key[int64(coseKeyAlgorithm)] != int64(COSE_ALG_ES256) || key[int64(coseEC2Curve)] != int64(coseCurveP256) {
+			return nil, errUnsupportedKey
+		}
+		x, okX := key[int64(coseEC2X)].([]byte)
+		y, okY := key[int64(coseEC2Y)].([]byte)
+		if !okX || !okY || len(x) != ec2CoordinateBytes || len(y) != ec2CoordinateBytes {
+			return nil, errUnsupportedKey
+		}
+		publicKey := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !publicKey.Curve.IsOnCurve(publicKey.X, publicKey.Y) {
+			return nil, errUnsupportedKey
+		}
+		return publicKey, nil
+	case int64(coseKeyTypeRSA):
+		if key[int64(coseKeyAlgorithm)] != int64(COSE_ALG_RS256) {
+			return nil, errUnsupportedKey
+		}
+		modulus, okN := key[int64(coseRSAModulus)].([]byte)
+		exponent, okE := key[int64(coseRSAExponent)].([]byte)
+		if !okN || !okE {
+			return nil, errUnsupportedKey
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(modulus), E: int(new(big.Int).SetBytes(exponent).Int64())}, nil
+	}
+	return nil, errUnsupportedKey
+}
+
+func verifySignature(publicKey crypto.PublicKey, signedData []byte, signature []byte) error {
+	digest := sha256.Sum256(signedData)
+
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		var sig ecdsaSignature
+		rest, err := asn1.Unmarshal(signature, &sig)
+		if err != nil || len(rest) != 0 || sig.R == nil || sig.S == nil {
+			return errInvalidSignature
+		}
+		if !ecdsa.Verify(key, digest[:], sig.R, sig.S) {
+			return errInvalidSignature
+		}
+		return nil
+	case *rsa.PublicKey:
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) != nil {
+			return errInvalidSignature
+		}
+		return nil
+	}
+	return errUnsupportedKey
+}
@@ -0,0 +1,311 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	CHALLENGE_LENGTH = 32
+	CEREMONY_TIMEOUT = 5 * time.Minute
+
+	PUBLIC_KEY_CREDENTIAL_TYPE = "public-key"
+
+	clientDataTypeCreate = "webauthn.create"
+	clientDataTypeGet    = "webauthn.get"
+)
+
+var (
+	ErrClientDataInvalid     = errors.New("Client data doesn't match the ceremony")
+	ErrRPIdHashInvalid       = errors.New("Authenticator data was created for another relying party")
+	ErrUserNotPresent        = errors.New("Authenticator didn't confirm user presence")
+	ErrCredentialMissing     = errors.New("Attestation doesn't contain a credential")
+	ErrCredentialIdMismatch  = errors.New("Assertion was made with another credential")
+	ErrSignCountRegression   = errors.New("Authenticator sign count went backwards, credential may be cloned")
+	ErrAttestationMalformed  = errors.New("Malformed attestation object")
+	ErrCredentialTypeInvalid = errors.New("Credential type must be public-key")
+)
+
+// URLEncodedBytes is binary data carried as unpadded base64url in JSON, the
+// encoding browsers use for WebAuthn buffers.
+type URLEncodedBytes []byte
+
+func (b URLEncodedBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+func (b *URLEncodedBytes) UnmarshalJSON(data []byte) error {
+	var encoded string
+	err := json.Unmarshal(data, &encoded)
+	if err != nil {
+		return err
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+type RelyingParty struct {
+	ID     string
+	Name   string
+	Origin string
+}
+
+type rpEntity struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type UserEntity struct {
+	Id          URLEncodedBytes `json:"id"`
+	Name        string          `json:"name"`
+	DisplayName string          `json:"displayName"`
+}
+
+type CredentialDescriptor struct {
+	Type       string          `json:"type"`
+	Id         URLEncodedBytes `json:"id"`
+	Transports []string        `json:"transports,omitempty"`
+}
+
+type credentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+type authenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+type CreationOptions struct {
+	Challenge              URLEncodedBytes        `json:"challenge"`
+	RP                     rpEntity               `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	PubKeyCredParams       []credentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection authenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+type RequestOptions struct {
+	Challenge        URLEncodedBytes        `json:"challenge"`
+	Timeout          int64                  `json:"timeout"`
+	RPId             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+type AttestationResponse struct {
+	Id       string          `json:"id"`
+	RawId    URLEncodedBytes `json:"rawId"`
+	Type     string          `json:"type"`
+	Response struct {
+		ClientDataJSON    URLEncodedBytes `json:"clientDataJSON"`
+		AttestationObject URLEncodedBytes `json:"attestationObject"`
+		Transports        []string        `json:"transports"`
+	} `json:"response"`
+}
+
+type AssertionResponse struct {
+	Id       string          `json:"id"`
+	RawId    URLEncodedBytes `json:"rawId"`
+	Type     string          `json:"type"`
+	Response struct {
+		ClientDataJSON    URLEncodedBytes `json:"clientDataJSON"`
+		AuthenticatorData URLEncodedBytes `json:"authenticatorData"`
+		Signature         URLEncodedBytes `json:"signature"`
+		UserHandle        URLEncodedBytes `json:"userHandle"`
+	} `json:"response"`
+}
+
+type Credential struct {
+	Id         []byte
+	PublicKey  []byte
+	SignCount  uint32
+	Transports []string
+}
+
+type Assertion struct {
+	SignCount    uint32
+	UserVerified bool
+}
+
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+func NewChallenge() ([]byte, error) {
+	challenge := make([]byte, CHALLENGE_LENGTH)
+	_, err := rand.Read(challenge)
+	return challenge, err
+}
+
+func (rp RelyingParty) RegistrationOptions(challenge []byte, user UserEntity, exclude []CredentialDescriptor) CreationOptions {
+	return CreationOptions{
+		Challenge: challenge,
+		RP:        rpEntity{Id: rp.ID, Name: rp.Name},
+		User:      user,
+		PubKeyCredParams: []credentialParameter{
+			{Type: PUBLIC_KEY_CREDENTIAL_TYPE, Alg: COSE_ALG_ES256},
+			{Type: PUBLIC_KEY_CREDENTIAL_TYPE, Alg: COSE_ALG_RS256},
+		},
+		Timeout:            CEREMONY_TIMEOUT.Milliseconds(),
+		ExcludeCredentials: exclude,
+		AuthenticatorSelection: authenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: "preferred",
+		},
+		Attestation: "none",
+	}
+}
+
+func (rp RelyingParty) LoginOptions(challenge []byte, allow []CredentialDescriptor) RequestOptions {
+	return RequestOptions{
+		Challenge:        challenge,
+		Timeout:          CEREMONY_TIMEOUT.Milliseconds(),
+		RPId:             rp.ID,
+		AllowCredentials: allow,
+		UserVerification: "preferred",
+	}
+}
+
+// VerifyRegistration checks a registration ceremony response against the
+// challenge that was issued for it. Attestation statements are not verified
+// since registration options always ask for "none" conveyance.
+func (rp RelyingParty) VerifyRegistration(challenge []byte, res AttestationResponse) (*Credential, error) {
+	if res.Type != PUBLIC_KEY_CREDENTIAL_TYPE {
+		return nil, ErrCredentialTypeInvalid
+	}
+
+	err := rp.verifyClientData(res.Response.ClientDataJSON, clientDataTypeCreate, challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	value, _, err := decodeCBOR(res.Response.AttestationObject)
+	if err != nil {
+		return nil, err
+	}
+	attestation, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return nil, ErrAttestationMalformed
+	}
+	rawAuthData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return nil, ErrAttestationMalformed
+	}
+
+	authData, err := rp.verifyAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if authData.CredentialId == nil {
+		return nil, ErrCredentialMissing
+	}
+	if len(res.RawId) > 0 && !bytes.Equal(res.RawId, authData.CredentialId) {
+		return nil, ErrCredentialIdMismatch
+	}
+
+	_, err = parsePublicKey(authData.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		Id:         authData.CredentialId,
+		PublicKey:  authData.PublicKey,
+		SignCount:  authData.SignCount,
+		Transports: res.Response.Transports,
+	}, nil
+}
+
+// VerifyAssertion checks an authentication ceremony response made with a
+// stored credential and returns the authenticator's new sign count.
+func (rp RelyingParty) VerifyAssertion(challenge []byte, credential Credential, res AssertionResponse) (*Assertion, error) {
+	if res.Type != PUBLIC_KEY_CREDENTIAL_TYPE {
+		return nil, ErrCredentialTypeInvalid
+	}
+	if !bytes.Equal(res.RawId, credential.Id) {
+		return nil, ErrCredentialIdMismatch
+	}
+
+	err := rp.verifyClientData(res.Response.ClientDataJSON, clientDataTypeGet, challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	authData, err := rp.verifyAuthenticatorData(res.Response.AuthenticatorData)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := parsePublicKey(credential.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	clientDataHash := sha256.Sum256(res.Response.ClientDataJSON)
+	signedData := append(append([]byte{}, res.Response.AuthenticatorData...), clientDataHash[:]...)
+	err = verifySignature(publicKey, signedData, res.Response.Signature)
+	if err != nil {
+		return nil, err
+	}
+
+	// authenticators that don't implement a counter always report zero
+	if (authData.SignCount != 0 || credential.SignCount != 0) && authData.SignCount <= credential.SignCount {
+		return nil, ErrSignCountRegression
+	}
+
+	return &Assertion{SignCount: authData.SignCount, UserVerified: authData.userVerified()}, nil
+}
+
+func (rp RelyingParty) verifyClientData(raw []byte, ceremonyType string, challenge []byte) error {
+	var data clientData
+	err := json.Unmarshal(raw, &data)
+	if err != nil {
+		return err
+	}
+
+	receivedChallenge, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(data.Challenge, "="))
+	if err != nil {
+		return ErrClientDataInvalid
+	}
+
+	if data.Type != ceremonyType || data.Origin != rp.Origin || data.CrossOrigin {
+		return ErrClientDataInvalid
+	}
+	if subtle.ConstantTimeCompare(receivedChallenge, challenge) != 1 {
+		return ErrClientDataInvalid
+	}
+	return nil
+}
+
+func (rp RelyingParty) verifyAuthenticatorData(raw []byte) (*authenticatorData, error) {
+	authData, err := parseAuthenticatorData(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	rpIdHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(authData.RPIdHash, rpIdHash[:]) {
+		return nil, ErrRPIdHashInvalid
+	}
+	if !authData.userPresent() {
+		return nil, ErrUserNotPresent
+	}
+	return authData, nil
+}
@@ -0,0 +1,94 @@
+package webauthn_test
+
+import (
+	"encoding/json"
+	"testing"
+	"userland/webauthn"
+	"userland/webauthn/webauthntest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	RP_ID     = "localhost"
+	RP_ORIGIN = "http://localhost:3000"
+)
+
+var (
+	rp = webauthn.RelyingParty{ID: RP_ID, Name: "Userland", Origin: RP_ORIGIN}
+)
+
+func registerCredential(t *testing.T, authenticator *webauthntest.Authenticator) *webauthn.Credential {
+	challenge, err := webauthn.NewChallenge()
+	require.Nil(t, err)
+	credential, err := rp.VerifyRegistration(challenge, authenticator.Register(challenge, []byte("1")))
+	require.Nil(t, err)
+	return credential
+}
+
+func TestURLEncodedBytes(t *testing.T) {
+	encoded, err := json.Marshal(webauthn.URLEncodedBytes{0xfb, 0xff})
+	require.Nil(t, err)
+	assert.Equal(t, `"-_8"`, string(encoded))
+
+	var decoded webauthn.URLEncodedBytes
+	require.Nil(t, json.Unmarshal([]byte(`"-_8="`), &decoded), "Padded base64url should be accepted")
+	assert.Equal(t, webauthn.URLEncodedBytes{0xfb, 0xff}, decoded)
+}
+
+func TestVerifyRegistration(t *testing.T) {
+	authenticator := webauthntest.NewAuthenticator(RP_ID, RP_ORIGIN)
+	challenge, err := webauthn.NewChallenge()
+	require.Nil(t, err)
+
+	credential, err := rp.VerifyRegistration(challenge, authenticator.Register(challenge, []byte("1")))
+	require.Nil(t, err)
+	assert.Equal(t, authenticator.CredentialId, credential.Id)
+	assert.Equal(t, authenticator.PublicKey(), credential.PublicKey)
+	assert.Equal(t, []string{"internal"}, credential.Transports)
+
+	otherChallenge, err := webauthn.NewChallenge()
+	require.Nil(t, err)
+	_, err = rp.VerifyRegistration(otherChallenge, authenticator.Register(challenge, []byte("1")))
+	assert.Equal(t, webauthn.ErrClientDataInvalid, err, "Registration for another challenge should fail")
+
+	phishingAuthenticator := webauthntest.NewAuthenticator(RP_ID, "https://evil.example.com")
+	_, err = rp.VerifyRegistration(challenge, phishingAuthenticator.Register(challenge, []byte("1")))
+	assert.Equal(t, webauthn.ErrClientDataInvalid, err, "Registration from another origin should fail")
+
+	otherRPAuthenticator := webauthntest.NewAuthenticator("example.com", RP_ORIGIN)
+	_, err = rp.VerifyRegistration(challenge, otherRPAuthenticator.Register(challenge, []byte("1")))
+	assert.Equal(t, webauthn.ErrRPIdHashInvalid, err, "Registration scoped to another relying party should fail")
+}
+
+func TestVerifyAssertion(t *testing.T) {
+	authenticator := webauthntest.NewAuthenticator(RP_ID, RP_ORIGIN)
+	credential := registerCredential(t, authenticator)
+
+	challenge, err := webauthn.NewChallenge()
+	require.Nil(t, err)
+	assertion, err := rp.VerifyAssertion(challenge, *credential, authenticator.Assert(challenge))
+	require.Nil(t, err)
+	assert.Equal(t, uint32(1), assertion.SignCount)
+	assert.True(t, assertion.UserVerified)
+
+	authenticator.UserVerified = false
+	assertion, err = rp.VerifyAssertion(challenge, *credential, authenticator.Assert(challenge))
+	require.Nil(t, err)
+	assert.False(t, assertion.UserVerified)
+
+	credential.SignCount = 10
+	_, err = rp.VerifyAssertion(challenge, *credential, authenticator.Assert(challenge))
+	assert.Equal(t, webauthn.ErrSignCountRegression, err, "Assertion with a lower sign count should fail")
+	credential.SignCount = 0
+
+	forgedAssertion := authenticator.Assert(challenge)
+	forgedAssertion.Response.Signature[len(forgedAssertion.Response.Signature)-1] ^= 0xff
+	_, err = rp.VerifyAssertion(challenge, *credential, forgedAssertion)
+	assert.NotNil(t, err, "Assertion with a tampered signature should fail")
+
+	otherAuthenticator := webauthntest.NewAuthenticator(RP_ID, RP_ORIGIN)
+	_, err = rp.VerifyAssertion(challenge, *credential, otherAuthenticator.Assert(challenge))
+	assert.Equal(t, webauthn.ErrCredentialIdMismatch, err, "Assertion from another credential should fail")
+}
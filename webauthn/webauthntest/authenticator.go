@@ -0,0 +1,211 @@
+// Package webauthntest provides a software authenticator for exercising
+// WebAuthn ceremonies in tests.
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+	"sort"
+	"userland/webauthn"
+)
+
+type Authenticator struct {
+	RPId   string
+	Origin string
+
+	CredentialId []byte
+	UserHandle   []byte
+	SignCount    uint32
+	UserVerified bool
+
+	key *ecdsa.PrivateKey
+}
+
+func NewAuthenticator(rpId string, origin string) *Authenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	credentialId := make([]byte, 16)
+	rand.Read(credentialId)
+
+	return &Authenticator{
+		RPId:         rpId,
+		Origin:       origin,
+		CredentialId: credentialId,
+		UserVerified: true,
+		key:          key,
+	}
+}
+
+// Register answers navigator.credentials.create() for the given challenge.
+func (a *Authenticator) Register(challenge []byte, userHandle []byte) webauthn.AttestationResponse {
+	a.UserHandle = userHandle
+
+	attestedCredential := make([]byte, 18)
+	binary.BigEndian.PutUint16(attestedCredential[16:], uint16(len(a.CredentialId)))
+	attestedCredential = append(attestedCredential, a.CredentialId...)
+	attestedCredential = append(attestedCredential, a.PublicKey()...)
+	authData := append(a.authenticatorData(webauthn.FLAG_ATTESTED_CREDENTIAL), attestedCredential...)
+
+	var res webauthn.AttestationResponse
+	res.Id = base64.RawURLEncoding.EncodeToString(a.CredentialId)
+	res.RawId = a.CredentialId
+	res.Type = webauthn.PUBLIC_KEY_CREDENTIAL_TYPE
+	res.Response.ClientDataJSON = a.clientData("webauthn.create", challenge)
+	res.Response.AttestationObject = EncodeCBOR(map[interface{}]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[interface{}]interface{}{},
+		"authData": authData,
+	})
+	res.Response.Transports = []string{"internal"}
+	return res
+}
+
+// Assert answers navigator.credentials.get() for the given challenge.
+func (a *Authenticator) Assert(challenge []byte) webauthn.AssertionResponse {
+	a.SignCount++
+	authData := a.authenticatorData(0)
+	clientDataJSON := a.clientData("webauthn.get", challenge)
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsaSign(a.key, digest[:])
+	if err != nil {
+		panic(err)
+	}
+
+	var res webauthn.AssertionResponse
+	res.Id = base64.RawURLEncoding.EncodeToString(a.CredentialId)
+	res.RawId = a.CredentialId
+	res.Type = webauthn.PUBLIC_KEY_CREDENTIAL_TYPE
+	res.Response.ClientDataJSON = clientDataJSON
+	res.Response.AuthenticatorData = authData
+	res.Response.Signature = signature
+	res.Response.UserHandle = a.UserHandle
+	return res
+}
+
+// PublicKey returns the credential public key as a COSE_Key.
+func (a *Authenticator) PublicKey() []byte {
+	x, y := padCoordinate(a.key.X), padCoordinate(a.key.Y)
+	return EncodeCBOR(map[interface{}]interface{}{
+		1:  int64(2),
+		3:  int64(webauthn.COSE_ALG_ES256),
+		-1: int64(1),
+		-2: x,
+		-3: y,
+	})
+}
+
+func padCoordinate(coordinate *big.Int) []byte {
+	raw := coordinate.Bytes()
+	padded := make([]byte, 32)
+	copy(padded[32-len(raw):], raw)
+	return padded
+}
+
+func ecdsaSign(key *ecdsa.PrivateKey, digest []byte) ([]byte, error) {
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(struct{ R, S *big.Int }{r, s})
+}
+
+func (a *Authenticator) authenticatorData(extraFlags byte) []byte {
+	rpIdHash := sha256.Sum256([]byte(a.RPId))
+	flags := webauthn.FLAG_USER_PRESENT | extraFlags
+	if a.UserVerified {
+		flags |= webauthn.FLAG_USER_VERIFIED
+	}
+	data := append([]byte{}, rpIdHash[:]...)
+	data = append(data, flags)
+	signCount := make([]byte, 4)
+	binary.BigEndian.PutUint32(signCount, a.SignCount)
+	return append(data, signCount...)
+}
+
+func (a *Authenticator) clientData(ceremonyType string, challenge []byte) []byte {
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":      ceremonyType,
+		"challenge": base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":    a.Origin,
+	})
+	return data
+}
+
+// EncodeCBOR encodes integers, byte and text strings, arrays and maps using
+// the canonical CBOR ordering authenticators use.
+func EncodeCBOR(value interface{}) []byte {
+	switch v := value.(type) {
+	case int:
+		return EncodeCBOR(int64(v))
+	case int64:
+		if v < 0 {
+			return cborHeader(1, uint64(-1-v))
+		}
+		return cborHeader(0, uint64(v))
+	case []byte:
+		return append(cborHeader(2, uint64(len(v))), v...)
+	case string:
+		return append(cborHeader(3, uint64(len(v))), v...)
+	case bool:
+		if v {
+			return []byte{0xf5}
+		}
+		return []byte{0xf4}
+	case nil:
+		return []byte{0xf6}
+	case []interface{}:
+		encoded := cborHeader(4, uint64(len(v)))
+		for _, item := range v {
+			encoded = append(encoded, EncodeCBOR(item)...)
+		}
+		return encoded
+	case map[interface{}]interface{}:
+		entries := make([][2][]byte, 0, len(v))
+		for key, item := range v {
+			entries = append(entries, [2][]byte{EncodeCBOR(key), EncodeCBOR(item)})
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			if len(entries[i][0]) != len(entries[j][0]) {
+				return len(entries[i][0]) < len(entries[j][0])
+			}
+			return string(entries[i][0]) < string(entries[j][0])
+		})
+		encoded := cborHeader(5, uint64(len(v)))
+		for _, entry := range entries {
+			encoded = append(encoded, entry[0]...)
+			encoded = append(encoded, entry[1]...)
+		}
+		return encoded
+	}
+	panic("unsupported CBOR value")
+}
+
+func cborHeader(majorType byte, argument uint64) []byte {
+	switch {
+	case argument < 24:
+		return []byte{majorType<<5 | byte(argument)}
+	case argument <= 0xff:
+		return []byte{majorType<<5 | 24, byte(argument)}
+	case argument <= 0xffff:
+		header := []byte{majorType<<5 | 25, 0, 0}
+		binary.BigEndian.PutUint16(header[1:], uint16(argument))
+		return header
+	case argument <= 0xffffffff:
+		header := []byte{majorType<<5 | 26, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(header[1:], uint32(argument))
+		return header
+	}
+	header := []byte{majorType<<5 | 27, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint64(header[1:], argument)
+	return header
+}